@@ -1,23 +1,44 @@
 package main
 
 import (
+  "context"
   "fmt"
   "os"
 
+  "github.com/peterh/liner"
+
   "github.com/johnjallday/dolphin-tool-calling-agent/internal/app"
   "github.com/johnjallday/dolphin-tool-calling-agent/internal/bubbletui"
+  "github.com/johnjallday/dolphin-tool-calling-agent/internal/paths"
+  "github.com/johnjallday/dolphin-tool-calling-agent/internal/tui"
 )
 
 func main() {
-  // 1) initialize your core application
+  paths.ParseFlags()
+
+  // 1) initialize your core application, unlocking the secrets file on
+  // the plain terminal: once the chat TUI owns it, nothing can be asked
   core := app.NewApp()
-  if err := core.Init(); err != nil {
+  rl := liner.NewLiner()
+  rl.SetCtrlCAborts(true)
+  core.SetPassphrasePrompt((&tui.TUIApp{Out: os.Stdout, Rl: rl}).Passphrase)
+  err := core.Init()
+  if err == nil {
+    _, err = core.SecretNames()
+  }
+  core.SetPassphrasePrompt(nil)
+  rl.Close()
+  if err != nil {
     fmt.Fprintf(os.Stderr, "init error: %v\n", err)
     os.Exit(1)
   }
 
-  // 2) launch the Bubble Tea TUI
-  if err := bubbletui.RunStatusTUI(core); err != nil {
+  // 2) launch the Bubble Tea TUI, then unload the agents it leaves live
+  err = bubbletui.RunChatTUI(context.Background(), core)
+  if err := core.Shutdown(); err != nil {
+    fmt.Fprintf(os.Stderr, "shutdown: %v\n", err)
+  }
+  if err != nil {
     fmt.Fprintf(os.Stderr, "fatal: %v\n", err)
     os.Exit(1)
   }
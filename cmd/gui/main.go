@@ -1,6 +1,7 @@
 package main
 
 import (
+    "errors"
     "fmt"
 		"log"
 
@@ -8,12 +9,18 @@ import (
 		"fyne.io/fyne/v2/theme"
     "github.com/johnjallday/dolphin-tool-calling-agent/internal/app"
     "github.com/johnjallday/dolphin-tool-calling-agent/internal/gui"
+    "github.com/johnjallday/dolphin-tool-calling-agent/internal/paths"
+    "github.com/johnjallday/dolphin-tool-calling-agent/internal/secrets"
 )
 
 func main() {
+    paths.ParseFlags()
+
     core := app.NewApp()       // core is an app.App interface
-    if err := core.Init(); err != nil {
-        fmt.Println("init error:", err)
+    // a locked secrets file is unlocked once the window is up
+    initErr := core.Init()
+    if initErr != nil && !errors.Is(initErr, secrets.ErrLocked) {
+        fmt.Println("init error:", initErr)
         return
     }
 
@@ -23,7 +30,7 @@ func main() {
 		// remote packages
 		remote, err := core.ListRemoteToolpacks()
 		if err != nil {
-			log.Fatal(err)
+			log.Println("remote toolpacks:", err)
 		}
 		fmt.Println("Local:", local)
 		fmt.Println("Remote:", remote)
@@ -34,5 +41,11 @@ func main() {
 		fy.Settings().SetTheme(gui.NewGreyedTextTheme(theme.DarkTheme()))
 
     w := gui.NewMainWindow(fy, core)
+    if initErr != nil {
+        w.UnlockAndInit(initErr)
+    }
     w.ShowAndRun()
+    if err := core.Shutdown(); err != nil {
+        log.Println("shutdown:", err)
+    }
 }
@@ -2,12 +2,20 @@ package main
 
 import (
 
+  "github.com/johnjallday/dolphin-tool-calling-agent/internal/app"
+  "github.com/johnjallday/dolphin-tool-calling-agent/internal/paths"
   "github.com/johnjallday/dolphin-tool-calling-agent/internal/toolmanager"
 	"log"
 )
 
 func main() {
-  if err := toolmanager.CheckVersion(); err != nil {
+  paths.ParseFlags()
+
+  cats, err := app.LoadCatalogs()
+  if err != nil {
+    log.Fatal(err)
+  }
+  if err := toolmanager.CheckVersion(cats); err != nil {
     log.Fatal(err)
   }
 }
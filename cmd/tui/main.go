@@ -2,34 +2,34 @@ package main
 
 import (
   "context"
+  "flag"
   "fmt"
   "os"
   "os/signal"
+  "strings"
   "syscall"
 
   "github.com/peterh/liner"
 
   "github.com/johnjallday/dolphin-tool-calling-agent/internal/app"
+  "github.com/johnjallday/dolphin-tool-calling-agent/internal/paths"
   "github.com/johnjallday/dolphin-tool-calling-agent/internal/tui"
 )
 
 func main() {
-  // 1) catch SIGINT
+  paths.ParseFlags()
+
+  // 1) core application, and SIGINT, which unloads it
+  application := app.NewApp()
   sigCh := make(chan os.Signal, 1)
   signal.Notify(sigCh, syscall.SIGINT)
   go func() {
     <-sigCh
     fmt.Fprintln(os.Stdout, "\nreceived SIGINT, exiting")
+    shutdown(application)
     os.Exit(1)
   }()
 
-  // 2) core application
-  application := app.NewApp()
-  if err := application.Init(); err != nil {
-    fmt.Fprintf(os.Stderr, "app.Init error: %v\n", err)
-    os.Exit(1)
-  }
-
   // 3) set up liner
   rl := liner.NewLiner()
   defer rl.Close()
@@ -45,6 +45,30 @@ func main() {
     Rl:  rl,
   }
 
+  // loading the default agent may already need a secret
+  application.SetPassphrasePrompt(t.Passphrase)
+  if err := application.Init(); err != nil {
+    fmt.Fprintf(os.Stderr, "app.Init error: %v\n", err)
+    os.Exit(1)
+  }
+
+  // one-shot mode for scripts: `dolphin_tui toolpack manifest x.so`
+  if flag.NArg() > 0 {
+    _, commands := buildCommands()
+    fn, ok := commands[strings.ToLower(flag.Arg(0))]
+    if !ok {
+      fmt.Fprintf(os.Stderr, "unknown command %q\n", flag.Arg(0))
+      os.Exit(2)
+    }
+    err := fn(t, flag.Args()[1:])
+    shutdown(application)
+    if err != nil {
+      fmt.Fprintln(os.Stderr, "ERROR:", err)
+      os.Exit(1)
+    }
+    return
+  }
+
   // ──────────────── NEW ───────────────────
   // 5) bootstrap users if none exist
   if err := tui.InitCmd(t, nil); err != nil {
@@ -53,6 +77,12 @@ func main() {
   }
   // ────────────────────────────────────────
 
+  // pick up hand edits to the user/toolpack TOML while running
+  t.WatchConfig()
+
+  // y/n prompt for tools that need approval
+  application.SetApprover(t.Approve)
+
   // 6) initial screen draw
   if err := t.Refresh(); err != nil {
     fmt.Fprintln(os.Stderr, "refresh error:", err)
@@ -63,11 +93,19 @@ func main() {
   t.RunInteractiveShell(helpKeys, commands)
 }
 
+// shutdown unloads every agent, reporting toolpacks that failed to shut
+// down cleanly.
+func shutdown(a app.App) {
+  if err := a.Shutdown(); err != nil {
+    fmt.Fprintln(os.Stderr, "shutdown:", err)
+  }
+}
+
 func buildCommands() ([]string, map[string]tui.CmdFunc) {
   helpKeys := []string{
-    "user", "users", "agent", "agents", "tools",
+    "user", "users", "agent", "agents", "tools [offered]",
     "create-agent", "load-user", "load-agent", "unload-user", "edit-agent", "unload-agent",
-    "switch-user", "switch-agent",
+    "switch-user", "switch-agent", "close-agent", "usage", "toolpack list|info|remote|install|update|outdated|resolve|sync|rollback|uninstall|quarantine|manifest|new|build|config|doctor", "secret list|set|rm", "@<agent> <message>",
     "help", "clear", "exit", "quit",
   }
 
@@ -85,6 +123,10 @@ func buildCommands() ([]string, map[string]tui.CmdFunc) {
     "unload-agent": tui.UnloadAgentCmd,
     "switch-user":  tui.SwitchUserCmd,
     "switch-agent": tui.SwitchAgentCmd,
+    "close-agent":  tui.CloseAgentCmd,
+    "usage":        tui.UsageCmd,
+    "toolpack":     tui.ToolpackCmd,
+    "secret":       tui.SecretCmd,
 
     "help": func(t *tui.TUIApp, _ []string) error {
 			fmt.Fprintln(t.Out, "Try typing one of the available commands to get/execute the information you need.")
@@ -100,10 +142,12 @@ func buildCommands() ([]string, map[string]tui.CmdFunc) {
       return t.Refresh()
     },
     "exit": func(t *tui.TUIApp, _ []string) error {
+      shutdown(t.App)
       os.Exit(0)
       return nil
     },
     "quit": func(t *tui.TUIApp, _ []string) error {
+      shutdown(t.App)
       os.Exit(0)
       return nil
     },
@@ -1,28 +1,87 @@
 package agent
 
 import (
-	"os"
   "context"
   "fmt"
-  "path/filepath"
-  "plugin"
-	"io/fs"
 	"errors"
 	"encoding/json"
+  "log/slog"
+  "slices"
+  "sort"
+  "strings"
+  "sync"
 
   "github.com/openai/openai-go"
+  "github.com/openai/openai-go/option"
   "github.com/johnjallday/dolphin-tool-calling-agent/internal/registry"
+  "github.com/johnjallday/dolphin-tool-calling-agent/internal/semver"
+  "github.com/johnjallday/dolphin-tool-calling-agent/internal/toolmanager"
   "github.com/johnjallday/dolphin-tool-calling-agent/pkg/tools"
 )
 
+// ErrCancelled is returned by SendMessage when the turn was aborted via
+// Cancel or Close (e.g. because the user switched agents mid-turn).
+var ErrCancelled = errors.New("agent: turn cancelled")
+
+// ErrClosed is returned by SendMessage once the agent has been closed.
+var ErrClosed = errors.New("agent: closed")
+
+// Agent is safe for concurrent use. Turns (SendMessage calls) are
+// serialised; read accessors never wait for an in-flight turn.
 type Agent struct {
   Name     string
   Model    string
   Registry *registry.ToolRegistry
   client   openai.Client
+	systemPrompt openai.ChatCompletionMessageParamUnion
+
+  // turn serialises SendMessage so tool calls and replies of two turns
+  // never interleave in params.Messages.
+  turn sync.Mutex
+
+  // mu guards everything below; it is only held for short bookkeeping,
+  // never across a network call or tool execution.
+  mu      sync.RWMutex
 	history []ChatMessage
   params   openai.ChatCompletionNewParams
-	systemPrompt openai.ChatCompletionMessageParamUnion
+  cancel  context.CancelFunc // cancels the in-flight turn, nil when idle
+  closed  bool
+
+  // delegation (see delegate.go): ask_<name> tool → sub-agent name
+  delegates  map[string]string
+  brokenSubs []BrokenSubAgent
+  resolve    Resolver
+  usage     Usage
+  subCalls  []SubCall
+  approve   Approver
+
+  // broken lists toolpacks skipped at build time (Options); immutable
+  broken []BrokenToolpack
+  // collisions lists tool names several of its packs define; immutable
+  collisions []registry.Collision
+
+  // tool selection (see selection.go)
+  selection registry.Selection
+  recent    []string // tools called in the last turn that called any
+  offers    []ToolOffer
+
+  // settings and configurable (see toolconfig.go): loaded packs that
+  // declare a Config, by name
+  settings     func(pack string, fields []tools.ConfigField) (tools.Config, error)
+  configurable map[string]tools.ToolPackage
+
+  // secrets reach tools through their ctx; redact masks them in what
+  // tools return before it joins the conversation. Both immutable.
+  secrets tools.Secrets
+  redact  func(string) string
+  // grants lists the secrets each pack may read (ToolPackage.Secrets);
+  // filled while building, immutable after
+  grants  map[string][]string
+  hostCtx func(context.Context) context.Context // Options.Context; immutable
+  log     *slog.Logger                          // Options.Logger; immutable
+
+  // started are the packs this agent holds running (see lifecycle.go)
+  started []string
 }
 
 type ChatMessage struct {
@@ -30,8 +89,57 @@ type ChatMessage struct {
   Content string
 }
 
+// Options tweak how NewAgentWith builds an agent.
+type Options struct {
+  // SkipBrokenToolpacks loads the agent even if some of its toolpacks
+  // fail to load; those are left out and reported by BrokenToolpacks.
+  SkipBrokenToolpacks bool
+  // Packages are toolpacks the caller already has (e.g. ones built in a
+  // test); their tools are named together with the loaded ones.
+  Packages []tools.ToolPackage
+  // Naming sets how tools of different packs are named for the model
+  // (namespace_tools and tool_aliases in the agent's TOML).
+  Naming registry.Naming
+  // Filter picks which of those tools the model is offered and how they
+  // are described (tools_include, tools_exclude and tool_overrides).
+  Filter registry.Filter
+  // Selection narrows the tools sent per turn to the most relevant ones
+  // (max_tools and pinned_tools).
+  Selection registry.Selection
+  // Settings returns the values of a toolpack's Config (the user's
+  // saved settings); nil gives every pack its defaults.
+  Settings func(pack string, fields []tools.ConfigField) (tools.Config, error)
+  // APIKey is the model provider's key; empty uses OPENAI_API_KEY.
+  APIKey string
+  // Secrets is handed to tools in the ctx of every call, and Redact
+  // masks the secrets they read in their results.
+  Secrets tools.Secrets
+  Redact  func(string) string
+  // Logger records each turn's tool offer; nil logs to slog.Default().
+  Logger *slog.Logger
+  // Context adds what the host hands its own packs (dolphin_tools gets
+  // the app this way) to the ctx of every tool call and Init.
+  Context func(context.Context) context.Context
+}
+
+// BrokenToolpack is a toolpack that was skipped while building an agent.
+type BrokenToolpack struct {
+  Name string
+  Err  error
+}
+
+// NewAgent builds an agent; any toolpack that fails to load is an error.
 func NewAgent(name, model string, pluginNames []string) (*Agent, error) {
-  client := openai.NewClient()
+  return NewAgentWith(name, model, pluginNames, Options{})
+}
+
+// NewAgentWith is NewAgent with Options.
+func NewAgentWith(name, model string, pluginNames []string, opts Options) (*Agent, error) {
+  var reqOpts []option.RequestOption
+  if opts.APIKey != "" {
+    reqOpts = append(reqOpts, option.WithAPIKey(opts.APIKey))
+  }
+  client := openai.NewClient(reqOpts...)
 
   // define your system prompt once, up front
 	const sysText = `You are only allowed to respond by invoking one of the available functions.
@@ -57,87 +165,202 @@ func NewAgent(name, model string, pluginNames []string) (*Agent, error) {
     Registry:     registry.NewToolRegistry(),
     params:       params,
     systemPrompt: sys,
+    selection:    opts.Selection,
+    settings:     opts.Settings,
+    configurable: map[string]tools.ToolPackage{},
+    secrets:      opts.Secrets,
+    redact:       opts.Redact,
+    hostCtx:      opts.Context,
+    grants:       map[string][]string{},
+    log:          opts.Logger,
   }
-
-
-  // load plugins (recursive search logic omitted for brevity)
-  cwd, _ := os.Getwd()
-  pluginDir := filepath.Join(cwd, "plugins")
-  for _, pname := range pluginNames {
-    soPath, err := locatePlugin(pluginDir, pname+".so")
-    if err != nil {
-      return nil, err
+  if a.log == nil {
+    a.log = slog.Default()
+  }
+  // packs started before a failure below are stopped again
+  built := false
+  defer func() {
+    if !built {
+      a.stopPacks()
     }
-    plug, err := plugin.Open(soPath)
-    if err != nil {
-      return nil, fmt.Errorf("open plugin %q: %w", pname, err)
+  }()
+
+  // resolve each toolpack: compiled-in packages first, then installed .so,
+  // each followed by what it depends on (loaded once per agent)
+  loaded := map[string]bool{}
+  var packs []tools.ToolPackage
+  for _, spec := range pluginNames {
+    pkgs, err := loadPackages(spec, loaded, a.startPack)
+    if err != nil && opts.SkipBrokenToolpacks {
+      name, _, _ := strings.Cut(spec, "@")
+      a.broken = append(a.broken, BrokenToolpack{Name: name, Err: err})
+      continue
     }
-    sym, err := plug.Lookup("PluginPackage")
     if err != nil {
-      return nil, fmt.Errorf("lookup PluginPackage in %q: %w", pname, err)
-    }
-    pkgFunc, ok := sym.(func() tools.ToolPackage)
-    if !ok {
-      return nil, fmt.Errorf("invalid PluginPackage signature in %q", pname)
+      return nil, err
     }
-    pkg := pkgFunc()
-    for _, t := range pkg.Tools {
-      a.Registry.Register(t)
+    packs = append(packs, pkgs...)
+  }
+  packs = append(packs, opts.Packages...)
+  for _, p := range packs {
+    a.grants[p.Name] = p.Secrets
+  }
+
+  // name the tools: bare where unique, pack__tool where two packs clash,
+  // plus the agent's aliases
+  naming := opts.Naming
+  naming.Optional = map[string]bool{}
+  for _, b := range a.broken {
+    naming.Optional[b.Name] = true
+  }
+  named, collisions, err := registry.Assign(packs, naming)
+  if err != nil {
+    return nil, fmt.Errorf("agent %s: %w", name, err)
+  }
+  a.collisions = collisions
+  for _, n := range named {
+    if err := a.Registry.RegisterNamed(n); err != nil {
+      return nil, fmt.Errorf("agent %s: %w", name, err)
     }
   }
+  filter := opts.Filter
+  filter.Optional = naming.Optional
+  if err := a.Registry.SetFilter(filter); err != nil {
+    return nil, fmt.Errorf("agent %s: %w", name, err)
+  }
 
   a.Registry.Initialize(&a.params)
+  built = true
   return a, nil
 }
 
-// findPluginFile walks pluginDir looking for a file named pluginFileName.
-// It returns the first match or an error if none are found.
-func locatePlugin(pluginDir, pluginFileName string) (string, error) {
-  var (
-    foundPath    string
-    sentinelErr  = errors.New("found")
-  )
-
-  err := filepath.WalkDir(pluginDir, func(path string, d fs.DirEntry, err error) error {
+// loadPackages returns the toolpack spec names ("name" or
+// "name@constraint") and, after it, the packs it depends on, skipping any
+// already in loaded. Every one must be installed at a version its
+// requirer allows and built for this dolphin. Each is passed to start,
+// with the name it was loaded by, once loaded.
+func loadPackages(spec string, loaded map[string]bool, start func(string, tools.ToolPackage) error) ([]tools.ToolPackage, error) {
+  name, want, err := toolmanager.ParseSpec(spec)
+  if err != nil {
+    return nil, err
+  }
+  if loaded[name] {
+    return nil, nil
+  }
+  pkg, deps, err := loadPackage(name, want)
+  if err != nil {
+    return nil, err
+  }
+  if err := start(name, pkg); err != nil {
+    return nil, err
+  }
+  loaded[name] = true
+  out := []tools.ToolPackage{pkg}
+  for _, dep := range sortedKeys(deps) {
+    more, err := loadPackages(dep+"@"+deps[dep], loaded, start)
     if err != nil {
-      return err
+      return nil, fmt.Errorf("toolpack %s needs %s: %w", name, dep, err)
     }
-    if !d.IsDir() && d.Name() == pluginFileName {
-      foundPath = path
-      return sentinelErr
-    }
-    return nil
-  })
+    out = append(out, more...)
+  }
+  return out, nil
+}
 
-  // If we bailed out early with sentinelErr, clear it
-  if err == sentinelErr {
-    err = nil
+// loadPackage returns the toolpack called pname, preferring one linked into
+// the binary (tools.RegisterPackage) over an installed one, which is
+// located through its toolpack.toml: declarative packs are built from it,
+// a script or .so is checksum-verified before it runs.
+// It also returns the pack's dependencies.
+func loadPackage(pname string, want semver.Constraint) (tools.ToolPackage, map[string]string, error) {
+  if pkg, ok := tools.LookupPackage(pname); ok {
+    if !want.IsAny() && !want.Allows(pkg.Version) {
+      return tools.ToolPackage{}, nil, fmt.Errorf("toolpack %s is compiled in at version %q, not %s", pname, pkg.Version, want)
+    }
+    return pkg, nil, nil
   }
+  m, err := toolmanager.Locate(pname)
   if err != nil {
-    return "", fmt.Errorf("walking %q: %w", pluginDir, err)
+    return tools.ToolPackage{}, nil, err
   }
-  if foundPath == "" {
-    return "", fmt.Errorf("plugin %q not found under %s", pluginFileName, pluginDir)
+  if !want.IsAny() && !want.Allows(m.Version) {
+    return tools.ToolPackage{}, nil, fmt.Errorf("toolpack %s %s is installed but %s is wanted; run `toolpack sync`", pname, m.Version, want)
+  }
+  if err := m.CheckHost(); err != nil {
+    return tools.ToolPackage{}, nil, err
+  }
+  if m.Declarative() {
+    pkg, err := toolmanager.OpenDeclarative(m)
+    return pkg, m.Dependencies, err
+  }
+  if err := m.Verify(); err != nil {
+    return tools.ToolPackage{}, nil, err
+  }
+  if m.Scripted() {
+    pkg, err := toolmanager.OpenScripted(m)
+    return pkg, m.Dependencies, err
+  }
+  // OpenPlugin prechecks build info and the manifest and explains failures
+  pkg, _, err := toolmanager.OpenPlugin(m.LibraryPath())
+  return pkg, m.Dependencies, err
+}
+
+func sortedKeys(m map[string]string) []string {
+  keys := make([]string, 0, len(m))
+  for k := range m {
+    keys = append(keys, k)
   }
-  return foundPath, nil
+  sort.Strings(keys)
+  return keys
 }
 
 func (a *Agent) SendMessage(ctx context.Context, userMessage string) (reply string, err error) {
-  // 1) append the user message
-  a.params.Messages = append(a.params.Messages, openai.UserMessage(userMessage))
-  a.history = append(a.history, ChatMessage{"user", userMessage})
+  a.turn.Lock()
+  defer a.turn.Unlock()
+
+  ctx, cancel := context.WithCancel(ctx)
+  defer cancel()
+
+  a.mu.Lock()
+  if a.closed {
+    a.mu.Unlock()
+    return "", ErrClosed
+  }
+  a.cancel = cancel
+  // remember where this turn started so a failed or cancelled turn can be
+  // rolled back instead of leaving half a tool exchange in the prompt
+  startMsgs, startHist := len(a.params.Messages), len(a.history)
+  a.mu.Unlock()
+
+  defer func() {
+    a.mu.Lock()
+    a.cancel = nil
+    if err != nil {
+      a.params.Messages = a.params.Messages[:startMsgs]
+      a.history = a.history[:startHist]
+      if ctx.Err() != nil && errors.Is(err, context.Canceled) {
+        err = ErrCancelled
+      }
+    }
+    a.mu.Unlock()
+  }()
+
+  // 1) append the user message and pick the tools to offer with it
+  a.appendMessages(openai.UserMessage(userMessage))
+  a.appendHistory(ChatMessage{"user", userMessage})
+  offered := a.offerTools(userMessage)
 
   // 2) first LLM call
-  cmp, err := a.client.Chat.Completions.New(ctx, a.params)
+  cmp, err := a.client.Chat.Completions.New(ctx, a.request(offered))
   if err != nil {
     return "", err
   }
+  a.addUsage(cmp.Usage)
   assistant := cmp.Choices[0].Message
 
   // 3) record assistant’s reply (and any tooling)
-  a.params.Messages = append(a.params.Messages, assistant.ToParam())
+  a.appendMessages(assistant.ToParam())
   if assistant.Content != "" {
-    a.history = append(a.history, ChatMessage{"assistant", assistant.Content})
+    a.appendHistory(ChatMessage{"assistant", assistant.Content})
   }
 
   // 4) if there are no tool calls, just return the content
@@ -146,42 +369,198 @@ func (a *Agent) SendMessage(ctx context.Context, userMessage string) (reply stri
   }
 
   // 5) otherwise perform the tool calls
-	a.dispatchTools(assistant.ToolCalls)
+  a.noteCalled(assistant.ToolCalls)
+  a.dispatchTools(ctx, assistant.ToolCalls)
+  if err := ctx.Err(); err != nil {
+    return "", err
+  }
 
   // 6) final LLM call after tools
-  finalResp, err := a.client.Chat.Completions.New(ctx, a.params)
+  finalResp, err := a.client.Chat.Completions.New(ctx, a.request(offered))
   if err != nil {
     return "", err
   }
+  a.addUsage(finalResp.Usage)
   finalMsg := finalResp.Choices[0].Message
 
   // 7) record & return final content
-  a.params.Messages = append(a.params.Messages, finalMsg.ToParam())
+  a.appendMessages(finalMsg.ToParam())
   if finalMsg.Content != "" {
-    a.history = append(a.history, ChatMessage{"assistant", finalMsg.Content})
+    a.appendHistory(ChatMessage{"assistant", finalMsg.Content})
   }
   return finalMsg.Content, nil
 }
 
-func (a *Agent) dispatchTools(toolCalls []openai.ChatCompletionMessageToolCall) {
+// toolContext is ctx as the tools of pack see it: carrying the secrets
+// it may read (grant) and whatever Options.Context adds.
+func (a *Agent) toolContext(ctx context.Context, pack string, grant []string) context.Context {
+  if a.secrets != nil {
+    ctx = tools.WithSecrets(ctx, packSecrets{pack: pack, grant: grant, s: a.secrets})
+  }
+  if a.hostCtx != nil {
+    ctx = a.hostCtx(ctx)
+  }
+  return ctx
+}
+
+// packSecrets is the secrets store as one pack sees it: only the names it
+// declares, so a pack can't read the provider key or another pack's
+// credentials and return them in a form redaction doesn't catch.
+type packSecrets struct {
+  pack  string
+  grant []string
+  s     tools.Secrets
+}
+
+func (p packSecrets) Secret(name string) (string, error) {
+  if !slices.Contains(p.grant, name) {
+    if p.pack == "" {
+      return "", fmt.Errorf("secret %s: only toolpacks may read secrets", name)
+    }
+    return "", fmt.Errorf("secret %s: toolpack %s doesn't declare it (secrets in its toolpack.toml)", name, p.pack)
+  }
+  return p.s.Secret(name)
+}
+
+// dispatchTools runs the tool calls without holding a.mu (tools may be
+// slow) and appends their results in one go afterwards. Tools get the
+// turn's ctx, carrying the secrets their pack may read, so cancellation
+// and the delegation depth limit carry over.
+func (a *Agent) dispatchTools(ctx context.Context, toolCalls []openai.ChatCompletionMessageToolCall) {
+  var out openai.ChatCompletionNewParams
   for _, tc := range toolCalls {
-    if h, ok := a.Registry.Handlers()[tc.Function.Name]; ok {
-      h(tc, &a.params)
+    if sub, ok := a.delegateFor(tc.Function.Name); ok {
+      var args struct{ Task string `json:"task"` }
+      _ = json.Unmarshal([]byte(tc.Function.Arguments), &args)
+      res, err := a.ask(ctx, sub, args.Task)
+      if err != nil {
+        res = fmt.Sprintf("Error running %s: %v", tc.Function.Name, err)
+      }
+      out.Messages = append(out.Messages, openai.ToolMessage(res, tc.ID))
+      continue
     }
+    // every call needs an answer, even to a hidden or made-up tool, or
+    // the next request is refused
+    h, ok := a.Registry.Handler(tc.Function.Name)
+    if !ok {
+      out.Messages = append(out.Messages, openai.ToolMessage(
+        fmt.Sprintf("Error: tool %s is not available.", tc.Function.Name), tc.ID))
+      continue
+    }
+    if !a.approved(ctx, tc) {
+      out.Messages = append(out.Messages, openai.ToolMessage(
+        fmt.Sprintf("The user did not approve running %s; do not retry it unless asked.", tc.Function.Name), tc.ID))
+      continue
+    }
+    pack := a.Registry.Pack(tc.Function.Name)
+    h(a.toolContext(ctx, pack, a.grants[pack]), tc, &out)
+  }
+  if a.redact != nil {
+    for _, m := range out.Messages {
+      if tm := m.OfTool; tm != nil {
+        tm.Content.OfString.Value = a.redact(tm.Content.OfString.Value)
+      }
+    }
+  }
+  a.appendMessages(out.Messages...)
+}
+
+// snapshot returns a copy of params that is safe to hand to the client
+// while other goroutines read the agent.
+func (a *Agent) snapshot() openai.ChatCompletionNewParams {
+  a.mu.RLock()
+  defer a.mu.RUnlock()
+  p := a.params
+  p.Messages = append([]openai.ChatCompletionMessageParamUnion(nil), a.params.Messages...)
+  return p
+}
+
+// request is a snapshot to send, with the turn's tools if they were
+// narrowed (nil means all of them).
+func (a *Agent) request(offered []openai.ChatCompletionToolParam) openai.ChatCompletionNewParams {
+  p := a.snapshot()
+  if offered != nil {
+    p.Tools = offered
+  }
+  return p
+}
+
+func (a *Agent) appendMessages(msgs ...openai.ChatCompletionMessageParamUnion) {
+  a.mu.Lock()
+  a.params.Messages = append(a.params.Messages, msgs...)
+  a.mu.Unlock()
+}
+
+func (a *Agent) appendHistory(m ChatMessage) {
+  a.mu.Lock()
+  a.history = append(a.history, m)
+  a.mu.Unlock()
+}
+
+// Cancel aborts the in-flight turn, if any. The pending SendMessage
+// returns ErrCancelled and the conversation is left as it was before it.
+func (a *Agent) Cancel() {
+  a.mu.RLock()
+  cancel := a.cancel
+  a.mu.RUnlock()
+  if cancel != nil {
+    cancel()
   }
 }
 
+// Busy reports whether a turn is currently in flight.
+func (a *Agent) Busy() bool {
+  a.mu.RLock()
+  defer a.mu.RUnlock()
+  return a.cancel != nil
+}
+
+// BrokenToolpacks returns the toolpacks that failed to load and were
+// skipped when the agent was built.
+func (a *Agent) BrokenToolpacks() []BrokenToolpack {
+  return append([]BrokenToolpack(nil), a.broken...)
+}
+
+// ToolCollisions returns the tool names more than one of the agent's
+// toolpacks defines, and what each was exposed as instead.
+func (a *Agent) ToolCollisions() []registry.Collision {
+  return append([]registry.Collision(nil), a.collisions...)
+}
+
+// Tools returns the tools the model is offered, as it sees them.
 func (a *Agent) Tools() []tools.Tool {
-    return a.Registry.Tools()
+  a.mu.RLock()
+  defer a.mu.RUnlock()
+  if a.Registry == nil {
+    return nil
+  }
+  return a.Registry.Offered()
 }
 
+// HiddenTools returns the pack.tool names of the tools its toolpacks
+// define but tools_include/tools_exclude keep from the model.
+func (a *Agent) HiddenTools() []string {
+  if a.Registry == nil {
+    return nil
+  }
+  return a.Registry.HiddenTools()
+}
 
 
-func (a *Agent) Close() {
-  a.Name = ""
-  a.Model = ""
-  a.params = openai.ChatCompletionNewParams{}
-  a.Registry = nil
+
+// Close cancels any in-flight turn and releases the agent. Further
+// SendMessage calls return ErrClosed. It returns what the Shutdown hooks
+// of the toolpacks it was the last to hold reported.
+func (a *Agent) Close() error {
+  a.Cancel()
+  a.mu.Lock()
+  if a.closed {
+    a.mu.Unlock()
+    return nil
+  }
+  a.closed = true
+  a.mu.Unlock()
+  return a.stopPacks()
 }
 
 func (a *Agent) String() string {
@@ -189,7 +568,15 @@ func (a *Agent) String() string {
 		return "No agent selected\n"
 	}
 	result := fmt.Sprintf("Agent: %s\nModel: %s\n", a.Name, a.Model)
-	result += a.Registry.String()
+	if a.Registry != nil {
+		result += a.Registry.String()
+	}
+	for _, b := range a.broken {
+		result += fmt.Sprintf("⚠ skipped toolpack %s: %v\n", b.Name, b.Err)
+	}
+	for _, c := range a.collisions {
+		result += fmt.Sprintf("⚠ %s\n", c)
+	}
 	return result
 }
 
@@ -197,7 +584,7 @@ func (a *Agent) String() string {
 
 // DumpMessages will pretty-print your prompt slice
 func (a *Agent) DumpMessages() {
-  b, err := json.MarshalIndent(a.params.Messages, "", "  ")
+  b, err := json.MarshalIndent(a.snapshot().Messages, "", "  ")
   if err != nil {
     fmt.Println("❌ failed to marshal messages:", err)
     return
@@ -208,6 +595,8 @@ func (a *Agent) DumpMessages() {
 }
 
 func (a *Agent) History() []ChatMessage {
+  a.mu.RLock()
+  defer a.mu.RUnlock()
   // copy to prevent callers mutating your internal slice
   out := make([]ChatMessage, len(a.history))
   copy(out, a.history)
@@ -0,0 +1,412 @@
+package agent
+
+import (
+  "bytes"
+  "context"
+  "encoding/json"
+  "errors"
+  "log/slog"
+  "net/http"
+  "net/http/httptest"
+  "strings"
+  "sync"
+  "sync/atomic"
+  "testing"
+  "time"
+
+  "github.com/openai/openai-go"
+  "github.com/johnjallday/dolphin-tool-calling-agent/internal/paths"
+  "github.com/johnjallday/dolphin-tool-calling-agent/internal/registry"
+  "github.com/johnjallday/dolphin-tool-calling-agent/pkg/tools"
+)
+
+// fakeModel stands in for the chat completions API. It answers "re: <the
+// user's message>"; a message containing "tool" first gets a call to the
+// echo tool, one containing "hang" no answer until the request is given
+// up. It records how many requests overlapped.
+type fakeModel struct {
+  inFlight, maxInFlight atomic.Int32
+}
+
+func serveModel(t *testing.T) *fakeModel {
+  t.Helper()
+  m := &fakeModel{}
+  srv := httptest.NewServer(http.HandlerFunc(m.serve))
+  t.Cleanup(srv.Close)
+  t.Setenv("OPENAI_BASE_URL", srv.URL+"/")
+  t.Setenv(paths.EnvHome, t.TempDir())
+  return m
+}
+
+func (m *fakeModel) serve(w http.ResponseWriter, r *http.Request) {
+  n := m.inFlight.Add(1)
+  defer m.inFlight.Add(-1)
+  for {
+    max := m.maxInFlight.Load()
+    if n <= max || m.maxInFlight.CompareAndSwap(max, n) {
+      break
+    }
+  }
+
+  var req struct {
+    Messages []struct {
+      Role       string                `json:"role"`
+      Content    json.RawMessage       `json:"content"`
+      ToolCalls  []struct{ ID string } `json:"tool_calls"`
+      ToolCallID string                `json:"tool_call_id"`
+    } `json:"messages"`
+  }
+  if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+    http.Error(w, err.Error(), http.StatusBadRequest)
+    return
+  }
+  var user string
+  unanswered := map[string]bool{}
+  for _, msg := range req.Messages {
+    switch msg.Role {
+    case "user":
+      json.Unmarshal(msg.Content, &user)
+    case "tool":
+      delete(unanswered, msg.ToolCallID)
+    }
+    for _, tc := range msg.ToolCalls {
+      unanswered[tc.ID] = true
+    }
+  }
+  // like the real API, refuse a history with a tool call left unanswered
+  if len(unanswered) > 0 {
+    http.Error(w, "tool calls without a tool message", http.StatusBadRequest)
+    return
+  }
+  last := req.Messages[len(req.Messages)-1].Role
+
+  message := map[string]interface{}{"role": "assistant", "content": "re: " + user}
+  switch {
+  case strings.Contains(user, "hang"):
+    <-r.Context().Done()
+    return
+  case strings.Contains(user, "tool") && last == "user":
+    message = map[string]interface{}{"role": "assistant", "content": "", "tool_calls": []map[string]interface{}{{
+      "id": "call_1", "type": "function",
+      "function": map[string]string{"name": "echo", "arguments": `{"text":"hi"}`},
+    }}}
+  default:
+    // let turns overlap if they aren't serialised
+    time.Sleep(time.Millisecond)
+  }
+  w.Header().Set("Content-Type", "application/json")
+  json.NewEncoder(w).Encode(map[string]interface{}{
+    "id": "cmpl", "object": "chat.completion", "model": "test",
+    "choices": []map[string]interface{}{{"index": 0, "finish_reason": "stop", "message": message}},
+    "usage":   map[string]int{"prompt_tokens": 1, "completion_tokens": 1, "total_tokens": 2},
+  })
+}
+
+func echoPack() tools.ToolPackage {
+  return tools.ToolPackage{Name: "test", Version: "v0.1.0", Tools: []tools.Tool{{
+    Name:        "echo",
+    Description: "Echo text",
+    Parameters:  map[string]interface{}{"type": "object", "properties": map[string]interface{}{"text": map[string]string{"type": "string"}}},
+    ExecContext: func(ctx context.Context, args map[string]interface{}) (string, error) {
+      return args["text"].(string), ctx.Err()
+    },
+  }}}
+}
+
+func newTestAgent(t *testing.T) *Agent {
+  t.Helper()
+  a, err := NewAgentWith("test", "test-model", nil, Options{APIKey: "k", Packages: []tools.ToolPackage{echoPack()}})
+  if err != nil {
+    t.Fatal(err)
+  }
+  t.Cleanup(func() { a.Close() })
+  return a
+}
+
+// checkHistory fails unless every user message is directly followed by
+// its own reply, i.e. no two turns interleaved.
+func checkHistory(t *testing.T, h []ChatMessage) {
+  t.Helper()
+  if len(h) == 0 || h[0].Role != "system" || len(h)%2 != 1 {
+    t.Fatalf("history: %+v", h)
+  }
+  for i := 1; i < len(h); i += 2 {
+    if h[i].Role != "user" || h[i+1].Role != "assistant" || h[i+1].Content != "re: "+h[i].Content {
+      t.Fatalf("turns interleaved at %d: %+v", i, h)
+    }
+  }
+}
+
+func waitBusy(t *testing.T, a *Agent) {
+  t.Helper()
+  for i := 0; !a.Busy(); i++ {
+    if i > 2000 {
+      t.Fatal("turn never started")
+    }
+    time.Sleep(time.Millisecond)
+  }
+}
+
+func TestConcurrentTurns(t *testing.T) {
+  m := serveModel(t)
+  a := newTestAgent(t)
+
+  const turns = 12
+  stop := make(chan struct{})
+  var readers sync.WaitGroup
+  readers.Add(1)
+  go func() {
+    defer readers.Done()
+    for {
+      select {
+      case <-stop:
+        return
+      default:
+      }
+      _ = a.Tools()
+      _ = a.History()
+      _ = a.Busy()
+      _ = a.Usage()
+      _ = a.ToolOffers()
+      _ = a.String()
+    }
+  }()
+
+  var wg sync.WaitGroup
+  errs := make(chan error, turns)
+  for i := 0; i < turns; i++ {
+    msg := "hello " + string(rune('a'+i))
+    if i%3 == 0 {
+      msg = "tool " + msg
+    }
+    wg.Add(1)
+    go func() {
+      defer wg.Done()
+      reply, err := a.SendMessage(context.Background(), msg)
+      if err == nil && reply != "re: "+msg {
+        err = errors.New("reply " + reply + " to " + msg)
+      }
+      errs <- err
+    }()
+  }
+  wg.Wait()
+  close(stop)
+  readers.Wait()
+  close(errs)
+  for err := range errs {
+    if err != nil {
+      t.Error(err)
+    }
+  }
+
+  if got := m.maxInFlight.Load(); got != 1 {
+    t.Errorf("%d requests overlapped; turns should be serialised", got)
+  }
+  h := a.History()
+  checkHistory(t, h)
+  if len(h) != 1+2*turns {
+    t.Errorf("%d messages in history, want %d", len(h), 1+2*turns)
+  }
+}
+
+func TestCancelRollsBackTurn(t *testing.T) {
+  serveModel(t)
+  a := newTestAgent(t)
+  if _, err := a.SendMessage(context.Background(), "first"); err != nil {
+    t.Fatal(err)
+  }
+  before := a.History()
+
+  done := make(chan error, 1)
+  go func() {
+    _, err := a.SendMessage(context.Background(), "hang")
+    done <- err
+  }()
+  waitBusy(t, a)
+  a.Cancel()
+  if err := <-done; !errors.Is(err, ErrCancelled) {
+    t.Fatalf("got %v, want ErrCancelled", err)
+  }
+  if a.Busy() {
+    t.Error("still busy after cancel")
+  }
+  if h := a.History(); len(h) != len(before) {
+    t.Errorf("cancelled turn left in history: %+v", h)
+  }
+  // the next turn goes ahead as if nothing happened
+  if reply, err := a.SendMessage(context.Background(), "second"); err != nil || reply != "re: second" {
+    t.Fatalf("after cancel: %q, %v", reply, err)
+  }
+  checkHistory(t, a.History())
+}
+
+func TestCancelWhileTurnsQueue(t *testing.T) {
+  serveModel(t)
+  a := newTestAgent(t)
+
+  stop := make(chan struct{})
+  go func() {
+    for {
+      select {
+      case <-stop:
+        return
+      default:
+        a.Cancel()
+        time.Sleep(100 * time.Microsecond)
+      }
+    }
+  }()
+  var wg sync.WaitGroup
+  for i := 0; i < 10; i++ {
+    msg := "tool " + string(rune('a'+i))
+    wg.Add(1)
+    go func() {
+      defer wg.Done()
+      if _, err := a.SendMessage(context.Background(), msg); err != nil && !errors.Is(err, ErrCancelled) {
+        t.Errorf("%s: %v", msg, err)
+      }
+    }()
+  }
+  wg.Wait()
+  close(stop)
+  // whatever got through is whole
+  checkHistory(t, a.History())
+}
+
+func TestCloseCancelsAndRefuses(t *testing.T) {
+  serveModel(t)
+  a := newTestAgent(t)
+
+  done := make(chan error, 1)
+  go func() {
+    _, err := a.SendMessage(context.Background(), "hang")
+    done <- err
+  }()
+  waitBusy(t, a)
+  a.Close()
+  if err := <-done; !errors.Is(err, ErrCancelled) {
+    t.Fatalf("in-flight turn: %v, want ErrCancelled", err)
+  }
+  if _, err := a.SendMessage(context.Background(), "hello"); !errors.Is(err, ErrClosed) {
+    t.Fatalf("after close: %v, want ErrClosed", err)
+  }
+  a.Close() // twice is fine
+}
+
+func TestBrokenSubAgents(t *testing.T) {
+  t.Setenv(paths.EnvHome, t.TempDir())
+  clash := tools.ToolPackage{Name: "clash", Tools: []tools.Tool{{
+    Name: "ask_helper", Description: "Not a sub-agent",
+    Exec: func(map[string]interface{}) (string, error) { return "", nil },
+  }}}
+  a, err := NewAgentWith("test", "test-model", nil, Options{APIKey: "k", Packages: []tools.ToolPackage{clash}})
+  if err != nil {
+    t.Fatal(err)
+  }
+  defer a.Close()
+
+  a.SetSubAgents([]string{"helper", "writer"}, nil)
+  if subs := a.SubAgents(); len(subs) != 1 || subs[0] != "writer" {
+    t.Errorf("sub-agents %v, want [writer]", subs)
+  }
+  broken := a.BrokenSubAgents()
+  if len(broken) != 1 || broken[0].Name != "helper" || broken[0].Err == nil {
+    t.Errorf("broken %+v, want helper", broken)
+  }
+}
+
+func TestToolOffersLogged(t *testing.T) {
+  serveModel(t)
+  var buf bytes.Buffer
+  a, err := NewAgentWith("test", "test-model", nil, Options{
+    APIKey:    "k",
+    Packages:  []tools.ToolPackage{echoPack()},
+    Selection: registry.Selection{Max: 1},
+    Logger:    slog.New(slog.NewTextHandler(&buf, nil)),
+  })
+  if err != nil {
+    t.Fatal(err)
+  }
+  defer a.Close()
+  if _, err := a.SendMessage(context.Background(), "echo this"); err != nil {
+    t.Fatal(err)
+  }
+  if log := buf.String(); !strings.Contains(log, `msg="tools offered" agent=test tools=[echo] pinned=0 total=1`) {
+    t.Errorf("log: %s", log)
+  }
+}
+
+func TestHiddenToolCallAnswered(t *testing.T) {
+  serveModel(t)
+  a, err := NewAgentWith("test", "test-model", nil, Options{
+    APIKey:   "k",
+    Packages: []tools.ToolPackage{echoPack()},
+    Filter:   registry.Filter{Exclude: []string{"echo"}},
+  })
+  if err != nil {
+    t.Fatal(err)
+  }
+  defer a.Close()
+  // the model calls echo anyway
+  reply, err := a.SendMessage(context.Background(), "tool please")
+  if err != nil || reply != "re: tool please" {
+    t.Fatalf("got %q, %v", reply, err)
+  }
+  if h := a.History(); len(h) != 3 {
+    t.Errorf("history: %+v", h)
+  }
+}
+
+type secretMap map[string]string
+
+func (m secretMap) Secret(name string) (string, error) {
+  if v, ok := m[name]; ok {
+    return v, nil
+  }
+  return "", errors.New("secret " + name + " is not set")
+}
+
+func TestPackReadsOnlyDeclaredSecrets(t *testing.T) {
+  t.Setenv(paths.EnvHome, t.TempDir())
+  var initErr error
+  peek := tools.ToolPackage{Name: "peek", Secrets: []string{"weather"},
+    Init: func(ctx context.Context, _ tools.Config) error {
+      _, initErr = tools.Secret(ctx, "openai")
+      return nil
+    },
+    Tools: []tools.Tool{{
+      Name: "peek", Description: "Read a secret",
+      ExecContext: func(ctx context.Context, args map[string]interface{}) (string, error) {
+        return tools.Secret(ctx, args["name"].(string))
+      },
+    }}}
+  a, err := NewAgentWith("test", "test-model", nil, Options{
+    APIKey:   "k",
+    Packages: []tools.ToolPackage{peek},
+    Secrets:  secretMap{"weather": "w-1", "openai": "sk-1"},
+  })
+  if err != nil {
+    t.Fatal(err)
+  }
+  defer a.Close()
+  if err := a.startPack("peek", peek); err != nil {
+    t.Fatal(err)
+  }
+  if initErr == nil {
+    t.Error("Init read an undeclared secret")
+  }
+
+  for name, want := range map[string]string{
+    "weather": "w-1",
+    "openai":  "Error running peek: secret openai: toolpack peek doesn't declare it",
+  } {
+    a.dispatchTools(context.Background(), []openai.ChatCompletionMessageToolCall{{
+      ID: "call_" + name, Function: openai.ChatCompletionMessageToolCallFunction{Name: "peek", Arguments: `{"name":"` + name + `"}`},
+    }})
+    msgs := a.snapshot().Messages
+    got := msgs[len(msgs)-1].OfTool.Content.OfString.Value
+    if !strings.HasPrefix(got, want) {
+      t.Errorf("%s: got %q, want %q", name, got, want)
+    }
+  }
+}
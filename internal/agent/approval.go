@@ -0,0 +1,64 @@
+package agent
+
+import (
+  "context"
+  "errors"
+
+  "github.com/openai/openai-go"
+  "github.com/johnjallday/dolphin-tool-calling-agent/pkg/tools"
+)
+
+// Approver decides whether a tool marked RequiresApproval may run. args is
+// the raw JSON the model passed. It is called from the turn's goroutine and
+// may block (e.g. on a y/n prompt) until ctx is done.
+type Approver func(ctx context.Context, agentName string, t tools.Tool, args string) bool
+
+// SetApprover installs the approval hook. Without one, tools that require
+// approval are refused.
+func (a *Agent) SetApprover(fn Approver) {
+  a.mu.Lock()
+  defer a.mu.Unlock()
+  a.approve = fn
+}
+
+// AddTools registers extra tools after construction and declares them to
+// the model. A tool whose name is taken is left out and reported.
+func (a *Agent) AddTools(ts ...tools.Tool) error {
+  a.mu.Lock()
+  defer a.mu.Unlock()
+  var errs []error
+  for _, t := range ts {
+    if err := a.declareLocked(t); err != nil {
+      errs = append(errs, err)
+    }
+  }
+  return errors.Join(errs...)
+}
+
+// declareLocked registers t and adds it to the tools sent to the model.
+// a.mu must be held.
+func (a *Agent) declareLocked(t tools.Tool) error {
+  if err := a.Registry.Register(t); err != nil {
+    return err
+  }
+  // the agent's filter applies to these too
+  if tp, ok := a.Registry.ToolParam(t.Name); ok {
+    a.params.Tools = append(a.params.Tools, tp)
+  }
+  return nil
+}
+
+// approved reports whether the named tool may run now.
+func (a *Agent) approved(ctx context.Context, call openai.ChatCompletionMessageToolCall) bool {
+  t, ok := a.Registry.Tool(call.Function.Name)
+  if !ok || !t.RequiresApproval {
+    return true
+  }
+  a.mu.RLock()
+  approve := a.approve
+  a.mu.RUnlock()
+  if approve == nil {
+    return false
+  }
+  return approve(ctx, a.Name, t, call.Function.Arguments)
+}
@@ -0,0 +1,194 @@
+package agent
+
+import (
+  "context"
+  "fmt"
+  "strings"
+
+  "github.com/openai/openai-go"
+  "github.com/johnjallday/dolphin-tool-calling-agent/pkg/tools"
+)
+
+// MaxDelegationDepth bounds how deeply ask_<agent> calls may nest. The
+// agent the user talks to is depth 0; its sub-agents run at depth 1, etc.
+// It also stops two agents that list each other from looping forever.
+var MaxDelegationDepth = 3
+
+// DelegatePrefix is prepended to a sub-agent's name to form its tool name.
+const DelegatePrefix = "ask_"
+
+// Resolver builds a fresh instance of the named agent for one delegated
+// call. Each call gets its own instance so sub-conversations never leak
+// into each other or into the agent's own chat.
+type Resolver func(name string) (*Agent, error)
+
+// BrokenSubAgent is a sub-agent that couldn't be offered as a tool, say
+// because its ask_<name> clashes with a toolpack's tool.
+type BrokenSubAgent struct {
+  Name string
+  Err  error
+}
+
+// Usage is the token cost of one or more completions.
+type Usage struct {
+  Calls            int
+  PromptTokens     int64
+  CompletionTokens int64
+  TotalTokens      int64
+}
+
+func (u *Usage) add(o Usage) {
+  u.Calls += o.Calls
+  u.PromptTokens += o.PromptTokens
+  u.CompletionTokens += o.CompletionTokens
+  u.TotalTokens += o.TotalTokens
+}
+
+func (u Usage) String() string {
+  return fmt.Sprintf("%d calls, %d tokens (%d prompt + %d completion)",
+    u.Calls, u.TotalTokens, u.PromptTokens, u.CompletionTokens)
+}
+
+// SubCall records one delegated call and what it cost, including whatever
+// the sub-agent delegated in turn.
+type SubCall struct {
+  Agent string
+  Task  string
+  Depth int
+  Usage Usage
+  Err   error
+}
+
+type depthKey struct{}
+
+// depthOf returns the delegation depth of the turn running under ctx.
+func depthOf(ctx context.Context) int {
+  d, _ := ctx.Value(depthKey{}).(int)
+  return d
+}
+
+// SetSubAgents exposes each named agent as an ask_<name>(task) tool. When
+// the model calls one, resolve builds the sub-agent, it runs a whole turn
+// (including its own tool calls) in an isolated conversation, and its
+// answer becomes the tool result. Sub-agents that can't be offered are
+// left out and reported by BrokenSubAgents.
+func (a *Agent) SetSubAgents(names []string, resolve Resolver) {
+  a.mu.Lock()
+  defer a.mu.Unlock()
+  if a.delegates == nil {
+    a.delegates = make(map[string]string)
+  }
+  for _, name := range names {
+    sub := name
+    t := tools.Tool{
+      Name:        DelegatePrefix + sub,
+      Description: fmt.Sprintf("Delegate a task to the %q agent and return its answer. Describe the task completely; the agent does not see this conversation.", sub),
+      Parameters: openai.FunctionParameters{
+        "type": "object",
+        "properties": map[string]interface{}{
+          "task": map[string]interface{}{
+            "type":        "string",
+            "description": "What the agent should do, in plain language",
+          },
+        },
+        "required": []string{"task"},
+      },
+      // only used when the tool is run outside a turn; turns go through
+      // dispatchTools so the depth limit and cancellation carry over
+      Exec: func(args map[string]interface{}) (string, error) {
+        task, _ := args["task"].(string)
+        return a.ask(context.Background(), sub, task)
+      },
+    }
+    if err := a.declareLocked(t); err != nil {
+      a.brokenSubs = append(a.brokenSubs, BrokenSubAgent{Name: sub, Err: err})
+      continue
+    }
+    a.delegates[t.Name] = sub
+  }
+  a.resolve = resolve
+}
+
+// SubAgents returns the names of the agents this one may delegate to.
+func (a *Agent) SubAgents() []string {
+  a.mu.RLock()
+  defer a.mu.RUnlock()
+  out := make([]string, 0, len(a.delegates))
+  for tool := range a.delegates {
+    out = append(out, strings.TrimPrefix(tool, DelegatePrefix))
+  }
+  return out
+}
+
+// BrokenSubAgents returns the sub-agents SetSubAgents had to leave out.
+func (a *Agent) BrokenSubAgents() []BrokenSubAgent {
+  a.mu.RLock()
+  defer a.mu.RUnlock()
+  return append([]BrokenSubAgent(nil), a.brokenSubs...)
+}
+
+// Usage returns the tokens spent by this agent so far, sub-calls included.
+func (a *Agent) Usage() Usage {
+  a.mu.RLock()
+  defer a.mu.RUnlock()
+  return a.usage
+}
+
+// SubCalls returns every delegated call made so far, oldest first.
+func (a *Agent) SubCalls() []SubCall {
+  a.mu.RLock()
+  defer a.mu.RUnlock()
+  return append([]SubCall(nil), a.subCalls...)
+}
+
+func (a *Agent) addUsage(u openai.CompletionUsage) {
+  a.mu.Lock()
+  a.usage.add(Usage{
+    Calls:            1,
+    PromptTokens:     u.PromptTokens,
+    CompletionTokens: u.CompletionTokens,
+    TotalTokens:      u.TotalTokens,
+  })
+  a.mu.Unlock()
+}
+
+// delegateFor reports whether toolName is an ask_<agent> tool.
+func (a *Agent) delegateFor(toolName string) (string, bool) {
+  a.mu.RLock()
+  defer a.mu.RUnlock()
+  sub, ok := a.delegates[toolName]
+  return sub, ok
+}
+
+// ask runs task on a fresh instance of the sub-agent one level deeper than
+// the current turn and accounts for what it cost.
+func (a *Agent) ask(ctx context.Context, sub, task string) (string, error) {
+  depth := depthOf(ctx) + 1
+  if depth > MaxDelegationDepth {
+    return "", fmt.Errorf("delegation depth limit (%d) reached, answer without %s", MaxDelegationDepth, sub)
+  }
+  if strings.TrimSpace(task) == "" {
+    return "", fmt.Errorf("task is required")
+  }
+
+  a.mu.RLock()
+  resolve := a.resolve
+  a.mu.RUnlock()
+  if resolve == nil {
+    return "", fmt.Errorf("no resolver for sub-agent %q", sub)
+  }
+  ag, err := resolve(sub)
+  if err != nil {
+    return "", fmt.Errorf("load sub-agent %q: %w", sub, err)
+  }
+  defer ag.Close()
+
+  reply, err := ag.SendMessage(context.WithValue(ctx, depthKey{}, depth), task)
+
+  call := SubCall{Agent: sub, Task: task, Depth: depth, Usage: ag.Usage(), Err: err}
+  a.mu.Lock()
+  a.usage.add(call.Usage)
+  a.subCalls = append(a.subCalls, call)
+  a.mu.Unlock()
+  return reply, err
+}
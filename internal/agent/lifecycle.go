@@ -0,0 +1,176 @@
+package agent
+
+import (
+  "context"
+  "errors"
+  "fmt"
+  "sort"
+  "sync"
+  "time"
+
+  "github.com/johnjallday/dolphin-tool-calling-agent/pkg/tools"
+)
+
+const (
+  // initTimeout bounds a toolpack's Init.
+  initTimeout = 30 * time.Second
+  // healthTimeout bounds one Health check.
+  healthTimeout = 3 * time.Second
+)
+
+// running counts the agents holding each toolpack, by name. A pack is
+// loaded once per process, so Init and Shutdown run for the first and
+// last of them, not per agent.
+var running = struct {
+  sync.Mutex
+  packs map[string]*runningPack
+}{packs: map[string]*runningPack{}}
+
+type runningPack struct {
+  pkg  tools.ToolPackage
+  refs int
+  // ready is closed once Init has run; until then the pack is only
+  // reserved, so agents loading it meanwhile wait for it, not the lock.
+  ready chan struct{}
+}
+
+// startPack configures a freshly loaded pack and, if no other agent runs
+// it yet, calls its Init. Init runs without holding running, so a slow
+// one holds up only the agents loading the same pack.
+func (a *Agent) startPack(name string, pkg tools.ToolPackage) error {
+  cfg, err := a.configure(name, pkg)
+  if err != nil {
+    return err
+  }
+  for {
+    running.Lock()
+    rp := running.packs[name]
+    if rp == nil {
+      // ours to start
+      rp = &runningPack{pkg: pkg, ready: make(chan struct{})}
+      running.packs[name] = rp
+      running.Unlock()
+      err := a.initPack(name, pkg, cfg)
+      running.Lock()
+      if err != nil {
+        delete(running.packs, name)
+      } else {
+        rp.refs++
+      }
+      close(rp.ready)
+      running.Unlock()
+      if err != nil {
+        return err
+      }
+      break
+    }
+    select {
+    case <-rp.ready:
+      rp.refs++
+      running.Unlock()
+    default:
+      // another agent is starting it; if its Init fails, we try ours
+      running.Unlock()
+      <-rp.ready
+      continue
+    }
+    break
+  }
+  a.mu.Lock()
+  a.started = append(a.started, name)
+  a.mu.Unlock()
+  return nil
+}
+
+// initPack runs pkg's Init, if any, with the ctx its tools get and a
+// timeout.
+func (a *Agent) initPack(name string, pkg tools.ToolPackage, cfg tools.Config) error {
+  if pkg.Init == nil {
+    return nil
+  }
+  ctx, cancel := context.WithTimeout(a.toolContext(context.Background(), name, pkg.Secrets), initTimeout)
+  defer cancel()
+  if err := pkg.Init(ctx, cfg); err != nil {
+    return fmt.Errorf("toolpack %s: init: %w", name, err)
+  }
+  return nil
+}
+
+// stopPacks lets go of the agent's packs, shutting down those no other
+// agent holds. It returns what their Shutdown hooks reported.
+func (a *Agent) stopPacks() error {
+  a.mu.Lock()
+  names := a.started
+  a.started = nil
+  a.mu.Unlock()
+
+  var stop []string
+  var pkgs []tools.ToolPackage
+  running.Lock()
+  for _, name := range names {
+    rp := running.packs[name]
+    if rp == nil {
+      continue
+    }
+    if rp.refs--; rp.refs == 0 {
+      delete(running.packs, name)
+      if rp.pkg.Shutdown != nil {
+        stop, pkgs = append(stop, name), append(pkgs, rp.pkg)
+      }
+    }
+  }
+  running.Unlock()
+
+  var errs []error
+  for i, pkg := range pkgs {
+    if err := pkg.Shutdown(); err != nil {
+      errs = append(errs, fmt.Errorf("toolpack %s: shutdown: %w", stop[i], err))
+    }
+  }
+  return errors.Join(errs...)
+}
+
+// PackHealth is the answer of one toolpack's Health hook.
+type PackHealth struct {
+  Name string
+  Err  error // nil when healthy
+}
+
+// ToolpackHealth asks each of the agent's packs that has a Health hook
+// whether it is healthy, all at once; one that doesn't answer in time
+// counts as unhealthy.
+func (a *Agent) ToolpackHealth() []PackHealth {
+  a.mu.RLock()
+  names := append([]string(nil), a.started...)
+  a.mu.RUnlock()
+  sort.Strings(names)
+
+  running.Lock()
+  var out []PackHealth
+  var checks []func() error
+  for _, name := range names {
+    if rp := running.packs[name]; rp != nil && rp.refs > 0 && rp.pkg.Health != nil {
+      out = append(out, PackHealth{Name: name})
+      checks = append(checks, rp.pkg.Health)
+    }
+  }
+  running.Unlock()
+
+  var wg sync.WaitGroup
+  for i, check := range checks {
+    wg.Add(1)
+    go func() {
+      defer wg.Done()
+      done := make(chan error, 1)
+      go func() { done <- check() }()
+      select {
+      case err := <-done:
+        out[i].Err = err
+      case <-time.After(healthTimeout):
+        out[i].Err = fmt.Errorf("no answer within %s", healthTimeout)
+      }
+    }()
+  }
+  wg.Wait()
+  return out
+}
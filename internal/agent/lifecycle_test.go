@@ -0,0 +1,148 @@
+package agent
+
+import (
+  "context"
+  "errors"
+  "strings"
+  "sync"
+  "sync/atomic"
+  "testing"
+  "time"
+
+  "github.com/johnjallday/dolphin-tool-calling-agent/internal/paths"
+  "github.com/johnjallday/dolphin-tool-calling-agent/pkg/tools"
+)
+
+// bareAgent is an agent with no packs, for starting them by hand.
+func bareAgent(t *testing.T) *Agent {
+  t.Helper()
+  a, err := NewAgentWith("test", "test-model", nil, Options{APIKey: "k"})
+  if err != nil {
+    t.Fatal(err)
+  }
+  return a
+}
+
+func TestPackSharedBetweenAgents(t *testing.T) {
+  t.Setenv(paths.EnvHome, t.TempDir())
+  var inits, shutdowns atomic.Int32
+  pkg := tools.ToolPackage{
+    Init: func(context.Context, tools.Config) error {
+      inits.Add(1)
+      time.Sleep(10 * time.Millisecond) // let the others arrive meanwhile
+      return nil
+    },
+    Shutdown: func() error {
+      shutdowns.Add(1)
+      return errors.New("port still open")
+    },
+  }
+
+  agents := []*Agent{bareAgent(t), bareAgent(t), bareAgent(t)}
+  var wg sync.WaitGroup
+  for _, a := range agents {
+    wg.Add(1)
+    go func() {
+      defer wg.Done()
+      if err := a.startPack("shared", pkg); err != nil {
+        t.Error(err)
+      }
+    }()
+  }
+  wg.Wait()
+  if n := inits.Load(); n != 1 {
+    t.Fatalf("Init ran %d times, want once", n)
+  }
+
+  for _, a := range agents[:2] {
+    if err := a.Close(); err != nil {
+      t.Errorf("pack shut down while held: %v", err)
+    }
+  }
+  err := agents[2].Close()
+  if n := shutdowns.Load(); n != 1 {
+    t.Fatalf("Shutdown ran %d times, want once", n)
+  }
+  if err == nil || !strings.Contains(err.Error(), "toolpack shared: shutdown: port still open") {
+    t.Errorf("Close returned %v", err)
+  }
+}
+
+func TestSlowInitBlocksOnlyItsPack(t *testing.T) {
+  t.Setenv(paths.EnvHome, t.TempDir())
+  release := make(chan struct{})
+  slow := tools.ToolPackage{Init: func(ctx context.Context, _ tools.Config) error {
+    select {
+    case <-release:
+      return nil
+    case <-ctx.Done():
+      return ctx.Err()
+    }
+  }}
+  healthy := tools.ToolPackage{Health: func() error { return nil }}
+
+  a, b := bareAgent(t), bareAgent(t)
+  defer a.Close()
+  defer b.Close()
+  started := make(chan error, 1)
+  go func() { started <- a.startPack("slow", slow) }()
+  time.Sleep(10 * time.Millisecond)
+
+  done := make(chan struct{})
+  go func() {
+    defer close(done)
+    if err := b.startPack("healthy", healthy); err != nil {
+      t.Error(err)
+    }
+    if h := b.ToolpackHealth(); len(h) != 1 || h[0].Err != nil {
+      t.Errorf("health: %+v", h)
+    }
+    b.Close()
+  }()
+  select {
+  case <-done:
+  case <-time.After(2 * time.Second):
+    t.Fatal("another pack waited for a slow Init")
+  }
+
+  close(release)
+  if err := <-started; err != nil {
+    t.Fatal(err)
+  }
+}
+
+func TestFailedInitLetsWaiterRetry(t *testing.T) {
+  t.Setenv(paths.EnvHome, t.TempDir())
+  var calls atomic.Int32
+  first := make(chan struct{})
+  pkg := tools.ToolPackage{Init: func(context.Context, tools.Config) error {
+    if calls.Add(1) == 1 {
+      <-first
+      return errors.New("service down")
+    }
+    return nil
+  }}
+
+  a, b := bareAgent(t), bareAgent(t)
+  defer a.Close()
+  defer b.Close()
+  errA := make(chan error, 1)
+  go func() { errA <- a.startPack("flaky", pkg) }()
+  for calls.Load() == 0 {
+    time.Sleep(time.Millisecond)
+  }
+  errB := make(chan error, 1)
+  go func() { errB <- b.startPack("flaky", pkg) }()
+  time.Sleep(10 * time.Millisecond)
+  close(first)
+
+  if err := <-errA; err == nil || !strings.Contains(err.Error(), "service down") {
+    t.Errorf("first Init: %v", err)
+  }
+  if err := <-errB; err != nil {
+    t.Errorf("the waiting agent didn't get its own Init: %v", err)
+  }
+  if n := calls.Load(); n != 2 {
+    t.Errorf("Init ran %d times, want 2", n)
+  }
+}
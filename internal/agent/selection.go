@@ -0,0 +1,73 @@
+package agent
+
+import (
+  "github.com/openai/openai-go"
+  "github.com/johnjallday/dolphin-tool-calling-agent/internal/registry"
+)
+
+// maxToolOffers bounds the per-turn log kept by ToolOffers.
+const maxToolOffers = 100
+
+// ToolOffer records which tools were sent with one turn when the agent
+// narrows its tools (max_tools).
+type ToolOffer struct {
+  Message string   // the user's message they were ranked against
+  Tools   []string // wire names, pinned and sticky first
+  Pinned  int      // how many of Tools were pinned or used last turn
+  Total   int      // how many the agent has
+}
+
+// offerTools picks the tools to send for a turn on msg, keeps the pick for
+// ToolOffers and logs it. It returns nil when every tool goes out.
+func (a *Agent) offerTools(msg string) []openai.ChatCompletionToolParam {
+  a.mu.RLock()
+  sel, sticky := a.selection, a.recent
+  a.mu.RUnlock()
+  if !sel.Enabled() {
+    return nil
+  }
+  offer := a.Registry.Select(msg, sel, sticky)
+
+  out := make([]openai.ChatCompletionToolParam, 0, len(offer.Tools))
+  for _, name := range offer.Tools {
+    if tp, ok := a.Registry.ToolParam(name); ok {
+      out = append(out, tp)
+    }
+  }
+
+  a.mu.Lock()
+  a.offers = append(a.offers, ToolOffer{Message: msg, Tools: offer.Tools, Pinned: offer.Pinned, Total: offer.Total})
+  if len(a.offers) > maxToolOffers {
+    a.offers = a.offers[len(a.offers)-maxToolOffers:]
+  }
+  a.mu.Unlock()
+  a.log.Info("tools offered", "agent", a.Name, "tools", offer.Tools, "pinned", offer.Pinned, "total", offer.Total)
+  return out
+}
+
+// noteCalled remembers the tools the model called this turn so the next
+// turn offers them again.
+func (a *Agent) noteCalled(calls []openai.ChatCompletionMessageToolCall) {
+  var names []string
+  for _, tc := range calls {
+    names = append(names, tc.Function.Name)
+  }
+  a.mu.Lock()
+  a.recent = names
+  a.mu.Unlock()
+}
+
+// ToolSelection returns how the agent narrows its tools per turn.
+func (a *Agent) ToolSelection() registry.Selection {
+  a.mu.RLock()
+  defer a.mu.RUnlock()
+  return a.selection
+}
+
+// ToolOffers returns which tools went out with each recent turn, oldest
+// first; empty unless the agent narrows its tools.
+func (a *Agent) ToolOffers() []ToolOffer {
+  a.mu.RLock()
+  defer a.mu.RUnlock()
+  return append([]ToolOffer(nil), a.offers...)
+}
@@ -0,0 +1,66 @@
+package agent
+
+import (
+  "fmt"
+  "sort"
+
+  "github.com/johnjallday/dolphin-tool-calling-agent/pkg/tools"
+)
+
+// configure hands a freshly loaded pack its settings, stored under name
+// (the installed pack's name, which pkg.Name need not match), and returns
+// them. Packs without a Config are left alone.
+func (a *Agent) configure(name string, pkg tools.ToolPackage) (tools.Config, error) {
+  if len(pkg.Config) == 0 {
+    return tools.Config{}, nil
+  }
+  if err := tools.CheckFields(pkg.Config); err != nil {
+    return nil, fmt.Errorf("toolpack %s: %w", name, err)
+  }
+  var cfg tools.Config
+  var err error
+  if a.settings != nil {
+    cfg, err = a.settings(name, pkg.Config)
+  } else {
+    cfg, err = tools.ResolveConfig(pkg.Config, nil)
+  }
+  if err != nil {
+    return nil, err
+  }
+  if pkg.Configure != nil {
+    if err := pkg.Configure(cfg); err != nil {
+      return nil, fmt.Errorf("toolpack %s: configure: %w", name, err)
+    }
+  }
+  a.mu.Lock()
+  a.configurable[name] = pkg
+  a.mu.Unlock()
+  return cfg, nil
+}
+
+// Reconfigure passes new settings to pack, if the agent loaded it. A
+// pack is loaded once per process, so this reaches every agent using it.
+func (a *Agent) Reconfigure(pack string, cfg tools.Config) (bool, error) {
+  a.mu.RLock()
+  pkg, ok := a.configurable[pack]
+  a.mu.RUnlock()
+  if !ok || pkg.Configure == nil {
+    return ok, nil
+  }
+  if err := pkg.Configure(cfg); err != nil {
+    return true, fmt.Errorf("toolpack %s: configure: %w", pack, err)
+  }
+  return true, nil
+}
+
+// ConfigurableToolpacks names the agent's packs that declare settings.
+func (a *Agent) ConfigurableToolpacks() []string {
+  a.mu.RLock()
+  defer a.mu.RUnlock()
+  names := make([]string, 0, len(a.configurable))
+  for n := range a.configurable {
+    names = append(names, n)
+  }
+  sort.Strings(names)
+  return names
+}
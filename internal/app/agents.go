@@ -0,0 +1,229 @@
+package app
+
+import (
+  "errors"
+  "fmt"
+
+  "github.com/johnjallday/dolphin-tool-calling-agent/internal/agent"
+  "github.com/johnjallday/dolphin-tool-calling-agent/internal/registry"
+  "github.com/johnjallday/dolphin-tool-calling-agent/internal/store"
+  "github.com/johnjallday/dolphin-tool-calling-agent/internal/user"
+  "github.com/johnjallday/dolphin-tool-calling-agent/pkg/tools"
+)
+
+// liveAgent is a built agent together with the definition it was built
+// from, so config reloads can tell whether it changed.
+type liveAgent struct {
+  agent *agent.Agent
+  def   user.AgentMeta
+}
+
+// LiveAgents returns every currently loaded agent, in load order.
+func (a *DefaultApp) LiveAgents() []*agent.Agent {
+  a.mu.RLock()
+  defer a.mu.RUnlock()
+  out := make([]*agent.Agent, 0, len(a.order))
+  for _, name := range a.order {
+    out = append(out, a.live[name].agent)
+  }
+  return out
+}
+
+// CloseAgent unloads one live agent, cancelling its in-flight turn. If it
+// was current, the most recently loaded remaining agent becomes current.
+func (a *DefaultApp) CloseAgent(name string) error {
+  a.mu.Lock()
+  la, ok := a.live[name]
+  if !ok {
+    a.mu.Unlock()
+    return fmt.Errorf("agent %q is not loaded", name)
+  }
+  a.removeLocked(name)
+  a.mu.Unlock()
+
+  return la.agent.Close()
+}
+
+// Shutdown unloads every live agent, which shuts down the toolpacks they
+// held. UIs call it on their way out; it returns what those toolpacks'
+// Shutdown hooks reported.
+func (a *DefaultApp) Shutdown() error {
+  return a.swapUser(a.User(), nil, user.AgentMeta{})
+}
+
+// ensureAgent returns the live agent called name, building and adding it
+// to the live set first if needed. The current agent is left alone.
+func (a *DefaultApp) ensureAgent(name string) (*agent.Agent, error) {
+  a.mu.RLock()
+  u, la := a.user, a.live[name]
+  a.mu.RUnlock()
+  if la != nil {
+    return la.agent, nil
+  }
+  if u == nil {
+    return nil, fmt.Errorf("no user loaded")
+  }
+  def, ok := findAgent(u.Agents, name)
+  if !ok {
+    return nil, fmt.Errorf("agent %q not found for user %q", name, u.Name)
+  }
+
+  // building an agent opens plugins; do it before taking the lock
+  ag, err := a.buildAgent(u.Name, def)
+  if err != nil {
+    return nil, fmt.Errorf("init agent %q: %w", def.Name, err)
+  }
+
+  a.mu.Lock()
+  defer a.mu.Unlock()
+  switch {
+  case a.user == nil || a.user.Name != u.Name:
+    // the user was switched while we were building; drop our work
+    ag.Close()
+    return nil, fmt.Errorf("user changed while loading agent %q", name)
+  case a.live[name] != nil:
+    // somebody else loaded it meanwhile; theirs wins
+    ag.Close()
+    return a.live[name].agent, nil
+  }
+  a.addLocked(ag, def)
+  return ag, nil
+}
+
+// buildAgent constructs an agent from its definition: toolpacks via
+// NewAgentWith (broken ones skipped unless strict_toolpacks, dolphin_tools
+// given the app, tools named per namespace_tools and tool_aliases and
+// filtered per tools_include, tools_exclude and tool_overrides, narrowed
+// per turn per max_tools, configured with username's toolpack settings,
+// with the secrets store), then its sub_agents as ask_<name> tools and the
+// approval hook.
+func (a *DefaultApp) buildAgent(username string, def user.AgentMeta) (*agent.Agent, error) {
+  opts := agent.Options{
+    SkipBrokenToolpacks: true,
+    Context:             a.hostContext,
+    Logger:              a.logger(),
+    Naming:              registry.Naming{Namespace: def.NamespaceTools, Aliases: def.ToolAliases},
+    Filter:              toolFilter(def),
+    Selection:           registry.Selection{Max: def.MaxTools, Pinned: def.PinnedTools},
+    Settings: func(pack string, fields []tools.ConfigField) (tools.Config, error) {
+      cfg, err := store.LoadToolSettings(username, pack, fields)
+      if err != nil {
+        return nil, err
+      }
+      if err := a.resolveSecretSettings(fields, cfg); err != nil {
+        return nil, fmt.Errorf("toolpack %s settings: %w", pack, err)
+      }
+      return cfg, nil
+    },
+  }
+  if s, err := store.LoadAppSettings(); err == nil && s.StrictToolpacks {
+    opts.SkipBrokenToolpacks = false
+  }
+  if err := a.agentSecrets(&opts); err != nil {
+    return nil, err
+  }
+  ag, err := agent.NewAgentWith(def.Name, def.Model, def.Plugins, opts)
+  if err != nil {
+    return nil, err
+  }
+  a.wireSubAgents(ag, def)
+  ag.SetApprover(a.approve)
+  return ag, nil
+}
+
+// toolFilter turns an agent's tools_include, tools_exclude and
+// tool_overrides into a registry.Filter.
+func toolFilter(def user.AgentMeta) registry.Filter {
+  f := registry.Filter{Include: def.ToolsInclude, Exclude: def.ToolsExclude}
+  if len(def.ToolOverrides) > 0 {
+    f.Overrides = make(map[string]registry.Override, len(def.ToolOverrides))
+    for name, o := range def.ToolOverrides {
+      f.Overrides[name] = registry.Override{Description: o.Description, Parameters: o.Parameters}
+    }
+  }
+  return f
+}
+
+func (a *DefaultApp) wireSubAgents(ag *agent.Agent, def user.AgentMeta) {
+  if ag != nil && len(def.SubAgents) > 0 {
+    ag.SetSubAgents(def.SubAgents, a.subAgent)
+  }
+}
+
+// subAgent builds a throwaway instance of one of the current user's agents
+// for a delegated call. It is never added to the live set, so delegation
+// doesn't disturb (or deadlock on) a conversation the user has open.
+func (a *DefaultApp) subAgent(name string) (*agent.Agent, error) {
+  u := a.User()
+  if u == nil {
+    return nil, fmt.Errorf("no user loaded")
+  }
+  def, ok := findAgent(u.Agents, name)
+  if !ok {
+    return nil, fmt.Errorf("agent %q not found for user %q", name, u.Name)
+  }
+  return a.buildAgent(u.Name, def)
+}
+
+// swapUser publishes a new user (with an optional already-built agent that
+// becomes current) and closes every agent of the previous user, returning
+// what the toolpacks shut down by that reported.
+func (a *DefaultApp) swapUser(u *user.User, ag *agent.Agent, def user.AgentMeta) error {
+  a.mu.Lock()
+  prev := a.live
+  a.user, a.live, a.order, a.current = u, nil, nil, ""
+  if ag != nil {
+    a.addLocked(ag, def)
+    a.current = ag.Name
+  }
+  a.mu.Unlock()
+
+  var errs []error
+  for _, la := range prev {
+    if la.agent != ag {
+      errs = append(errs, la.agent.Close())
+    }
+  }
+  return errors.Join(errs...)
+}
+
+func (a *DefaultApp) addLocked(ag *agent.Agent, def user.AgentMeta) {
+  if a.live == nil {
+    a.live = make(map[string]*liveAgent)
+  }
+  a.live[def.Name] = &liveAgent{agent: ag, def: def}
+  a.order = append(a.order, def.Name)
+}
+
+func (a *DefaultApp) removeLocked(name string) {
+  delete(a.live, name)
+  for i, n := range a.order {
+    if n == name {
+      a.order = append(a.order[:i:i], a.order[i+1:]...)
+      break
+    }
+  }
+  if a.current == name {
+    a.current = ""
+    if n := len(a.order); n > 0 {
+      a.current = a.order[n-1]
+    }
+    if a.user != nil {
+      nu := *a.user
+      nu.DefaultAgent = nil
+      if a.current != "" {
+        nu.DefaultAgent = a.live[a.current].agent
+      }
+      a.user = &nu
+    }
+  }
+}
+
+func findAgent(metas []user.AgentMeta, name string) (user.AgentMeta, bool) {
+  for _, m := range metas {
+    if m.Name == name {
+      return m, true
+    }
+  }
+  return user.AgentMeta{}, false
+}
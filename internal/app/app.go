@@ -2,13 +2,17 @@ package app
 
 import (
   "fmt"
+  "log/slog"
   "os"
   "path/filepath"
 	"context"
-	"strings"
+  "sync"
 
   "github.com/BurntSushi/toml"
   "github.com/johnjallday/dolphin-tool-calling-agent/internal/agent"
+  "github.com/johnjallday/dolphin-tool-calling-agent/internal/paths"
+  "github.com/johnjallday/dolphin-tool-calling-agent/internal/secrets"
+  "github.com/johnjallday/dolphin-tool-calling-agent/internal/toolmanager"
   "github.com/johnjallday/dolphin-tool-calling-agent/internal/user"
 	"github.com/johnjallday/dolphin-tool-calling-agent/internal/store"
 	//"github.com/johnjallday/dolphin-tool-calling-agent/internal/registry"
@@ -19,9 +23,34 @@ type AppConfig struct {
   DefaultUser string `toml:"default_user"`
 }
 
+// DefaultApp is safe for concurrent use: the UI thread may switch users or
+// agents while chat turns run on other goroutines.
+//
+// Several agents can be live at once, each with its own conversation;
+// "current" is just the one un-addressed messages go to. Unloading an
+// agent (or its user) cancels that agent's in-flight turn.
 type DefaultApp struct {
+  // mu guards everything below. It is never held while an agent is being
+  // built or a turn is running.
+  mu   sync.RWMutex
   user *user.User
-	agent *agent.Agent
+  live map[string]*liveAgent
+  // order is the load order of live agents, for stable tab order in UIs.
+  order   []string
+  current string
+
+  // approver asks the user about tools that need approval (see builtin.go)
+  approver Approver
+  // passphrase unlocks the secrets file (see secrets.go)
+  passphrase func(create bool) (string, error)
+
+  secretsOnce sync.Once
+  secrets     *secrets.Store
+  secretsFile *secrets.File
+
+  // log is where agents log (see log.go)
+  logOnce sync.Once
+  log     *slog.Logger
 }
 
 // NewApp returns the concrete implementation.
@@ -31,14 +60,23 @@ func NewApp() App {
 
 
 func (a *DefaultApp) Init() error {
+  // one-time copy of an old ./configs checkout into the resolved config dir
+  moved, err := paths.MigrateLegacy()
+  if err != nil {
+    return fmt.Errorf("migrate legacy config: %w", err)
+  }
+  for _, m := range moved {
+    fmt.Println("migrated", m)
+  }
+
 	// ensure configs/ + app_setting.toml + toolpacks.toml
   if err := store.EnsureConfigDir(); err != nil {
     return fmt.Errorf("ensure config dir: %w", err)
   }
 
   // create subfolders if you still need them
-  _ = os.MkdirAll(filepath.Join(store.DefaultConfigDir, "users"), 0755)
-  _ = os.MkdirAll("plugins", 0755)
+  _ = os.MkdirAll(store.UsersDir(), 0755)
+  _ = os.MkdirAll(paths.PluginDir(), 0755)
 
   // load the top-level app settings (which only has DefaultUser)
   settings, err := store.LoadAppSettings()
@@ -52,20 +90,12 @@ func (a *DefaultApp) Init() error {
   }
 
   // 1) load the user from configs/users/…  
+  //    if the user‐TOML specified a default_agent, NewUser(...) already
+  //    built it and LoadUser wires it up as the current agent.
   if err := a.LoadUser(settings.DefaultUser); err != nil {
     return fmt.Errorf("load default user %q: %w", settings.DefaultUser, err)
   }
 
-  // 2) if the user‐TOML specified a default_agent, the User.DefaultAgent
-  //    field will already be set by NewUser(...).  Detect it and call
-  //    your normal SetDefaultAgent logic to wire it up:
-  if u := a.User(); u != nil && u.DefaultAgent != nil {
-    if err := a.SetDefaultAgent(u.DefaultAgent.Name); err != nil {
-      return fmt.Errorf("load user default agent %q: %w",
-        u.DefaultAgent.Name, err)
-    }
-  }
-
   return nil
 }
 
@@ -83,7 +113,7 @@ func (a *DefaultApp) SetDefaultUser(userName string) error {
 
 func (a *DefaultApp) Users() []string {
   var names []string
-  dir := "configs/users"
+  dir := store.UsersDir()
   entries, err := os.ReadDir(dir)
   if err != nil {
     return names
@@ -105,53 +135,68 @@ func (a *DefaultApp) CreateUser(userID string) error {
   if err != nil {
     return fmt.Errorf("create user %q: %w", userID, err)
   }
-  a.user = u
-  a.agent = nil
-
-  return nil
+  return a.swapUser(u, nil, user.AgentMeta{})
 }
 
 // LoadUser loads the user TOML and then loads the default agent.
 func (a *DefaultApp) LoadUser(username string) error {
-  u, err := user.NewUser(username)
+  u, err := user.NewUser(username, func(def user.AgentMeta) (*agent.Agent, error) {
+    return a.buildAgent(username, def)
+  })
   if err != nil {
     return fmt.Errorf("load user %q: %w", username, err)
   }
-  a.user = u
-  a.agent = u.DefaultAgent
-  return nil
+  var def user.AgentMeta
+  if u.DefaultAgent != nil {
+    for _, m := range u.Agents {
+      if m.Name == u.DefaultAgent.Name {
+        def = m
+        break
+      }
+    }
+  }
+  return a.swapUser(u, u.DefaultAgent, def)
 }
 
 func (a *DefaultApp) User() *user.User {
-    return a.user
+  a.mu.RLock()
+  defer a.mu.RUnlock()
+  return a.user
 }
 
+// Agent returns the current agent (the one un-addressed messages go to).
 func (a *DefaultApp) Agent() *agent.Agent {
-  return a.agent
+  a.mu.RLock()
+  defer a.mu.RUnlock()
+  if la := a.live[a.current]; la != nil {
+    return la.agent
+  }
+  return nil
 }
 
 // SetDefaultAgent persists the named agent as the default in the user's
 // TOML, then loads it so that a.agent and a.user.DefaultAgent get set.
 func (a *DefaultApp) SetDefaultAgent(agentName string) error {
   // 1) must have a user loaded
-  if a.user == nil {
+  u := a.User()
+  if u == nil {
     return fmt.Errorf("no user loaded")
   }
 
-  // 2) make sure that agentName is one of a.user.Agents
+  // 2) make sure that agentName is one of u.Agents
   var ok bool
-  for _, m := range a.user.Agents {
+  for _, m := range u.Agents {
     if m.Name == agentName {
       ok = true
       break
     }
   }
   if !ok {
-    return fmt.Errorf("agent %q not found for user %q", agentName, a.user.Name)
+    return fmt.Errorf("agent %q not found for user %q", agentName, u.Name)
   }
 
   // 3) load the on‐disk config, set the default_agent field, save it
-  cfg, err := store.LoadUserConfig(a.user.Name)
+  cfg, err := store.LoadUserConfig(u.Name)
   if err != nil {
     return fmt.Errorf("could not load user config: %w", err)
   }
@@ -168,37 +213,31 @@ func (a *DefaultApp) SetDefaultAgent(agentName string) error {
   return nil
 }
 
-// LoadAgent selects one of the user’s agents by name and sets it to agent.
+// LoadAgent makes one of the user’s agents current, building it first if
+// it isn't live yet. Other live agents keep running.
 func (a *DefaultApp) LoadAgent(agentName string) error {
-  if a.user == nil {
-    return fmt.Errorf("no user loaded")
-  }
-  var meta *user.AgentMeta
-  for i := range a.user.Agents {
-    if a.user.Agents[i].Name == agentName {
-      meta = &a.user.Agents[i]
-      break
-    }
-  }
-  if meta == nil {
-    fmt.Println("agent %q not found for user %q", agentName, a.user.Name)
-    return nil
-  }
-
-  ag, err := agent.NewAgent(meta.Name, meta.Model, meta.Plugins)
+  ag, err := a.ensureAgent(agentName)
   if err != nil {
-    return fmt.Errorf("init agent %q: %w", meta.Name, err)
+    return err
   }
 
-  a.agent = ag
-  // also update the default in the user struct if desired
-  a.user.DefaultAgent = ag
+  a.mu.Lock()
+  defer a.mu.Unlock()
+  if a.live[agentName] == nil || a.live[agentName].agent != ag {
+    return fmt.Errorf("agent %q was unloaded while loading", agentName)
+  }
+  a.current = agentName
+  // also update the default in the user struct (copy‐on‐write: the old
+  // *User may still be read by other goroutines)
+  nu := *a.user
+  nu.DefaultAgent = ag
+  a.user = &nu
   return nil
 }
 
 func (a *DefaultApp) SwitchUser(name string) error {
     // if there’s already a user, unload them
-    if a.User != nil {
+    if a.User() != nil {
         if err := a.UnloadUser(); err != nil {
             return fmt.Errorf("could not unload existing user: %w", err)
         }
@@ -210,49 +249,79 @@ func (a *DefaultApp) SwitchUser(name string) error {
     return nil
 }
 
+// UnloadAgent closes the current agent, cancelling its in-flight turn.
+// The most recently loaded remaining agent (if any) becomes current.
 func (a *DefaultApp) UnloadAgent() error {
-  if a.agent == nil {
+  a.mu.RLock()
+  name := a.current
+  a.mu.RUnlock()
+  if name == "" {
     return fmt.Errorf("no agent loaded")
   }
-  a.agent = nil
-  return nil
+  return a.CloseAgent(name)
 }
 
 func (a *DefaultApp) UnloadUser() error {
-  if a.user == nil {
+  if a.User() == nil {
     return fmt.Errorf("no user loaded")
   }
-  a.user = nil
-  a.agent = nil
-  return nil
+  return a.swapUser(nil, nil, user.AgentMeta{})
 }
 
 func (a *DefaultApp) SendMessage(ctx context.Context, msg string) (reply string, err error) {
-  if a.agent == nil {
+  ag := a.Agent()
+  if ag == nil {
     // must return "" for reply when erroring
     return "", fmt.Errorf("no agent loaded")
   }
-  // forward the two return values from your agent
-  return a.agent.SendMessage(ctx, msg)
+  // forward the two return values from your agent; no app lock is held
+  // here so the UI can switch or unload agents meanwhile
+  return ag.SendMessage(ctx, msg)
+}
+
+// SendMessageTo addresses a specific agent (e.g. “@reaper_agent …” in the
+// REPL), loading it alongside the others if it isn't live yet. It does not
+// change the current agent.
+func (a *DefaultApp) SendMessageTo(ctx context.Context, agentName, msg string) (reply string, err error) {
+  ag, err := a.ensureAgent(agentName)
+  if err != nil {
+    return "", err
+  }
+  return ag.SendMessage(ctx, msg)
 }
 
 
 // Tools returns the slice of registered tools.
 func (a *DefaultApp) Tools() []tools.Tool {
+    ag := a.Agent()
     // if there is no agent, just return an empty slice
-    if a.agent == nil {
+    if ag == nil {
         return nil
     }
-    return a.agent.Tools()
+    return ag.Tools()
 }
 
 func (a *DefaultApp) Agents() []user.AgentMeta {
-    if a.user == nil {
+    u := a.User()
+    if u == nil {
         // no user loaded → no agents
         return nil
     }
-    // simply return the slice of AgentMeta from the loaded user
-    return a.user.Agents
+    // copy so callers can't race with a config reload
+    return append([]user.AgentMeta(nil), u.Agents...)
+}
+
+// setAgents publishes a fresh agent list for the current user
+// (copy‐on‐write, see LoadAgent).
+func (a *DefaultApp) setAgents(u *user.User, agents []user.AgentMeta) {
+  a.mu.Lock()
+  defer a.mu.Unlock()
+  if a.user == nil || a.user.Name != u.Name {
+    return
+  }
+  nu := *a.user
+  nu.Agents = agents
+  a.user = &nu
 }
 
 
@@ -260,21 +329,15 @@ func (a *DefaultApp) Agents() []user.AgentMeta {
 // CreateAgent will add a new agent entry to the currently
 // loaded user’s TOML config and then refresh the in‐memory User.
 func (a *DefaultApp) CreateAgent(meta AgentMeta) error {
-  if a.user == nil {
+  u := a.User()
+  if u == nil {
     return fmt.Errorf("no user loaded")
   }
 
-  // Path to the user’s TOML file
-  userFile := filepath.Join("configs", "users", a.user.Name+".toml")
-
-  // 1) decode existing file into a struct that mirrors your on‐disk layout
-  var cfg struct {
-    Name         string          `toml:"name"`
-    DefaultAgent string          `toml:"default_agent"`
-    Agents       []user.AgentMeta `toml:"agents"`
-  }
-  if _, err := toml.DecodeFile(userFile, &cfg); err != nil {
-    return fmt.Errorf("decode %s: %w", userFile, err)
+  // 1) load the on‐disk config (validated + migrated by the config package)
+  cfg, err := store.LoadUserConfig(u.Name)
+  if err != nil {
+    return fmt.Errorf("load user config: %w", err)
   }
 
   // 2) append the new agent meta (convert our app.AgentMeta → user.AgentMeta)
@@ -284,28 +347,19 @@ func (a *DefaultApp) CreateAgent(meta AgentMeta) error {
     Plugins: meta.ToolPaths,
   })
 
-  // 3) re‐write the TOML file (truncating)
-  f, err := os.Create(userFile)
-  if err != nil {
-    return fmt.Errorf("rewrite %s: %w", userFile, err)
-  }
-  defer f.Close()
-  enc := toml.NewEncoder(f)
-  if err := enc.Encode(cfg); err != nil {
-    return fmt.Errorf("encode %s: %w", userFile, err)
+  // 3) validate & re‐write the TOML file
+  if err := store.SaveUserConfig(cfg); err != nil {
+    return fmt.Errorf("save user config: %w", err)
   }
 
-  // 4) re‐load the user so that a.user.Agents is refreshed
-  u, err := user.NewUser(a.user.Name)
-  if err != nil {
-    return fmt.Errorf("reload user %q: %w", a.user.Name, err)
-  }
-  a.user = u
+  // 4) refresh the in-memory agent list
+  a.setAgents(u, cfg.Agents)
   return nil
 }
 
 // SwitchAgent switches the current agent to one of the already‐created agents
-// for the current user (loading its .so plugins under the hood).
+// for the current user (loading its .so plugins under the hood if it isn't
+// live yet). The previous agent stays live with its conversation intact.
 func (a *DefaultApp) SwitchAgent(name string) error {
     // pull the loaded user back out via the method
     u := a.User()
@@ -325,12 +379,7 @@ func (a *DefaultApp) SwitchAgent(name string) error {
         return fmt.Errorf("agent %q not found for user %q", name, u.Name)
     }
 
-    // unload the prior agent (if any)
-    if err := a.UnloadAgent(); err != nil {
-        return fmt.Errorf("unload existing agent: %w", err)
-    }
-
-    // load the new agent
+    // load (or just focus) the agent
     if err := a.LoadAgent(name); err != nil {
         return fmt.Errorf("load agent %q: %w", name, err)
     }
@@ -339,30 +388,36 @@ func (a *DefaultApp) SwitchAgent(name string) error {
 }
 
 func (a *DefaultApp) EditAgent(oldName string, meta AgentMeta) error {
-    if a.user == nil {
+    u := a.User()
+    if u == nil {
         return fmt.Errorf("no user loaded")
     }
-    userFile := filepath.Join("configs", "users", a.user.Name+".toml")
 
-    // Decode the existing user config
-    var cfg struct {
-        Name         string            `toml:"name"`
-        DefaultAgent string            `toml:"default_agent"`
-        Agents       []user.AgentMeta  `toml:"agents"`
-    }
-    if _, err := toml.DecodeFile(userFile, &cfg); err != nil {
-        return fmt.Errorf("decode %s: %w", userFile, err)
+    // Load the existing user config
+    cfg, err := store.LoadUserConfig(u.Name)
+    if err != nil {
+        return fmt.Errorf("load user config: %w", err)
     }
 
     // Find & update the matching agent
     found := false
+    var referrers []string // agents whose sub_agents name the renamed one
+    if meta.Name != oldName {
+        for i := range cfg.Agents {
+            for j, sub := range cfg.Agents[i].SubAgents {
+                if sub == oldName {
+                    cfg.Agents[i].SubAgents[j] = meta.Name
+                    referrers = append(referrers, cfg.Agents[i].Name)
+                }
+            }
+        }
+    }
     for i := range cfg.Agents {
         if cfg.Agents[i].Name == oldName {
-            cfg.Agents[i] = user.AgentMeta{
-                Name:    meta.Name,
-                Model:   meta.Model,
-                Plugins: meta.ToolPaths,
-            }
+            // keep fields the UIs don't edit (sub_agents)
+            cfg.Agents[i].Name = meta.Name
+            cfg.Agents[i].Model = meta.Model
+            cfg.Agents[i].Plugins = meta.ToolPaths
             // If you also want to rename the default_agent setting:
             if cfg.DefaultAgent == oldName {
                 cfg.DefaultAgent = meta.Name
@@ -372,31 +427,50 @@ func (a *DefaultApp) EditAgent(oldName string, meta AgentMeta) error {
         }
     }
     if !found {
-        return fmt.Errorf("agent %q not found for user %q", oldName, a.user.Name)
+        return fmt.Errorf("agent %q not found for user %q", oldName, u.Name)
     }
 
-    // Rewrite the TOML file
-    f, err := os.Create(userFile)
-    if err != nil {
-        return fmt.Errorf("rewrite %s: %w", userFile, err)
-    }
-    defer f.Close()
-    enc := toml.NewEncoder(f)
-    if err := enc.Encode(cfg); err != nil {
-        return fmt.Errorf("encode %s: %w", userFile, err)
+    // Validate & rewrite the TOML file
+    if err := store.SaveUserConfig(cfg); err != nil {
+        return fmt.Errorf("save user config: %w", err)
     }
 
-    // Reload the in-memory user so a.user.Agents is fresh
-    u, err := user.NewUser(a.user.Name)
-    if err != nil {
-        return fmt.Errorf("reload user %q: %w", a.user.Name, err)
+    // Refresh the in-memory agent list
+    a.setAgents(u, cfg.Agents)
+
+    // If we had that agent loaded, rebuild it under its new definition
+    a.mu.RLock()
+    _, wasLive := a.live[oldName]
+    wasCurrent := a.current == oldName
+    a.mu.RUnlock()
+    if wasLive {
+        if err := a.CloseAgent(oldName); err != nil {
+            return fmt.Errorf("unload edited agent: %w", err)
+        }
+        if _, err := a.ensureAgent(meta.Name); err != nil {
+            return fmt.Errorf("reload edited agent: %w", err)
+        }
+        if wasCurrent {
+            if err := a.LoadAgent(meta.Name); err != nil {
+                return fmt.Errorf("reload current agent: %w", err)
+            }
+        }
     }
-    a.user = u
 
-    // If we had that agent loaded, switch to the new name
-    if a.agent != nil && a.agent.Name == oldName {
-        if err := a.LoadAgent(meta.Name); err != nil {
-            return fmt.Errorf("reload current agent: %w", err)
+    // Live agents that delegate to it get their ask_<name> tool renamed
+    for _, name := range referrers {
+        if name == oldName {
+            continue // the edited agent itself, rebuilt above
+        }
+        a.mu.RLock()
+        la := a.live[name]
+        a.mu.RUnlock()
+        if la == nil {
+            continue
+        }
+        def, _ := findAgent(cfg.Agents, name)
+        if err := a.restartLive(u, name, la, def); err != nil {
+            return err
         }
     }
 
@@ -405,40 +479,58 @@ func (a *DefaultApp) EditAgent(oldName string, meta AgentMeta) error {
 
 
 
-// Toolpacks returns the list of plugin “names” found under ./plugins.
-// It looks for files ending in .so, and returns each filename minus the .so.
-func (a *DefaultApp) Toolpacks() []string {
-  const pluginDir = "plugins"
-  var names []string
+// LocalToolpacks describes every toolpack an agent can use: the compiled-in
+// ones, then everything installed under the plugin dir (from each pack's
+// toolpack.toml; no plugin code is loaded). An installed pack shadowed by
+// a compiled-in one of the same name is listed once.
+func (a *DefaultApp) LocalToolpacks() []*toolmanager.Manifest {
+  var out []*toolmanager.Manifest
+  seen := map[string]bool{}
+  for _, name := range tools.Packages() {
+    pkg, _ := tools.LookupPackage(name)
+    m := toolmanager.ManifestFor(pkg)
+    m.Name, m.Builtin = name, true
+    out = append(out, &m)
+    seen[name] = true
+  }
 
-  entries, err := os.ReadDir(pluginDir)
+  installed, _, err := toolmanager.Manifests()
   if err != nil {
-    // no plugins folder or unreadable → just return empty
-    return names
+    // no plugins folder or unreadable → just the compiled-in ones
+    return out
   }
-  for _, e := range entries {
-    if e.IsDir() {
-      continue
-    }
-    if filepath.Ext(e.Name()) != ".so" {
-      continue
+  for _, m := range installed {
+    if !seen[m.Name] {
+      out = append(out, m)
+      seen[m.Name] = true
     }
-    base := strings.TrimSuffix(e.Name(), ".so")
-    names = append(names, base)
+  }
+  return out
+}
+
+// Toolpacks returns the names of LocalToolpacks.
+func (a *DefaultApp) Toolpacks() []string {
+  var names []string
+  for _, m := range a.LocalToolpacks() {
+    names = append(names, m.Name)
   }
   return names
 }
 
-// ListRemoteToolpacks reads configs/toolpacks.toml and returns each package name.
+// ListRemoteToolpacks returns the name of every pack the configured
+// catalogs offer (see RemoteToolpacks), each once.
 func (a *DefaultApp) ListRemoteToolpacks() ([]string, error) {
-  pkgs, err := store.LoadRemoteToolpacks()
-  if err != nil {
-    return nil, fmt.Errorf("load remote toolpacks: %w", err)
+  entries, err := a.RemoteToolpacks()
+  if err != nil && len(entries) == 0 {
+    return nil, err
   }
-
-  names := make([]string, 0, len(pkgs))
-  for _, tp := range pkgs {
-    names = append(names, tp.Name)
+  seen := map[string]bool{}
+  names := make([]string, 0, len(entries))
+  for _, e := range entries {
+    if !seen[e.Name] {
+      seen[e.Name] = true
+      names = append(names, e.Name)
+    }
   }
   return names, nil
 }
@@ -0,0 +1,292 @@
+package app
+
+import (
+  "context"
+  "encoding/json"
+  "errors"
+  "net/http"
+  "net/http/httptest"
+  "os"
+  "path/filepath"
+  "strings"
+  "sync"
+  "testing"
+  "time"
+
+  "github.com/johnjallday/dolphin-tool-calling-agent/internal/agent"
+  "github.com/johnjallday/dolphin-tool-calling-agent/internal/paths"
+  "github.com/johnjallday/dolphin-tool-calling-agent/internal/store"
+  "github.com/johnjallday/dolphin-tool-calling-agent/pkg/tools"
+  dolphintools "github.com/johnjallday/dolphin-tool-calling-agent/plugins/dolphin_tools"
+)
+
+// serveModel stands in for the chat completions API: it answers "re:
+// <the user's message>", or nothing until the request is given up when
+// the message contains "hang".
+func serveModel(t *testing.T) {
+  t.Helper()
+  srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+    var req struct {
+      Messages []struct {
+        Role    string          `json:"role"`
+        Content json.RawMessage `json:"content"`
+      } `json:"messages"`
+    }
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+      http.Error(w, err.Error(), http.StatusBadRequest)
+      return
+    }
+    var user string
+    for _, m := range req.Messages {
+      if m.Role == "user" {
+        json.Unmarshal(m.Content, &user)
+      }
+    }
+    if strings.Contains(user, "hang") {
+      <-r.Context().Done()
+      return
+    }
+    time.Sleep(time.Millisecond)
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(map[string]interface{}{
+      "id": "cmpl", "object": "chat.completion", "model": "test",
+      "choices": []map[string]interface{}{{"index": 0, "finish_reason": "stop",
+        "message": map[string]string{"role": "assistant", "content": "re: " + user}}},
+    })
+  }))
+  t.Cleanup(srv.Close)
+  t.Setenv("OPENAI_BASE_URL", srv.URL+"/")
+  t.Setenv("OPENAI_API_KEY", "k")
+}
+
+// testApp is an app with user "u" and agents "a" and "b" (both with the
+// built-in dolphin_tools), "a" current.
+func testApp(t *testing.T) *DefaultApp {
+  t.Helper()
+  t.Setenv(paths.EnvHome, t.TempDir())
+  serveModel(t)
+  a := NewApp().(*DefaultApp)
+  t.Cleanup(func() { a.Shutdown() })
+  if err := a.Init(); err != nil {
+    t.Fatal(err)
+  }
+  if err := a.CreateUser("u"); err != nil {
+    t.Fatal(err)
+  }
+  for _, name := range []string{"a", "b"} {
+    if err := a.CreateAgent(AgentMeta{Name: name, Model: "test-model", ToolPaths: []string{"dolphin_tools"}}); err != nil {
+      t.Fatal(err)
+    }
+  }
+  if err := a.SwitchAgent("a"); err != nil {
+    t.Fatal(err)
+  }
+  return a
+}
+
+func TestSwitchDuringTurns(t *testing.T) {
+  a := testApp(t)
+
+  stop := make(chan struct{})
+  var bg sync.WaitGroup
+  loop := func(f func(i int)) {
+    bg.Add(1)
+    go func() {
+      defer bg.Done()
+      for i := 0; ; i++ {
+        select {
+        case <-stop:
+          return
+        default:
+        }
+        f(i)
+      }
+    }()
+  }
+  loop(func(i int) {
+    if err := a.SwitchAgent([]string{"a", "b"}[i%2]); err != nil {
+      t.Error(err)
+    }
+  })
+  loop(func(int) {
+    _ = a.Tools()
+    _ = a.Agents()
+    _ = a.LiveAgents()
+    if ag := a.Agent(); ag != nil {
+      _ = ag.History()
+    }
+  })
+  loop(func(int) {
+    if ag := a.Agent(); ag != nil {
+      ag.Cancel()
+    }
+    time.Sleep(200 * time.Microsecond)
+  })
+
+  var wg sync.WaitGroup
+  for i := 0; i < 16; i++ {
+    msg := "hello " + string(rune('a'+i))
+    wg.Add(1)
+    go func() {
+      defer wg.Done()
+      var (
+        reply string
+        err   error
+      )
+      if i%4 == 0 {
+        reply, err = a.SendMessageTo(context.Background(), "b", msg)
+      } else {
+        reply, err = a.SendMessage(context.Background(), msg)
+      }
+      switch {
+      case errors.Is(err, agent.ErrCancelled):
+      case err != nil:
+        t.Errorf("%s: %v", msg, err)
+      case reply != "re: "+msg:
+        t.Errorf("reply %q to %q", reply, msg)
+      }
+    }()
+  }
+  wg.Wait()
+  close(stop)
+  bg.Wait()
+
+  // both agents stayed live, each with whole turns only
+  if live := a.LiveAgents(); len(live) != 2 {
+    t.Fatalf("%d live agents, want 2", len(live))
+  }
+  for _, ag := range a.LiveAgents() {
+    h := ag.History()
+    for i := 1; i+1 < len(h); i += 2 {
+      if h[i].Role != "user" || h[i+1].Content != "re: "+h[i].Content {
+        t.Fatalf("agent %s: turns interleaved at %d: %+v", ag.Name, i, h)
+      }
+    }
+  }
+}
+
+func TestUnloadCancelsTurn(t *testing.T) {
+  for _, unload := range []struct {
+    name string
+    do   func(a *DefaultApp) error
+  }{
+    {"agent", (*DefaultApp).UnloadAgent},
+    {"user", (*DefaultApp).UnloadUser},
+    {"switch user", func(a *DefaultApp) error { return a.SwitchUser("u") }},
+  } {
+    t.Run(unload.name, func(t *testing.T) {
+      a := testApp(t)
+      ag := a.Agent()
+      done := make(chan error, 1)
+      go func() {
+        _, err := a.SendMessage(context.Background(), "hang")
+        done <- err
+      }()
+      for i := 0; !ag.Busy(); i++ {
+        if i > 2000 {
+          t.Fatal("turn never started")
+        }
+        time.Sleep(time.Millisecond)
+      }
+      if err := unload.do(a); err != nil {
+        t.Fatal(err)
+      }
+      select {
+      case err := <-done:
+        if !errors.Is(err, agent.ErrCancelled) {
+          t.Fatalf("in-flight turn: %v, want ErrCancelled", err)
+        }
+      case <-time.After(5 * time.Second):
+        t.Fatal("unloading didn't cancel the turn")
+      }
+      if _, err := ag.SendMessage(context.Background(), "hello"); !errors.Is(err, agent.ErrClosed) {
+        t.Errorf("unloaded agent: %v, want ErrClosed", err)
+      }
+    })
+  }
+}
+
+func TestDolphinToolsCompiledIn(t *testing.T) {
+  a := testApp(t)
+  n := 0
+  for _, m := range a.LocalToolpacks() {
+    if m.Name == dolphintools.PackName {
+      n++
+    }
+  }
+  if n != 1 {
+    t.Fatalf("dolphin_tools listed %d times", n)
+  }
+
+  pkg, ok := tools.LookupPackage(dolphintools.PackName)
+  if !ok {
+    t.Fatal("dolphin_tools isn't registered")
+  }
+  list := pkg.Tools[0]
+  out, err := list.Call(a.hostContext(context.Background()), nil)
+  if err != nil || !strings.Contains(out, "- a (current)") || !strings.Contains(out, "- b: model") {
+    t.Errorf("list_agents: %q, %v", out, err)
+  }
+  if _, err := list.Call(context.Background(), nil); err == nil {
+    t.Error("list_agents ran without the app")
+  }
+}
+
+func TestReloadToolpacksFile(t *testing.T) {
+  a := testApp(t)
+  path := filepath.Join(store.ConfigDir(), store.ToolpacksFileName)
+  write := func(s string) {
+    t.Helper()
+    if err := os.WriteFile(path, []byte(s), 0o644); err != nil {
+      t.Fatal(err)
+    }
+  }
+
+  write("[[catalog]]\nname = \"team\"\ntype = \"dir\"\npath = \"" + t.TempDir() + "\"\n")
+  ev := a.reloadFile(path)
+  if ev.Err != nil || strings.Join(ev.Catalogs, ",") != "github,team" {
+    t.Errorf("catalogs %v, %v", ev.Catalogs, ev.Err)
+  }
+
+  write("[[catalog]]\nname = \"team\"\ntype = \"ftp\"\n")
+  if ev := a.reloadFile(path); ev.Err == nil || ev.Catalogs != nil {
+    t.Errorf("a bad edit was taken: %+v", ev)
+  }
+}
+
+func TestRenameUpdatesSubAgents(t *testing.T) {
+  a := testApp(t)
+  cfg, err := store.LoadUserConfig("u")
+  if err != nil {
+    t.Fatal(err)
+  }
+  cfg.Agents[0].SubAgents = []string{"b"}
+  if err := store.SaveUserConfig(cfg); err != nil {
+    t.Fatal(err)
+  }
+  a.setAgents(a.User(), cfg.Agents)
+  if err := a.CloseAgent("a"); err != nil {
+    t.Fatal(err)
+  }
+  if err := a.SwitchAgent("a"); err != nil {
+    t.Fatal(err)
+  }
+
+  if err := a.EditAgent("b", AgentMeta{Name: "c", Model: "test-model", ToolPaths: []string{"dolphin_tools"}}); err != nil {
+    t.Fatal(err)
+  }
+  cfg, err = store.LoadUserConfig("u")
+  if err != nil {
+    t.Fatal(err)
+  }
+  if subs := strings.Join(cfg.Agents[0].SubAgents, ","); subs != "c" {
+    t.Errorf("saved sub_agents %q", subs)
+  }
+  ag := a.Agent()
+  if subs := strings.Join(ag.SubAgents(), ","); subs != "c" || len(ag.BrokenSubAgents()) != 0 {
+    t.Errorf("live sub-agents %q, broken %v", subs, ag.BrokenSubAgents())
+  }
+  if _, err := a.subAgent("c"); err != nil {
+    t.Errorf("ask_c: %v", err)
+  }
+}
@@ -0,0 +1,103 @@
+package app
+
+import (
+  "context"
+  "fmt"
+
+  "github.com/johnjallday/dolphin-tool-calling-agent/internal/store"
+  "github.com/johnjallday/dolphin-tool-calling-agent/pkg/tools"
+  dolphintools "github.com/johnjallday/dolphin-tool-calling-agent/plugins/dolphin_tools"
+)
+
+// SetApprover installs the UI's prompt for tools that need approval when
+// approval_policy is "ask". Without one those tools are refused.
+func (a *DefaultApp) SetApprover(fn Approver) {
+  a.mu.Lock()
+  defer a.mu.Unlock()
+  a.approver = fn
+}
+
+// approve applies approval_policy (re-read every time, so edits to
+// app_setting.toml apply immediately) and falls back to asking the UI.
+func (a *DefaultApp) approve(ctx context.Context, agentName string, t tools.Tool, args string) bool {
+  policy := store.ApprovalAsk
+  if s, err := store.LoadAppSettings(); err == nil {
+    policy = s.Approval()
+  }
+  switch policy {
+  case store.ApprovalAuto:
+    return true
+  case store.ApprovalDeny:
+    return false
+  }
+
+  a.mu.RLock()
+  fn := a.approver
+  a.mu.RUnlock()
+  if fn == nil {
+    return false
+  }
+  return fn(ctx, ApprovalRequest{
+    Agent:       agentName,
+    Tool:        t.Name,
+    Description: t.Description,
+    Args:        args,
+  })
+}
+
+// dolphinOps adapts DefaultApp to what the dolphin_tools pack may do. The
+// pack is compiled in like any other; agents hand its tools the app
+// through their ctx (see hostContext).
+type dolphinOps struct{ a *DefaultApp }
+
+// hostContext is the agent Options.Context that gives dolphin_tools a.
+func (a *DefaultApp) hostContext(ctx context.Context) context.Context {
+  return dolphintools.WithOps(ctx, dolphinOps{a})
+}
+
+func (o dolphinOps) Agents() []dolphintools.AgentInfo {
+  live := map[string]bool{}
+  for _, ag := range o.a.LiveAgents() {
+    live[ag.Name] = true
+  }
+  current := ""
+  if ag := o.a.Agent(); ag != nil {
+    current = ag.Name
+  }
+  var out []dolphintools.AgentInfo
+  for _, m := range o.a.Agents() {
+    out = append(out, dolphintools.AgentInfo{
+      Name:    m.Name,
+      Model:   m.Model,
+      Plugins: m.Plugins,
+      Current: m.Name == current,
+      Live:    live[m.Name],
+    })
+  }
+  return out
+}
+
+func (o dolphinOps) SwitchAgent(name string) error {
+  return o.a.SwitchAgent(name)
+}
+
+func (o dolphinOps) CreateAgent(name, model string, plugins []string) error {
+  installed := map[string]bool{}
+  for _, p := range o.a.Toolpacks() {
+    installed[p] = true
+  }
+  for _, p := range plugins {
+    if !installed[p] {
+      return fmt.Errorf("toolpack %q is not installed", p)
+    }
+  }
+  return o.a.CreateAgent(AgentMeta{Name: name, Model: model, ToolPaths: plugins})
+}
+
+func (o dolphinOps) InstalledToolpacks() []string {
+  return o.a.Toolpacks()
+}
+
+func (o dolphinOps) RemoteToolpacks() ([]string, error) {
+  return o.a.ListRemoteToolpacks()
+}
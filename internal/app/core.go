@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/johnjallday/dolphin-tool-calling-agent/internal/agent"
+	"github.com/johnjallday/dolphin-tool-calling-agent/internal/toolmanager"
   "github.com/johnjallday/dolphin-tool-calling-agent/internal/user"
 	"github.com/johnjallday/dolphin-tool-calling-agent/pkg/tools"
 )
@@ -12,6 +13,18 @@ type AgentMeta struct {
   Name, Model string
   ToolPaths   []string
 }
+// ApprovalRequest describes a tool call waiting for the user's go-ahead.
+type ApprovalRequest struct {
+  Agent       string
+  Tool        string
+  Description string
+  Args        string // raw JSON arguments from the model
+}
+
+// Approver is the UI's y/n prompt for an ApprovalRequest. It runs on the
+// goroutine of the chat turn and should give up when ctx is done.
+type Approver func(ctx context.Context, req ApprovalRequest) bool
+
 type ToolInfo struct {
   Name, Description string
 }
@@ -25,6 +38,9 @@ type App interface {
 	Agent() *agent.Agent
 	Agents() []user.AgentMeta
 	SendMessage(ctx context.Context, text string) (reply string, err error)
+	SendMessageTo(ctx context.Context, agentName, text string) (reply string, err error)
+	LiveAgents() []*agent.Agent
+	CloseAgent(name string) error
 	CreateAgent(meta AgentMeta) error
 	CreateUser(username string) error
 	LoadUser(username string) error
@@ -36,5 +52,23 @@ type App interface {
 	UnloadAgent() error
 	Tools() []tools.Tool
 	Toolpacks() []string
+	LocalToolpacks() []*toolmanager.Manifest
 	ListRemoteToolpacks() ([]string, error)
+	RemoteToolpacks() ([]toolmanager.CatalogEntry, error)
+	InstallToolpack(spec string) ([]toolmanager.LockEntry, error)
+	UpdateToolpack(name string) ([]toolmanager.LockEntry, error)
+	ToolpackUpdates() ([]toolmanager.UpdateStatus, error)
+	ResolveToolpacks() (*toolmanager.Plan, error)
+	SyncToolpacks() ([]toolmanager.LockEntry, error)
+	RollbackToolpack(name string) (*toolmanager.LockEntry, error)
+	UninstallToolpack(name string) error
+	ToolpackConfig(pack string) ([]tools.ConfigField, map[string]interface{}, error)
+	SetToolpackConfig(pack string, values map[string]string) (*ConfigApplied, error)
+	Watch(ctx context.Context, notify func(ReloadEvent)) error
+	SetApprover(fn Approver)
+	SetPassphrasePrompt(fn func(create bool) (string, error))
+	SecretNames() ([]string, error)
+	SetSecret(name, value string) error
+	DeleteSecret(name string) error
+	Shutdown() error
 }
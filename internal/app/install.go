@@ -0,0 +1,258 @@
+package app
+
+import (
+  "errors"
+  "fmt"
+  "sort"
+  "strings"
+
+  "github.com/johnjallday/dolphin-tool-calling-agent/internal/semver"
+  "github.com/johnjallday/dolphin-tool-calling-agent/internal/store"
+  "github.com/johnjallday/dolphin-tool-calling-agent/internal/toolmanager"
+  "github.com/johnjallday/dolphin-tool-calling-agent/pkg/tools"
+)
+
+// InstallToolpack installs a pack from the catalogs in
+// configs/toolpacks.toml, with whatever it depends on. spec is "name" or
+// "catalog/name", either optionally followed by "@version" or
+// "@constraint" (e.g. "weather@^0.2"); it gets the newest version that
+// also fits what the agents ask for. The first entry returned is the pack
+// itself. Agents already running keep the old code until dolphin restarts.
+func (a *DefaultApp) InstallToolpack(spec string) ([]toolmanager.LockEntry, error) {
+  catName, rest, ok := strings.Cut(spec, "/")
+  if !ok {
+    catName, rest = "", spec
+  }
+  name, c, err := toolmanager.ParseSpec(rest)
+  if err != nil {
+    return nil, err
+  }
+  if err := a.notCompiledIn(name); err != nil {
+    return nil, err
+  }
+  return a.installResolved(toolmanager.Requirement{Name: name, Constraint: c, Catalog: catName, From: "toolpack install"})
+}
+
+// UpdateToolpack moves an installed pack (and its dependencies, as
+// needed) to the newest version the agents allow, from the catalog it
+// came from.
+func (a *DefaultApp) UpdateToolpack(name string) ([]toolmanager.LockEntry, error) {
+  if err := a.notCompiledIn(name); err != nil {
+    return nil, err
+  }
+  lock, err := toolmanager.LoadLock()
+  if err != nil {
+    return nil, err
+  }
+  if _, ok := lock.Get(name); !ok {
+    return nil, fmt.Errorf("toolpack %q was not installed from a catalog", name)
+  }
+  return a.installResolved(toolmanager.Requirement{Name: name, Constraint: semver.Any, From: "toolpack update"})
+}
+
+// installResolved resolves want against the agents' constraints and
+// installs the result, want's pack first in the returned list.
+func (a *DefaultApp) installResolved(want toolmanager.Requirement) ([]toolmanager.LockEntry, error) {
+  r, err := a.resolver()
+  if err != nil {
+    return nil, err
+  }
+  if r.Constraints, err = a.agentRequirements(); err != nil {
+    return nil, err
+  }
+  r.Upgrade = map[string]bool{want.Name: true}
+  plan, err := r.Resolve([]toolmanager.Requirement{want})
+  if err != nil {
+    return nil, err
+  }
+  trust, err := installTrust()
+  if err != nil {
+    return nil, err
+  }
+  entries, err := toolmanager.Apply(plan, trust)
+  if err != nil {
+    return entries, err
+  }
+  if len(entries) == 0 {
+    for _, p := range plan.Picks {
+      if p.Name == want.Name {
+        return nil, fmt.Errorf("toolpack %s is already at %s", p.Name, p.Version)
+      }
+    }
+  }
+  // the pack asked for first, then its dependencies in install order
+  sort.SliceStable(entries, func(i, j int) bool {
+    return entries[i].Name == want.Name && entries[j].Name != want.Name
+  })
+  return entries, nil
+}
+
+// ResolveToolpacks works out which version of every toolpack the agents
+// of all users need, without installing anything.
+func (a *DefaultApp) ResolveToolpacks() (*toolmanager.Plan, error) {
+  r, err := a.resolver()
+  if err != nil {
+    return nil, err
+  }
+  roots, err := a.agentRequirements()
+  if err != nil {
+    return nil, err
+  }
+  return r.Resolve(roots)
+}
+
+// SyncToolpacks installs what ResolveToolpacks says is missing or at the
+// wrong version.
+func (a *DefaultApp) SyncToolpacks() ([]toolmanager.LockEntry, error) {
+  plan, err := a.ResolveToolpacks()
+  if err != nil {
+    return nil, err
+  }
+  trust, err := installTrust()
+  if err != nil {
+    return nil, err
+  }
+  return toolmanager.Apply(plan, trust)
+}
+
+// resolver is a Resolver over the configured catalogs that knows the
+// compiled-in packs can't be installed.
+func (a *DefaultApp) resolver() (*toolmanager.Resolver, error) {
+  cats, err := LoadCatalogs()
+  if err != nil {
+    return nil, err
+  }
+  fixed := map[string]string{}
+  for _, name := range tools.Packages() {
+    if pkg, ok := tools.LookupPackage(name); ok {
+      fixed[name] = pkg.Version
+    }
+  }
+  return &toolmanager.Resolver{Catalogs: cats, Fixed: fixed}, nil
+}
+
+// agentRequirements is every plugins entry of every user's agents.
+func (a *DefaultApp) agentRequirements() ([]toolmanager.Requirement, error) {
+  var reqs []toolmanager.Requirement
+  for _, u := range a.Users() {
+    cfg, err := store.LoadUserConfig(u)
+    if err != nil {
+      return nil, err
+    }
+    for _, ag := range cfg.Agents {
+      for _, spec := range ag.Plugins {
+        name, c, err := toolmanager.ParseSpec(spec)
+        if err != nil {
+          return nil, fmt.Errorf("user %s, agent %s: %w", u, ag.Name, err)
+        }
+        reqs = append(reqs, toolmanager.Requirement{
+          Name:       name,
+          Constraint: c,
+          From:       fmt.Sprintf("agent %s/%s", u, ag.Name),
+        })
+      }
+    }
+  }
+  return reqs, nil
+}
+
+// ToolpackUpdates reports, for every pack installed from a catalog,
+// whether a newer release is available.
+func (a *DefaultApp) ToolpackUpdates() ([]toolmanager.UpdateStatus, error) {
+  cats, err := LoadCatalogs()
+  if err != nil {
+    return nil, err
+  }
+  return toolmanager.Updates(cats)
+}
+
+// RemoteToolpacks lists what every configured catalog offers. Catalogs
+// that can't be reached are skipped; the error then says which.
+func (a *DefaultApp) RemoteToolpacks() ([]toolmanager.CatalogEntry, error) {
+  cats, err := LoadCatalogs()
+  if err != nil {
+    return nil, err
+  }
+  entries, errs := cats.List()
+  return entries, errors.Join(errs...)
+}
+
+// RollbackToolpack swaps a pack with the version its last install replaced.
+func (a *DefaultApp) RollbackToolpack(name string) (*toolmanager.LockEntry, error) {
+  if err := a.notCompiledIn(name); err != nil {
+    return nil, err
+  }
+  return toolmanager.Rollback(name)
+}
+
+// UninstallToolpack removes an installed pack. Agents that list it keep
+// their entry and report it as broken until it is reinstalled.
+func (a *DefaultApp) UninstallToolpack(name string) error {
+  if err := a.notCompiledIn(name); err != nil {
+    return err
+  }
+  return toolmanager.Uninstall(name)
+}
+
+// notCompiledIn refuses to install over or remove a pack linked into the binary.
+func (a *DefaultApp) notCompiledIn(name string) error {
+  if _, ok := tools.LookupPackage(name); ok {
+    return fmt.Errorf("toolpack %q is compiled into this binary", name)
+  }
+  return nil
+}
+
+// installTrust reads trusted_keys and require_signature from
+// app_setting.toml (every time, so edits apply to the next install).
+func installTrust() (toolmanager.Trust, error) {
+  var trust toolmanager.Trust
+  s, err := store.LoadAppSettings()
+  if err != nil {
+    return trust, err
+  }
+  for _, k := range s.TrustedKeys {
+    key, err := toolmanager.ParsePublicKey(k)
+    if err != nil {
+      return trust, fmt.Errorf("%s: trusted_keys: %w", store.SettingsFileName, err)
+    }
+    trust.Keys = append(trust.Keys, key)
+  }
+  trust.RequireSignature = s.RequireSignature
+  if trust.RequireSignature && len(trust.Keys) == 0 {
+    return trust, fmt.Errorf("%s: require_signature is set but trusted_keys is empty", store.SettingsFileName)
+  }
+  return trust, nil
+}
+
+// LoadCatalogs builds the catalogs configured in configs/toolpacks.toml:
+// the [[toolpack]] list as the "github" catalog, then each [[catalog]].
+func LoadCatalogs() (toolmanager.Catalogs, error) {
+  packs, err := store.LoadRemoteToolpacks()
+  if err != nil {
+    return nil, fmt.Errorf("load remote toolpacks: %w", err)
+  }
+  srcs, err := store.LoadCatalogSources()
+  if err != nil {
+    return nil, err
+  }
+  cats := toolmanager.Catalogs{toolmanager.NewGitHubCatalog(packs)}
+  for _, src := range srcs {
+    var (
+      c   toolmanager.Catalog
+      err error
+    )
+    switch src.Type {
+    case store.CatalogHTTP:
+      c, err = toolmanager.NewIndexCatalog(src.Name, src.URL)
+    case store.CatalogDir:
+      c, err = toolmanager.NewDirCatalog(src.Name, src.Path)
+    case store.CatalogGit:
+      c = toolmanager.NewGitCatalog(src.Name, src.URL, src.Ref)
+    }
+    if err != nil {
+      return nil, err
+    }
+    cats = append(cats, c)
+  }
+  return cats, nil
+}
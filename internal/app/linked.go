@@ -0,0 +1,9 @@
+package app
+
+// Toolpacks linked into every binary. Each registers itself with
+// tools.RegisterPackage from init, so agents can list it in plugins = [...]
+// without a .so in the plugin dir. Add a blank import here to link another.
+// (dolphin_tools is linked by builtin.go, which hands it the app.)
+import (
+  _ "github.com/johnjallday/dolphin-tool-calling-agent/plugins/examples/calculator"
+)
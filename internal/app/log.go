@@ -0,0 +1,27 @@
+package app
+
+import (
+  "log/slog"
+  "os"
+  "path/filepath"
+
+  "github.com/johnjallday/dolphin-tool-calling-agent/internal/paths"
+)
+
+// logger returns the log agents write to (which tools each turn was
+// offered), appending to paths.LogFile. If the file can't be opened
+// nothing is logged: the UIs own the terminal.
+func (a *DefaultApp) logger() *slog.Logger {
+  a.logOnce.Do(func() {
+    a.log = slog.New(slog.DiscardHandler)
+    if err := os.MkdirAll(filepath.Dir(paths.LogFile()), 0o755); err != nil {
+      return
+    }
+    f, err := os.OpenFile(paths.LogFile(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+    if err != nil {
+      return
+    }
+    a.log = slog.New(slog.NewTextHandler(f, nil))
+  })
+  return a.log
+}
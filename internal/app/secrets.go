@@ -0,0 +1,95 @@
+package app
+
+import (
+  "fmt"
+  "path/filepath"
+
+  "github.com/johnjallday/dolphin-tool-calling-agent/internal/agent"
+  "github.com/johnjallday/dolphin-tool-calling-agent/internal/paths"
+  "github.com/johnjallday/dolphin-tool-calling-agent/internal/secrets"
+  "github.com/johnjallday/dolphin-tool-calling-agent/internal/store"
+  "github.com/johnjallday/dolphin-tool-calling-agent/pkg/tools"
+)
+
+// SecretsFileName is the encrypted secrets file in the config dir.
+const SecretsFileName = "secrets.enc"
+
+// vault returns the secrets store: the environment, then the encrypted
+// file, unlocked with the prompt the UI set (see SetPassphrasePrompt).
+func (a *DefaultApp) vault() (*secrets.Store, *secrets.File) {
+  a.secretsOnce.Do(func() {
+    a.secretsFile = secrets.NewFile(filepath.Join(paths.ConfigDir(), SecretsFileName), func(create bool) (string, error) {
+      a.mu.RLock()
+      ask := a.passphrase
+      a.mu.RUnlock()
+      if ask == nil {
+        return "", secrets.ErrLocked
+      }
+      return ask(create)
+    })
+    a.secrets = secrets.New(secrets.Env{}, a.secretsFile)
+  })
+  return a.secrets, a.secretsFile
+}
+
+// SetPassphrasePrompt sets how the secrets file's passphrase is asked for
+// when it is first needed (unless DOLPHIN_SECRETS_PASSPHRASE is set).
+func (a *DefaultApp) SetPassphrasePrompt(fn func(create bool) (string, error)) {
+  a.mu.Lock()
+  defer a.mu.Unlock()
+  a.passphrase = fn
+}
+
+// SecretNames lists the secrets in the encrypted file.
+func (a *DefaultApp) SecretNames() ([]string, error) {
+  _, f := a.vault()
+  return f.Names()
+}
+
+// SetSecret stores a secret in the encrypted file. Agents pick it up the
+// next time a tool asks for it.
+func (a *DefaultApp) SetSecret(name, value string) error {
+  _, f := a.vault()
+  return f.Set(name, value)
+}
+
+// DeleteSecret removes a secret from the encrypted file.
+func (a *DefaultApp) DeleteSecret(name string) error {
+  _, f := a.vault()
+  return f.Delete(name)
+}
+
+// agentSecrets fills in what an agent needs from the secrets store: the
+// provider key from app_setting.toml's api_key, the store for its tools
+// and the redaction of what they return.
+func (a *DefaultApp) agentSecrets(opts *agent.Options) error {
+  vault, _ := a.vault()
+  opts.Secrets, opts.Redact = vault, vault.Redact
+  s, err := store.LoadAppSettings()
+  if err != nil || s.APIKey == "" {
+    return nil
+  }
+  key, err := vault.Resolve(s.APIKey)
+  if err != nil {
+    return fmt.Errorf("api_key: %w", err)
+  }
+  opts.APIKey = key
+  return nil
+}
+
+// resolveSecretSettings swaps the secret:name references of a pack's
+// secret fields for the secrets themselves.
+func (a *DefaultApp) resolveSecretSettings(fields []tools.ConfigField, cfg tools.Config) error {
+  vault, _ := a.vault()
+  for _, f := range fields {
+    if f.Type != tools.ConfigSecret || !cfg.Has(f.Key) {
+      continue
+    }
+    v, err := vault.Resolve(cfg.String(f.Key))
+    if err != nil {
+      return fmt.Errorf("%s: %w", f.Key, err)
+    }
+    cfg[f.Key] = v
+  }
+  return nil
+}
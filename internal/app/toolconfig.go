@@ -0,0 +1,162 @@
+package app
+
+import (
+  "fmt"
+  "sort"
+  "strings"
+
+  "github.com/johnjallday/dolphin-tool-calling-agent/internal/store"
+  "github.com/johnjallday/dolphin-tool-calling-agent/internal/toolmanager"
+  "github.com/johnjallday/dolphin-tool-calling-agent/internal/user"
+  "github.com/johnjallday/dolphin-tool-calling-agent/pkg/tools"
+)
+
+// ConfigApplied reports what SetToolpackConfig did with the new settings.
+type ConfigApplied struct {
+  // Reconfigured lists live agents whose pack got the new settings.
+  Reconfigured []string
+  // Restarted lists live agents that had skipped the pack (say, for a
+  // missing setting) and were rebuilt with it.
+  Restarted []string
+  // Missing is set when required settings are still unset; the values
+  // were saved but no agent was touched.
+  Missing error
+}
+
+// ToolpackConfig returns the settings pack declares and the current
+// user's saved values for them (unset ones are left out).
+func (a *DefaultApp) ToolpackConfig(pack string) ([]tools.ConfigField, map[string]interface{}, error) {
+  u := a.User()
+  if u == nil {
+    return nil, nil, fmt.Errorf("no user loaded")
+  }
+  fields, err := toolpackFields(pack)
+  if err != nil {
+    return nil, nil, err
+  }
+  values, err := store.ToolSettings(u.Name, pack)
+  if err != nil {
+    return nil, nil, err
+  }
+  return fields, values, nil
+}
+
+// SetToolpackConfig saves settings for pack, as typed by the user
+// ("" resets a key to its default), and hands them to every live agent
+// using it.
+func (a *DefaultApp) SetToolpackConfig(pack string, values map[string]string) (*ConfigApplied, error) {
+  fields, saved, err := a.ToolpackConfig(pack)
+  if err != nil {
+    return nil, err
+  }
+  u := a.User()
+  byKey := map[string]tools.ConfigField{}
+  for _, f := range fields {
+    byKey[f.Key] = f
+  }
+  for k, s := range values {
+    f, ok := byKey[k]
+    if !ok {
+      return nil, fmt.Errorf("toolpack %s has no setting %q (it has %s)", pack, k, fieldKeys(fields))
+    }
+    if strings.TrimSpace(s) == "" {
+      delete(saved, k)
+      continue
+    }
+    v, err := f.Parse(s)
+    if err != nil {
+      return nil, err
+    }
+    saved[k] = v
+  }
+  if err := store.SaveToolSettings(u.Name, pack, fields, saved); err != nil {
+    return nil, err
+  }
+
+  applied := &ConfigApplied{}
+  cfg, err := tools.ResolveConfig(fields, saved)
+  if err != nil {
+    applied.Missing = err
+    return applied, nil
+  }
+
+  a.mu.RLock()
+  live := make(map[string]*liveAgent, len(a.live))
+  for name, la := range a.live {
+    live[name] = la
+  }
+  a.mu.RUnlock()
+  for name, la := range live {
+    ok, err := la.agent.Reconfigure(pack, cfg)
+    if err != nil {
+      return applied, fmt.Errorf("agent %s: %w", name, err)
+    }
+    if ok {
+      applied.Reconfigured = append(applied.Reconfigured, name)
+      continue
+    }
+    for _, b := range la.agent.BrokenToolpacks() {
+      if b.Name == pack {
+        if err := a.restartLive(u, name, la, la.def); err != nil {
+          return applied, err
+        }
+        applied.Restarted = append(applied.Restarted, name)
+        break
+      }
+    }
+  }
+  sort.Strings(applied.Reconfigured)
+  sort.Strings(applied.Restarted)
+  return applied, nil
+}
+
+// restartLive rebuilds the live agent called name from def, unless it was
+// unloaded or replaced meanwhile. Its conversation starts over.
+func (a *DefaultApp) restartLive(u *user.User, name string, old *liveAgent, def user.AgentMeta) error {
+  ag, err := a.buildAgent(u.Name, def)
+  if err != nil {
+    return fmt.Errorf("restart agent %q: %w", name, err)
+  }
+  a.mu.Lock()
+  if a.user == nil || a.user.Name != u.Name || a.live[name] != old {
+    a.mu.Unlock()
+    ag.Close()
+    return nil
+  }
+  a.live[name] = &liveAgent{agent: ag, def: def}
+  if a.current == name {
+    nu := *a.user
+    nu.DefaultAgent = ag
+    a.user = &nu
+  }
+  a.mu.Unlock()
+  old.agent.Close()
+  return nil
+}
+
+// toolpackFields returns the settings pack declares, from the compiled-in
+// package or the installed pack's toolpack.toml.
+func toolpackFields(pack string) ([]tools.ConfigField, error) {
+  var fields []tools.ConfigField
+  if pkg, ok := tools.LookupPackage(pack); ok {
+    fields = pkg.Config
+  } else {
+    m, err := toolmanager.Locate(pack)
+    if err != nil {
+      return nil, err
+    }
+    fields = m.Config
+  }
+  if len(fields) == 0 {
+    return nil, fmt.Errorf("toolpack %s has no settings", pack)
+  }
+  return fields, nil
+}
+
+func fieldKeys(fields []tools.ConfigField) string {
+  keys := make([]string, len(fields))
+  for i, f := range fields {
+    keys[i] = f.Key
+  }
+  return strings.Join(keys, ", ")
+}
@@ -0,0 +1,210 @@
+package app
+
+import (
+  "context"
+  "errors"
+  "fmt"
+  "path/filepath"
+  "reflect"
+  "sort"
+  "time"
+
+  "github.com/fsnotify/fsnotify"
+  "github.com/johnjallday/dolphin-tool-calling-agent/internal/agent"
+  "github.com/johnjallday/dolphin-tool-calling-agent/internal/store"
+)
+
+// reloadDebounce coalesces the burst of events editors emit on save
+// (write temp, rename, chmod …) into a single reload.
+const reloadDebounce = 250 * time.Millisecond
+
+// ReloadEvent reports the outcome of a config file change picked up by Watch.
+type ReloadEvent struct {
+  // Path is the file that changed.
+  Path string
+  // Err is set when the new contents were rejected; the running user and
+  // agent are left untouched in that case.
+  Err error
+  // Restarted lists live agents whose definition changed and that were
+  // rebuilt (which starts a fresh conversation).
+  Restarted []string
+  // Removed lists live agents that no longer exist and were unloaded.
+  Removed []string
+  // Shutdown is what the toolpacks that unloading or replacing agents
+  // shut down reported, if anything.
+  Shutdown error
+  // Catalogs lists, after a toolpacks.toml change, the catalogs installs
+  // and remote listings now use; UIs showing remote toolpacks re-list.
+  Catalogs []string
+}
+
+// Watch watches the active user's TOML and toolpacks.toml and hot-reloads
+// them on change, reporting each outcome to notify. It blocks until ctx is
+// cancelled, so run it in its own goroutine. notify is called from the
+// watcher goroutine; UIs must hop back onto their own thread.
+func (a *DefaultApp) Watch(ctx context.Context, notify func(ReloadEvent)) error {
+  w, err := fsnotify.NewWatcher()
+  if err != nil {
+    return fmt.Errorf("watch: %w", err)
+  }
+  defer w.Close()
+
+  // watch the directories rather than the files: most editors save by
+  // writing a new file and renaming it over the old one.
+  for _, dir := range []string{store.ConfigDir(), store.UsersDir()} {
+    if err := w.Add(dir); err != nil {
+      return fmt.Errorf("watch %s: %w", dir, err)
+    }
+  }
+
+  pending := make(map[string]bool)
+  timer := time.NewTimer(reloadDebounce)
+  timer.Stop()
+
+  for {
+    select {
+    case <-ctx.Done():
+      return nil
+
+    case ev, ok := <-w.Events:
+      if !ok {
+        return nil
+      }
+      if ev.Has(fsnotify.Chmod) || !a.watched(ev.Name) {
+        continue
+      }
+      pending[ev.Name] = true
+      timer.Reset(reloadDebounce)
+
+    case err, ok := <-w.Errors:
+      if !ok {
+        return nil
+      }
+      notify(ReloadEvent{Err: fmt.Errorf("watch: %w", err)})
+
+    case <-timer.C:
+      for path := range pending {
+        notify(a.reloadFile(path))
+      }
+      pending = make(map[string]bool)
+    }
+  }
+}
+
+// watched reports whether path is one of the files we hot-reload.
+func (a *DefaultApp) watched(path string) bool {
+  path = filepath.Clean(path)
+  if path == filepath.Join(store.ConfigDir(), store.ToolpacksFileName) {
+    return true
+  }
+  u := a.User()
+  return u != nil && path == store.UserConfigPath(u.Name)
+}
+
+// reloadFile applies a change to path. Catalogs are built from
+// toolpacks.toml for every install or listing, so for that file it builds
+// them once to reject a bad edit and report what is now configured.
+func (a *DefaultApp) reloadFile(path string) ReloadEvent {
+  if filepath.Base(path) == store.ToolpacksFileName {
+    cats, err := LoadCatalogs()
+    if err != nil {
+      return ReloadEvent{Path: path, Err: err}
+    }
+    ev := ReloadEvent{Path: path}
+    for _, c := range cats {
+      ev.Catalogs = append(ev.Catalogs, c.Name())
+    }
+    return ev
+  }
+  ev, err := a.ReloadUser()
+  ev.Path, ev.Err = path, err
+  return ev
+}
+
+// ReloadUser re-reads the active user's TOML and swaps in the new agent
+// definitions. Each live agent keeps its conversation when its own
+// definition is unchanged; otherwise it is rebuilt (or unloaded if it was
+// removed). On any error the previous state is kept.
+func (a *DefaultApp) ReloadUser() (ReloadEvent, error) {
+  var ev ReloadEvent
+
+  a.mu.RLock()
+  u := a.user
+  live := make(map[string]*liveAgent, len(a.live))
+  for name, la := range a.live {
+    live[name] = la
+  }
+  a.mu.RUnlock()
+  if u == nil {
+    return ev, fmt.Errorf("no user loaded")
+  }
+
+  cfg, err := store.LoadUserConfig(u.Name)
+  if err != nil {
+    return ev, err
+  }
+
+  // figure out what happens to each live agent before touching anything
+  rebuilt := make(map[string]*liveAgent)
+  closeAll := func() {
+    for _, la := range rebuilt {
+      la.agent.Close()
+    }
+  }
+  for name, la := range live {
+    def, ok := cfg.Agent(name)
+    switch {
+    case !ok:
+      ev.Removed = append(ev.Removed, name)
+    case reflect.DeepEqual(def, la.def):
+      // unchanged: keep the running session
+    default:
+      ag, err := a.buildAgent(u.Name, def)
+      if err != nil {
+        closeAll()
+        return ReloadEvent{}, fmt.Errorf("reload agent %q: %w", def.Name, err)
+      }
+      rebuilt[name] = &liveAgent{agent: ag, def: def}
+      ev.Restarted = append(ev.Restarted, name)
+    }
+  }
+
+  var retired []*agent.Agent
+  a.mu.Lock()
+  if a.user == nil || a.user.Name != u.Name {
+    // someone switched user while we were reloading; theirs wins
+    a.mu.Unlock()
+    closeAll()
+    return ReloadEvent{}, nil
+  }
+  nu := *a.user
+  nu.Agents = cfg.Agents
+  a.user = &nu
+  for _, name := range ev.Removed {
+    if la := a.live[name]; la != nil && la == live[name] {
+      retired = append(retired, la.agent)
+      a.removeLocked(name)
+    }
+  }
+  for name, la := range rebuilt {
+    if cur := a.live[name]; cur != nil && cur == live[name] {
+      retired = append(retired, cur.agent)
+      a.live[name] = la
+    } else {
+      la.agent.Close() // unloaded or replaced meanwhile
+    }
+  }
+  if la := a.live[a.current]; la != nil {
+    a.user.DefaultAgent = la.agent
+  }
+  a.mu.Unlock()
+
+  var errs []error
+  for _, ag := range retired {
+    errs = append(errs, ag.Close())
+  }
+  ev.Shutdown = errors.Join(errs...)
+  sort.Strings(ev.Removed)
+  sort.Strings(ev.Restarted)
+  return ev, nil
+}
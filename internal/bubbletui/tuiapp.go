@@ -19,14 +19,18 @@ type chatMsg struct {
   content string // the text
 }
 
-// chatModel is our Bubble Tea model.
+// chatModel is our Bubble Tea model. Every live agent gets its own pane
+// (history); tab / shift+tab cycle the active one.
 type chatModel struct {
   ctx     context.Context
   App     app.App
   width   int
   height  int
-  history []chatMsg
+  panes   []string             // live agent names, in load order
+  active  int                  // index into panes
+  history map[string][]chatMsg // per-agent history
   input   textinput.Model
+  status  string // why the last pane switch failed, if it did
 }
 
 // NewChatModel constructs the model.
@@ -41,9 +45,51 @@ func NewChatModel(ctx context.Context, a app.App) chatModel {
   return chatModel{
     ctx:     ctx,
     App:     a,
-    history: make([]chatMsg, 0),
+    history: make(map[string][]chatMsg),
     input:   ti,
+  }.syncPanes()
+}
+
+// syncPanes rebuilds the pane list from the app's live agents, keeping the
+// active pane on the current agent.
+func (m chatModel) syncPanes() chatModel {
+  m.panes = m.panes[:0:0]
+  for _, a := range m.App.LiveAgents() {
+    m.panes = append(m.panes, a.Name)
+  }
+  m.active = 0
+  if a := m.App.Agent(); a != nil {
+    for i, name := range m.panes {
+      if name == a.Name {
+        m.active = i
+      }
+    }
+  }
+  return m
+}
+
+// current returns the agent name of the active pane ("" when none).
+func (m chatModel) current() string {
+  if len(m.panes) == 0 {
+    return ""
+  }
+  return m.panes[m.active]
+}
+
+// cycle moves the active pane by delta and makes its agent current. If the
+// agent can't be made current the pane stays put and the status line says
+// why.
+func (m chatModel) cycle(delta int) chatModel {
+  if len(m.panes) < 2 {
+    return m
+  }
+  next := (m.active + delta + len(m.panes)) % len(m.panes)
+  if err := m.App.SwitchAgent(m.panes[next]); err != nil {
+    m.status = fmt.Sprintf("switch to %s: %v", m.panes[next], err)
+    return m
   }
+  m.active, m.status = next, ""
+  return m
 }
 
 // Init tells Bubble Tea to start the textinput caret blinking.
@@ -66,23 +112,61 @@ func (m chatModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
     switch msg.String() {
     case "ctrl+c", "q":
       return m, tea.Quit
+    case "tab":
+      return m.cycle(1), nil
+    case "shift+tab":
+      return m.cycle(-1), nil
     case "enter":
       // send it
       userLine := strings.TrimSpace(m.input.Value())
       if userLine != "" {
-        // append user message
-        m.history = append(m.history, chatMsg{user: true, content: userLine})
+        // "@name message" addresses (and, if needed, loads) another agent
+        target := m.current()
+        if strings.HasPrefix(userLine, "@") {
+          parts := strings.SplitN(userLine[1:], " ", 2)
+          target = parts[0]
+          userLine = ""
+          if len(parts) == 2 {
+            userLine = strings.TrimSpace(parts[1])
+          }
+        }
         // clear input
         m.input.SetValue("")
+        if target == "" {
+          m.history[""] = append(m.history[""],
+            chatMsg{user: false, content: "[error] no agent loaded"},
+          )
+          return m, textinput.Blink
+        }
+        if userLine == "" {
+          return m, textinput.Blink
+        }
 
-        // call your core SendMessage → must return (reply string, err error)
-        reply, err := m.App.SendMessage(m.ctx, userLine)
+        // append user message
+        m.history[target] = append(m.history[target], chatMsg{user: true, content: userLine})
+
+        // call your core SendMessageTo → must return (reply string, err error)
+        reply, err := m.App.SendMessageTo(m.ctx, target, userLine)
         if err != nil {
-          m.history = append(m.history,
+          m.history[target] = append(m.history[target],
             chatMsg{user: false, content: fmt.Sprintf("[error] %v", err)},
           )
         } else {
-          m.history = append(m.history, chatMsg{user: false, content: reply})
+          m.history[target] = append(m.history[target], chatMsg{user: false, content: reply})
+        }
+        // the message may have loaded a new agent: show its pane
+        m = m.syncPanes()
+        found := false
+        for i, name := range m.panes {
+          if name == target {
+            m.active, found = i, true
+          }
+        }
+        if !found && err != nil {
+          // unknown agent: surface the error where the user is looking
+          m.history[m.current()] = append(m.history[m.current()],
+            chatMsg{user: false, content: fmt.Sprintf("[error] @%s: %v", target, err)},
+          )
         }
       }
       return m, textinput.Blink
@@ -103,27 +187,66 @@ func (m chatModel) View() string {
     Bold(true).
     Foreground(lipgloss.Color("36")).
     Render("🐬 Dolphin Chat")
-  b.WriteString(header + "\n\n")
+  b.WriteString(header + "\n")
+
+  // pane bar: one entry per live agent, active one highlighted
+  activeTab := lipgloss.NewStyle().Bold(true).Reverse(true).Padding(0, 1)
+  idleTab := lipgloss.NewStyle().Faint(true).Padding(0, 1)
+  var tabs []string
+  for i, name := range m.panes {
+    if i == m.active {
+      tabs = append(tabs, activeTab.Render(name))
+    } else {
+      tabs = append(tabs, idleTab.Render(name))
+    }
+  }
+  if len(tabs) == 0 {
+    tabs = append(tabs, idleTab.Render("(no agent loaded)"))
+  }
+  b.WriteString(lipgloss.JoinHorizontal(lipgloss.Top, tabs...) + "\n")
+
+  // what the active agent had to leave out
+  warn := lipgloss.NewStyle().Foreground(lipgloss.Color("9"))
+  for _, a := range m.App.LiveAgents() {
+    if a.Name != m.current() {
+      continue
+    }
+    for _, p := range a.BrokenToolpacks() {
+      b.WriteString(warn.Render(fmt.Sprintf("⚠ toolpack %s skipped: %v", p.Name, p.Err)) + "\n")
+    }
+    for _, s := range a.BrokenSubAgents() {
+      b.WriteString(warn.Render(fmt.Sprintf("⚠ sub-agent %s left out: %v", s.Name, s.Err)) + "\n")
+    }
+  }
+  b.WriteString("\n")
 
   youStyle := color.New(color.FgCyan, color.Bold).SprintFunc()
   agStyle := color.New(color.FgGreen, color.Bold).SprintFunc()
 
   // Print history.  You could add real scrolling logic here if
   // len(history) > available lines.
-  for _, cm := range m.history {
+  who := m.current()
+  if who == "" {
+    who = "Agent"
+  }
+  for _, cm := range m.history[m.current()] {
     if cm.user {
       b.WriteString(youStyle("You: ") + cm.content + "\n")
     } else {
-      b.WriteString(agStyle("Agent: ") + cm.content + "\n")
+      b.WriteString(agStyle(who+": ") + cm.content + "\n")
     }
   }
 
   // Leave a blank line, then the input box
   b.WriteString("\n" + m.input.View())
 
+  if m.status != "" {
+    b.WriteString("\n" + warn.Render("⚠ "+m.status))
+  }
+
   // hint
   b.WriteString("\n\n" + lipgloss.NewStyle().Faint(true).
-    Render("Enter to send • q or Ctrl+C to quit"))
+    Render("Enter to send • @agent msg to address another agent • Tab/Shift+Tab switch pane • q or Ctrl+C to quit"))
 
   return b.String()
 }
@@ -0,0 +1,224 @@
+// Package config owns the on-disk schema of configs/users/<name>.toml.
+// Everything that reads or rewrites a user file goes through LoadUser and
+// SaveUser so the file is always validated, versioned and migrated the
+// same way.
+package config
+
+import (
+  "errors"
+  "fmt"
+  "os"
+  "path/filepath"
+  "strings"
+
+  "github.com/BurntSushi/toml"
+)
+
+// CurrentSchemaVersion is the schema_version written by SaveUser.
+// Files with an older (or missing) version are migrated on load.
+const CurrentSchemaVersion = 1
+
+// Agent is one [[agents]] entry of a user file.
+type Agent struct {
+  Name    string   `toml:"name"`
+  Model   string   `toml:"model"`
+  Plugins []string `toml:"plugins"`
+  // SubAgents names other agents of the same user this one may delegate
+  // to; each is exposed to the model as an ask_<name>(task) tool.
+  SubAgents []string `toml:"sub_agents,omitempty"`
+  // NamespaceTools exposes every tool to the model as pack__tool instead
+  // of only those two of its toolpacks both define.
+  NamespaceTools bool `toml:"namespace_tools,omitempty"`
+  // ToolAliases renames tools for this agent: the name the model sees →
+  // "pack.tool" (or a bare tool name only one of its packs defines).
+  ToolAliases map[string]string `toml:"tool_aliases,omitempty"`
+  // ToolsInclude and ToolsExclude pick which tools of its toolpacks the
+  // model is offered: globs over "pack.tool" or the tool name, e.g.
+  // "reaper.get_*". Exclude wins; no include means every tool.
+  ToolsInclude []string `toml:"tools_include,omitempty"`
+  ToolsExclude []string `toml:"tools_exclude,omitempty"`
+  // ToolOverrides rewrite how a tool is described to the model, keyed
+  // by "pack.tool" (or the tool name).
+  ToolOverrides map[string]ToolOverride `toml:"tool_overrides,omitempty"`
+  // MaxTools, if set, sends only the tools most relevant to each message
+  // (this many, ranked by keyword), plus PinnedTools (same patterns as
+  // tools_include) which always go out.
+  MaxTools    int      `toml:"max_tools,omitempty"`
+  PinnedTools []string `toml:"pinned_tools,omitempty"`
+}
+
+// ToolOverride is one [agents.tool_overrides."pack.tool"] table.
+type ToolOverride struct {
+  Description string `toml:"description,omitempty"`
+  // Parameters replaces the descriptions of some parameters.
+  Parameters map[string]string `toml:"parameters,omitempty"`
+}
+
+// User mirrors the on‐disk structure of a configs/users/<name>.toml
+type User struct {
+  SchemaVersion int     `toml:"schema_version"`
+  Name          string  `toml:"name"`
+  DefaultAgent  string  `toml:"default_agent"`
+  Agents        []Agent `toml:"agents"`
+}
+
+// Agent returns the agent definition with the given name.
+func (u *User) Agent(name string) (Agent, bool) {
+  for _, a := range u.Agents {
+    if a.Name == name {
+      return a, true
+    }
+  }
+  return Agent{}, false
+}
+
+// LoadUser reads, migrates and validates the user file at path.
+//
+// Files written with an older schema are upgraded in place (the original is
+// kept next to it as <file>.bak) before validation, so the line numbers in
+// any returned *Error always match what is on disk.
+func LoadUser(path string) (*User, error) {
+  src, err := os.ReadFile(path)
+  if err != nil {
+    return nil, fmt.Errorf("config: read %s: %w", path, err)
+  }
+
+  // 1) find out which schema we're looking at
+  var raw map[string]interface{}
+  if _, err := toml.Decode(string(src), &raw); err != nil {
+    return nil, decodeError(path, err)
+  }
+  version, err := schemaVersion(raw)
+  if err != nil {
+    return nil, &Error{Path: path, Line: newLocator(src).key("", -1, "schema_version"), Msg: err.Error()}
+  }
+  if version > CurrentSchemaVersion {
+    return nil, &Error{
+      Path: path,
+      Line: newLocator(src).key("", -1, "schema_version"),
+      Msg: fmt.Sprintf("schema_version %d is newer than this build supports (%d)",
+        version, CurrentSchemaVersion),
+    }
+  }
+
+  // 2) upgrade old files and write them back before we validate
+  if version < CurrentSchemaVersion {
+    if src, err = migrate(path, src, raw, version); err != nil {
+      return nil, err
+    }
+  }
+
+  // 3) strict decode + validation against the current schema
+  var u User
+  md, err := toml.Decode(string(src), &u)
+  if err != nil {
+    return nil, decodeError(path, err)
+  }
+  loc := newLocator(src)
+  var errs Errors
+  for _, k := range md.Undecoded() {
+    errs = append(errs, &Error{
+      Path: path,
+      Line: loc.firstKey(k),
+      Msg:  fmt.Sprintf("unknown key %q", k.String()),
+    })
+  }
+  errs = append(errs, u.validate(path, loc)...)
+  if want := strings.TrimSuffix(filepath.Base(path), ".toml"); u.Name != "" && u.Name != want {
+    errs = append(errs, &Error{
+      Path: path,
+      Line: loc.key("", -1, "name"),
+      Msg:  fmt.Sprintf("name %q does not match file name %q", u.Name, want),
+    })
+  }
+  if len(errs) > 0 {
+    return nil, errs.sorted()
+  }
+  return &u, nil
+}
+
+// SaveUser validates u and atomically writes it to path (via a “.tmp” file +
+// rename), stamping the current schema_version.
+func SaveUser(path string, u *User) error {
+  if u == nil {
+    return fmt.Errorf("config: cannot save nil User")
+  }
+  u.SchemaVersion = CurrentSchemaVersion
+  if errs := u.validate(path, locator{}); len(errs) > 0 {
+    return errs
+  }
+  return writeAtomic(path, u)
+}
+
+// Validate checks u against the schema without touching the disk.
+func (u *User) Validate() error {
+  if errs := u.validate("", locator{}); len(errs) > 0 {
+    return errs
+  }
+  return nil
+}
+
+// writeAtomic encodes v as TOML into path via a temp file + rename.
+func writeAtomic(path string, v interface{}) error {
+  if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+    return fmt.Errorf("config: mkdir %s: %w", filepath.Dir(path), err)
+  }
+
+  tmpFile := path + ".tmp"
+  f, err := os.OpenFile(tmpFile, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
+  if err != nil {
+    return fmt.Errorf("config: open temp file %s: %w", tmpFile, err)
+  }
+  // ensure we clean up the temp on error
+  defer func() {
+    f.Close()
+    os.Remove(tmpFile)
+  }()
+
+  if err := toml.NewEncoder(f).Encode(v); err != nil {
+    return fmt.Errorf("config: encode to %s: %w", tmpFile, err)
+  }
+  if err := f.Sync(); err != nil {
+    return fmt.Errorf("config: sync %s: %w", tmpFile, err)
+  }
+  if err := f.Close(); err != nil {
+    return fmt.Errorf("config: close %s: %w", tmpFile, err)
+  }
+  if err := os.Rename(tmpFile, path); err != nil {
+    return fmt.Errorf("config: rename %s → %s: %w", tmpFile, path, err)
+  }
+  return nil
+}
+
+// decodeError turns a toml decode error into an *Error carrying its line.
+func decodeError(path string, err error) error {
+  var pe toml.ParseError
+  if errors.As(err, &pe) {
+    msg := pe.Message
+    if msg == "" {
+      // errors raised by the lexer only carry their text in Error(),
+      // after "toml: line N: " or "toml: line N (last key "k"): "
+      full := pe.Error()
+      _, msg, _ = strings.Cut(strings.TrimPrefix(full, "toml: "), ": ")
+      if pe.LastKey != "" {
+        _, msg, _ = strings.Cut(full, "): ")
+      }
+    }
+    return &Error{Path: path, Line: pe.Position.Line, Msg: msg}
+  }
+  return &Error{Path: path, Msg: strings.TrimPrefix(err.Error(), "toml: ")}
+}
+
+// schemaVersion reads schema_version out of a raw decoded file; a missing
+// key means the file predates versioning (version 0).
+func schemaVersion(raw map[string]interface{}) (int, error) {
+  v, ok := raw["schema_version"]
+  if !ok {
+    return 0, nil
+  }
+  n, ok := v.(int64)
+  if !ok || n < 0 {
+    return 0, fmt.Errorf("schema_version must be a non-negative integer")
+  }
+  return int(n), nil
+}
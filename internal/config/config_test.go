@@ -0,0 +1,132 @@
+package config
+
+import (
+  "errors"
+  "os"
+  "path/filepath"
+  "reflect"
+  "strings"
+  "testing"
+)
+
+// twV0 is configs/users/tw.toml as it was before schema_version: plugin
+// files listed by path under tool_path.
+const twV0 = `name           = "tw"
+default_agent  = "reaper_agent"
+
+[[agents]]
+name       = "reaper_agent"
+model      = "gpt-4.1-nano"
+tool_path  = [
+  "./plugins/reaper_tools.so",
+  "./plugins/reaper_project_manager/reaper_project_manager.so",
+]
+
+[[agents]]
+name       = "example_agent"
+model      = "gpt-4.1-nano"
+tool_path  = [
+  "./plugins/mytool.so",
+  "./plugins/weather.so",
+]
+`
+
+func writeUser(t *testing.T, name, src string) string {
+  t.Helper()
+  path := filepath.Join(t.TempDir(), name+".toml")
+  if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+    t.Fatal(err)
+  }
+  return path
+}
+
+func TestMigrateV0(t *testing.T) {
+  path := writeUser(t, "tw", twV0)
+  u, err := LoadUser(path)
+  if err != nil {
+    t.Fatal(err)
+  }
+  if u.SchemaVersion != CurrentSchemaVersion || u.DefaultAgent != "reaper_agent" {
+    t.Errorf("user %+v", u)
+  }
+  want := map[string][]string{
+    "reaper_agent":  {"reaper_tools", "reaper_project_manager"},
+    "example_agent": {"mytool", "weather"},
+  }
+  for name, plugins := range want {
+    a, ok := u.Agent(name)
+    if !ok || !reflect.DeepEqual(a.Plugins, plugins) {
+      t.Errorf("agent %s: %+v, want plugins %v", name, a, plugins)
+    }
+  }
+
+  if bak, err := os.ReadFile(path + ".bak"); err != nil || string(bak) != twV0 {
+    t.Errorf("backup: %q, %v", bak, err)
+  }
+  src, _ := os.ReadFile(path)
+  if s := string(src); !strings.HasPrefix(s, "schema_version = 1\n") || strings.Contains(s, "tool_path") {
+    t.Errorf("rewritten file:\n%s", s)
+  }
+
+  // a current file is left alone
+  os.Remove(path + ".bak")
+  if _, err := LoadUser(path); err != nil {
+    t.Fatal(err)
+  }
+  if _, err := os.Stat(path + ".bak"); !os.IsNotExist(err) {
+    t.Errorf("migrated twice: %v", err)
+  }
+}
+
+func TestErrorLines(t *testing.T) {
+  path := writeUser(t, "jj", `schema_version = 1
+name = "jj"
+
+[[agents]]
+  name = "a"
+  model = "gpt-4.1-nano"
+
+[[agents]]
+  name = "b"
+  modle = "gpt-4.1-nano"
+  plugins = ["./plugins/weather.so"]
+`)
+  _, err := LoadUser(path)
+  var errs Errors
+  if !errors.As(err, &errs) {
+    t.Fatalf("got %v, want Errors", err)
+  }
+  got := map[int]string{}
+  for _, e := range errs {
+    got[e.Line] = e.Msg
+  }
+  for line, msg := range map[int]string{
+    8:  "agents[1] (b): model is required",
+    10: `unknown key "agents.modle"`,
+    11: `plugin "./plugins/weather.so" must be a toolpack name`,
+  } {
+    if !strings.Contains(got[line], msg) {
+      t.Errorf("line %d: %q, want %q (all: %v)", line, got[line], msg, err)
+    }
+  }
+  if !strings.HasPrefix(err.Error(), path+":8: ") {
+    t.Errorf("not sorted by line or without the path: %v", err)
+  }
+}
+
+func TestLoadUserRejects(t *testing.T) {
+  for _, c := range []struct {
+    name, src, want string
+  }{
+    {"syntax", "schema_version = 1\nname = jj\n", ":2: expected value but found \"jj\" instead"},
+    {"newer schema", "name = \"jj\"\nschema_version = 99\n", ":2: schema_version 99 is newer"},
+    {"name mismatch", "schema_version = 1\nname = \"tw\"\n", `:2: name "tw" does not match file name "jj"`},
+  } {
+    t.Run(c.name, func(t *testing.T) {
+      _, err := LoadUser(writeUser(t, "jj", c.src))
+      if err == nil || !strings.Contains(err.Error(), c.want) {
+        t.Errorf("got %v, want %q", err, c.want)
+      }
+    })
+  }
+}
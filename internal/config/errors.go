@@ -0,0 +1,111 @@
+package config
+
+import (
+  "fmt"
+  "sort"
+  "strings"
+
+  "github.com/BurntSushi/toml"
+)
+
+// Error is a single problem found in a config file.
+type Error struct {
+  Path string
+  Line int // 1-based; 0 when the position is unknown
+  Msg  string
+}
+
+func (e *Error) Error() string {
+  switch {
+  case e.Path != "" && e.Line > 0:
+    return fmt.Sprintf("%s:%d: %s", e.Path, e.Line, e.Msg)
+  case e.Path != "":
+    return fmt.Sprintf("%s: %s", e.Path, e.Msg)
+  default:
+    return e.Msg
+  }
+}
+
+// Errors collects every problem found while validating a file, so users can
+// fix them all in one pass instead of one per restart.
+type Errors []*Error
+
+func (es Errors) Error() string {
+  lines := make([]string, len(es))
+  for i, e := range es {
+    lines[i] = e.Error()
+  }
+  return strings.Join(lines, "\n")
+}
+
+func (es Errors) sorted() Errors {
+  sort.SliceStable(es, func(i, j int) bool { return es[i].Line < es[j].Line })
+  return es
+}
+
+// locator maps schema positions back to source lines. The toml decoder
+// doesn't expose key positions, so we do a cheap line scan of our own; the
+// user files are flat enough that this is exact in practice.
+type locator struct {
+  lines []string
+}
+
+func newLocator(src []byte) locator {
+  return locator{lines: strings.Split(string(src), "\n")}
+}
+
+// key returns the line of key inside the index'th [[table]] (or the
+// top level when table is ""). An empty key returns the table header line.
+func (l locator) key(table string, index int, key string) int {
+  cur, n := "", -1
+  for i, raw := range l.lines {
+    line := strings.TrimSpace(raw)
+    if hdr, ok := tableHeader(line); ok {
+      cur = hdr
+      if cur == table {
+        n++
+        if key == "" && n == index {
+          return i + 1
+        }
+      }
+      continue
+    }
+    if cur != table || (table != "" && n != index) || key == "" {
+      continue
+    }
+    if k, _, ok := strings.Cut(line, "="); ok && strings.Trim(strings.TrimSpace(k), `"`) == key {
+      return i + 1
+    }
+  }
+  return 0
+}
+
+// firstKey returns the first line defining the dotted key k, in whichever
+// array entry it appears.
+func (l locator) firstKey(k toml.Key) int {
+  if len(k) == 1 {
+    return l.key("", -1, k[0])
+  }
+  table, key := strings.Join(k[:len(k)-1], "."), k[len(k)-1]
+  for i := 0; ; i++ {
+    hdr := l.key(table, i, "")
+    if hdr == 0 {
+      return 0
+    }
+    if line := l.key(table, i, key); line > 0 {
+      return line
+    }
+  }
+}
+
+// tableHeader recognises “[name]” and “[[name]]” lines.
+func tableHeader(line string) (string, bool) {
+  if !strings.HasPrefix(line, "[") {
+    return "", false
+  }
+  if i := strings.Index(line, "#"); i > 0 {
+    line = strings.TrimSpace(line[:i])
+  }
+  name := strings.Trim(line, "[]")
+  return strings.TrimSpace(name), true
+}
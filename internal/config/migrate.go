@@ -0,0 +1,114 @@
+package config
+
+import (
+  "bytes"
+  "fmt"
+  "os"
+  "path/filepath"
+  "strings"
+
+  "github.com/BurntSushi/toml"
+)
+
+// migrations[n] upgrades a raw decoded file from schema n to n+1.
+// Append a step here whenever CurrentSchemaVersion is bumped.
+var migrations = []func(raw map[string]interface{}) error{
+  migrateV0,
+}
+
+// migrate upgrades src from version to CurrentSchemaVersion, keeps the
+// original as path.bak and rewrites path. It returns the new contents.
+func migrate(path string, src []byte, raw map[string]interface{}, version int) ([]byte, error) {
+  for v := version; v < CurrentSchemaVersion; v++ {
+    if err := migrations[v](raw); err != nil {
+      return nil, &Error{Path: path, Msg: fmt.Sprintf("migrate schema %d → %d: %v", v, v+1, err)}
+    }
+  }
+  raw["schema_version"] = int64(CurrentSchemaVersion)
+
+  var buf bytes.Buffer
+  if err := toml.NewEncoder(&buf).Encode(orderedUser(raw)); err != nil {
+    return nil, fmt.Errorf("config: encode migrated %s: %w", path, err)
+  }
+  if err := os.WriteFile(path+".bak", src, 0o644); err != nil {
+    return nil, fmt.Errorf("config: back up %s: %w", path, err)
+  }
+  if err := os.WriteFile(path+".tmp", buf.Bytes(), 0o644); err != nil {
+    return nil, fmt.Errorf("config: write migrated %s: %w", path, err)
+  }
+  if err := os.Rename(path+".tmp", path); err != nil {
+    os.Remove(path + ".tmp")
+    return nil, fmt.Errorf("config: replace %s: %w", path, err)
+  }
+  return buf.Bytes(), nil
+}
+
+// migrateV0 handles files written before schema_version existed, where
+// agents listed plugin files via tool_path = ["./plugins/foo.so", …]
+// instead of toolpack names.
+func migrateV0(raw map[string]interface{}) error {
+  agents, _ := raw["agents"].([]map[string]interface{})
+  for _, a := range agents {
+    paths, ok := a["tool_path"].([]interface{})
+    if !ok {
+      continue
+    }
+    plugins, _ := a["plugins"].([]interface{})
+    for _, p := range paths {
+      s, ok := p.(string)
+      if !ok {
+        return fmt.Errorf("agent %v: tool_path entries must be strings", a["name"])
+      }
+      name := strings.TrimSuffix(filepath.Base(s), ".so")
+      if !containsValue(plugins, name) {
+        plugins = append(plugins, name)
+      }
+    }
+    a["plugins"] = plugins
+    delete(a, "tool_path")
+  }
+  return nil
+}
+
+// orderedUser re-encodes a raw file so the well-known keys come first
+// (toml.Encoder sorts map keys alphabetically otherwise). Unknown keys are
+// carried along untouched so validation can still report them.
+func orderedUser(raw map[string]interface{}) interface{} {
+  type doc struct {
+    SchemaVersion interface{}              `toml:"schema_version"`
+    Name          interface{}              `toml:"name,omitempty"`
+    DefaultAgent  interface{}              `toml:"default_agent,omitempty"`
+    Agents        []map[string]interface{} `toml:"agents"`
+  }
+  d := doc{
+    SchemaVersion: raw["schema_version"],
+    Name:          raw["name"],
+    DefaultAgent:  raw["default_agent"],
+  }
+  d.Agents, _ = raw["agents"].([]map[string]interface{})
+  if hasExtraKeys(raw) {
+    // rare: unknown top-level keys; fall back to the plain map
+    return raw
+  }
+  return d
+}
+
+func hasExtraKeys(raw map[string]interface{}) bool {
+  for k := range raw {
+    switch k {
+    case "schema_version", "name", "default_agent", "agents":
+    default:
+      return true
+    }
+  }
+  return false
+}
+
+func containsValue(list []interface{}, s string) bool {
+  for _, v := range list {
+    if v == s {
+      return true
+    }
+  }
+  return false
+}
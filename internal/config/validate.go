@@ -0,0 +1,135 @@
+package config
+
+import (
+  "fmt"
+  "path/filepath"
+  "strings"
+
+  "github.com/johnjallday/dolphin-tool-calling-agent/internal/semver"
+)
+
+// validate checks the semantic rules of the schema. loc may be empty, in
+// which case errors carry no line numbers.
+func (u *User) validate(path string, loc locator) Errors {
+  var errs Errors
+  add := func(line int, format string, args ...interface{}) {
+    errs = append(errs, &Error{Path: path, Line: line, Msg: fmt.Sprintf(format, args...)})
+  }
+
+  if strings.TrimSpace(u.Name) == "" {
+    add(loc.key("", -1, "name"), "name is required")
+  }
+
+  seen := make(map[string]int, len(u.Agents))
+  for i, a := range u.Agents {
+    hdr := loc.key("agents", i, "")
+    if strings.TrimSpace(a.Name) == "" {
+      add(hdr, "agents[%d]: name is required", i)
+    } else if prev, dup := seen[a.Name]; dup {
+      add(loc.key("agents", i, "name"), "agents[%d]: duplicate agent name %q (also agents[%d])", i, a.Name, prev)
+    } else {
+      seen[a.Name] = i
+    }
+    if strings.TrimSpace(a.Model) == "" {
+      add(hdr, "agents[%d] (%s): model is required", i, a.Name)
+    }
+    for _, p := range a.Plugins {
+      // "name" or "name@constraint", e.g. "weather@^0.2"
+      name, want, _ := strings.Cut(p, "@")
+      _, cerr := semver.ParseConstraint(want)
+      switch {
+      case strings.TrimSpace(name) == "":
+        add(loc.key("agents", i, "plugins"), "agents[%d] (%s): empty plugin name", i, a.Name)
+      case strings.ContainsAny(name, `/\`) || filepath.Ext(name) == ".so":
+        add(loc.key("agents", i, "plugins"),
+          "agents[%d] (%s): plugin %q must be a toolpack name, not a path", i, a.Name, p)
+      case cerr != nil:
+        add(loc.key("agents", i, "plugins"), "agents[%d] (%s): plugin %q: %v", i, a.Name, p, cerr)
+      }
+    }
+  }
+
+  for i, a := range u.Agents {
+    line := loc.key("agents", i, "tool_aliases")
+    if line == 0 {
+      line = loc.key("agents", i, "") // written as an [agents.tool_aliases] table
+    }
+    for alias, target := range a.ToolAliases {
+      switch {
+      case !validToolName(alias):
+        add(line, "agents[%d] (%s): tool alias %q may only use letters, digits, _ and - (at most 64)", i, a.Name, alias)
+      case strings.TrimSpace(target) == "" || strings.HasPrefix(target, ".") || strings.HasSuffix(target, "."):
+        add(line, "agents[%d] (%s): tool alias %q must name a tool as \"pack.tool\"", i, a.Name, alias)
+      }
+    }
+  }
+
+  for i, a := range u.Agents {
+    for _, key := range []string{"tools_include", "tools_exclude", "pinned_tools"} {
+      patterns := a.ToolsInclude
+      switch key {
+      case "tools_exclude":
+        patterns = a.ToolsExclude
+      case "pinned_tools":
+        patterns = a.PinnedTools
+      }
+      for _, p := range patterns {
+        if _, err := filepath.Match(p, ""); err != nil || strings.TrimSpace(p) == "" {
+          add(loc.key("agents", i, key), "agents[%d] (%s): %s: bad pattern %q", i, a.Name, key, p)
+        }
+      }
+    }
+    if a.MaxTools < 0 {
+      add(loc.key("agents", i, "max_tools"), "agents[%d] (%s): max_tools must be 0 (all tools) or more", i, a.Name)
+    }
+    line := loc.key("agents", i, "tool_overrides")
+    if line == 0 {
+      line = loc.key("agents", i, "")
+    }
+    for name, o := range a.ToolOverrides {
+      if strings.TrimSpace(name) == "" {
+        add(line, "agents[%d] (%s): tool_overrides: empty tool name", i, a.Name)
+      }
+      if o.Description == "" && len(o.Parameters) == 0 {
+        add(line, "agents[%d] (%s): tool_overrides.%q overrides nothing; set description or parameters", i, a.Name, name)
+      }
+    }
+  }
+
+  // sub_agents may point forward, so check them once every name is known
+  for i, a := range u.Agents {
+    dup := make(map[string]bool, len(a.SubAgents))
+    for _, sub := range a.SubAgents {
+      line := loc.key("agents", i, "sub_agents")
+      switch _, ok := seen[sub]; {
+      case sub == a.Name:
+        add(line, "agents[%d] (%s): an agent cannot be its own sub_agent", i, a.Name)
+      case !ok:
+        add(line, "agents[%d] (%s): sub_agent %q is not one of the defined agents", i, a.Name, sub)
+      case dup[sub]:
+        add(line, "agents[%d] (%s): sub_agent %q listed twice", i, a.Name, sub)
+      }
+      dup[sub] = true
+    }
+  }
+
+  if u.DefaultAgent != "" {
+    if _, ok := seen[u.DefaultAgent]; !ok {
+      add(loc.key("", -1, "default_agent"), "default_agent %q is not one of the defined agents", u.DefaultAgent)
+    }
+  }
+  return errs
+}
+
+// validToolName reports whether name is usable as an OpenAI function name.
+func validToolName(name string) bool {
+  if name == "" || len(name) > 64 {
+    return false
+  }
+  for _, r := range name {
+    if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-') {
+      return false
+    }
+  }
+  return true
+}
@@ -0,0 +1,432 @@
+// Package decltool builds tools from their toolpack.toml alone, for the
+// simple ones that shouldn't need a Go plugin build. Each tool has an
+// [tools.action] that is one of:
+//
+//	template = "Hello, {{.name}}!"                     # a static reply
+//	shell    = { command = ["df", "-h", "{{.path}}"] }  # run a program
+//	http     = { url = "https://wttr.in/{{urlquery .city}}?format=j1",
+//	             extract = "$.current_condition[0].temp_C" }
+//
+// Every string is a Go text/template over the call's arguments. Header
+// and env values may instead name a stored secret, "secret:name" (or
+// "Bearer secret:name"), resolved when the tool runs.
+package decltool
+
+import (
+  "bytes"
+  "context"
+  "encoding/json"
+  "fmt"
+  "io"
+  "net/http"
+  "os"
+  "os/exec"
+  "path/filepath"
+  "sort"
+  "strings"
+  "text/template"
+  "time"
+  "unicode/utf8"
+
+  "github.com/openai/openai-go"
+  "github.com/johnjallday/dolphin-tool-calling-agent/pkg/tools"
+)
+
+// Action is what a declarative tool does when called. Exactly one of
+// Template, Shell and HTTP is set.
+type Action struct {
+  Template string `toml:"template,omitempty"`
+  Shell    *Shell `toml:"shell,omitempty"`
+  HTTP     *HTTP  `toml:"http,omitempty"`
+  // Result formats what Shell or HTTP produced, available as {{.result}}
+  // next to the arguments; without it the output is returned as is.
+  Result string `toml:"result,omitempty"`
+  // Timeout bounds Shell and HTTP, e.g. "10s" (default 30s).
+  Timeout string `toml:"timeout,omitempty"`
+}
+
+// Shell runs a program. Command is the argv, each element templated on
+// its own; nothing goes through a shell, so arguments can't inject one.
+// It runs in the toolpack's folder (or Dir, relative to it), so a pack
+// can ship its own scripts: command = ["./backup.sh", "{{.target}}"].
+type Shell struct {
+  Command []string          `toml:"command"`
+  Dir     string            `toml:"dir,omitempty"`
+  Env     map[string]string `toml:"env,omitempty"`
+}
+
+// HTTP makes a request and returns the body, or the part of a JSON body
+// Extract (a JSONPath such as "$.items[0].name") selects.
+type HTTP struct {
+  Method  string            `toml:"method,omitempty"` // default GET
+  URL     string            `toml:"url"`
+  Headers map[string]string `toml:"headers,omitempty"`
+  Body    string            `toml:"body,omitempty"`
+  Extract string            `toml:"extract,omitempty"`
+}
+
+// Def is a declarative tool as its toolpack.toml describes it.
+type Def struct {
+  Name        string
+  Description string
+  Parameters  map[string]interface{}
+  Action      Action
+  // Dir is the toolpack's folder.
+  Dir string
+  // RequiresApproval overrides the default: shell commands and HTTP
+  // requests other than GET/HEAD need approval, templates don't.
+  RequiresApproval *bool
+}
+
+const (
+  defaultTimeout = 30 * time.Second
+  // maxOutput caps what a tool hands back to the model.
+  maxOutput = 64 << 10
+)
+
+// Build turns d into a tool. Templates and the JSONPath are parsed here,
+// so a broken toolpack.toml fails when it is loaded, not when called.
+func Build(d Def) (tools.Tool, error) {
+  t := tools.Tool{Name: d.Name, Description: d.Description, Parameters: openai.FunctionParameters(d.Parameters)}
+  if t.Parameters == nil {
+    t.Parameters = openai.FunctionParameters{"type": "object", "properties": map[string]interface{}{}}
+  }
+  run, approval, err := compile(d)
+  if err != nil {
+    return tools.Tool{}, fmt.Errorf("tool %s: %w", d.Name, err)
+  }
+  if d.RequiresApproval != nil {
+    approval = *d.RequiresApproval
+  }
+  t.RequiresApproval = approval
+  props, _ := d.Parameters["properties"].(map[string]interface{})
+  t.ExecContext = func(ctx context.Context, args map[string]interface{}) (string, error) {
+    // declared but omitted arguments render as "" rather than <no value>
+    data := make(map[string]interface{}, len(args)+len(props))
+    for k := range props {
+      data[k] = ""
+    }
+    for k, v := range args {
+      data[k] = v
+    }
+    return run(ctx, data)
+  }
+  return t, nil
+}
+
+// compile prepares d's action and says whether it needs approval by
+// default.
+func compile(d Def) (func(context.Context, map[string]interface{}) (string, error), bool, error) {
+  a := d.Action
+  n := 0
+  if a.Template != "" {
+    n++
+  }
+  if a.Shell != nil {
+    n++
+  }
+  if a.HTTP != nil {
+    n++
+  }
+  if n != 1 {
+    return nil, false, fmt.Errorf("action must have exactly one of template, shell and http")
+  }
+
+  timeout := defaultTimeout
+  if a.Timeout != "" {
+    var err error
+    if timeout, err = time.ParseDuration(a.Timeout); err != nil || timeout <= 0 {
+      return nil, false, fmt.Errorf("action.timeout %q: want a duration like \"10s\"", a.Timeout)
+    }
+  }
+  var result *template.Template
+  if a.Result != "" {
+    var err error
+    if result, err = parse("result", a.Result); err != nil {
+      return nil, false, err
+    }
+  }
+  finish := func(out string, data map[string]interface{}) (string, error) {
+    if result == nil {
+      return clip(out), nil
+    }
+    data["result"] = out
+    s, err := render(result, data)
+    return clip(s), err
+  }
+
+  switch {
+  case a.Template != "":
+    if a.Result != "" {
+      return nil, false, fmt.Errorf("action.result only applies to shell and http")
+    }
+    tmpl, err := parse("template", a.Template)
+    if err != nil {
+      return nil, false, err
+    }
+    return func(_ context.Context, data map[string]interface{}) (string, error) {
+      s, err := render(tmpl, data)
+      return clip(s), err
+    }, false, nil
+
+  case a.Shell != nil:
+    run, err := compileShell(a.Shell, d.Dir, timeout)
+    if err != nil {
+      return nil, false, err
+    }
+    return func(ctx context.Context, data map[string]interface{}) (string, error) {
+      out, err := run(ctx, data)
+      if err != nil {
+        return "", err
+      }
+      return finish(out, data)
+    }, true, nil
+
+  default:
+    run, err := compileHTTP(a.HTTP, timeout)
+    if err != nil {
+      return nil, false, err
+    }
+    method := strings.ToUpper(a.HTTP.Method)
+    return func(ctx context.Context, data map[string]interface{}) (string, error) {
+      out, err := run(ctx, data)
+      if err != nil {
+        return "", err
+      }
+      return finish(out, data)
+    }, method != "" && method != http.MethodGet && method != http.MethodHead, nil
+  }
+}
+
+func compileShell(s *Shell, dir string, timeout time.Duration) (func(context.Context, map[string]interface{}) (string, error), error) {
+  if len(s.Command) == 0 || s.Command[0] == "" {
+    return nil, fmt.Errorf("action.shell.command is empty")
+  }
+  argv := make([]*template.Template, len(s.Command))
+  for i, arg := range s.Command {
+    var err error
+    if argv[i], err = parse(fmt.Sprintf("command[%d]", i), arg); err != nil {
+      return nil, err
+    }
+  }
+  env := map[string]value{}
+  for k, v := range s.Env {
+    var err error
+    if env[k], err = parseValue("env."+k, v); err != nil {
+      return nil, err
+    }
+  }
+  return func(ctx context.Context, data map[string]interface{}) (string, error) {
+    args := make([]string, len(argv))
+    for i, t := range argv {
+      var err error
+      if args[i], err = render(t, data); err != nil {
+        return "", err
+      }
+    }
+    ctx, cancel := context.WithTimeout(ctx, timeout)
+    defer cancel()
+    cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+    cmd.Dir = dir
+    if filepath.IsAbs(s.Dir) {
+      cmd.Dir = s.Dir
+    } else if s.Dir != "" {
+      cmd.Dir = filepath.Join(dir, s.Dir)
+    }
+    cmd.Env = os.Environ()
+    for k, t := range env {
+      v, err := t.render(ctx, data)
+      if err != nil {
+        return "", err
+      }
+      cmd.Env = append(cmd.Env, k+"="+v)
+    }
+    var stdout, stderr bytes.Buffer
+    cmd.Stdout, cmd.Stderr = &stdout, &stderr
+    if err := cmd.Run(); err != nil {
+      if ctx.Err() != nil {
+        return "", fmt.Errorf("%s: timed out after %s", args[0], timeout)
+      }
+      msg := strings.TrimSpace(stderr.String())
+      if msg == "" {
+        msg = strings.TrimSpace(stdout.String())
+      }
+      return "", fmt.Errorf("%s: %v: %s", args[0], err, clip(msg))
+    }
+    return strings.TrimSpace(stdout.String()), nil
+  }, nil
+}
+
+func compileHTTP(h *HTTP, timeout time.Duration) (func(context.Context, map[string]interface{}) (string, error), error) {
+  if h.URL == "" {
+    return nil, fmt.Errorf("action.http.url is empty")
+  }
+  method := strings.ToUpper(h.Method)
+  if method == "" {
+    method = http.MethodGet
+  }
+  url, err := parse("url", h.URL)
+  if err != nil {
+    return nil, err
+  }
+  body, err := parse("body", h.Body)
+  if err != nil {
+    return nil, err
+  }
+  headers := map[string]value{}
+  for k, v := range h.Headers {
+    if headers[k], err = parseValue("headers."+k, v); err != nil {
+      return nil, err
+    }
+  }
+  var path jsonPath
+  if h.Extract != "" {
+    if path, err = parseJSONPath(h.Extract); err != nil {
+      return nil, fmt.Errorf("action.http.extract: %w", err)
+    }
+  }
+  client := &http.Client{Timeout: timeout}
+
+  return func(ctx context.Context, data map[string]interface{}) (string, error) {
+    u, err := render(url, data)
+    if err != nil {
+      return "", err
+    }
+    b, err := render(body, data)
+    if err != nil {
+      return "", err
+    }
+    req, err := http.NewRequestWithContext(ctx, method, u, strings.NewReader(b))
+    if err != nil {
+      return "", err
+    }
+    for k, t := range headers {
+      v, err := t.render(ctx, data)
+      if err != nil {
+        return "", err
+      }
+      req.Header.Set(k, v)
+    }
+    resp, err := client.Do(req)
+    if err != nil {
+      return "", err
+    }
+    defer resp.Body.Close()
+    raw, err := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+    if err != nil {
+      return "", err
+    }
+    if resp.StatusCode < 200 || resp.StatusCode > 299 {
+      return "", fmt.Errorf("%s %s: %s: %s", method, u, resp.Status, clip(strings.TrimSpace(string(raw))))
+    }
+    if path == nil {
+      return strings.TrimSpace(string(raw)), nil
+    }
+    var doc interface{}
+    if err := json.Unmarshal(raw, &doc); err != nil {
+      return "", fmt.Errorf("%s %s: extract needs a JSON response: %w", method, u, err)
+    }
+    return path.extract(doc)
+  }, nil
+}
+
+// funcs are available in every template, next to text/template's own
+// (urlquery, printf, js, …).
+var funcs = template.FuncMap{
+  "json": func(v interface{}) (string, error) {
+    b, err := json.Marshal(v)
+    return string(b), err
+  },
+  "default": func(def, v interface{}) interface{} {
+    if v == nil || v == "" {
+      return def
+    }
+    return v
+  },
+  "lower": strings.ToLower,
+  "upper": strings.ToUpper,
+  "trim":  strings.TrimSpace,
+}
+
+func parse(name, text string) (*template.Template, error) {
+  t, err := template.New(name).Funcs(funcs).Parse(text)
+  if err != nil {
+    return nil, fmt.Errorf("action: %w", err)
+  }
+  return t, nil
+}
+
+// value is a header or env value: a template, or a secret the TOML names
+// after optional literal text. The reference is read from the TOML as
+// written, never from rendered arguments, so a model can't ask for one.
+type value struct {
+  tmpl           *template.Template
+  prefix, secret string
+}
+
+// Secrets returns the names of the stored secrets a's header and env
+// values refer to, which its pack may read.
+func (a Action) Secrets() []string {
+  var texts []string
+  if a.Shell != nil {
+    for _, v := range a.Shell.Env {
+      texts = append(texts, v)
+    }
+  }
+  if a.HTTP != nil {
+    for _, v := range a.HTTP.Headers {
+      texts = append(texts, v)
+    }
+  }
+  var out []string
+  for _, text := range texts {
+    if v, err := parseValue("", text); err == nil && v.secret != "" {
+      out = append(out, v.secret)
+    }
+  }
+  sort.Strings(out)
+  return out
+}
+
+func parseValue(name, text string) (value, error) {
+  if i := strings.Index(text, tools.SecretPrefix); i >= 0 && !strings.Contains(text, "{{") {
+    ref := text[i+len(tools.SecretPrefix):]
+    if ref == "" || strings.ContainsAny(ref, " \t") {
+      return value{}, fmt.Errorf("action: %s: %q is not a secret:name reference", name, text)
+    }
+    return value{prefix: text[:i], secret: ref}, nil
+  }
+  t, err := parse(name, text)
+  return value{tmpl: t}, err
+}
+
+func (v value) render(ctx context.Context, data map[string]interface{}) (string, error) {
+  if v.tmpl != nil {
+    return render(v.tmpl, data)
+  }
+  s, err := tools.Secret(ctx, v.secret)
+  if err != nil {
+    return "", err
+  }
+  return v.prefix + s, nil
+}
+
+func render(t *template.Template, data map[string]interface{}) (string, error) {
+  var b strings.Builder
+  if err := t.Execute(&b, data); err != nil {
+    return "", err
+  }
+  return b.String(), nil
+}
+
+// clip keeps output within maxOutput, cutting between characters.
+func clip(s string) string {
+  if len(s) <= maxOutput {
+    return s
+  }
+  cut := maxOutput
+  for cut > 0 && !utf8.RuneStart(s[cut]) {
+    cut--
+  }
+  return s[:cut] + "\n…(truncated)"
+}
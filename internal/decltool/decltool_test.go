@@ -0,0 +1,111 @@
+package decltool
+
+import (
+  "context"
+  "net/http"
+  "net/http/httptest"
+  "strings"
+  "testing"
+  "unicode/utf8"
+
+  "github.com/johnjallday/dolphin-tool-calling-agent/pkg/tools"
+)
+
+type secretMap map[string]string
+
+func (m secretMap) Secret(name string) (string, error) { return m[name], nil }
+
+func build(t *testing.T, a Action) tools.Tool {
+  t.Helper()
+  tool, err := Build(Def{Name: "test", Action: a, Dir: t.TempDir(), Parameters: map[string]interface{}{
+    "type":       "object",
+    "properties": map[string]interface{}{"name": map[string]string{"type": "string"}, "city": map[string]string{"type": "string"}},
+  }})
+  if err != nil {
+    t.Fatal(err)
+  }
+  return tool
+}
+
+func TestTemplateAction(t *testing.T) {
+  tool := build(t, Action{Template: `Hello, {{default "stranger" .name}}!`})
+  if tool.RequiresApproval {
+    t.Error("a template needs approval")
+  }
+  for args, want := range map[string]string{`{"name":"Ann"}`: "Hello, Ann!", `{}`: "Hello, stranger!"} {
+    if got := tool.Reply(context.Background(), args); got != want {
+      t.Errorf("%s: got %q, want %q", args, got, want)
+    }
+  }
+  if _, err := Build(Def{Name: "bad", Action: Action{Template: "{{.name"}}); err == nil {
+    t.Error("a broken template was accepted")
+  }
+}
+
+func TestShellActionQuoting(t *testing.T) {
+  tool := build(t, Action{Shell: &Shell{
+    Command: []string{"printf", "%s|", "{{.name}}", "x{{.city}}"},
+  }})
+  if !tool.RequiresApproval {
+    t.Error("a shell command runs without approval")
+  }
+  // each argument stays one argv element; nothing reaches a shell
+  got := tool.Reply(context.Background(), `{"name":"a b; echo pwned","city":"$(id) 'q'"}`)
+  if want := "a b; echo pwned|x$(id) 'q'|"; got != want {
+    t.Errorf("got %q, want %q", got, want)
+  }
+}
+
+func TestShellActionSecretEnv(t *testing.T) {
+  tool := build(t, Action{Shell: &Shell{
+    Command: []string{"sh", "-c", `printf %s "$TOKEN"`},
+    Env:     map[string]string{"TOKEN": "Bearer secret:api"},
+  }})
+  ctx := tools.WithSecrets(context.Background(), secretMap{"api": "s3cret"})
+  if got := tool.Reply(ctx, `{}`); got != "Bearer s3cret" {
+    t.Errorf("got %q", got)
+  }
+  if got := (Action{Shell: &Shell{Env: map[string]string{"A": "secret:api", "B": "{{.x}}"}}}).Secrets(); strings.Join(got, ",") != "api" {
+    t.Errorf("secrets %v", got)
+  }
+}
+
+func TestHTTPAction(t *testing.T) {
+  srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+    if r.Header.Get("Authorization") != "Bearer s3cret" {
+      http.Error(w, "who are you", http.StatusUnauthorized)
+      return
+    }
+    w.Header().Set("Content-Type", "application/json")
+    w.Write([]byte(`{"city":"` + r.URL.Query().Get("q") + `","current":[{"temp_C":"21"}]}`))
+  }))
+  defer srv.Close()
+
+  tool := build(t, Action{
+    HTTP: &HTTP{
+      URL:     srv.URL + "/weather?q={{urlquery .city}}",
+      Headers: map[string]string{"Authorization": "Bearer secret:api"},
+      Extract: "$.current[0].temp_C",
+    },
+    Result: "{{.result}}°C in {{.city}}",
+  })
+  if tool.RequiresApproval {
+    t.Error("a GET needs approval")
+  }
+  ctx := tools.WithSecrets(context.Background(), secretMap{"api": "s3cret"})
+  if got := tool.Reply(ctx, `{"city":"São Paulo"}`); got != "21°C in São Paulo" {
+    t.Errorf("got %q", got)
+  }
+  got := tool.Reply(tools.WithSecrets(context.Background(), secretMap{"api": "wrong"}), `{"city":"x"}`)
+  if !strings.Contains(got, "401 Unauthorized: who are you") {
+    t.Errorf("failed request: %q", got)
+  }
+}
+
+func TestClipKeepsCharacters(t *testing.T) {
+  s := strings.Repeat("a", maxOutput-1) + "é and more"
+  got := clip(s)
+  if !utf8.ValidString(got) || !strings.HasSuffix(got, "a\n…(truncated)") {
+    t.Errorf("clipped to %q", got[len(got)-20:])
+  }
+}
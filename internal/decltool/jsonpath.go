@@ -0,0 +1,178 @@
+package decltool
+
+import (
+  "encoding/json"
+  "fmt"
+  "sort"
+  "strconv"
+  "strings"
+)
+
+// jsonPath is the subset of JSONPath HTTP actions need:
+//
+//	$.a.b        members
+//	$.list[0]    array index (negative counts from the end)
+//	$.list[*].id every element
+//	$['a b']     quoted member names
+//	$..name      every "name" anywhere below
+type jsonPath []step
+
+type step struct {
+  key   string
+  index int
+  kind  stepKind
+}
+
+type stepKind int
+
+const (
+  member stepKind = iota
+  index
+  wildcard
+  descend // ..key
+)
+
+func parseJSONPath(src string) (jsonPath, error) {
+  s := strings.TrimSpace(src)
+  if !strings.HasPrefix(s, "$") {
+    return nil, fmt.Errorf("%q: must start with $", src)
+  }
+  s = s[1:]
+  var p jsonPath
+  for s != "" {
+    switch {
+    case strings.HasPrefix(s, ".."):
+      name, rest := splitName(s[2:])
+      if name == "" {
+        return nil, fmt.Errorf("%q: .. must be followed by a name", src)
+      }
+      p, s = append(p, step{kind: descend, key: name}), rest
+    case strings.HasPrefix(s, ".*"):
+      p, s = append(p, step{kind: wildcard}), s[2:]
+    case s[0] == '.':
+      name, rest := splitName(s[1:])
+      if name == "" {
+        return nil, fmt.Errorf("%q: empty member name", src)
+      }
+      p, s = append(p, step{kind: member, key: name}), rest
+    case s[0] == '[':
+      end := strings.IndexByte(s, ']')
+      if end < 0 {
+        return nil, fmt.Errorf("%q: unclosed [", src)
+      }
+      in := strings.TrimSpace(s[1:end])
+      s = s[end+1:]
+      switch {
+      case in == "*":
+        p = append(p, step{kind: wildcard})
+      case len(in) >= 2 && (in[0] == '\'' || in[0] == '"') && in[len(in)-1] == in[0]:
+        p = append(p, step{kind: member, key: in[1 : len(in)-1]})
+      default:
+        n, err := strconv.Atoi(in)
+        if err != nil {
+          return nil, fmt.Errorf("%q: [%s] is not an index, * or a quoted name", src, in)
+        }
+        p = append(p, step{kind: index, index: n})
+      }
+    default:
+      return nil, fmt.Errorf("%q: unexpected %q", src, s[:1])
+    }
+  }
+  return p, nil
+}
+
+func splitName(s string) (string, string) {
+  i := strings.IndexAny(s, ".[")
+  if i < 0 {
+    return s, ""
+  }
+  return s[:i], s[i:]
+}
+
+// extract applies p to doc. A single string comes back bare, anything
+// else as JSON; a path with * or .. yields a JSON array of its matches.
+func (p jsonPath) extract(doc interface{}) (string, error) {
+  nodes := []interface{}{doc}
+  many := false
+  for _, st := range p {
+    // even when nothing is left to match
+    many = many || st.kind == wildcard || st.kind == descend
+    var next []interface{}
+    for _, n := range nodes {
+      switch st.kind {
+      case member:
+        if m, ok := n.(map[string]interface{}); ok {
+          if v, ok := m[st.key]; ok {
+            next = append(next, v)
+          }
+        }
+      case index:
+        if a, ok := n.([]interface{}); ok {
+          i := st.index
+          if i < 0 {
+            i += len(a)
+          }
+          if i >= 0 && i < len(a) {
+            next = append(next, a[i])
+          }
+        }
+      case wildcard:
+        switch v := n.(type) {
+        case []interface{}:
+          next = append(next, v...)
+        case map[string]interface{}:
+          for _, k := range sortedKeys(v) {
+            next = append(next, v[k])
+          }
+        }
+      case descend:
+        next = append(next, findAll(n, st.key)...)
+      }
+    }
+    nodes = next
+  }
+
+  if many {
+    if nodes == nil {
+      nodes = []interface{}{}
+    }
+    b, err := json.Marshal(nodes)
+    return string(b), err
+  }
+  if len(nodes) == 0 {
+    return "", fmt.Errorf("nothing in the response matches the extract path")
+  }
+  if s, ok := nodes[0].(string); ok {
+    return s, nil
+  }
+  b, err := json.Marshal(nodes[0])
+  return string(b), err
+}
+
+// findAll returns every value under key anywhere in n, depth first.
+func findAll(n interface{}, key string) []interface{} {
+  var out []interface{}
+  switch v := n.(type) {
+  case map[string]interface{}:
+    for _, k := range sortedKeys(v) {
+      if k == key {
+        out = append(out, v[k])
+      }
+      out = append(out, findAll(v[k], key)...)
+    }
+  case []interface{}:
+    for _, x := range v {
+      out = append(out, findAll(x, key)...)
+    }
+  }
+  return out
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+  keys := make([]string, 0, len(m))
+  for k := range m {
+    keys = append(keys, k)
+  }
+  sort.Strings(keys)
+  return keys
+}
@@ -0,0 +1,57 @@
+package decltool
+
+import (
+  "encoding/json"
+  "testing"
+)
+
+const doc = `{
+  "name": "Lisbon",
+  "current_condition": [{"temp_C": "21", "humidity": 60}],
+  "days": [{"max": 25, "hours": [{"t": 1}, {"t": 2}]}, {"max": 23}],
+  "a b": "spaced"
+}`
+
+func TestJSONPath(t *testing.T) {
+  var v interface{}
+  if err := json.Unmarshal([]byte(doc), &v); err != nil {
+    t.Fatal(err)
+  }
+  for _, c := range []struct {
+    path, want, err string
+  }{
+    {path: "$.name", want: "Lisbon"},
+    {path: "$.current_condition[0].temp_C", want: "21"},
+    {path: "$.current_condition[0].humidity", want: "60"},
+    {path: "$.days[-1].max", want: "23"},
+    {path: "$.days[*].max", want: "[25,23]"},
+    {path: "$.days[0].hours.*.t", want: "[1,2]"},
+    {path: "$['a b']", want: "spaced"},
+    {path: "$..t", want: "[1,2]"},
+    {path: "$.days[0]", want: `{"hours":[{"t":1},{"t":2}],"max":25}`},
+    {path: "$.missing", err: "nothing in the response matches the extract path"},
+    {path: "$.days[5].max", err: "nothing in the response matches the extract path"},
+    {path: "$.missing[*]", want: "[]"},
+  } {
+    p, err := parseJSONPath(c.path)
+    if err != nil {
+      t.Errorf("%s: %v", c.path, err)
+      continue
+    }
+    got, err := p.extract(v)
+    switch {
+    case c.err != "" && (err == nil || err.Error() != c.err):
+      t.Errorf("%s: got %q, %v, want error %q", c.path, got, err, c.err)
+    case c.err == "" && (err != nil || got != c.want):
+      t.Errorf("%s: got %q, %v, want %q", c.path, got, err, c.want)
+    }
+  }
+}
+
+func TestJSONPathSyntax(t *testing.T) {
+  for _, bad := range []string{"name", "$.", "$..", "$[0", "$[x]", "$x"} {
+    if _, err := parseJSONPath(bad); err == nil {
+      t.Errorf("%q parsed", bad)
+    }
+  }
+}
@@ -1,9 +1,14 @@
 package gui
 
 import (
+  "fmt"
+  "strings"
+
   "fyne.io/fyne/v2"
   "fyne.io/fyne/v2/container"
   "fyne.io/fyne/v2/widget"
+
+  "github.com/johnjallday/dolphin-tool-calling-agent/internal/toolmanager"
 )
 
 type AddAgentForm struct {
@@ -12,20 +17,30 @@ type AddAgentForm struct {
   NameEntry  *widget.Entry
   ModelEntry *widget.Entry
   Tools      *widget.CheckGroup
+  Details    *widget.Label // describes the selected toolpacks
 
+  packs    map[string]*toolmanager.Manifest
   onSubmit func(name, model string, tools []string)
 }
 
 func NewAddAgentForm(
-  toolpacks []string,
+  toolpacks []*toolmanager.Manifest,
   onSubmit func(name, model string, tools []string),
 ) *AddAgentForm {
   f := &AddAgentForm{
     NameEntry:  widget.NewEntry(),
     ModelEntry: widget.NewEntry(),
-    Tools:      widget.NewCheckGroup(toolpacks, nil),
+    Details:    widget.NewLabel(""),
+    packs:      map[string]*toolmanager.Manifest{},
     onSubmit:   onSubmit,
   }
+  var names []string
+  for _, m := range toolpacks {
+    names = append(names, m.Name)
+    f.packs[m.Name] = m
+  }
+  f.Tools = widget.NewCheckGroup(names, f.showDetails)
+  f.Details.Wrapping = fyne.TextWrapWord
   f.NameEntry.SetPlaceHolder("Agent name")
   f.ModelEntry.SetPlaceHolder("Model (eg “gpt-4”)")
   f.ExtendBaseWidget(f)
@@ -44,6 +59,7 @@ func (f *AddAgentForm) CreateRenderer() fyne.WidgetRenderer {
     &widget.FormItem{Text: "Name", Widget: f.NameEntry},
     &widget.FormItem{Text: "Model", Widget: f.ModelEntry},
     &widget.FormItem{Text: "Toolpacks", Widget: toolsScroll},
+    &widget.FormItem{Text: "", Widget: f.Details},
   )
 
   btn := widget.NewButton("Create Agent", func() {
@@ -57,3 +73,20 @@ func (f *AddAgentForm) CreateRenderer() fyne.WidgetRenderer {
   )
   return widget.NewSimpleRenderer(box)
 }
+
+// showDetails lists what each selected toolpack does, from its manifest.
+func (f *AddAgentForm) showDetails(selected []string) {
+  var b strings.Builder
+  for _, name := range selected {
+    m := f.packs[name]
+    if m == nil {
+      continue
+    }
+    desc := m.Description
+    if desc == "" {
+      desc = "(no description)"
+    }
+    fmt.Fprintf(&b, "%s: %s (%d tools)\n", name, desc, len(m.Tools))
+  }
+  f.Details.SetText(strings.TrimSpace(b.String()))
+}
@@ -51,7 +51,7 @@ func (cw *MainWindow) buildAgentPane() fyne.CanvasObject {
 
   // AddAgentForm (your existing form)
   form := NewAddAgentForm(
-    cw.core.Toolpacks(),
+    cw.core.LocalToolpacks(),
     func(name, model string, tools []string) {
       if name == "" || model == "" {
         dialog.ShowInformation("Missing fields",
@@ -0,0 +1,29 @@
+package gui
+
+import (
+  "context"
+  "fmt"
+
+  "fyne.io/fyne/v2"
+  "fyne.io/fyne/v2/dialog"
+
+  "github.com/johnjallday/dolphin-tool-calling-agent/internal/app"
+)
+
+// approve shows a confirm dialog for a tool that needs approval and blocks
+// the (background) chat turn until the user answers or the turn is
+// cancelled.
+func (cw *MainWindow) approve(ctx context.Context, req app.ApprovalRequest) bool {
+  answer := make(chan bool, 1)
+  fyne.Do(func() {
+    msg := fmt.Sprintf("%s wants to run %s\n\n%s\n\nArguments: %s",
+      req.Agent, req.Tool, req.Description, req.Args)
+    dialog.ShowConfirm("Allow tool?", msg, func(ok bool) { answer <- ok }, cw.wnd)
+  })
+  select {
+  case ok := <-answer:
+    return ok
+  case <-ctx.Done():
+    return false
+  }
+}
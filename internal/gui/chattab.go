@@ -1,63 +1,153 @@
-
 package gui
 
 import (
   "context"
+  "errors"
   "fmt"
 
   "fyne.io/fyne/v2"
   "fyne.io/fyne/v2/container"
+  "fyne.io/fyne/v2/dialog"
   "fyne.io/fyne/v2/widget"
+
+  "github.com/johnjallday/dolphin-tool-calling-agent/internal/agent"
 )
 
+// chatPane is the conversation view of one live agent.
+type chatPane struct {
+  agentName     string
+  tab           *container.TabItem
+  historyBox    *fyne.Container
+  historyScroll *container.Scroll
+  inputEntry    *widget.Entry
+}
 
 func (cw *MainWindow) makeChatTab() *container.TabItem {
-  cw.inputEntry = widget.NewEntry()
-  cw.inputEntry.SetPlaceHolder("Type your message…")
-  cw.inputEntry.OnSubmitted = func(_ string) { cw.sendMessage() }
+  cw.chatPanes = make(map[string]*chatPane)
+  cw.chatTabs = container.NewAppTabs()
+  cw.chatTabs.SetTabLocation(container.TabLocationTop)
+  cw.chatTabs.OnSelected = func(ti *container.TabItem) {
+    // focusing a pane makes its agent current (it is already live, so
+    // this is cheap and keeps the other conversations running)
+    for name, p := range cw.chatPanes {
+      if p.tab == ti {
+        if a := cw.core.Agent(); a == nil || a.Name != name {
+          _ = cw.core.SwitchAgent(name)
+          cw.refreshUserStatus()
+        }
+        return
+      }
+    }
+  }
+
+  cw.chatEmpty = widget.NewLabelWithStyle(
+    "No agent loaded — switch to one in the Agent tab.",
+    fyne.TextAlignCenter, fyne.TextStyle{Italic: true},
+  )
+  return container.NewTabItem("Chat", container.NewStack(cw.chatEmpty, cw.chatTabs))
+}
 
-  sendBtn := widget.NewButton("Send", cw.sendMessage)
-  bottom := container.NewBorder(nil, nil, nil, sendBtn, cw.inputEntry)
+func (cw *MainWindow) newChatPane(name string) *chatPane {
+  p := &chatPane{agentName: name}
+  p.inputEntry = widget.NewEntry()
+  p.inputEntry.SetPlaceHolder(fmt.Sprintf("Message %s…", name))
+  p.inputEntry.OnSubmitted = func(_ string) { cw.sendMessage(p) }
+
+  sendBtn := widget.NewButton("Send", func() { cw.sendMessage(p) })
+  closeBtn := widget.NewButton("Close", func() {
+    // the agent is gone even if one of its toolpacks failed to shut down
+    err := cw.core.CloseAgent(name)
+    cw.RefreshAll()
+    if err != nil {
+      dialog.ShowError(err, cw.wnd)
+    }
+  })
+  bottom := container.NewBorder(nil, nil, nil, container.NewHBox(sendBtn, closeBtn), p.inputEntry)
+
+  p.historyBox = container.NewVBox()
+  p.historyScroll = container.NewVScroll(p.historyBox)
+
+  p.tab = container.NewTabItem(name, container.NewBorder(nil, bottom, nil, nil, p.historyScroll))
+  return p
+}
 
-  cw.historyBox = container.NewVBox()
-  cw.historyScroll = container.NewVScroll(cw.historyBox)
+// refreshChatPanes keeps one tab per live agent, preserving the history of
+// agents that stay live, and selects the current agent's tab.
+func (cw *MainWindow) refreshChatPanes() {
+  live := map[string]bool{}
+  for _, a := range cw.core.LiveAgents() {
+    live[a.Name] = true
+    if _, ok := cw.chatPanes[a.Name]; !ok {
+      p := cw.newChatPane(a.Name)
+      cw.chatPanes[a.Name] = p
+      cw.chatTabs.Append(p.tab)
+    }
+  }
+  for name, p := range cw.chatPanes {
+    if !live[name] {
+      cw.chatTabs.Remove(p.tab)
+      delete(cw.chatPanes, name)
+    }
+  }
 
-  pane := container.NewBorder(nil, bottom, nil, nil, cw.historyScroll)
-  return container.NewTabItem("Chat", pane)
+  if len(cw.chatPanes) == 0 {
+    cw.chatTabs.Hide()
+    cw.chatEmpty.Show()
+    return
+  }
+  cw.chatEmpty.Hide()
+  cw.chatTabs.Show()
+  if a := cw.core.Agent(); a != nil {
+    if p, ok := cw.chatPanes[a.Name]; ok && cw.chatTabs.Selected() != p.tab {
+      cw.chatTabs.Select(p.tab)
+    }
+  }
+  for _, p := range cw.chatPanes {
+    p.historyBox.Refresh()
+    p.historyScroll.ScrollToBottom()
+  }
 }
 
-func (cw *MainWindow) sendMessage() {
-  txt := cw.inputEntry.Text
+func (cw *MainWindow) sendMessage(p *chatPane) {
+  txt := p.inputEntry.Text
   if txt == "" {
     return
   }
 
   // This is already on Fyne’s UI thread:
-  cw.appendMessage("You", txt)
-  cw.inputEntry.SetText("")
-  cw.wnd.Canvas().Focus(cw.inputEntry)
+  cw.appendMessage(p, "You", txt)
+  p.inputEntry.SetText("")
+  cw.wnd.Canvas().Focus(p.inputEntry)
 
-  // Do the network/agent call in a goroutine
+  // Do the network/agent call in a goroutine; address this pane's agent
+  // explicitly so switching tabs meanwhile doesn't reroute the reply
   go func(userText string) {
-    reply, err := cw.core.SendMessage(context.Background(), userText)
+    reply, err := cw.core.SendMessageTo(context.Background(), p.agentName, userText)
+    if errors.Is(err, agent.ErrCancelled) || errors.Is(err, agent.ErrClosed) {
+      // the agent was unloaded or reloaded while this turn was in flight
+      fyne.Do(func() {
+        cw.appendMessage(p, "System", "request cancelled (agent unloaded)")
+      })
+      return
+    }
     if err != nil {
       // schedule error on the UI thread
       fyne.Do(func() {
-        cw.appendMessage("Error", err.Error())
+        cw.appendMessage(p, "Error", err.Error())
       })
       return
     }
     // schedule agent reply on the UI thread
     fyne.Do(func() {
-      cw.appendMessage("Agent", reply)
+      cw.appendMessage(p, p.agentName, reply)
     })
   }(txt)
 }
 
 // appendMessage _must_ run on the UI thread.
-func (cw *MainWindow) appendMessage(who, msg string) {
+func (cw *MainWindow) appendMessage(p *chatPane, who, msg string) {
   lbl := widget.NewLabel(fmt.Sprintf("%s: %s", who, msg))
-  cw.historyBox.Add(lbl)
-  cw.historyBox.Refresh()
-  cw.historyScroll.ScrollToBottom()
+  p.historyBox.Add(lbl)
+  p.historyBox.Refresh()
+  p.historyScroll.ScrollToBottom()
 }
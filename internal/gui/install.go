@@ -0,0 +1,137 @@
+package gui
+
+import (
+  "fmt"
+  "strings"
+
+  "fyne.io/fyne/v2"
+  "fyne.io/fyne/v2/dialog"
+  "fyne.io/fyne/v2/widget"
+
+  "github.com/johnjallday/dolphin-tool-calling-agent/internal/toolmanager"
+)
+
+// toolpackOp runs a (network-bound) toolpack change off the UI thread,
+// then reports the outcome and refreshes both toolpack lists.
+func (cw *MainWindow) toolpackOp(title string, fn func() (string, error)) {
+  progress := dialog.NewCustomWithoutButtons(title, widget.NewProgressBarInfinite(), cw.wnd)
+  progress.Show()
+  go func() {
+    msg, err := fn()
+    fyne.Do(func() {
+      progress.Hide()
+      if err != nil {
+        dialog.ShowError(err, cw.wnd)
+      } else {
+        dialog.ShowInformation(title, msg, cw.wnd)
+      }
+      cw.refreshToolpacksList()
+      cw.refreshRemoteToolpacksList()
+    })
+  }()
+}
+
+// installToolpack installs spec ("catalog/name").
+func (cw *MainWindow) installToolpack(spec string) {
+  cw.toolpackOp("Installing "+spec, func() (string, error) {
+    es, err := cw.core.InstallToolpack(spec)
+    if err != nil {
+      return "", err
+    }
+    fyne.Do(func() {
+      for _, e := range es {
+        delete(cw.updates, e.Name)
+      }
+    })
+    return installedAllMsg("Installed", es), nil
+  })
+}
+
+func (cw *MainWindow) updateToolpack(name string) {
+  cw.toolpackOp("Updating "+name, func() (string, error) {
+    es, err := cw.core.UpdateToolpack(name)
+    if err != nil {
+      return "", err
+    }
+    fyne.Do(func() {
+      for _, e := range es {
+        delete(cw.updates, e.Name)
+      }
+    })
+    return installedAllMsg("Updated", es), nil
+  })
+}
+
+func (cw *MainWindow) rollbackToolpack(name string) {
+  cw.toolpackOp("Rolling back "+name, func() (string, error) {
+    e, err := cw.core.RollbackToolpack(name)
+    if err != nil {
+      return "", err
+    }
+    return installedMsg("Rolled back", e), nil
+  })
+}
+
+func (cw *MainWindow) uninstallToolpack(name string) {
+  dialog.ShowConfirm("Uninstall "+name,
+    fmt.Sprintf("Remove toolpack %s? Agents that use it will report it as missing.", name),
+    func(ok bool) {
+      if !ok {
+        return
+      }
+      cw.toolpackOp("Uninstalling "+name, func() (string, error) {
+        if err := cw.core.UninstallToolpack(name); err != nil {
+          return "", err
+        }
+        return "Uninstalled " + name, nil
+      })
+    }, cw.wnd)
+}
+
+// checkToolpackUpdates asks the catalog for newer releases; the Remote
+// tab then offers Update on those packs.
+func (cw *MainWindow) checkToolpackUpdates() {
+  cw.toolpackOp("Checking for updates", func() (string, error) {
+    ups, err := cw.core.ToolpackUpdates()
+    if err != nil {
+      return "", err
+    }
+    found := map[string]string{}
+    msg := ""
+    for _, u := range ups {
+      switch {
+      case u.Err != nil:
+        msg += fmt.Sprintf("%s: %v\n", u.Name, u.Err)
+      case u.Available():
+        found[u.Name] = u.Latest
+        msg += fmt.Sprintf("%s: %s → %s\n", u.Name, u.Installed, u.Latest)
+      }
+    }
+    fyne.Do(func() { cw.updates = found })
+    if msg == "" {
+      msg = "All toolpacks are up to date"
+    }
+    return msg, nil
+  })
+}
+
+// installedAllMsg describes an install: the requested pack, then any
+// dependencies that came along.
+func installedAllMsg(what string, es []toolmanager.LockEntry) string {
+  var msgs []string
+  for i := range es {
+    if i > 0 {
+      what = "Installed dependency"
+    }
+    msgs = append(msgs, installedMsg(what, &es[i]))
+  }
+  return strings.Join(msgs, "\n")
+}
+
+func installedMsg(what string, e *toolmanager.LockEntry) string {
+  msg := fmt.Sprintf("%s %s %s", what, e.Name, e.Version)
+  if e.Previous != "" {
+    msg += fmt.Sprintf("\n(replaced %s; restart to use it in running agents)", e.Previous)
+  }
+  return msg
+}
@@ -1,8 +1,12 @@
 package gui
 
 import (
+  "context"
+  "fmt"
+
   "fyne.io/fyne/v2"
   "fyne.io/fyne/v2/container"
+  "fyne.io/fyne/v2/dialog"
   "fyne.io/fyne/v2/layout"
   "fyne.io/fyne/v2/widget"
 
@@ -22,11 +26,12 @@ type MainWindow struct {
   toolsTab *container.TabItem
   agentTab *container.TabItem
   userTab  *container.TabItem
+  secretsTab *container.TabItem
 
-  // chat widgets
-  historyBox    *fyne.Container
-  historyScroll *container.Scroll
-  inputEntry    *widget.Entry
+  // chat widgets: one pane per live agent
+  chatTabs  *container.AppTabs
+  chatPanes map[string]*chatPane
+  chatEmpty *widget.Label
 
   // top bar
   statusLabel *widget.Label
@@ -34,7 +39,10 @@ type MainWindow struct {
   // tools widgets
   toolsList     *fyne.Container
   toolpacksList *fyne.Container
+  toolpackSearch *widget.Entry
 	remotetoolpacksList *fyne.Container
+  updates map[string]string // pack → newer release, from "Check for updates"
+  healthGen int // bumped per tools refresh, so late health checks are dropped
 
   // agent widgets
   agentList *fyne.Container
@@ -45,6 +53,11 @@ type MainWindow struct {
   userNameEntry     *widget.Entry
   userDefaultSelect *widget.Select
 	userList *fyne.Container
+
+  // secrets widgets
+  secretsList   *fyne.Container
+  secretsLocked bool // the last listing found the file locked
+  passphraseSet bool // the core has a passphrase from us (see usePassphrase)
 }
 
 
@@ -58,10 +71,11 @@ func NewMainWindow(fy fyne.App, core app.App) *MainWindow {
   cw.toolsTab = cw.makeToolsTab()
   cw.agentTab = cw.makeAgentTab()
   cw.userTab = cw.makeUserTab()
+  cw.secretsTab = cw.makeSecretsTab()
 
   // put them into AppTabs
   cw.mainTabs = container.NewAppTabs(
-    cw.chatTab, cw.toolsTab, cw.agentTab, cw.userTab,
+    cw.chatTab, cw.toolsTab, cw.agentTab, cw.userTab, cw.secretsTab,
   )
   cw.mainTabs.SetTabLocation(container.TabLocationTop)
 
@@ -77,9 +91,37 @@ func NewMainWindow(fy fyne.App, core app.App) *MainWindow {
   // initial fill
   cw.RefreshAll()
 
+  // hot-reload hand edits to the config files
+  go cw.watchConfig()
+
+  // ask before running tools that change things
+  core.SetApprover(cw.approve)
+
   return cw
 }
 
+// watchConfig runs the core config watcher and redraws on every reload.
+func (cw *MainWindow) watchConfig() {
+  err := cw.core.Watch(context.Background(), func(ev app.ReloadEvent) {
+    fyne.Do(func() {
+      if ev.Err != nil {
+        dialog.ShowError(fmt.Errorf("config reload failed, keeping current state:\n%w", ev.Err), cw.wnd)
+        return
+      }
+      cw.RefreshAll()
+      if len(ev.Catalogs) > 0 {
+        cw.refreshRemoteToolpacksList()
+      }
+      if ev.Shutdown != nil {
+        dialog.ShowError(ev.Shutdown, cw.wnd)
+      }
+    })
+  })
+  if err != nil {
+    fyne.Do(func() { dialog.ShowError(err, cw.wnd) })
+  }
+}
+
 // ShowAndRun pops up the window.
 func (cw *MainWindow) ShowAndRun() {
   cw.wnd.ShowAndRun()
@@ -110,9 +152,8 @@ func (cw *MainWindow) RefreshAll() {
   // 1) status bar
   cw.refreshUserStatus()
 
-  // 2) chat history (in case underlying messages have changed)
-  cw.historyBox.Refresh()
-  cw.historyScroll.ScrollToBottom()
+  // 2) chat panes (agents may have been loaded or unloaded)
+  cw.refreshChatPanes()
 
   // 3) tools
   cw.refreshCurrentToolsList()
@@ -126,5 +167,7 @@ func (cw *MainWindow) RefreshAll() {
   cw.userTab.Content = cw.buildUserPane()
   cw.userTab.Content.Refresh()
 
+  // 6) secrets
+  cw.refreshSecretsList()
 }
 
@@ -0,0 +1,220 @@
+package gui
+
+import (
+  "errors"
+  "fmt"
+  "os"
+
+  "fyne.io/fyne/v2"
+  "fyne.io/fyne/v2/container"
+  "fyne.io/fyne/v2/dialog"
+  "fyne.io/fyne/v2/widget"
+
+  "github.com/johnjallday/dolphin-tool-calling-agent/internal/secrets"
+)
+
+
+// ─────────────────────────────────────────────────────────────────────────────
+// SECRETS TAB
+// ─────────────────────────────────────────────────────────────────────────────
+
+// The core asks for the secrets file's passphrase from whatever goroutine
+// first needs a secret, often the UI one, so the GUI never lets it block
+// on a dialog: it asks up front and hands the core a prompt that answers
+// with what was entered.
+
+func (cw *MainWindow) makeSecretsTab() *container.TabItem {
+  cw.secretsList = container.NewVBox()
+
+  name := widget.NewEntry()
+  name.SetPlaceHolder("Name, e.g. weather_api_key")
+  value := widget.NewPasswordEntry()
+  value.SetPlaceHolder("Value")
+  form := widget.NewForm(
+    widget.NewFormItem("Name", name),
+    widget.NewFormItem("Value", value),
+  )
+  form.SubmitText = "Save"
+  form.OnSubmit = func() {
+    n, v := name.Text, value.Text
+    cw.withSecrets(func() {
+      cw.secretsJob(func() error { return cw.core.SetSecret(n, v) }, func() {
+        name.SetText("")
+        value.SetText("")
+      })
+    })
+  }
+
+  hint := widget.NewLabel("Settings and app_setting.toml refer to these as secret:<name>; " +
+    "agents pick up changes the next time a tool asks.")
+  hint.Wrapping = fyne.TextWrapWord
+  top := container.NewVBox(form, hint, widget.NewSeparator())
+  return container.NewTabItem("Secrets",
+    container.NewBorder(top, nil, nil, nil, container.NewVScroll(cw.secretsList)))
+}
+
+// refreshSecretsList lists the stored secret names, or offers to unlock
+// the file.
+func (cw *MainWindow) refreshSecretsList() {
+  go func() {
+    names, err := cw.core.SecretNames()
+    fyne.Do(func() { cw.showSecrets(names, err) })
+  }()
+}
+
+func (cw *MainWindow) showSecrets(names []string, err error) {
+  cw.secretsList.Objects = nil
+  cw.secretsLocked = errors.Is(err, secrets.ErrLocked)
+  switch {
+  case cw.secretsLocked:
+    cw.secretsList.Add(widget.NewLabel("🔒 The secrets file is locked."))
+    cw.secretsList.Add(widget.NewButton("Unlock…", func() {
+      cw.unlockSecrets(cw.refreshSecretsList, nil)
+    }))
+  case err != nil:
+    lbl := widget.NewLabel(fmt.Sprintf("⚠ %v", err))
+    lbl.Wrapping = fyne.TextWrapWord
+    lbl.Importance = widget.WarningImportance
+    cw.secretsList.Add(lbl)
+  case len(names) == 0:
+    cw.secretsList.Add(widget.NewLabelWithStyle(
+      "No secrets stored", fyne.TextAlignCenter,
+      fyne.TextStyle{Italic: true}))
+  }
+  for _, n := range names {
+    del := widget.NewButton("Delete", func() {
+      dialog.ShowConfirm("Delete secret?", "Delete "+n+"?", func(ok bool) {
+        if ok {
+          cw.secretsJob(func() error { return cw.core.DeleteSecret(n) }, nil)
+        }
+      }, cw.wnd)
+    })
+    cw.secretsList.Add(container.NewBorder(nil, nil, nil, del, widget.NewLabel(n)))
+  }
+  cw.secretsList.Refresh()
+}
+
+// secretsJob runs f off the UI thread (unlocking and sealing the file is
+// slow on purpose), then done and a refresh, or shows f's error.
+func (cw *MainWindow) secretsJob(f func() error, done func()) {
+  go func() {
+    err := f()
+    fyne.Do(func() {
+      if err != nil {
+        dialog.ShowError(err, cw.wnd)
+      } else if done != nil {
+        done()
+      }
+      cw.refreshSecretsList()
+    })
+  }()
+}
+
+// withSecrets runs f once the core has a passphrase for the secrets file:
+// the one entered earlier or in the environment, else one asked for now
+// (a new one if there is no file yet).
+func (cw *MainWindow) withSecrets(f func()) {
+  switch {
+  case cw.passphraseSet || os.Getenv(secrets.PassphraseEnv) != "":
+    f()
+  case cw.secretsLocked:
+    cw.unlockSecrets(f, nil)
+  default:
+    cw.askPassphrase(true, func(pass string) {
+      cw.usePassphrase(pass)
+      f()
+    }, nil)
+  }
+}
+
+// unlockSecrets asks for the passphrase and checks it against the file,
+// asking again if it is wrong, then runs then. cancelled, if set, runs
+// when the user gives up.
+func (cw *MainWindow) unlockSecrets(then, cancelled func()) {
+  cw.askPassphrase(false, func(pass string) {
+    cw.usePassphrase(pass)
+    go func() {
+      _, err := cw.core.SecretNames()
+      fyne.Do(func() {
+        if err == nil {
+          then()
+          return
+        }
+        cw.usePassphrase("")
+        d := dialog.NewError(err, cw.wnd)
+        d.SetOnClosed(func() { cw.unlockSecrets(then, cancelled) })
+        d.Show()
+      })
+    }()
+  }, cancelled)
+}
+
+// usePassphrase has the core unlock (or create) the secrets file with
+// pass; "" forgets it again.
+func (cw *MainWindow) usePassphrase(pass string) {
+  cw.passphraseSet = pass != ""
+  if pass == "" {
+    cw.core.SetPassphrasePrompt(nil)
+    return
+  }
+  cw.core.SetPassphrasePrompt(func(bool) (string, error) { return pass, nil })
+}
+
+// askPassphrase shows the passphrase dialog, with a confirmation field
+// when choosing a new one, and hands ok what was entered.
+func (cw *MainWindow) askPassphrase(create bool, ok func(string), cancelled func()) {
+  pass := widget.NewPasswordEntry()
+  items := []*widget.FormItem{widget.NewFormItem("Passphrase", pass)}
+  title := "Unlock secrets"
+  confirm := widget.NewPasswordEntry()
+  if create {
+    title = "Choose a passphrase for the secrets file"
+    items = append(items, widget.NewFormItem("Again", confirm))
+  }
+  pass.Validator = func(s string) error {
+    if s == "" {
+      return errors.New("empty passphrase")
+    }
+    return nil
+  }
+
+  d := dialog.NewForm(title, "OK", "Cancel", items, func(submit bool) {
+    switch {
+    case !submit:
+      if cancelled != nil {
+        cancelled()
+      }
+    case create && confirm.Text != pass.Text:
+      e := dialog.NewError(errors.New("the passphrases don't match"), cw.wnd)
+      e.SetOnClosed(func() { cw.askPassphrase(create, ok, cancelled) })
+      e.Show()
+    default:
+      ok(pass.Text)
+    }
+  }, cw.wnd)
+  d.Resize(fyne.NewSize(400, d.MinSize().Height))
+  d.Show()
+  cw.wnd.Canvas().Focus(pass)
+}
+
+// UnlockAndInit asks for the secrets passphrase once the window is up and
+// then initialises the core again, for when Init failed on the locked
+// secrets file (say for a secret: api_key).
+func (cw *MainWindow) UnlockAndInit(initErr error) {
+  cw.secretsLocked = true
+  cw.app.Lifecycle().SetOnStarted(func() {
+    cw.unlockSecrets(func() {
+      go func() {
+        err := cw.core.Init()
+        fyne.Do(func() {
+          if err != nil {
+            dialog.ShowError(err, cw.wnd)
+          }
+          cw.RefreshAll()
+        })
+      }()
+    }, func() {
+      dialog.ShowError(fmt.Errorf("%w\n\nUnlock it in the Secrets tab.", initErr), cw.wnd)
+    })
+  })
+}
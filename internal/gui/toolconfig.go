@@ -0,0 +1,98 @@
+package gui
+
+import (
+  "fmt"
+  "strings"
+
+  "fyne.io/fyne/v2"
+  "fyne.io/fyne/v2/dialog"
+  "fyne.io/fyne/v2/widget"
+
+  "github.com/johnjallday/dolphin-tool-calling-agent/pkg/tools"
+)
+
+// showToolpackSettings opens a form with the settings pack declares, one
+// input per field by its type, and saves them for the current user.
+func (cw *MainWindow) showToolpackSettings(pack string) {
+  fields, values, err := cw.core.ToolpackConfig(pack)
+  if err != nil {
+    dialog.ShowError(err, cw.wnd)
+    return
+  }
+
+  var items []*widget.FormItem
+  read := map[string]func() string{}
+  for _, f := range fields {
+    cur := ""
+    if v, ok := values[f.Key]; ok {
+      cur = fmt.Sprint(v)
+    }
+    def := ""
+    if f.Default != nil {
+      def = fmt.Sprint(f.Default)
+    }
+
+    var input fyne.CanvasObject
+    switch f.Type {
+    case tools.ConfigBool:
+      if cur == "" {
+        cur = def
+      }
+      check := widget.NewCheck("", nil)
+      check.SetChecked(cur == "true")
+      input = check
+      read[f.Key] = func() string { return fmt.Sprint(check.Checked) }
+    case tools.ConfigChoice:
+      sel := widget.NewSelect(f.Choices, nil)
+      sel.PlaceHolder = def
+      sel.SetSelected(cur)
+      input = sel
+      read[f.Key] = func() string { return sel.Selected }
+    default:
+      entry := widget.NewEntry()
+      entry.SetPlaceHolder(def)
+      entry.SetText(cur)
+      input = entry
+      read[f.Key] = func() string { return entry.Text }
+    }
+
+    label := f.Key
+    if f.Required {
+      label += " *"
+    }
+    item := widget.NewFormItem(label, input)
+    item.HintText = f.Description
+    items = append(items, item)
+  }
+
+  d := dialog.NewForm(pack+" settings", "Save", "Cancel", items, func(ok bool) {
+    if !ok {
+      return
+    }
+    changed := map[string]string{}
+    for k, get := range read {
+      changed[k] = get()
+    }
+    res, err := cw.core.SetToolpackConfig(pack, changed)
+    if err != nil {
+      dialog.ShowError(err, cw.wnd)
+      return
+    }
+    msg := "Saved settings for " + pack
+    if res.Missing != nil {
+      msg += fmt.Sprintf("\nStill needed: %v", res.Missing)
+    }
+    if len(res.Reconfigured) > 0 {
+      msg += "\nApplied to " + strings.Join(res.Reconfigured, ", ")
+    }
+    if len(res.Restarted) > 0 {
+      msg += "\nRestarted " + strings.Join(res.Restarted, ", ") + ", which had skipped it"
+      cw.RefreshAll()
+    } else {
+      cw.refreshCurrentToolsList()
+    }
+    dialog.ShowInformation(pack, msg, cw.wnd)
+  }, cw.wnd)
+  d.Resize(fyne.NewSize(520, 0))
+  d.Show()
+}
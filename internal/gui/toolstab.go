@@ -2,11 +2,15 @@ package gui
 
 import (
 	"fmt"
+  "strings"
 
   "fyne.io/fyne/v2"
   "fyne.io/fyne/v2/layout"
   "fyne.io/fyne/v2/container"
   "fyne.io/fyne/v2/widget"
+
+  "github.com/johnjallday/dolphin-tool-calling-agent/internal/agent"
+  "github.com/johnjallday/dolphin-tool-calling-agent/internal/toolmanager"
 )
 
 
@@ -22,13 +26,19 @@ func (cw *MainWindow) makeToolsTab() *container.TabItem {
 
   // b) On-disk Toolpacks
   cw.toolpacksList = container.NewVBox()
+  cw.toolpackSearch = widget.NewEntry()
+  cw.toolpackSearch.SetPlaceHolder("Search toolpacks and tools…")
+  cw.toolpackSearch.OnChanged = func(string) { cw.refreshToolpacksList() }
   cw.refreshToolpacksList()
-  localScroll := container.NewVScroll(cw.toolpacksList)
+  localScroll := container.NewBorder(cw.toolpackSearch, nil, nil, nil,
+    container.NewVScroll(cw.toolpacksList))
 
   // c) Remote Toolpacks
   cw.remotetoolpacksList = container.NewVBox()
   cw.refreshRemoteToolpacksList()
-  remoteScroll := container.NewVScroll(cw.remotetoolpacksList)
+  checkBtn := widget.NewButton("Check for updates", cw.checkToolpackUpdates)
+  remoteScroll := container.NewBorder(container.NewHBox(layout.NewSpacer(), checkBtn), nil, nil, nil,
+    container.NewVScroll(cw.remotetoolpacksList))
 
   tabs := container.NewAppTabs(
     container.NewTabItem("Current Tools", curScroll),
@@ -39,24 +49,55 @@ func (cw *MainWindow) makeToolsTab() *container.TabItem {
   return container.NewTabItem("Tools", tabs)
 }
 
-// refreshRemoteToolpacksList loads and displays the registry entries
+// refreshRemoteToolpacksList lists what the catalogs offer, with Install
+// or Update buttons depending on what is installed.
 func (cw *MainWindow) refreshRemoteToolpacksList() {
   cw.remotetoolpacksList.Objects = nil
-  packs, err := cw.core.ListRemoteToolpacks()
+  entries, err := cw.core.RemoteToolpacks()
   if err != nil {
-    cw.remotetoolpacksList.Add(widget.NewLabel(fmt.Sprintf(
-      "Error loading remote toolpacks: %v", err)))
-  } else if len(packs) == 0 {
+    lbl := widget.NewLabel(fmt.Sprintf("⚠ %v", err))
+    lbl.Wrapping = fyne.TextWrapWord
+    lbl.Importance = widget.WarningImportance
+    cw.remotetoolpacksList.Add(lbl)
+  }
+  if len(entries) == 0 && err == nil {
     cw.remotetoolpacksList.Add(widget.NewLabelWithStyle(
       "No remote toolpacks found", fyne.TextAlignCenter,
       fyne.TextStyle{Italic: true}))
-  } else {
-    for _, name := range packs {
-      cw.remotetoolpacksList.Add(container.NewHBox(
-        widget.NewLabel(name),
-        layout.NewSpacer(),
-      ))
+  }
+
+  installed := map[string]string{}
+  for _, m := range cw.core.LocalToolpacks() {
+    if !m.Builtin {
+      installed[m.Name] = m.Version
+    }
+  }
+  for _, e := range entries {
+    spec := e.Catalog + "/" + e.Name
+    name := e.Name
+    label := fmt.Sprintf("%s [%s]", e.Name, e.Catalog)
+    var btn *widget.Button
+    ver, ok := installed[name]
+    switch {
+    case !ok:
+      btn = widget.NewButton("Install", func() { cw.installToolpack(spec) })
+    case cw.updates[name] != "":
+      label = fmt.Sprintf("%s [%s] %s → %s", name, e.Catalog, ver, cw.updates[name])
+      btn = widget.NewButton("Update", func() { cw.updateToolpack(name) })
+    default:
+      label = fmt.Sprintf("%s [%s] %s (installed)", name, e.Catalog, ver)
+      btn = widget.NewButton("Reinstall", func() { cw.installToolpack(spec) })
     }
+    desc := widget.NewLabel(e.Description)
+    desc.Wrapping = fyne.TextWrapWord
+    cw.remotetoolpacksList.Add(container.NewVBox(
+      container.NewHBox(
+        widget.NewLabelWithStyle(label, fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+        layout.NewSpacer(),
+        btn,
+      ),
+      desc,
+    ))
   }
   cw.remotetoolpacksList.Refresh()
 }
@@ -67,36 +108,142 @@ func (cw *MainWindow) refreshRemoteToolpacksList() {
 
 func (cw *MainWindow) refreshCurrentToolsList() {
   cw.toolsList.Objects = nil
+  a := cw.core.Agent()
   for _, t := range cw.core.Tools() {
-    cw.toolsList.Add(widget.NewLabel(fmt.Sprintf(
-      "%s: %s", t.Name, t.Description,
-    )))
+    text := fmt.Sprintf("%s: %s", t.Name, t.Description)
+    if a != nil {
+      if q, ok := a.Registry.Renamed(t.Name); ok {
+        text += fmt.Sprintf(" (%s)", q)
+      }
+    }
+    cw.toolsList.Add(widget.NewLabel(text))
     cw.toolsList.Add(widget.NewSeparator())
   }
   if len(cw.toolsList.Objects) == 0 {
     cw.toolsList.Add(widget.NewLabel("(no tools registered)"))
   }
+  // flag toolpacks and sub-agents the agent had to skip and clashing tool names
+  if a != nil {
+    for _, b := range a.BrokenToolpacks() {
+      lbl := widget.NewLabel(fmt.Sprintf("⚠ toolpack %s skipped: %v", b.Name, b.Err))
+      lbl.Importance = widget.DangerImportance
+      lbl.Wrapping = fyne.TextWrapWord
+      cw.toolsList.Add(lbl)
+    }
+    for _, b := range a.BrokenSubAgents() {
+      lbl := widget.NewLabel(fmt.Sprintf("⚠ sub-agent %s left out: %v", b.Name, b.Err))
+      lbl.Importance = widget.DangerImportance
+      lbl.Wrapping = fyne.TextWrapWord
+      cw.toolsList.Add(lbl)
+    }
+    for _, c := range a.ToolCollisions() {
+      lbl := widget.NewLabel("⚠ " + c.String())
+      lbl.Importance = widget.WarningImportance
+      lbl.Wrapping = fyne.TextWrapWord
+      cw.toolsList.Add(lbl)
+    }
+    if hidden := a.HiddenTools(); len(hidden) > 0 {
+      lbl := widget.NewLabel("hidden by tools_include/tools_exclude: " + strings.Join(hidden, ", "))
+      lbl.Importance = widget.LowImportance
+      lbl.Wrapping = fyne.TextWrapWord
+      cw.toolsList.Add(lbl)
+    }
+  }
   cw.toolsList.Refresh()
+  if a != nil {
+    cw.healthGen++
+    go cw.addToolpackHealth(a, cw.healthGen)
+  }
+}
+
+// addToolpackHealth runs the packs' Health hooks off the UI thread and
+// lists the answers, unless the list was rebuilt in the meantime.
+func (cw *MainWindow) addToolpackHealth(a *agent.Agent, gen int) {
+  health := a.ToolpackHealth()
+  if len(health) == 0 {
+    return
+  }
+  fyne.Do(func() {
+    if gen != cw.healthGen {
+      return
+    }
+    for _, h := range health {
+      lbl := widget.NewLabel("✓ " + h.Name + " healthy")
+      lbl.Importance = widget.SuccessImportance
+      if h.Err != nil {
+        lbl.SetText(fmt.Sprintf("✗ %s: %v", h.Name, h.Err))
+        lbl.Importance = widget.DangerImportance
+      }
+      lbl.Wrapping = fyne.TextWrapWord
+      cw.toolsList.Add(lbl)
+    }
+    cw.toolsList.Refresh()
+  })
 }
 
 
 func (cw *MainWindow) refreshToolpacksList() {
   cw.toolpacksList.Objects = nil
-  packs := cw.core.Toolpacks()
-  if len(packs) == 0 {
-    cw.toolpacksList.Add(widget.NewLabelWithStyle(
-      "No toolpacks found in ./plugins",
-      fyne.TextAlignCenter, fyne.TextStyle{Italic: true}),
+  query := ""
+  if cw.toolpackSearch != nil {
+    query = cw.toolpackSearch.Text
+  }
+  // described from toolpack.toml; nothing is loaded to list them
+  shown := 0
+  for _, m := range cw.core.LocalToolpacks() {
+    if !m.Matches(query) {
+      continue
+    }
+    shown++
+    title := m.Name
+    if m.Version != "" {
+      title += " " + m.Version
+    }
+    switch {
+    case m.Builtin:
+      title += " (built in)"
+    case m.Legacy:
+      title += " ⚠ no toolpack.toml"
+    case m.Declarative():
+      title += " (declarative)"
+    case m.Scripted():
+      title += " (starlark)"
+    case m.SignedBy != "":
+      title += " ✓ signed"
+    }
+    desc := m.Description
+    if desc == "" {
+      desc = "(no description)"
+    }
+    header := container.NewHBox(
+      widget.NewLabelWithStyle(title, fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+      layout.NewSpacer(),
     )
-  } else {
-    for _, name := range packs {
-      cw.toolpacksList.Add(container.NewHBox(
-        widget.NewLabel(name),
-        layout.NewSpacer(),
-      ))
+    if len(m.Config) > 0 {
+      name := m.Name
+      header.Add(widget.NewButton("Settings", func() { cw.showToolpackSettings(name) }))
+    }
+    if !m.Builtin {
+      name := m.Name
+      if toolmanager.CanRollback(name) {
+        header.Add(widget.NewButton("Rollback", func() { cw.rollbackToolpack(name) }))
+      }
+      header.Add(widget.NewButton("Uninstall", func() { cw.uninstallToolpack(name) }))
     }
+    cw.toolpacksList.Add(container.NewVBox(
+      header,
+      widget.NewLabel(fmt.Sprintf("%s — %d tools", desc, len(m.Tools))),
+    ))
   }
-
+  if shown == 0 {
+    msg := "No toolpacks installed"
+    if query != "" {
+      msg = fmt.Sprintf("No toolpacks match %q", query)
+    }
+    cw.toolpacksList.Add(widget.NewLabelWithStyle(msg,
+      fyne.TextAlignCenter, fyne.TextStyle{Italic: true}))
+  }
+  cw.toolpacksList.Refresh()
 }
 
 
@@ -4,6 +4,7 @@ import (
     "fmt"
     "github.com/BurntSushi/toml"
     "github.com/johnjallday/dolphin-tool-calling-agent/internal/device"
+    "github.com/johnjallday/dolphin-tool-calling-agent/internal/paths"
 )
 
 type Location struct {
@@ -36,7 +37,7 @@ func GetMyLocation() (*Location, error) {
 
     currentDisplay := device.GetCurrentDisplay()
 
-    locations, err := LoadLocations("./user/locations.toml")
+    locations, err := LoadLocations(paths.LocationsFile())
     if err != nil {
         return nil, fmt.Errorf("load locations: %w", err)
     }
@@ -0,0 +1,112 @@
+package paths
+
+import (
+  "fmt"
+  "io"
+  "io/fs"
+  "os"
+  "path/filepath"
+)
+
+// MigrateLegacy performs the one-time move from the old CWD-relative layout.
+// If ./configs exists and the resolved ConfigDir has not been initialised
+// yet, the tree is copied over (along with ./user/locations.toml and any
+// .so files under ./plugins). Originals are left in place. It returns the
+// list of directories copied, for reporting.
+func MigrateLegacy() ([]string, error) {
+  cfgDir := ConfigDir()
+  if _, err := os.Stat(filepath.Join(cfgDir, "app_setting.toml")); err == nil {
+    return nil, nil // already initialised
+  }
+  legacy, err := filepath.Abs("configs")
+  if err != nil {
+    return nil, err
+  }
+  if fi, err := os.Stat(legacy); err != nil || !fi.IsDir() || sameDir(legacy, cfgDir) {
+    return nil, nil
+  }
+
+  var moved []string
+  if err := copyTree(legacy, cfgDir, nil); err != nil {
+    return nil, fmt.Errorf("migrate %s → %s: %w", legacy, cfgDir, err)
+  }
+  moved = append(moved, legacy+" → "+cfgDir)
+
+  if _, err := os.Stat(filepath.Join("user", "locations.toml")); err == nil {
+    if err := copyFile(filepath.Join("user", "locations.toml"), LocationsFile()); err != nil {
+      return moved, fmt.Errorf("migrate locations.toml: %w", err)
+    }
+  }
+
+  if plugins, err := filepath.Abs("plugins"); err == nil && !sameDir(plugins, PluginDir()) {
+    if fi, err := os.Stat(plugins); err == nil && fi.IsDir() {
+      onlySO := func(p string) bool { return filepath.Ext(p) == ".so" }
+      if err := copyTree(plugins, PluginDir(), onlySO); err != nil {
+        return moved, fmt.Errorf("migrate plugins: %w", err)
+      }
+      moved = append(moved, plugins+" → "+PluginDir())
+    }
+  }
+  return moved, nil
+}
+
+// copyTree copies src into dst, skipping files that already exist at the
+// destination. keep, when non-nil, filters which files are copied.
+func copyTree(src, dst string, keep func(string) bool) error {
+  return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+    if err != nil {
+      return err
+    }
+    rel, err := filepath.Rel(src, path)
+    if err != nil {
+      return err
+    }
+    target := filepath.Join(dst, rel)
+    if d.IsDir() {
+      if keep != nil {
+        return nil // created on demand by copyFile
+      }
+      return os.MkdirAll(target, 0o755)
+    }
+    if keep != nil && !keep(path) {
+      return nil
+    }
+    if _, err := os.Stat(target); err == nil {
+      return nil
+    }
+    return copyFile(path, target)
+  })
+}
+
+func copyFile(src, dst string) error {
+  in, err := os.Open(src)
+  if err != nil {
+    return err
+  }
+  defer in.Close()
+  fi, err := in.Stat()
+  if err != nil {
+    return err
+  }
+  if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+    return err
+  }
+  out, err := os.OpenFile(dst, os.O_CREATE|os.O_EXCL|os.O_WRONLY, fi.Mode().Perm())
+  if err != nil {
+    return err
+  }
+  if _, err := io.Copy(out, in); err != nil {
+    out.Close()
+    return err
+  }
+  return out.Close()
+}
+
+func sameDir(a, b string) bool {
+  ai, err1 := os.Stat(a)
+  bi, err2 := os.Stat(b)
+  if err1 != nil || err2 != nil {
+    return filepath.Clean(a) == filepath.Clean(b)
+  }
+  return os.SameFile(ai, bi)
+}
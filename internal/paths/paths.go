@@ -0,0 +1,110 @@
+// Package paths resolves where Dolphin keeps its configuration and data, so
+// the binaries work no matter which directory they are started from.
+//
+// Resolution order:
+//
+//  1. the -home flag (SetHome)
+//  2. the DOLPHIN_HOME environment variable
+//  3. $XDG_CONFIG_HOME/dolphin for config and $XDG_DATA_HOME/dolphin for data
+//     (defaulting to ~/.config and ~/.local/share)
+//
+// With an explicit home, config lives in <home>/configs and plugins in
+// <home>/plugins, i.e. the same layout as a source checkout, so
+// DOLPHIN_HOME=$PWD reproduces the old CWD-relative behaviour.
+package paths
+
+import (
+  "flag"
+  "os"
+  "path/filepath"
+)
+
+// EnvHome is the environment variable that overrides the XDG directories.
+const EnvHome = "DOLPHIN_HOME"
+
+// SetHome pins the home directory, typically from a -home flag. It also
+// exports DOLPHIN_HOME so plugins (through pkg/tools) and child processes
+// resolve the same directories. An empty dir is a no-op.
+func SetHome(dir string) {
+  if dir == "" {
+    return
+  }
+  if abs, err := filepath.Abs(dir); err == nil {
+    dir = abs
+  }
+  os.Setenv(EnvHome, dir)
+}
+
+// ParseFlags parses the command line with a -home flag that SetHome
+// applies. Define any other flags first.
+func ParseFlags() {
+  home := flag.String("home", "", "dolphin home directory (overrides $"+EnvHome+" and the XDG dirs)")
+  flag.Parse()
+  SetHome(*home)
+}
+
+// Home returns the explicit home directory, or "" when the XDG layout is in use.
+func Home() string {
+  return os.Getenv(EnvHome)
+}
+
+// ConfigDir holds app_setting.toml, toolpacks.toml, users/ and per-tool settings.
+func ConfigDir() string {
+  if h := Home(); h != "" {
+    return filepath.Join(h, "configs")
+  }
+  return filepath.Join(xdg("XDG_CONFIG_HOME", ".config"), "dolphin")
+}
+
+// DataDir holds installed plugins and other non-config state.
+func DataDir() string {
+  if h := Home(); h != "" {
+    return h
+  }
+  return filepath.Join(xdg("XDG_DATA_HOME", filepath.Join(".local", "share")), "dolphin")
+}
+
+// UsersDir is where the per-user TOML files live.
+func UsersDir() string {
+  return filepath.Join(ConfigDir(), "users")
+}
+
+// PluginDir is where toolpack .so files are installed.
+func PluginDir() string {
+  return filepath.Join(DataDir(), "plugins")
+}
+
+// ToolConfigDir is where a toolpack keeps its own settings.
+func ToolConfigDir(pack string) string {
+  return filepath.Join(ConfigDir(), "tools", pack)
+}
+
+// ToolDataDir is where a toolpack keeps its own data (caches, state,
+// files its scripts write).
+func ToolDataDir(pack string) string {
+  return filepath.Join(DataDir(), "tooldata", pack)
+}
+
+// LogFile is the app's log, e.g. which tools each turn was offered.
+func LogFile() string {
+  return filepath.Join(DataDir(), "dolphin.log")
+}
+
+// LocationsFile is the device/location map used by the location package.
+func LocationsFile() string {
+  return filepath.Join(ConfigDir(), "locations.toml")
+}
+
+// xdg returns $env, or ~/fallback when it is unset or not absolute (as the
+// XDG spec requires relative values to be ignored).
+func xdg(env, fallback string) string {
+  if v := os.Getenv(env); filepath.IsAbs(v) {
+    return v
+  }
+  home, err := os.UserHomeDir()
+  if err != nil {
+    // no $HOME: last resort, keep things next to the binary's CWD
+    return fallback
+  }
+  return filepath.Join(home, fallback)
+}
@@ -0,0 +1,25 @@
+package paths
+
+import (
+  "path/filepath"
+  "testing"
+
+  "github.com/johnjallday/dolphin-tool-calling-agent/pkg/tools"
+)
+
+// Plugins resolve their directories through pkg/tools, which can't import
+// this package; both have to land in the same place.
+func TestToolDirsMatchSDK(t *testing.T) {
+  base := t.TempDir()
+  for _, home := range []string{"", filepath.Join(base, "home")} {
+    t.Setenv(EnvHome, home)
+    t.Setenv("XDG_CONFIG_HOME", filepath.Join(base, "cfg"))
+    t.Setenv("XDG_DATA_HOME", filepath.Join(base, "data"))
+    if got, want := tools.ConfigDir("weather"), ToolConfigDir("weather"); got != want {
+      t.Errorf("home %q: tools.ConfigDir = %s, want %s", home, got, want)
+    }
+    if got, want := tools.DataDir("weather"), ToolDataDir("weather"); got != want {
+      t.Errorf("home %q: tools.DataDir = %s, want %s", home, got, want)
+    }
+  }
+}
@@ -0,0 +1,224 @@
+package registry
+
+import (
+    "fmt"
+    "path"
+    "sort"
+    "strings"
+
+    "github.com/openai/openai-go"
+    "github.com/johnjallday/dolphin-tool-calling-agent/pkg/tools"
+)
+
+// Filter narrows what the model is offered from the registered tools, so
+// e.g. a read-only agent can load a pack without its destructive tools.
+// Patterns are globs (path.Match) tried against a tool's pack.tool name,
+// its own name and its wire name: "reaper.*", "*.delete_*", "render".
+type Filter struct {
+    // Include, if set, offers only tools matching one of its patterns.
+    Include []string
+    // Exclude hides matching tools; it wins over Include.
+    Exclude []string
+    // Overrides change how a tool is described, keyed by pack.tool, tool
+    // name or wire name.
+    Overrides map[string]Override
+    // Optional lists packs whose tools may be missing (see Naming);
+    // overrides of their tools are dropped instead of failing.
+    Optional map[string]bool
+}
+
+// Override rewrites a tool's description and those of its parameters.
+type Override struct {
+    Description string
+    Parameters  map[string]string // parameter → description
+}
+
+// SetFilter checks f against the registered tools and applies it from the
+// next Initialize on. Hidden tools have no handler, so the model can't
+// call them by guessing the name either.
+func (r *ToolRegistry) SetFilter(f Filter) error {
+    for _, p := range append(append([]string(nil), f.Include...), f.Exclude...) {
+        if _, err := path.Match(p, ""); err != nil {
+            return fmt.Errorf("tool pattern %q: %w", p, err)
+        }
+    }
+
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    for _, key := range sortedKeys(f.Overrides) {
+        name, ok := r.lookupLocked(key)
+        if !ok {
+            pack, _, _ := strings.Cut(key, ".")
+            if f.Optional[pack] {
+                continue
+            }
+            return fmt.Errorf("tool override %q: no such tool", key)
+        }
+        props, _ := r.tools[name].Parameters["properties"].(map[string]interface{})
+        for _, param := range sortedKeys(f.Overrides[key].Parameters) {
+            if _, ok := props[param]; !ok {
+                return fmt.Errorf("tool override %q: tool has no parameter %q", key, param)
+            }
+        }
+    }
+    r.filter = f
+    return nil
+}
+
+// Hidden reports whether the filter keeps the named tool from the model.
+func (r *ToolRegistry) Hidden(name string) bool {
+    r.mu.RLock()
+    defer r.mu.RUnlock()
+    return r.hiddenLocked(name)
+}
+
+func (r *ToolRegistry) hiddenLocked(name string) bool {
+    if _, ok := r.tools[name]; !ok {
+        return false
+    }
+    names := r.namesLocked(name)
+    if len(r.filter.Include) > 0 && !matchAny(r.filter.Include, names) {
+        return true
+    }
+    return matchAny(r.filter.Exclude, names)
+}
+
+// Offered returns the tools the model is offered, sorted, as it sees them
+// (overrides applied).
+func (r *ToolRegistry) Offered() []tools.Tool {
+    var out []tools.Tool
+    for _, t := range r.Tools() {
+        if o, ok := r.Offer(t.Name); ok {
+            out = append(out, o)
+        }
+    }
+    return out
+}
+
+// HiddenTools returns the pack.tool names of the tools the filter hides.
+func (r *ToolRegistry) HiddenTools() []string {
+    r.mu.RLock()
+    defer r.mu.RUnlock()
+    var out []string
+    for name := range r.tools {
+        if r.hiddenLocked(name) {
+            out = append(out, r.qualifiedLocked(name))
+        }
+    }
+    sort.Strings(out)
+    return out
+}
+
+// Offer returns the named tool as the model should see it, or false if
+// it is unknown or hidden.
+func (r *ToolRegistry) Offer(name string) (tools.Tool, bool) {
+    r.mu.RLock()
+    defer r.mu.RUnlock()
+    t, ok := r.tools[name]
+    if !ok || r.hiddenLocked(name) {
+        return tools.Tool{}, false
+    }
+    o, ok := r.overrideLocked(name)
+    if !ok {
+        return t, true
+    }
+    if o.Description != "" {
+        t.Description = o.Description
+    }
+    if len(o.Parameters) > 0 {
+        t.Parameters = describeParams(t.Parameters, o.Parameters)
+    }
+    return t, true
+}
+
+// ToolParam is the declaration of the named tool sent to the model.
+func (r *ToolRegistry) ToolParam(name string) (openai.ChatCompletionToolParam, bool) {
+    t, ok := r.Offer(name)
+    if !ok {
+        return openai.ChatCompletionToolParam{}, false
+    }
+    return openai.ChatCompletionToolParam{
+        Function: openai.FunctionDefinitionParam{
+            Name:        t.Name,
+            Description: openai.String(t.Description),
+            Parameters:  t.Parameters,
+        },
+    }, true
+}
+
+// overrideLocked finds the override of the named tool, by any of its names.
+func (r *ToolRegistry) overrideLocked(name string) (Override, bool) {
+    for _, n := range r.namesLocked(name) {
+        if o, ok := r.filter.Overrides[n]; ok {
+            return o, true
+        }
+    }
+    return Override{}, false
+}
+
+// lookupLocked resolves pack.tool, a tool name or a wire name to the wire
+// name it is registered under.
+func (r *ToolRegistry) lookupLocked(key string) (string, bool) {
+    if _, ok := r.tools[key]; ok {
+        return key, true
+    }
+    for name := range r.tools {
+        for _, n := range r.namesLocked(name) {
+            if n == key {
+                return name, true
+            }
+        }
+    }
+    return "", false
+}
+
+// namesLocked returns the names patterns and overrides may use for a
+// tool: pack.tool, its own name and its wire name.
+func (r *ToolRegistry) namesLocked(name string) []string {
+    q := r.qualifiedLocked(name)
+    _, own, _ := strings.Cut(q, ".")
+    if own == "" {
+        own = q
+    }
+    return []string{q, own, name}
+}
+
+func matchAny(patterns, names []string) bool {
+    for _, p := range patterns {
+        for _, n := range names {
+            if ok, _ := path.Match(p, n); ok {
+                return true
+            }
+        }
+    }
+    return false
+}
+
+// describeParams copies schema with the descriptions of some properties
+// replaced; the tool's own schema is shared and must not be modified.
+func describeParams(schema openai.FunctionParameters, descs map[string]string) openai.FunctionParameters {
+    props, ok := schema["properties"].(map[string]interface{})
+    if !ok {
+        return schema
+    }
+    out := make(openai.FunctionParameters, len(schema))
+    for k, v := range schema {
+        out[k] = v
+    }
+    newProps := make(map[string]interface{}, len(props))
+    for k, v := range props {
+        newProps[k] = v
+    }
+    for param, desc := range descs {
+        p := map[string]interface{}{}
+        if old, ok := props[param].(map[string]interface{}); ok {
+            for k, v := range old {
+                p[k] = v
+            }
+        }
+        p["description"] = desc
+        newProps[param] = p
+    }
+    out["properties"] = newProps
+    return out
+}
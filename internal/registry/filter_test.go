@@ -0,0 +1,88 @@
+package registry
+
+import (
+    "reflect"
+    "testing"
+
+    "github.com/johnjallday/dolphin-tool-calling-agent/pkg/tools"
+)
+
+// filterRegistry has reaper.render, reaper.delete_project and a packless
+// calc, registered as Assign names them.
+func filterRegistry(t *testing.T) *ToolRegistry {
+    t.Helper()
+    noop := func(map[string]interface{}) (string, error) { return "", nil }
+    packs := []tools.ToolPackage{
+        {Name: "reaper", Tools: []tools.Tool{{Name: "render", Exec: noop}, {Name: "delete_project", Exec: noop}}},
+    }
+    named, _, err := Assign(packs, Naming{})
+    if err != nil {
+        t.Fatal(err)
+    }
+    r := NewToolRegistry()
+    for _, n := range named {
+        if err := r.RegisterNamed(n); err != nil {
+            t.Fatal(err)
+        }
+    }
+    if err := r.Register(tools.Tool{Name: "calc", Exec: noop}); err != nil {
+        t.Fatal(err)
+    }
+    return r
+}
+
+func TestFilterHides(t *testing.T) {
+    for _, c := range []struct {
+        name   string
+        filter Filter
+        hidden []string
+    }{
+        {"none", Filter{}, nil},
+        {"include pack", Filter{Include: []string{"reaper.*"}}, []string{"calc"}},
+        {"include bare name", Filter{Include: []string{"render"}}, []string{"calc", "reaper.delete_project"}},
+        {"exclude glob", Filter{Exclude: []string{"*.delete_*"}}, []string{"reaper.delete_project"}},
+        {"exclude wins over include", Filter{Include: []string{"reaper.*"}, Exclude: []string{"reaper.delete_project"}},
+            []string{"calc", "reaper.delete_project"}},
+        {"exclude wire name", Filter{Exclude: []string{"calc"}}, []string{"calc"}},
+    } {
+        t.Run(c.name, func(t *testing.T) {
+            r := filterRegistry(t)
+            if err := r.SetFilter(c.filter); err != nil {
+                t.Fatal(err)
+            }
+            if got := r.HiddenTools(); !reflect.DeepEqual(got, c.hidden) {
+                t.Errorf("hidden %v, want %v", got, c.hidden)
+            }
+            for _, name := range r.ListToolNames() {
+                _, ok := r.Handler(name)
+                if hidden := r.Hidden(name); ok == hidden {
+                    t.Errorf("%s: hidden %v but handler %v", name, hidden, ok)
+                }
+            }
+        })
+    }
+}
+
+func TestFilterUnknownTool(t *testing.T) {
+    r := filterRegistry(t)
+    if err := r.SetFilter(Filter{Include: []string{"calc"}}); err != nil {
+        t.Fatal(err)
+    }
+    // not registered, so not "hidden", but there is nothing to call either
+    if r.Hidden("made_up") {
+        t.Error("an unknown tool counts as hidden")
+    }
+    if _, ok := r.Handler("made_up"); ok {
+        t.Error("an unknown tool has a handler")
+    }
+}
+
+func TestFilterBadPattern(t *testing.T) {
+    r := filterRegistry(t)
+    if err := r.SetFilter(Filter{Exclude: []string{"["}}); err == nil {
+        t.Error("a malformed pattern was accepted")
+    }
+    if err := r.SetFilter(Filter{Overrides: map[string]Override{"reaper.nope": {Description: "x"}}}); err == nil {
+        t.Error("an override of a missing tool was accepted")
+    }
+}
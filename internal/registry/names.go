@@ -0,0 +1,162 @@
+package registry
+
+import (
+    "fmt"
+    "sort"
+    "strings"
+
+    "github.com/johnjallday/dolphin-tool-calling-agent/pkg/tools"
+)
+
+// Tools are identified across toolpacks by their qualified name,
+// "pack.tool" (e.g. calculator.add). The model sees a wire name instead:
+// the bare tool name when no other pack of the agent defines it, else the
+// qualified name mangled to calculator__add, since OpenAI function names
+// must match ^[a-zA-Z0-9_-]{1,64}$.
+
+// MaxNameLen is the longest function name the API accepts.
+const MaxNameLen = tools.MaxNameLen
+
+// Qualify returns pack.tool ("tool" alone for tools of no pack).
+func Qualify(pack, tool string) string {
+    if pack == "" {
+        return tool
+    }
+    return pack + "." + tool
+}
+
+// WireName makes name safe to send as a function name (see
+// tools.WireName).
+func WireName(name string) string { return tools.WireName(name) }
+
+// ValidWireName reports whether name can be sent as-is.
+func ValidWireName(name string) bool { return tools.ValidWireName(name) }
+
+// Named is a tool with the name the model will call it by.
+type Named struct {
+    Pack string
+    Tool tools.Tool
+    Name string // wire name
+}
+
+// Qualified is the tool's pack.tool name.
+func (n Named) Qualified() string { return Qualify(n.Pack, n.Tool.Name) }
+
+// Collision is a tool name defined by more than one toolpack of an agent.
+type Collision struct {
+    Tool  string
+    Packs []string
+    Names []string // the wire names each pack's tool got, in Packs order
+}
+
+func (c Collision) String() string {
+    return fmt.Sprintf("tool %q is defined by %s; exposed as %s",
+        c.Tool, strings.Join(c.Packs, " and "), strings.Join(c.Names, ", "))
+}
+
+// Naming controls how Assign names tools.
+type Naming struct {
+    // Namespace exposes every tool as pack__tool, not only colliding ones.
+    Namespace bool
+    // Aliases maps a wire name of the agent's choosing to "pack.tool" (or
+    // a bare tool name if only one pack defines it).
+    Aliases map[string]string
+    // Optional lists packs whose tools may be missing (e.g. skipped as
+    // broken); aliases pointing into them are dropped instead of failing.
+    Optional map[string]bool
+}
+
+// Assign gives every tool of packs its wire name and reports the names
+// more than one pack defines. It fails when an alias points nowhere or two
+// tools would end up with the same wire name.
+func Assign(packs []tools.ToolPackage, naming Naming) ([]Named, []Collision, error) {
+    defs := map[string][]string{} // bare name → packs defining it
+    var out []Named
+    for _, p := range packs {
+        for _, t := range p.Tools {
+            defs[t.Name] = append(defs[t.Name], p.Name)
+            out = append(out, Named{Pack: p.Name, Tool: t})
+        }
+    }
+    for i := range out {
+        n := &out[i]
+        if naming.Namespace || len(defs[n.Tool.Name]) > 1 {
+            n.Name = WireName(n.Qualified())
+        } else {
+            n.Name = WireName(n.Tool.Name)
+        }
+    }
+
+    // aliases replace the wire name of their target
+    for _, alias := range sortedKeys(naming.Aliases) {
+        target := naming.Aliases[alias]
+        if !ValidWireName(alias) {
+            return nil, nil, fmt.Errorf("tool alias %q: only letters, digits, _ and - are allowed (max %d)", alias, MaxNameLen)
+        }
+        i, err := findTool(out, defs, target)
+        if err != nil {
+            pack, _, _ := strings.Cut(target, ".")
+            if naming.Optional[pack] {
+                continue
+            }
+            return nil, nil, fmt.Errorf("tool alias %q: %w", alias, err)
+        }
+        out[i].Name = alias
+    }
+
+    byName := map[string]int{}
+    for i, n := range out {
+        if j, dup := byName[n.Name]; dup {
+            return nil, nil, fmt.Errorf("tools %s and %s would both be called %q; add a tool alias for one of them",
+                out[j].Qualified(), n.Qualified(), n.Name)
+        }
+        byName[n.Name] = i
+    }
+
+    var cols []Collision
+    for _, name := range sortedKeys(defs) {
+        if len(defs[name]) < 2 {
+            continue
+        }
+        c := Collision{Tool: name, Packs: defs[name]}
+        for _, n := range out {
+            if n.Tool.Name == name {
+                c.Names = append(c.Names, n.Name)
+            }
+        }
+        cols = append(cols, c)
+    }
+    return out, cols, nil
+}
+
+// findTool resolves an alias target, "pack.tool" or a bare tool name.
+func findTool(ns []Named, defs map[string][]string, target string) (int, error) {
+    pack, tool, qualified := strings.Cut(target, ".")
+    if !qualified {
+        tool = target
+        switch len(defs[tool]) {
+        case 0:
+            return -1, fmt.Errorf("no tool %q", target)
+        case 1:
+            pack = defs[tool][0]
+        default:
+            return -1, fmt.Errorf("tool %q is defined by %s; say which, e.g. %s",
+                tool, strings.Join(defs[tool], " and "), Qualify(defs[tool][0], tool))
+        }
+    }
+    for i, n := range ns {
+        if n.Pack == pack && n.Tool.Name == tool {
+            return i, nil
+        }
+    }
+    return -1, fmt.Errorf("no tool %q", target)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+    keys := make([]string, 0, len(m))
+    for k := range m {
+        keys = append(keys, k)
+    }
+    sort.Strings(keys)
+    return keys
+}
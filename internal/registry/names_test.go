@@ -0,0 +1,113 @@
+package registry
+
+import (
+    "strings"
+    "testing"
+
+    "github.com/johnjallday/dolphin-tool-calling-agent/pkg/tools"
+)
+
+func pack(name string, toolNames ...string) tools.ToolPackage {
+    p := tools.ToolPackage{Name: name}
+    for _, n := range toolNames {
+        p.Tools = append(p.Tools, tools.Tool{Name: n})
+    }
+    return p
+}
+
+// wireNames maps each tool's pack.tool name to the name Assign gave it.
+func wireNames(t *testing.T, packs []tools.ToolPackage, naming Naming) (map[string]string, []Collision) {
+    t.Helper()
+    named, cols, err := Assign(packs, naming)
+    if err != nil {
+        t.Fatal(err)
+    }
+    out := map[string]string{}
+    for _, n := range named {
+        out[n.Qualified()] = n.Name
+    }
+    return out, cols
+}
+
+func TestAssignCollisions(t *testing.T) {
+    got, cols := wireNames(t, []tools.ToolPackage{
+        pack("reaper", "render", "list"),
+        pack("files", "list"),
+    }, Naming{})
+    want := map[string]string{"reaper.render": "render", "reaper.list": "reaper__list", "files.list": "files__list"}
+    for q, name := range want {
+        if got[q] != name {
+            t.Errorf("%s: %q, want %q", q, got[q], name)
+        }
+    }
+    if len(cols) != 1 || cols[0].String() != `tool "list" is defined by reaper and files; exposed as reaper__list, files__list` {
+        t.Errorf("collisions %v", cols)
+    }
+
+    got, _ = wireNames(t, []tools.ToolPackage{pack("reaper", "render")}, Naming{Namespace: true})
+    if got["reaper.render"] != "reaper__render" {
+        t.Errorf("namespaced: %v", got)
+    }
+}
+
+func TestAssignAliases(t *testing.T) {
+    packs := []tools.ToolPackage{pack("reaper", "list"), pack("files", "list"), pack("calc", "add")}
+    got, _ := wireNames(t, packs, Naming{Aliases: map[string]string{"projects": "reaper.list", "plus": "add"}})
+    if got["reaper.list"] != "projects" || got["files.list"] != "files__list" || got["calc.add"] != "plus" {
+        t.Errorf("aliased: %v", got)
+    }
+
+    // the registry maps the alias back to what it stands for
+    named, _, _ := Assign(packs, Naming{Aliases: map[string]string{"projects": "reaper.list"}})
+    r := NewToolRegistry()
+    for _, n := range named {
+        if err := r.RegisterNamed(n); err != nil {
+            t.Fatal(err)
+        }
+    }
+    if q := r.Qualified("projects"); q != "reaper.list" {
+        t.Errorf("projects resolves to %q", q)
+    }
+    if q, ok := r.Renamed("projects"); !ok || q != "reaper.list" {
+        t.Errorf("Renamed: %q, %v", q, ok)
+    }
+    if _, ok := r.Renamed("add"); ok {
+        t.Error("a tool under its own name counts as renamed")
+    }
+    if p := r.Pack("files__list"); p != "files" {
+        t.Errorf("pack of files__list: %q", p)
+    }
+
+    for alias, target := range map[string]string{
+        "x":        "list",       // ambiguous
+        "y":        "reaper.nope", // no such tool
+        "bad name": "calc.add",
+        "add":      "reaper.list", // clashes with calc's add
+    } {
+        if _, _, err := Assign(packs, Naming{Aliases: map[string]string{alias: target}}); err == nil {
+            t.Errorf("alias %q → %q accepted", alias, target)
+        }
+    }
+    if _, _, err := Assign(packs, Naming{Aliases: map[string]string{"z": "broken.tool"}, Optional: map[string]bool{"broken": true}}); err != nil {
+        t.Errorf("alias into a skipped pack: %v", err)
+    }
+}
+
+func TestAssignUnsafeNames(t *testing.T) {
+    long := strings.Repeat("very_long_tool_name_", 5)
+    got, _ := wireNames(t, []tools.ToolPackage{
+        pack("my pack", "do thing", long),
+        pack("other", "do thing"),
+    }, Naming{})
+    for q, name := range got {
+        if !ValidWireName(name) {
+            t.Errorf("%s got the invalid name %q", q, name)
+        }
+    }
+    if got["my pack.do thing"] != "my_pack__do_thing" {
+        t.Errorf("mangled: %v", got)
+    }
+    if n := got["my pack."+long]; len(n) != MaxNameLen {
+        t.Errorf("long name %q (%d)", n, len(n))
+    }
+}
@@ -1,70 +1,152 @@
 package registry
 
 import (
-    "encoding/json"
+    "context"
     "fmt"
     "sort"
+    "strings"
+    "sync"
 
     "github.com/openai/openai-go"
     "github.com/johnjallday/dolphin-tool-calling-agent/pkg/tools"
 )
 
+// HandlerFunc runs one tool call and appends its result to params. ctx is
+// the chat turn's; it reaches tools that have an ExecContext.
+type HandlerFunc func(ctx context.Context, call openai.ChatCompletionMessageToolCall, params *openai.ChatCompletionNewParams)
+
+// ToolRegistry is safe for concurrent use.
 type ToolRegistry struct {
-    // tools maps the tool‐name to its definition
+    mu sync.RWMutex
+    // tools maps the tool‐name (the wire name the model calls) to its definition
     tools    map[string]tools.Tool
+    // qualified maps the tool-name to pack.tool, for tools of a pack
+    qualified map[string]string
     // handlers maps the tool‐name to the code that executes it
-    handlers map[string]func(openai.ChatCompletionMessageToolCall, *openai.ChatCompletionNewParams)
+    handlers map[string]HandlerFunc
+    // filter picks what the model is offered (see filter.go)
+    filter Filter
 }
 
 func NewToolRegistry() *ToolRegistry {
     return &ToolRegistry{
-        tools:    make(map[string]tools.Tool),
-        handlers: make(map[string]func(openai.ChatCompletionMessageToolCall, *openai.ChatCompletionNewParams)),
+        tools:     make(map[string]tools.Tool),
+        qualified: make(map[string]string),
+        handlers:  make(map[string]HandlerFunc),
     }
 }
 
-// Initialize registers all tools in this registry into the chat params.
+// Initialize registers the tools of this registry the filter lets
+// through into the chat params, with their overrides applied.
 func (r *ToolRegistry) Initialize(params *openai.ChatCompletionNewParams) {
-    for _, t := range r.Tools() {
-        params.Tools = append(params.Tools, openai.ChatCompletionToolParam{
-            Function: openai.FunctionDefinitionParam{
-                Name:        t.Name,
-                Description: openai.String(t.Description),
-                Parameters:  t.Parameters,
-            },
-        })
+    for _, name := range r.ListToolNames() {
+        if tp, ok := r.ToolParam(name); ok {
+            params.Tools = append(params.Tools, tp)
+        }
     }
 }
 
-// Register adds or updates a tool and wires up its handler.
-func (r *ToolRegistry) Register(t tools.Tool) {
-    r.tools[t.Name] = t
+// Register adds a tool under its own name and wires up its handler. A
+// name that is already taken is an error; nothing is overwritten.
+func (r *ToolRegistry) Register(t tools.Tool) error {
+    return r.RegisterNamed(Named{Tool: t, Name: t.Name})
+}
 
-    // overwrite any existing handler for this name
-    r.handlers[t.Name] = func(call openai.ChatCompletionMessageToolCall, params *openai.ChatCompletionNewParams) {
-        var args map[string]interface{}
-        if err := json.Unmarshal([]byte(call.Function.Arguments), &args); err != nil {
-            params.Messages = append(params.Messages,
-                openai.ToolMessage(fmt.Sprintf("Error parsing arguments: %v", err), call.ID))
-            return
+// RegisterNamed adds a tool of a pack under the wire name Assign gave it.
+func (r *ToolRegistry) RegisterNamed(n Named) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    if _, taken := r.tools[n.Name]; taken {
+        if by, ok := r.qualified[n.Name]; ok {
+            return fmt.Errorf("tool name %q is already taken by %s", n.Name, by)
         }
-        res, err := t.Exec(args)
-        if err != nil {
-            params.Messages = append(params.Messages,
-                openai.ToolMessage(fmt.Sprintf("Error running %s: %v", t.Name, err), call.ID))
-            return
-        }
-        params.Messages = append(params.Messages, openai.ToolMessage(res, call.ID))
+        return fmt.Errorf("tool name %q is already taken", n.Name)
+    }
+    t := n.Tool
+    t.Name = n.Name
+    r.tools[t.Name] = t
+    if n.Pack != "" {
+        r.qualified[t.Name] = n.Qualified()
     }
+
+    r.handlers[t.Name] = func(ctx context.Context, call openai.ChatCompletionMessageToolCall, params *openai.ChatCompletionNewParams) {
+        params.Messages = append(params.Messages, openai.ToolMessage(t.Reply(ctx, call.Function.Arguments), call.ID))
+    }
+    return nil
 }
 
-// Handlers returns the map of function names to handler functions.
-func (r *ToolRegistry) Handlers() map[string]func(openai.ChatCompletionMessageToolCall, *openai.ChatCompletionNewParams) {
-    return r.handlers
+// Handlers returns a copy of the map of function names to handler functions.
+func (r *ToolRegistry) Handlers() map[string]HandlerFunc {
+    r.mu.RLock()
+    defer r.mu.RUnlock()
+    out := make(map[string]HandlerFunc, len(r.handlers))
+    for name, h := range r.handlers {
+        out[name] = h
+    }
+    return out
+}
+
+// Handler returns the handler registered for the named tool, unless the
+// filter hides it.
+func (r *ToolRegistry) Handler(name string) (HandlerFunc, bool) {
+    r.mu.RLock()
+    defer r.mu.RUnlock()
+    if r.hiddenLocked(name) {
+        return nil, false
+    }
+    h, ok := r.handlers[name]
+    return h, ok
+}
+
+// Tool returns the definition of the named tool.
+func (r *ToolRegistry) Tool(name string) (tools.Tool, bool) {
+    r.mu.RLock()
+    defer r.mu.RUnlock()
+    t, ok := r.tools[name]
+    return t, ok
+}
+
+// Qualified returns the pack.tool name of the named tool, or the name
+// itself for a tool of no pack.
+func (r *ToolRegistry) Qualified(name string) string {
+    r.mu.RLock()
+    defer r.mu.RUnlock()
+    return r.qualifiedLocked(name)
+}
+
+func (r *ToolRegistry) qualifiedLocked(name string) string {
+    if q, ok := r.qualified[name]; ok {
+        return q
+    }
+    return name
+}
+
+// Pack returns the toolpack the named tool came from ("" for none).
+func (r *ToolRegistry) Pack(name string) string {
+    r.mu.RLock()
+    defer r.mu.RUnlock()
+    q, ok := r.qualified[name]
+    if !ok {
+        return ""
+    }
+    pack, _, _ := strings.Cut(q, ".")
+    return pack
+}
+
+// Renamed returns the pack.tool name of the named tool if the model sees
+// it under another name (namespaced or aliased).
+func (r *ToolRegistry) Renamed(name string) (string, bool) {
+    q := r.Qualified(name)
+    if q == name || strings.HasSuffix(q, "."+name) {
+        return "", false
+    }
+    return q, true
 }
 
 // Tools returns a sorted slice of all registered tools.
 func (r *ToolRegistry) Tools() []tools.Tool {
+    r.mu.RLock()
+    defer r.mu.RUnlock()
     names := make([]string, 0, len(r.tools))
     for name := range r.tools {
         names = append(names, name)
@@ -80,6 +162,8 @@ func (r *ToolRegistry) Tools() []tools.Tool {
 
 // ListToolNames returns just the names, sorted.
 func (r *ToolRegistry) ListToolNames() []string {
+    r.mu.RLock()
+    defer r.mu.RUnlock()
     names := make([]string, 0, len(r.tools))
     for name := range r.tools {
         names = append(names, name)
@@ -90,8 +174,12 @@ func (r *ToolRegistry) ListToolNames() []string {
 
 // Clear resets the registry to empty.
 func (r *ToolRegistry) Clear() {
+    r.mu.Lock()
+    defer r.mu.Unlock()
     r.tools = make(map[string]tools.Tool)
-    r.handlers = make(map[string]func(openai.ChatCompletionMessageToolCall, *openai.ChatCompletionNewParams))
+    r.qualified = make(map[string]string)
+    r.handlers = make(map[string]HandlerFunc)
+    r.filter = Filter{}
 }
 
 // String prints a human‐readable list of tools.
@@ -102,7 +190,14 @@ func (r *ToolRegistry) String() string {
     }
     out := "Available tools:\n"
     for _, t := range ts {
-        out += fmt.Sprintf(" - %-20s  %s\n", t.Name, t.Description)
+        out += fmt.Sprintf(" - %-20s  %s", t.Name, t.Description)
+        if q, ok := r.Renamed(t.Name); ok {
+            out += fmt.Sprintf(" (%s)", q)
+        }
+        if r.Hidden(t.Name) {
+            out += " [hidden]"
+        }
+        out += "\n"
     }
     return out
 }
@@ -0,0 +1,213 @@
+package registry
+
+import (
+    "math"
+    "sort"
+    "strings"
+    "unicode"
+
+    "github.com/johnjallday/dolphin-tool-calling-agent/pkg/tools"
+)
+
+// Selection caps how many tools are sent with a request. With many packs
+// loaded, every schema on every call costs tokens and makes the model
+// pick worse; instead the tools are ranked against the user's message and
+// only the best Max go out, plus the pinned ones.
+type Selection struct {
+    // Max is how many tools to offer per turn; 0 offers all of them.
+    Max int
+    // Pinned tools are always offered, on top of Max: globs over
+    // pack.tool, the tool name or its wire name (see Filter).
+    Pinned []string
+}
+
+// Enabled reports whether s narrows anything.
+func (s Selection) Enabled() bool { return s.Max > 0 }
+
+// Offer is what Select picked for one message.
+type Offer struct {
+    Tools  []string // wire names, pinned and sticky first, then by rank
+    Pinned int      // how many of Tools were pinned or sticky
+    Total  int      // how many tools there were to pick from
+}
+
+// Select ranks the offered tools against query and returns the wire names
+// to send: the pinned ones, then sticky (e.g. those called last turn, so
+// "do that again" works), then the Max best matches. Tools that match
+// nothing fill what is left of Max in name order.
+func (r *ToolRegistry) Select(query string, s Selection, sticky []string) Offer {
+    all := r.Offered()
+    out := Offer{Total: len(all)}
+    if !s.Enabled() || len(all) <= s.Max {
+        for _, t := range all {
+            out.Tools = append(out.Tools, t.Name)
+        }
+        return out
+    }
+
+    taken := map[string]bool{}
+    take := func(name string) {
+        if !taken[name] {
+            taken[name] = true
+            out.Tools = append(out.Tools, name)
+        }
+    }
+    r.mu.RLock()
+    for _, t := range all {
+        if matchAny(s.Pinned, r.namesLocked(t.Name)) {
+            take(t.Name)
+        }
+    }
+    r.mu.RUnlock()
+    for _, name := range sticky {
+        if _, ok := r.Offer(name); ok {
+            take(name)
+        }
+    }
+    out.Pinned = len(out.Tools)
+
+    scores := newIndex(all, r.Qualified).score(query)
+    ranked := append([]tools.Tool(nil), all...)
+    sort.SliceStable(ranked, func(i, j int) bool {
+        return scores[ranked[i].Name] > scores[ranked[j].Name]
+    })
+    for _, t := range ranked {
+        if len(out.Tools)-out.Pinned >= s.Max {
+            break
+        }
+        take(t.Name)
+    }
+    return out
+}
+
+// index is a BM25 keyword index over tool names, descriptions and
+// parameters. Small enough to rebuild per turn.
+type index struct {
+    docs  map[string]map[string]float64 // tool → term → weight
+    lens  map[string]float64
+    df    map[string]int
+    avgLn float64
+}
+
+// nameWeight counts a term of the tool's name this many times, since a
+// name says more than a word somewhere in a description.
+const nameWeight = 3
+
+func newIndex(ts []tools.Tool, qualify func(string) string) *index {
+    ix := &index{docs: map[string]map[string]float64{}, lens: map[string]float64{}, df: map[string]int{}}
+    total := 0.0
+    for _, t := range ts {
+        doc := map[string]float64{}
+        add := func(text string, w float64) {
+            for _, term := range terms(text) {
+                doc[term] += w
+            }
+        }
+        add(t.Name, nameWeight)
+        add(qualify(t.Name), 1)
+        add(t.Description, 1)
+        if props, ok := t.Parameters["properties"].(map[string]interface{}); ok {
+            for name, p := range props {
+                add(name, 1)
+                if pm, ok := p.(map[string]interface{}); ok {
+                    if d, ok := pm["description"].(string); ok {
+                        add(d, 1)
+                    }
+                }
+            }
+        }
+        ln := 0.0
+        for term, w := range doc {
+            ln += w
+            ix.df[term]++
+        }
+        ix.docs[t.Name] = doc
+        ix.lens[t.Name] = ln
+        total += ln
+    }
+    if len(ts) > 0 {
+        ix.avgLn = total / float64(len(ts))
+    }
+    return ix
+}
+
+// score returns the BM25 score of every tool for query.
+func (ix *index) score(query string) map[string]float64 {
+    const k1, b = 1.2, 0.75
+    n := float64(len(ix.docs))
+    out := make(map[string]float64, len(ix.docs))
+    for _, term := range terms(query) {
+        df := float64(ix.df[term])
+        if df == 0 {
+            continue
+        }
+        idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+        for name, doc := range ix.docs {
+            tf := doc[term]
+            if tf == 0 {
+                continue
+            }
+            norm := 1.0
+            if ix.avgLn > 0 {
+                norm = 1 - b + b*ix.lens[name]/ix.avgLn
+            }
+            out[name] += idf * tf * (k1 + 1) / (tf + k1*norm)
+        }
+    }
+    return out
+}
+
+// stopWords are left out of the index and of queries.
+var stopWords = map[string]bool{
+    "a": true, "an": true, "and": true, "are": true, "as": true, "at": true,
+    "be": true, "by": true, "can": true, "do": true, "for": true, "from": true,
+    "how": true, "i": true, "in": true, "is": true, "it": true, "me": true,
+    "my": true, "of": true, "on": true, "or": true, "please": true, "the": true,
+    "this": true, "to": true, "what": true, "with": true, "you": true,
+}
+
+// terms splits text into lower-case words (breaking snake_case, dotted
+// and camelCase names too), drops stop words and folds plurals so
+// "tracks" finds get_track.
+func terms(text string) []string {
+    var (
+        out  []string
+        word []rune
+    )
+    flush := func() {
+        if len(word) == 0 {
+            return
+        }
+        w := strings.ToLower(string(word))
+        word = word[:0]
+        if !stopWords[w] {
+            out = append(out, stem(w))
+        }
+    }
+    var prev rune
+    for _, r := range text {
+        switch {
+        case unicode.IsUpper(r) && unicode.IsLower(prev):
+            flush()
+            word = append(word, r)
+        case unicode.IsLetter(r) || unicode.IsDigit(r):
+            word = append(word, r)
+        default:
+            flush()
+        }
+        prev = r
+    }
+    flush()
+    return out
+}
+
+// stem folds plurals: tracks → track, entries → entry.
+func stem(w string) string {
+    switch {
+    case len(w) > 4 && strings.HasSuffix(w, "ies"):
+        return w[:len(w)-3] + "y"
+    case len(w) > 3 && strings.HasSuffix(w, "s") && !strings.HasSuffix(w, "ss"):
+        return w[:len(w)-1]
+    }
+    return w
+}
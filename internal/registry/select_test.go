@@ -0,0 +1,109 @@
+package registry
+
+import (
+    "reflect"
+    "testing"
+
+    "github.com/johnjallday/dolphin-tool-calling-agent/pkg/tools"
+)
+
+// corpus is a handful of packs an agent might load together.
+func corpus(t *testing.T) *ToolRegistry {
+    t.Helper()
+    tool := func(name, desc string, params ...string) tools.Tool {
+        props := map[string]interface{}{}
+        for _, p := range params {
+            props[p] = map[string]interface{}{"type": "string"}
+        }
+        return tools.Tool{Name: name, Description: desc,
+            Parameters: map[string]interface{}{"type": "object", "properties": props}}
+    }
+    packs := []tools.ToolPackage{
+        {Name: "reaper", Tools: []tools.Tool{
+            tool("get_tracks", "List the tracks of the open Reaper project"),
+            tool("render_project", "Render the project to an audio file", "format"),
+            tool("set_tempo", "Change the project tempo in BPM", "bpm"),
+        }},
+        {Name: "weather", Tools: []tools.Tool{
+            tool("get_weather", "Current weather and temperature for a city", "city"),
+            tool("get_forecast", "Weather forecast for the next days", "city", "days"),
+        }},
+        {Name: "notes", Tools: []tools.Tool{
+            tool("add_note", "Save a note", "text"),
+            tool("list_entries", "List saved notes"),
+        }},
+        {Name: "calc", Tools: []tools.Tool{
+            tool("add", "Add two numbers", "a", "b"),
+        }},
+    }
+    named, _, err := Assign(packs, Naming{})
+    if err != nil {
+        t.Fatal(err)
+    }
+    r := NewToolRegistry()
+    for _, n := range named {
+        if err := r.RegisterNamed(n); err != nil {
+            t.Fatal(err)
+        }
+    }
+    return r
+}
+
+func TestSelectRanks(t *testing.T) {
+    r := corpus(t)
+    for _, c := range []struct {
+        query string
+        max   int
+        want  []string
+    }{
+        {"what's the weather like in Lisbon?", 1, []string{"get_weather"}},
+        {"will it rain in the next days? forecast please", 1, []string{"get_forecast"}},
+        {"show me the tracks", 1, []string{"get_tracks"}},
+        {"render the project as wav", 1, []string{"render_project"}},
+        // both match weather; only the forecast matches twice
+        {"weather in Porto and then the forecast", 2, []string{"get_forecast", "get_weather"}},
+        // nothing matches: the first tools by name fill the offer
+        {"hello there", 2, []string{"add", "add_note"}},
+    } {
+        got := r.Select(c.query, Selection{Max: c.max}, nil)
+        if !reflect.DeepEqual(got.Tools, c.want) || got.Pinned != 0 || got.Total != 8 {
+            t.Errorf("%q: %+v, want %v", c.query, got, c.want)
+        }
+    }
+}
+
+func TestSelectKeepsPinnedAndSticky(t *testing.T) {
+    r := corpus(t)
+    got := r.Select("weather in Lisbon", Selection{Max: 1, Pinned: []string{"notes.*"}}, []string{"set_tempo", "gone"})
+    want := []string{"add_note", "list_entries", "set_tempo", "get_weather"}
+    if !reflect.DeepEqual(got.Tools, want) || got.Pinned != 3 {
+        t.Errorf("got %+v, want %v with 3 pinned", got, want)
+    }
+
+    // hidden tools aren't offered even when pinned
+    if err := r.SetFilter(Filter{Exclude: []string{"add_note"}}); err != nil {
+        t.Fatal(err)
+    }
+    got = r.Select("weather", Selection{Max: 1, Pinned: []string{"notes.*"}}, nil)
+    if !reflect.DeepEqual(got.Tools, []string{"list_entries", "get_weather"}) || got.Total != 7 {
+        t.Errorf("with add_note hidden: %+v", got)
+    }
+
+    // room for everything: all of them
+    if got := r.Select("weather", Selection{Max: 10}, nil); len(got.Tools) != 7 {
+        t.Errorf("under max: %+v", got)
+    }
+}
+
+func TestTerms(t *testing.T) {
+    for in, want := range map[string][]string{
+        "get_tracks":                {"get", "track"},
+        "reaper.renderProject":      {"reaper", "render", "project"},
+        "List the entries, please!": {"list", "entry"},
+        "class bus":                 {"class", "bus"},
+    } {
+        if got := terms(in); !reflect.DeepEqual(got, want) {
+            t.Errorf("terms(%q) = %v, want %v", in, got, want)
+        }
+    }
+}
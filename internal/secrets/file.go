@@ -0,0 +1,272 @@
+package secrets
+
+import (
+  "crypto/rand"
+  "encoding/json"
+  "errors"
+  "fmt"
+  "os"
+  "path/filepath"
+  "sort"
+  "sync"
+
+  "golang.org/x/crypto/chacha20poly1305"
+  "golang.org/x/crypto/scrypt"
+)
+
+// PassphraseEnv, when set, unlocks the secrets file without a prompt.
+const PassphraseEnv = "DOLPHIN_SECRETS_PASSPHRASE"
+
+// Passphrase asks the user for the secrets file's passphrase; create is
+// true when the file doesn't exist yet and the passphrase is a new one.
+type Passphrase func(create bool) (string, error)
+
+// ErrLocked is returned when the file is needed but there is no way to
+// ask for its passphrase.
+var ErrLocked = fmt.Errorf("the secrets file is locked (set %s)", PassphraseEnv)
+
+// scrypt parameters for new files; existing ones keep theirs.
+const (
+  scryptN = 1 << 15
+  scryptR = 8
+  scryptP = 1
+)
+
+// envelope is the file on disk: the secrets as JSON, sealed with
+// XChaCha20-Poly1305 under a key scrypt derives from the passphrase.
+type envelope struct {
+  Version int    `json:"version"`
+  N       int    `json:"n"`
+  R       int    `json:"r"`
+  P       int    `json:"p"`
+  Salt    []byte `json:"salt"`
+  Nonce   []byte `json:"nonce"`
+  Data    []byte `json:"data"`
+}
+
+// File keeps secrets in one encrypted file. It asks for the passphrase
+// the first time it is read and keeps the key until Lock.
+type File struct {
+  path       string
+  passphrase Passphrase
+
+  mu     sync.Mutex
+  env    *envelope         // header of the unlocked file (nil while locked)
+  key    []byte
+  values map[string]string
+}
+
+// NewFile returns the secrets file at path. passphrase may be nil, in
+// which case only PassphraseEnv can unlock it.
+func NewFile(path string, passphrase Passphrase) *File {
+  return &File{path: path, passphrase: passphrase}
+}
+
+func (f *File) Name() string { return "file" }
+
+// Path is where the file lives.
+func (f *File) Path() string { return f.path }
+
+// Exists reports whether the file has been created.
+func (f *File) Exists() bool {
+  _, err := os.Stat(f.path)
+  return err == nil
+}
+
+// Lookup returns the named secret. A file that doesn't exist yet has
+// none and doesn't ask for a passphrase.
+func (f *File) Lookup(name string) (string, bool, error) {
+  if !f.Exists() {
+    return "", false, nil
+  }
+  f.mu.Lock()
+  defer f.mu.Unlock()
+  if err := f.unlockLocked(false); err != nil {
+    return "", false, err
+  }
+  v, ok := f.values[name]
+  return v, ok, nil
+}
+
+// Names lists the stored secrets, never their values.
+func (f *File) Names() ([]string, error) {
+  if !f.Exists() {
+    return nil, nil
+  }
+  f.mu.Lock()
+  defer f.mu.Unlock()
+  if err := f.unlockLocked(false); err != nil {
+    return nil, err
+  }
+  names := make([]string, 0, len(f.values))
+  for n := range f.values {
+    names = append(names, n)
+  }
+  sort.Strings(names)
+  return names, nil
+}
+
+// Set stores a secret, creating the file (and its passphrase) if needed.
+func (f *File) Set(name, value string) error {
+  if err := CheckName(name); err != nil {
+    return err
+  }
+  if value == "" {
+    return fmt.Errorf("secret %s: empty value", name)
+  }
+  f.mu.Lock()
+  defer f.mu.Unlock()
+  if err := f.unlockLocked(!f.Exists()); err != nil {
+    return err
+  }
+  f.values[name] = value
+  return f.saveLocked()
+}
+
+// Delete removes a secret; it is an error if there is none by that name.
+func (f *File) Delete(name string) error {
+  if !f.Exists() {
+    return fmt.Errorf("secret %s is not stored", name)
+  }
+  f.mu.Lock()
+  defer f.mu.Unlock()
+  if err := f.unlockLocked(false); err != nil {
+    return err
+  }
+  if _, ok := f.values[name]; !ok {
+    return fmt.Errorf("secret %s is not stored", name)
+  }
+  delete(f.values, name)
+  return f.saveLocked()
+}
+
+// Lock forgets the key and the decrypted values.
+func (f *File) Lock() {
+  f.mu.Lock()
+  defer f.mu.Unlock()
+  f.env, f.key, f.values = nil, nil, nil
+}
+
+func (f *File) ask(create bool) (string, error) {
+  if p := os.Getenv(PassphraseEnv); p != "" {
+    return p, nil
+  }
+  if f.passphrase == nil {
+    return "", ErrLocked
+  }
+  p, err := f.passphrase(create)
+  if err != nil {
+    return "", err
+  }
+  if p == "" {
+    return "", errors.New("empty passphrase")
+  }
+  return p, nil
+}
+
+// unlockLocked decrypts the file, or with create starts a new one.
+func (f *File) unlockLocked(create bool) error {
+  if f.values != nil {
+    return nil
+  }
+  if create {
+    pass, err := f.ask(true)
+    if err != nil {
+      return err
+    }
+    env := &envelope{Version: 1, N: scryptN, R: scryptR, P: scryptP, Salt: make([]byte, 16)}
+    if _, err := rand.Read(env.Salt); err != nil {
+      return err
+    }
+    key, err := deriveKey(pass, env)
+    if err != nil {
+      return err
+    }
+    f.env, f.key, f.values = env, key, map[string]string{}
+    return nil
+  }
+
+  raw, err := os.ReadFile(f.path)
+  if err != nil {
+    return err
+  }
+  var env envelope
+  if err := json.Unmarshal(raw, &env); err != nil {
+    return fmt.Errorf("%s: %w", f.path, err)
+  }
+  if env.Version != 1 {
+    return fmt.Errorf("%s: unsupported version %d", f.path, env.Version)
+  }
+  pass, err := f.ask(false)
+  if err != nil {
+    return err
+  }
+  key, err := deriveKey(pass, &env)
+  if err != nil {
+    return err
+  }
+  aead, err := chacha20poly1305.NewX(key)
+  if err != nil {
+    return err
+  }
+  plain, err := aead.Open(nil, env.Nonce, env.Data, env.header())
+  if err != nil {
+    return fmt.Errorf("%s: wrong passphrase (or the file is damaged)", f.path)
+  }
+  values := map[string]string{}
+  if err := json.Unmarshal(plain, &values); err != nil {
+    return fmt.Errorf("%s: %w", f.path, err)
+  }
+  f.env, f.key, f.values = &env, key, values
+  return nil
+}
+
+// saveLocked seals the values under a fresh nonce and replaces the file.
+func (f *File) saveLocked() error {
+  plain, err := json.Marshal(f.values)
+  if err != nil {
+    return err
+  }
+  aead, err := chacha20poly1305.NewX(f.key)
+  if err != nil {
+    return err
+  }
+  env := *f.env
+  env.Nonce = make([]byte, aead.NonceSize())
+  if _, err := rand.Read(env.Nonce); err != nil {
+    return err
+  }
+  env.Data = aead.Seal(nil, env.Nonce, plain, env.header())
+  raw, err := json.MarshalIndent(env, "", "  ")
+  if err != nil {
+    return err
+  }
+
+  if err := os.MkdirAll(filepath.Dir(f.path), 0o700); err != nil {
+    return err
+  }
+  tmp := f.path + ".tmp"
+  if err := os.WriteFile(tmp, raw, 0o600); err != nil {
+    os.Remove(tmp)
+    return err
+  }
+  if err := os.Rename(tmp, f.path); err != nil {
+    os.Remove(tmp)
+    return err
+  }
+  f.env = &env
+  return nil
+}
+
+// header is authenticated with the data, so the parameters can't be
+// swapped under it.
+func (e *envelope) header() []byte {
+  return []byte(fmt.Sprintf("dolphin-secrets v%d n=%d r=%d p=%d salt=%x", e.Version, e.N, e.R, e.P, e.Salt))
+}
+
+func deriveKey(pass string, e *envelope) ([]byte, error) {
+  if e.N < 2 || e.R < 1 || e.P < 1 || len(e.Salt) < 8 {
+    return nil, fmt.Errorf("bad key derivation parameters")
+  }
+  return scrypt.Key([]byte(pass), e.Salt, e.N, e.R, e.P, chacha20poly1305.KeySize)
+}
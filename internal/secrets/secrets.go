@@ -0,0 +1,120 @@
+// Package secrets keeps credentials for the model provider and toolpacks
+// out of config files. TOML refers to them as "secret:name"; the value is
+// looked up in the environment (DOLPHIN_SECRET_<NAME>) and then in an
+// encrypted file under the config dir.
+package secrets
+
+import (
+  "fmt"
+  "os"
+  "regexp"
+  "sort"
+  "strings"
+  "sync"
+
+  "github.com/johnjallday/dolphin-tool-calling-agent/pkg/tools"
+)
+
+// Backend is somewhere secrets are kept.
+type Backend interface {
+  Name() string
+  // Lookup returns the named secret; ok is false if it isn't there.
+  Lookup(name string) (value string, ok bool, err error)
+}
+
+var validName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// CheckName rejects names that couldn't be stored or referenced.
+func CheckName(name string) error {
+  if !validName.MatchString(name) {
+    return fmt.Errorf("invalid secret name %q (use letters, digits, _, . and -)", name)
+  }
+  return nil
+}
+
+// Env reads secrets from environment variables named by EnvVar.
+type Env struct{}
+
+func (Env) Name() string { return "env" }
+
+func (Env) Lookup(name string) (string, bool, error) {
+  v, ok := os.LookupEnv(EnvVar(name))
+  return v, ok && v != "", nil
+}
+
+// EnvVar is the variable Env reads name from: DOLPHIN_SECRET_ and the
+// name upper-cased, with . and - as _ (openai.key → DOLPHIN_SECRET_OPENAI_KEY).
+func EnvVar(name string) string {
+  return "DOLPHIN_SECRET_" + strings.NewReplacer(".", "_", "-", "_").Replace(strings.ToUpper(name))
+}
+
+// Store looks secrets up in its backends in order and remembers the
+// values it handed out, so Redact can mask them. It implements
+// tools.Secrets.
+type Store struct {
+  backends []Backend
+
+  mu       sync.Mutex
+  revealed map[string]string // value → name
+}
+
+// New returns a store over backends, the first having precedence.
+func New(backends ...Backend) *Store {
+  return &Store{backends: backends, revealed: map[string]string{}}
+}
+
+// Secret returns the named secret from the first backend that has it.
+func (s *Store) Secret(name string) (string, error) {
+  if err := CheckName(name); err != nil {
+    return "", err
+  }
+  for _, b := range s.backends {
+    v, ok, err := b.Lookup(name)
+    if err != nil {
+      return "", fmt.Errorf("secret %s: %s: %w", name, b.Name(), err)
+    }
+    if ok {
+      s.mu.Lock()
+      s.revealed[v] = name
+      s.mu.Unlock()
+      return v, nil
+    }
+  }
+  return "", fmt.Errorf("secret %s is not set (store it with `secret set %s` or set %s)", name, name, EnvVar(name))
+}
+
+// Resolve returns v, or the secret it names if it is a "secret:name"
+// reference.
+func (s *Store) Resolve(v string) (string, error) {
+  name, ok := tools.SecretRef(v)
+  if !ok {
+    return v, nil
+  }
+  return s.Secret(name)
+}
+
+// minRedact is the shortest value Redact masks; shorter ones would mask
+// ordinary words.
+const minRedact = 4
+
+// Redact replaces every secret handed out so far that occurs in text with
+// [secret:name].
+func (s *Store) Redact(text string) string {
+  s.mu.Lock()
+  values := make([]string, 0, len(s.revealed))
+  for v := range s.revealed {
+    if len(v) >= minRedact {
+      values = append(values, v)
+    }
+  }
+  names := s.revealed
+  // longest first, so a secret containing another is masked whole
+  sort.Slice(values, func(i, j int) bool { return len(values[i]) > len(values[j]) })
+  for _, v := range values {
+    if strings.Contains(text, v) {
+      text = strings.ReplaceAll(text, v, "["+tools.SecretPrefix+names[v]+"]")
+    }
+  }
+  s.mu.Unlock()
+  return text
+}
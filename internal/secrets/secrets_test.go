@@ -0,0 +1,128 @@
+package secrets
+
+import (
+  "encoding/json"
+  "os"
+  "path/filepath"
+  "strings"
+  "testing"
+)
+
+func pass(p string) Passphrase {
+  return func(bool) (string, error) { return p, nil }
+}
+
+// testFile is a secrets file holding openai=sk-test, sealed with "pw".
+func testFile(t *testing.T) string {
+  t.Helper()
+  t.Setenv(PassphraseEnv, "")
+  path := filepath.Join(t.TempDir(), "secrets.json")
+  if err := NewFile(path, pass("pw")).Set("openai", "sk-test"); err != nil {
+    t.Fatal(err)
+  }
+  return path
+}
+
+func TestFileRoundTrip(t *testing.T) {
+  path := testFile(t)
+  f := NewFile(path, pass("pw"))
+  if err := f.Set("weather", "w-123"); err != nil {
+    t.Fatal(err)
+  }
+  f.Lock()
+  for name, want := range map[string]string{"openai": "sk-test", "weather": "w-123"} {
+    if v, ok, err := f.Lookup(name); err != nil || !ok || v != want {
+      t.Errorf("%s: %q, %v, %v", name, v, ok, err)
+    }
+  }
+  if names, err := f.Names(); err != nil || strings.Join(names, ",") != "openai,weather" {
+    t.Errorf("names %v, %v", names, err)
+  }
+
+  raw, _ := os.ReadFile(path)
+  if strings.Contains(string(raw), "sk-test") {
+    t.Error("the value is stored in the clear")
+  }
+  if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+    t.Errorf("temp file left behind: %v", err)
+  }
+  if fi, _ := os.Stat(path); fi.Mode().Perm() != 0o600 {
+    t.Errorf("mode %v", fi.Mode().Perm())
+  }
+}
+
+func TestWrongPassphrase(t *testing.T) {
+  path := testFile(t)
+  _, _, err := NewFile(path, pass("nope")).Lookup("openai")
+  if err == nil || !strings.Contains(err.Error(), "wrong passphrase") {
+    t.Errorf("got %v", err)
+  }
+  if _, _, err := NewFile(path, nil).Lookup("openai"); err != ErrLocked {
+    t.Errorf("without a prompt: %v, want ErrLocked", err)
+  }
+}
+
+func TestTamperedFileRejected(t *testing.T) {
+  for _, tamper := range []struct {
+    name string
+    do   func(e *envelope)
+  }{
+    {"salt", func(e *envelope) { e.Salt[0] ^= 1 }},
+    {"cost", func(e *envelope) { e.N /= 2 }},
+    {"data", func(e *envelope) { e.Data[0] ^= 1 }},
+    {"version", func(e *envelope) { e.Version = 2 }},
+  } {
+    t.Run(tamper.name, func(t *testing.T) {
+      path := testFile(t)
+      raw, _ := os.ReadFile(path)
+      var e envelope
+      if err := json.Unmarshal(raw, &e); err != nil {
+        t.Fatal(err)
+      }
+      tamper.do(&e)
+      raw, _ = json.Marshal(e)
+      os.WriteFile(path, raw, 0o600)
+      if v, _, err := NewFile(path, pass("pw")).Lookup("openai"); err == nil {
+        t.Errorf("tampered file opened: %q", v)
+      }
+    })
+  }
+}
+
+func TestEnvTakesPrecedence(t *testing.T) {
+  path := testFile(t)
+  s := New(Env{}, NewFile(path, pass("pw")))
+  if v, err := s.Secret("openai"); err != nil || v != "sk-test" {
+    t.Errorf("from the file: %q, %v", v, err)
+  }
+  t.Setenv("DOLPHIN_SECRET_OPENAI", "sk-env")
+  if v, err := s.Secret("openai"); err != nil || v != "sk-env" {
+    t.Errorf("with the env set: %q, %v", v, err)
+  }
+  if _, err := s.Secret("missing"); err == nil || !strings.Contains(err.Error(), "DOLPHIN_SECRET_MISSING") {
+    t.Errorf("missing: %v", err)
+  }
+  if _, err := s.Secret("../x"); err == nil {
+    t.Error("a bad name was looked up")
+  }
+}
+
+func TestRedact(t *testing.T) {
+  t.Setenv("DOLPHIN_SECRET_KEY", "abcd")
+  t.Setenv("DOLPHIN_SECRET_LONGKEY", "abcd1234")
+  t.Setenv("DOLPHIN_SECRET_PIN", "123")
+  s := New(Env{})
+  if got := s.Redact("abcd1234"); got != "abcd1234" {
+    t.Errorf("redacted a value never handed out: %q", got)
+  }
+  for _, name := range []string{"key", "longkey", "pin"} {
+    if _, err := s.Secret(name); err != nil {
+      t.Fatal(err)
+    }
+  }
+  got := s.Redact("token abcd1234, prefix abcd, pin 123")
+  want := "token [secret:longkey], prefix [secret:key], pin 123"
+  if got != want {
+    t.Errorf("got %q, want %q", got, want)
+  }
+}
@@ -0,0 +1,277 @@
+// Package semver compares toolpack and dolphin versions and matches them
+// against the constraints agents and toolpack manifests may state, e.g.
+// "^0.2", "~1.4.1", ">=0.3, <0.5" or an exact "v0.2.1".
+package semver
+
+import (
+  "fmt"
+  "strings"
+)
+
+// Compare orders two version strings the semver way: an optional leading
+// "v", numeric dot-separated parts (missing ones count as 0), and a "-pre"
+// suffix sorting before the release. It returns -1, 0 or 1.
+func Compare(a, b string) int {
+  a, b = strings.TrimPrefix(a, "v"), strings.TrimPrefix(b, "v")
+  a, _, _ = strings.Cut(a, "+")
+  b, _, _ = strings.Cut(b, "+")
+  ac, apre, _ := strings.Cut(a, "-")
+  bc, bpre, _ := strings.Cut(b, "-")
+  ap, bp := strings.Split(ac, "."), strings.Split(bc, ".")
+  for len(ap) < len(bp) {
+    ap = append(ap, "0")
+  }
+  for len(bp) < len(ap) {
+    bp = append(bp, "0")
+  }
+  for i := range ap {
+    if c := compareIdent(ap[i], bp[i]); c != 0 {
+      return c
+    }
+  }
+  switch {
+  case apre == bpre:
+    return 0
+  case apre == "":
+    return 1
+  case bpre == "":
+    return -1
+  }
+  ai, bi := strings.Split(apre, "."), strings.Split(bpre, ".")
+  for i := 0; i < len(ai) && i < len(bi); i++ {
+    if c := compareIdent(ai[i], bi[i]); c != 0 {
+      return c
+    }
+  }
+  return compareInt(len(ai), len(bi))
+}
+
+// Valid reports whether v looks like a version: numeric parts, optionally
+// prefixed with "v" and followed by -pre or +build.
+func Valid(v string) bool {
+  v = strings.TrimPrefix(v, "v")
+  v, _, _ = strings.Cut(v, "+")
+  v, _, _ = strings.Cut(v, "-")
+  for _, p := range strings.Split(v, ".") {
+    if _, ok := atoi(p); !ok {
+      return false
+    }
+  }
+  return true
+}
+
+// Prerelease reports whether v carries a -pre suffix.
+func Prerelease(v string) bool {
+  v, _, _ = strings.Cut(strings.TrimPrefix(v, "v"), "+")
+  return strings.Contains(v, "-")
+}
+
+// compareIdent compares numerically when both are numbers, else as text
+// (numbers sorting first, as in semver).
+func compareIdent(a, b string) int {
+  an, aok := atoi(a)
+  bn, bok := atoi(b)
+  switch {
+  case aok && bok:
+    return compareInt(an, bn)
+  case aok:
+    return -1
+  case bok:
+    return 1
+  }
+  return strings.Compare(a, b)
+}
+
+func atoi(s string) (int, bool) {
+  if s == "" {
+    return 0, false
+  }
+  n := 0
+  for _, r := range s {
+    if r < '0' || r > '9' {
+      return 0, false
+    }
+    n = n*10 + int(r-'0')
+  }
+  return n, true
+}
+
+func compareInt(a, b int) int {
+  switch {
+  case a < b:
+    return -1
+  case a > b:
+    return 1
+  }
+  return 0
+}
+
+// Constraint is a set of versions: every comparison must hold. The zero
+// Constraint allows anything.
+type Constraint struct {
+  src  string
+  cmps []comparison
+}
+
+type comparison struct {
+  op string // =, >, >=, <, <=
+  v  string
+}
+
+// Any allows every version.
+var Any = Constraint{}
+
+// ParseConstraint reads a comma-separated list of comparisons. Each is
+//
+//	1.2.3 or =1.2.3   exactly that version
+//	>=1.2 >1.2 <2 <=2 the usual
+//	^1.2              compatible: >=1.2.0 <2.0.0 (^0.2 means <0.3.0,
+//	                  ^0.0.3 means <0.0.4)
+//	~1.2.3            patch updates: >=1.2.3 <1.3.0 (~1 means <2)
+//	* or ""           anything (so is "latest")
+func ParseConstraint(s string) (Constraint, error) {
+  s = strings.TrimSpace(s)
+  c := Constraint{src: s}
+  if s == "" || s == "*" || s == "latest" {
+    c.src = ""
+    return c, nil
+  }
+  for _, part := range strings.Split(s, ",") {
+    part = strings.TrimSpace(part)
+    op := ""
+    for _, o := range []string{">=", "<=", "^", "~", ">", "<", "="} {
+      if strings.HasPrefix(part, o) {
+        op = o
+        break
+      }
+    }
+    v := strings.TrimSpace(strings.TrimPrefix(part, op))
+    if v == "" || !Valid(v) {
+      return Constraint{}, fmt.Errorf("bad version constraint %q", s)
+    }
+    switch op {
+    case "^":
+      c.cmps = append(c.cmps, comparison{">=", v}, comparison{"<", caretLimit(v)})
+    case "~":
+      c.cmps = append(c.cmps, comparison{">=", v}, comparison{"<", tildeLimit(v)})
+    case "":
+      c.cmps = append(c.cmps, comparison{"=", v})
+    default:
+      c.cmps = append(c.cmps, comparison{op, v})
+    }
+  }
+  return c, nil
+}
+
+// String is the constraint as written ("*" for Any).
+func (c Constraint) String() string {
+  if c.src == "" {
+    return "*"
+  }
+  return c.src
+}
+
+// IsAny reports whether c allows every version.
+func (c Constraint) IsAny() bool { return len(c.cmps) == 0 }
+
+// Exact returns the version c pins, if it is a single =version.
+func (c Constraint) Exact() (string, bool) {
+  if len(c.cmps) == 1 && c.cmps[0].op == "=" {
+    return c.cmps[0].v, true
+  }
+  return "", false
+}
+
+// Allows reports whether v is in the set. Pre-releases are only allowed
+// when a comparison names a pre-release itself, so "^0.2" never picks
+// 0.3.0-rc1 and nothing picks one by accident.
+func (c Constraint) Allows(v string) bool {
+  if Prerelease(v) && !c.mentionsPrerelease() {
+    return false
+  }
+  for _, cmp := range c.cmps {
+    r := Compare(v, cmp.v)
+    ok := false
+    switch cmp.op {
+    case "=":
+      ok = r == 0
+    case ">":
+      ok = r > 0
+    case ">=":
+      ok = r >= 0
+    case "<":
+      ok = r < 0
+    case "<=":
+      ok = r <= 0
+    }
+    if !ok {
+      return false
+    }
+  }
+  return true
+}
+
+func (c Constraint) mentionsPrerelease() bool {
+  for _, cmp := range c.cmps {
+    if Prerelease(cmp.v) {
+      return true
+    }
+  }
+  return false
+}
+
+// caretLimit is the exclusive upper bound of ^v: the first non-zero part
+// is bumped (or the last one given, so ^0.0 means <0.1.0).
+func caretLimit(v string) string {
+  parts, given := numericParts(v)
+  for i := 0; i < given; i++ {
+    if parts[i] != 0 || i == given-1 {
+      return bump(parts, i)
+    }
+  }
+  return bump(parts, 0)
+}
+
+// tildeLimit is the exclusive upper bound of ~v: the minor part is bumped,
+// or the major when only that is given.
+func tildeLimit(v string) string {
+  parts, given := numericParts(v)
+  if given < 2 {
+    return bump(parts, 0)
+  }
+  return bump(parts, 1)
+}
+
+// numericParts returns v's numbers padded to major.minor.patch, and how
+// many were actually written.
+func numericParts(v string) ([]int, int) {
+  v = strings.TrimPrefix(v, "v")
+  v, _, _ = strings.Cut(v, "+")
+  v, _, _ = strings.Cut(v, "-")
+  var out []int
+  for _, p := range strings.Split(v, ".") {
+    n, _ := atoi(p)
+    out = append(out, n)
+  }
+  given := len(out)
+  for len(out) < 3 {
+    out = append(out, 0)
+  }
+  return out, given
+}
+
+// bump increments part i and zeroes the ones after it.
+func bump(parts []int, i int) string {
+  out := make([]string, len(parts))
+  for j := range parts {
+    switch {
+    case j < i:
+      out[j] = fmt.Sprint(parts[j])
+    case j == i:
+      out[j] = fmt.Sprint(parts[j] + 1)
+    default:
+      out[j] = "0"
+    }
+  }
+  return strings.Join(out, ".")
+}
@@ -0,0 +1,125 @@
+package semver
+
+import "testing"
+
+func TestCompare(t *testing.T) {
+  tests := []struct {
+    a, b string
+    want int
+  }{
+    {"1.2.3", "1.2.3", 0},
+    {"v1.2.3", "1.2.3", 0},
+    {"1.2", "1.2.0", 0},
+    {"1.2.3+build.7", "1.2.3", 0},
+    {"1.2.3", "1.2.4", -1},
+    {"1.10.0", "1.9.0", 1},
+    {"2", "1.99.99", 1},
+    {"1.0.0-rc1", "1.0.0", -1},
+    {"1.0.0-alpha", "1.0.0-beta", -1},
+    {"1.0.0-alpha.2", "1.0.0-alpha.10", -1},
+    {"1.0.0-2", "1.0.0-alpha", -1}, // numbers sort before text
+    {"1.0.0-alpha", "1.0.0-alpha.1", -1},
+    {"0.3.0-rc1", "0.2.9", 1},
+  }
+  for _, tt := range tests {
+    if got := Compare(tt.a, tt.b); got != tt.want {
+      t.Errorf("Compare(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+    }
+    if got := Compare(tt.b, tt.a); got != -tt.want {
+      t.Errorf("Compare(%q, %q) = %d, want %d", tt.b, tt.a, got, -tt.want)
+    }
+  }
+}
+
+func TestValid(t *testing.T) {
+  for _, v := range []string{"1", "1.2", "v1.2.3", "1.2.3-rc.1", "1.2.3+abc", "0.0.0"} {
+    if !Valid(v) {
+      t.Errorf("Valid(%q) = false", v)
+    }
+  }
+  for _, v := range []string{"", "v", "1.x", "latest", "1..2", "-1", "1.2.3a"} {
+    if Valid(v) {
+      t.Errorf("Valid(%q) = true", v)
+    }
+  }
+}
+
+func TestConstraintAllows(t *testing.T) {
+  tests := []struct {
+    constraint string
+    allowed    []string
+    refused    []string
+  }{
+    {"", []string{"0.0.1", "9.9.9"}, []string{"1.0.0-rc1"}},
+    {"*", []string{"1.0.0"}, nil},
+    {"latest", []string{"1.0.0"}, nil},
+    {"1.2.3", []string{"1.2.3", "v1.2.3"}, []string{"1.2.4", "1.2.2"}},
+    {"=v1.2", []string{"1.2.0"}, []string{"1.2.1"}},
+    {"^1.2", []string{"1.2.0", "1.9.9"}, []string{"1.1.9", "2.0.0", "1.3.0-rc1"}},
+    {"^1.2.3", []string{"1.2.3", "1.3.0"}, []string{"1.2.2", "2.0.0"}},
+    {"^0.2", []string{"0.2.0", "0.2.9"}, []string{"0.3.0", "0.1.9", "0.3.0-rc1"}},
+    {"^0.0.3", []string{"0.0.3"}, []string{"0.0.4", "0.0.2"}},
+    {"^0.0", []string{"0.0.0", "0.0.9"}, []string{"0.1.0"}},
+    {"~1.2.3", []string{"1.2.3", "1.2.9"}, []string{"1.3.0", "1.2.2"}},
+    {"~1.2", []string{"1.2.0", "1.2.9"}, []string{"1.3.0"}},
+    {"~1", []string{"1.0.0", "1.9.0"}, []string{"2.0.0"}},
+    {">=0.3, <0.5", []string{"0.3.0", "0.4.9"}, []string{"0.2.9", "0.5.0"}},
+    {">1, <=2", []string{"1.0.1", "2.0.0"}, []string{"1.0.0", "2.0.1"}},
+    {"^1.0.0-rc1", []string{"1.0.0-rc1", "1.0.0-rc2", "1.0.0", "1.5.0", "1.1.0-beta"}, []string{"1.0.0-alpha", "2.0.0"}},
+    {">=2.0.0-beta", []string{"2.0.0-beta", "2.0.0", "3.0.0"}, []string{"2.0.0-alpha", "1.9.0"}},
+  }
+  for _, tt := range tests {
+    c, err := ParseConstraint(tt.constraint)
+    if err != nil {
+      t.Errorf("ParseConstraint(%q): %v", tt.constraint, err)
+      continue
+    }
+    for _, v := range tt.allowed {
+      if !c.Allows(v) {
+        t.Errorf("%q refuses %s", tt.constraint, v)
+      }
+    }
+    for _, v := range tt.refused {
+      if c.Allows(v) {
+        t.Errorf("%q allows %s", tt.constraint, v)
+      }
+    }
+  }
+}
+
+func TestParseConstraint(t *testing.T) {
+  for _, bad := range []string{"^", ">=", "1.x", "^1, ~", ">=1,,<2", "~latest", "=>1"} {
+    if _, err := ParseConstraint(bad); err == nil {
+      t.Errorf("ParseConstraint(%q) accepted", bad)
+    }
+  }
+
+  c, _ := ParseConstraint(" ^0.2 ")
+  if c.String() != "^0.2" || c.IsAny() {
+    t.Errorf("^0.2: String %q, IsAny %v", c.String(), c.IsAny())
+  }
+  if _, ok := c.Exact(); ok {
+    t.Error("^0.2 is not exact")
+  }
+  for _, s := range []string{"", "*", "latest"} {
+    c, _ := ParseConstraint(s)
+    if !c.IsAny() || c.String() != "*" {
+      t.Errorf("%q: IsAny %v, String %q", s, c.IsAny(), c.String())
+    }
+  }
+  if v, ok := mustParse(t, "v1.2.3").Exact(); !ok || v != "v1.2.3" {
+    t.Errorf("Exact = %q, %v", v, ok)
+  }
+  if !Any.IsAny() || !Any.Allows("1.0.0") {
+    t.Error("Any doesn't allow everything")
+  }
+}
+
+func mustParse(t *testing.T, s string) Constraint {
+  t.Helper()
+  c, err := ParseConstraint(s)
+  if err != nil {
+    t.Fatal(err)
+  }
+  return c
+}
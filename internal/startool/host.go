@@ -0,0 +1,319 @@
+package startool
+
+import (
+  "context"
+  "errors"
+  "fmt"
+  "io"
+  "io/fs"
+  "math"
+  "net"
+  "net/http"
+  "os"
+  "path/filepath"
+  "sort"
+  "strings"
+  "syscall"
+  "time"
+
+  "go.starlark.net/starlark"
+  "go.starlark.net/starlarkstruct"
+
+  "github.com/johnjallday/dolphin-tool-calling-agent/pkg/tools"
+)
+
+// maxBody caps what http and files read into a script.
+const maxBody = 8 << 20
+
+// httpModule lets scripts talk to services on this machine:
+//
+//	r = http.get("http://localhost:8080/status", headers = {"Accept": "application/json"})
+//	r = http.post(url, body = json.encode(doc), headers = {...})
+//	r.status, r.body, r.headers
+//
+// Connections to anything but a loopback address are refused, whatever
+// the URL says, and proxies are never used.
+var httpModule = &starlarkstruct.Module{
+  Name: "http",
+  Members: starlark.StringDict{
+    "get":    starlark.NewBuiltin("http.get", httpCall("GET")),
+    "post":   starlark.NewBuiltin("http.post", httpCall("POST")),
+    "put":    starlark.NewBuiltin("http.put", httpCall("PUT")),
+    "delete": starlark.NewBuiltin("http.delete", httpCall("DELETE")),
+  },
+}
+
+var errNotLocal = errors.New("scripts may only connect to localhost")
+
+var localClient = &http.Client{
+  Timeout: callTimeout,
+  Transport: &http.Transport{
+    Proxy: nil,
+    DialContext: (&net.Dialer{
+      Timeout: 10 * time.Second,
+      // checked on the resolved address, so DNS tricks don't get out
+      Control: func(_, address string, _ syscall.RawConn) error {
+        host, _, err := net.SplitHostPort(address)
+        if err != nil {
+          return err
+        }
+        if ip := net.ParseIP(host); ip == nil || !ip.IsLoopback() {
+          return errNotLocal
+        }
+        return nil
+      },
+    }).DialContext,
+  },
+}
+
+func httpCall(method string) func(*starlark.Thread, *starlark.Builtin, starlark.Tuple, []starlark.Tuple) (starlark.Value, error) {
+  return func(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+    var (
+      url     string
+      body    string
+      headers *starlark.Dict
+    )
+    if err := starlark.UnpackArgs(b.Name(), args, kwargs, "url", &url, "body?", &body, "headers?", &headers); err != nil {
+      return nil, err
+    }
+    ctx, _ := thread.Local(ctxKey).(context.Context)
+    if ctx == nil {
+      ctx = context.Background()
+    }
+
+    req, err := http.NewRequestWithContext(ctx, method, url, strings.NewReader(body))
+    if err != nil {
+      return nil, fmt.Errorf("%s: %w", b.Name(), err)
+    }
+    if headers != nil {
+      for _, item := range headers.Items() {
+        k, ok1 := starlark.AsString(item[0])
+        v, ok2 := starlark.AsString(item[1])
+        if !ok1 || !ok2 {
+          return nil, fmt.Errorf("%s: headers must map strings to strings", b.Name())
+        }
+        req.Header.Set(k, v)
+      }
+    }
+    resp, err := localClient.Do(req)
+    if err != nil {
+      if errors.Is(err, errNotLocal) {
+        return nil, fmt.Errorf("%s %s: %w", b.Name(), url, errNotLocal)
+      }
+      return nil, fmt.Errorf("%s: %w", b.Name(), err)
+    }
+    defer resp.Body.Close()
+    raw, err := io.ReadAll(io.LimitReader(resp.Body, maxBody))
+    if err != nil {
+      return nil, fmt.Errorf("%s: %w", b.Name(), err)
+    }
+    hdrs := starlark.NewDict(len(resp.Header))
+    for k := range resp.Header {
+      hdrs.SetKey(starlark.String(k), starlark.String(resp.Header.Get(k)))
+    }
+    return starlarkstruct.FromStringDict(starlarkstruct.Default, starlark.StringDict{
+      "status":  starlark.MakeInt(resp.StatusCode),
+      "body":    starlark.String(raw),
+      "headers": hdrs,
+    }), nil
+  }
+}
+
+// secretsModule reads the user's secrets, those the pack lists in
+// secrets = [...] in its toolpack.toml, which dolphin masks in whatever
+// the tool returns:
+//
+//	key = secrets.get("weather_api_key")
+var secretsModule = &starlarkstruct.Module{
+  Name: "secrets",
+  Members: starlark.StringDict{
+    "get": starlark.NewBuiltin("secrets.get", func(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+      var name string
+      if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 1, &name); err != nil {
+        return nil, err
+      }
+      ctx, _ := thread.Local(ctxKey).(context.Context)
+      if ctx == nil {
+        ctx = context.Background()
+      }
+      v, err := tools.Secret(ctx, name)
+      if err != nil {
+        return nil, fmt.Errorf("%s(%q): %w", b.Name(), name, err)
+      }
+      return starlark.String(v), nil
+    }),
+  },
+}
+
+// filesModule gives a script the files of its pack's data dir, and only
+// those (os.Root refuses .., absolute paths and symlinks leading out):
+//
+//	files.read("notes.txt")        files.write("notes.txt", text)
+//	files.append("log.txt", line)  files.exists("notes.txt")
+//	files.list("sub")              files.remove("notes.txt")
+func filesModule(dir string) *starlarkstruct.Module {
+  open := func() (*os.Root, error) {
+    if err := os.MkdirAll(dir, 0o755); err != nil {
+      return nil, err
+    }
+    return os.OpenRoot(dir)
+  }
+  builtin := func(name string, fn func(root *os.Root, path string, data *string) (starlark.Value, error), withData bool) *starlark.Builtin {
+    return starlark.NewBuiltin("files."+name, func(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+      var path, data string
+      var err error
+      if withData {
+        err = starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 2, &path, &data)
+      } else if name == "list" {
+        err = starlark.UnpackArgs(b.Name(), args, kwargs, "path?", &path)
+      } else {
+        err = starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 1, &path)
+      }
+      if err != nil {
+        return nil, err
+      }
+      if path == "" {
+        path = "."
+      }
+      root, err := open()
+      if err != nil {
+        return nil, fmt.Errorf("%s: %w", b.Name(), err)
+      }
+      defer root.Close()
+      v, err := fn(root, filepath.Clean(path), &data)
+      if err != nil {
+        return nil, fmt.Errorf("%s(%q): %w", b.Name(), path, err)
+      }
+      return v, nil
+    })
+  }
+  write := func(flag int) func(*os.Root, string, *string) (starlark.Value, error) {
+    return func(root *os.Root, path string, data *string) (starlark.Value, error) {
+      if err := mkdirAll(root, filepath.Dir(path)); err != nil {
+        return nil, err
+      }
+      f, err := root.OpenFile(path, os.O_WRONLY|os.O_CREATE|flag, 0o644)
+      if err != nil {
+        return nil, err
+      }
+      if _, err := f.WriteString(*data); err != nil {
+        f.Close()
+        return nil, err
+      }
+      return starlark.None, f.Close()
+    }
+  }
+
+  return &starlarkstruct.Module{
+    Name: "files",
+    Members: starlark.StringDict{
+      "read": builtin("read", func(root *os.Root, path string, _ *string) (starlark.Value, error) {
+        f, err := root.Open(path)
+        if err != nil {
+          return nil, err
+        }
+        defer f.Close()
+        b, err := io.ReadAll(io.LimitReader(f, maxBody))
+        return starlark.String(b), err
+      }, false),
+      "write":  builtin("write", write(os.O_TRUNC), true),
+      "append": builtin("append", write(os.O_APPEND), true),
+      "exists": builtin("exists", func(root *os.Root, path string, _ *string) (starlark.Value, error) {
+        _, err := root.Stat(path)
+        if errors.Is(err, fs.ErrNotExist) {
+          return starlark.False, nil
+        }
+        return starlark.Bool(err == nil), err
+      }, false),
+      "list": builtin("list", func(root *os.Root, path string, _ *string) (starlark.Value, error) {
+        f, err := root.Open(path)
+        if err != nil {
+          return nil, err
+        }
+        defer f.Close()
+        entries, err := f.ReadDir(-1)
+        if err != nil {
+          return nil, err
+        }
+        var names []starlark.Value
+        for _, e := range entries {
+          n := e.Name()
+          if e.IsDir() {
+            n += "/"
+          }
+          names = append(names, starlark.String(n))
+        }
+        sort.Slice(names, func(i, j int) bool { return names[i].(starlark.String) < names[j].(starlark.String) })
+        return starlark.NewList(names), nil
+      }, false),
+      "remove": builtin("remove", func(root *os.Root, path string, _ *string) (starlark.Value, error) {
+        return starlark.None, root.Remove(path)
+      }, false),
+    },
+  }
+}
+
+// mkdirAll is os.MkdirAll inside root.
+func mkdirAll(root *os.Root, dir string) error {
+  if dir == "." || dir == "" {
+    return nil
+  }
+  if err := mkdirAll(root, filepath.Dir(dir)); err != nil {
+    return err
+  }
+  if err := root.Mkdir(dir, 0o755); err != nil && !errors.Is(err, fs.ErrExist) {
+    return err
+  }
+  return nil
+}
+
+// toStarlark converts a decoded JSON argument.
+func toStarlark(v interface{}) (starlark.Value, error) {
+  switch v := v.(type) {
+  case nil:
+    return starlark.None, nil
+  case bool:
+    return starlark.Bool(v), nil
+  case string:
+    return starlark.String(v), nil
+  case float64:
+    if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+      return starlark.MakeInt64(int64(v)), nil
+    }
+    return starlark.Float(v), nil
+  case int:
+    return starlark.MakeInt(v), nil
+  case int64:
+    return starlark.MakeInt64(v), nil
+  case []interface{}:
+    out := make([]starlark.Value, len(v))
+    for i, x := range v {
+      sv, err := toStarlark(x)
+      if err != nil {
+        return nil, err
+      }
+      out[i] = sv
+    }
+    return starlark.NewList(out), nil
+  case map[string]interface{}:
+    d := starlark.NewDict(len(v))
+    for _, k := range sortedKeys(v) {
+      sv, err := toStarlark(v[k])
+      if err != nil {
+        return nil, err
+      }
+      d.SetKey(starlark.String(k), sv)
+    }
+    return d, nil
+  }
+  return nil, fmt.Errorf("unsupported value %T", v)
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+  keys := make([]string, 0, len(m))
+  for k := range m {
+    keys = append(keys, k)
+  }
+  sort.Strings(keys)
+  return keys
+}
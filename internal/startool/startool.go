@@ -0,0 +1,170 @@
+// Package startool runs toolpacks written in Starlark, a small Python
+// dialect with no I/O of its own: quick team tools without building a .so.
+// The pack's toolpack.toml names the script and lists its tools; each tool
+// calls the script's function of the same name with the arguments as
+// keywords:
+//
+//	# toolpack.toml
+//	name = "notes"
+//	script = "notes.star"
+//	[[tools]]
+//	name = "add_note"
+//	parameters = { type = "object", properties = { text = { type = "string" } } }
+//
+//	# notes.star
+//	def add_note(text):
+//	    old = files.read("notes.txt") if files.exists("notes.txt") else ""
+//	    files.write("notes.txt", old + text + "\n")
+//	    return "noted"
+//
+// Scripts get four modules and nothing else: http (to localhost only),
+// files (inside the pack's data dir), json (encode/decode) and secrets
+// (the user's, as tools.Secret gives them to Go tools).
+package startool
+
+import (
+  "context"
+  "errors"
+  "fmt"
+  "os"
+  "time"
+
+  "go.starlark.net/lib/json"
+  "go.starlark.net/starlark"
+  "go.starlark.net/syntax"
+
+  "github.com/openai/openai-go"
+  "github.com/johnjallday/dolphin-tool-calling-agent/pkg/tools"
+)
+
+const (
+  // maxSteps bounds the work one call (or loading the script) may do, so
+  // a runaway loop can't hang the agent.
+  maxSteps = 50_000_000
+  // callTimeout bounds a call in wall time, e.g. waiting on http.
+  callTimeout = 30 * time.Second
+)
+
+var fileOptions = &syntax.FileOptions{Set: true, While: true, TopLevelControl: true}
+
+// Script is a loaded toolpack script. Its globals are frozen after
+// loading, so calls can run concurrently and keep no state between them
+// except through files.
+type Script struct {
+  pack    string
+  path    string
+  globals starlark.StringDict
+  encode  starlark.Value // json.encode, for results
+}
+
+// Load runs the script at path for the toolpack pack.
+func Load(pack, path string) (*Script, error) {
+  src, err := os.ReadFile(path)
+  if err != nil {
+    return nil, err
+  }
+  s := &Script{pack: pack, path: path, encode: json.Module.Members["encode"]}
+  thread, cancel := s.thread(context.Background(), "load")
+  defer cancel()
+  globals, err := starlark.ExecFileOptions(fileOptions, thread, path, src, s.predeclared())
+  if err != nil {
+    return nil, fmt.Errorf("toolpack %s: %w", pack, describe(err))
+  }
+  globals.Freeze()
+  s.globals = globals
+  return s, nil
+}
+
+// Tool binds the script's function name to a tool with that schema.
+func (s *Script) Tool(name, description string, params map[string]interface{}) (tools.Tool, error) {
+  fn, ok := s.globals[name].(starlark.Callable)
+  if !ok {
+    return tools.Tool{}, fmt.Errorf("toolpack %s: %s defines no function %s()", s.pack, s.path, name)
+  }
+  t := tools.Tool{Name: name, Description: description, Parameters: openai.FunctionParameters(params)}
+  if t.Parameters == nil {
+    t.Parameters = openai.FunctionParameters{"type": "object", "properties": map[string]interface{}{}}
+  }
+  t.ExecContext = func(ctx context.Context, args map[string]interface{}) (string, error) {
+    return s.call(ctx, fn, args)
+  }
+  return t, nil
+}
+
+func (s *Script) call(ctx context.Context, fn starlark.Callable, args map[string]interface{}) (string, error) {
+  thread, cancel := s.thread(ctx, fn.Name())
+  defer cancel()
+
+  var kwargs []starlark.Tuple
+  for _, k := range sortedKeys(args) {
+    v, err := toStarlark(args[k])
+    if err != nil {
+      return "", fmt.Errorf("argument %s: %w", k, err)
+    }
+    kwargs = append(kwargs, starlark.Tuple{starlark.String(k), v})
+  }
+  res, err := starlark.Call(thread, fn, nil, kwargs)
+  if err != nil {
+    return "", describe(err)
+  }
+  switch v := res.(type) {
+  case starlark.NoneType:
+    return "", nil
+  case starlark.String:
+    return string(v), nil
+  }
+  out, err := starlark.Call(thread, s.encode, starlark.Tuple{res}, nil)
+  if err != nil {
+    return "", fmt.Errorf("%s() returned a %s that can't be sent back: %w", fn.Name(), res.Type(), describe(err))
+  }
+  return string(out.(starlark.String)), nil
+}
+
+// ctxKey holds a thread's context.Context, which host calls (http,
+// secrets) obey.
+const ctxKey = "dolphin.ctx"
+
+// thread is a fresh interpreter thread with the step limit, print going
+// to stderr tagged with the pack. It stops when ctx is done (the chat turn
+// is cancelled) or after callTimeout. Call the returned func when done
+// with it.
+func (s *Script) thread(ctx context.Context, name string) (*starlark.Thread, func()) {
+  t := &starlark.Thread{
+    Name: s.pack + "/" + name,
+    Print: func(_ *starlark.Thread, msg string) {
+      fmt.Fprintf(os.Stderr, "[%s] %s\n", s.pack, msg)
+    },
+  }
+  t.SetMaxExecutionSteps(maxSteps)
+  ctx, cancel := context.WithTimeout(ctx, callTimeout)
+  t.SetLocal(ctxKey, ctx)
+  stop := context.AfterFunc(ctx, func() {
+    if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+      t.Cancel("timed out after " + callTimeout.String())
+      return
+    }
+    t.Cancel("cancelled")
+  })
+  return t, func() {
+    stop()
+    cancel()
+  }
+}
+
+func (s *Script) predeclared() starlark.StringDict {
+  return starlark.StringDict{
+    "http":    httpModule,
+    "files":   filesModule(tools.DataDir(s.pack)),
+    "json":    json.Module,
+    "secrets": secretsModule,
+  }
+}
+
+// describe adds the script backtrace to evaluation errors.
+func describe(err error) error {
+  var ee *starlark.EvalError
+  if errors.As(err, &ee) {
+    return errors.New(ee.Backtrace())
+  }
+  return err
+}
@@ -0,0 +1,96 @@
+package startool
+
+import (
+  "context"
+  "os"
+  "path/filepath"
+  "strings"
+  "testing"
+  "time"
+
+  "github.com/johnjallday/dolphin-tool-calling-agent/internal/paths"
+  "github.com/johnjallday/dolphin-tool-calling-agent/pkg/tools"
+)
+
+const testScript = `
+def spin():
+    n = 0
+    while True:
+        n += 1
+
+def key(name):
+    return secrets.get(name)
+
+def note(text):
+    files.append("notes.txt", text + "\n")
+    return files.read("notes.txt")
+`
+
+type secretMap map[string]string
+
+func (m secretMap) Secret(name string) (string, error) {
+  v, ok := m[name]
+  if !ok {
+    return "", os.ErrNotExist
+  }
+  return v, nil
+}
+
+func loadTest(t *testing.T, fn string) tools.Tool {
+  t.Helper()
+  t.Setenv(paths.EnvHome, t.TempDir())
+  path := filepath.Join(t.TempDir(), "test.star")
+  if err := os.WriteFile(path, []byte(testScript), 0o644); err != nil {
+    t.Fatal(err)
+  }
+  s, err := Load("test", path)
+  if err != nil {
+    t.Fatal(err)
+  }
+  tool, err := s.Tool(fn, "", nil)
+  if err != nil {
+    t.Fatal(err)
+  }
+  return tool
+}
+
+func TestCancelStopsScript(t *testing.T) {
+  spin := loadTest(t, "spin")
+  ctx, cancel := context.WithCancel(context.Background())
+  time.AfterFunc(50*time.Millisecond, cancel)
+  start := time.Now()
+  _, err := spin.Call(ctx, nil)
+  if err == nil || !strings.Contains(err.Error(), "cancelled") {
+    t.Fatalf("got %v, want the script cancelled", err)
+  }
+  if d := time.Since(start); d > 5*time.Second {
+    t.Errorf("cancelling took %s", d)
+  }
+}
+
+func TestScriptSecrets(t *testing.T) {
+  key := loadTest(t, "key")
+  ctx := tools.WithSecrets(context.Background(), secretMap{"api": "s3cret"})
+  if out, err := key.Call(ctx, map[string]interface{}{"name": "api"}); err != nil || out != "s3cret" {
+    t.Errorf("got %q, %v", out, err)
+  }
+  if _, err := key.Call(ctx, map[string]interface{}{"name": "other"}); err == nil {
+    t.Error("an unset secret was read")
+  }
+  _, err := key.Call(context.Background(), map[string]interface{}{"name": "api"})
+  if err == nil || !strings.Contains(err.Error(), tools.ErrNoSecrets.Error()) {
+    t.Errorf("without secrets: %v", err)
+  }
+}
+
+func TestScriptFiles(t *testing.T) {
+  note := loadTest(t, "note")
+  note.Call(context.Background(), map[string]interface{}{"text": "a"})
+  out, err := note.Call(context.Background(), map[string]interface{}{"text": "b"})
+  if err != nil || out != "a\nb\n" {
+    t.Errorf("got %q, %v", out, err)
+  }
+  if _, err := os.Stat(filepath.Join(tools.DataDir("test"), "notes.txt")); err != nil {
+    t.Errorf("notes not in the data dir: %v", err)
+  }
+}
@@ -4,52 +4,119 @@ import (
   "fmt"
   "os"
   "path/filepath"
+  "strings"
 
   "github.com/BurntSushi/toml"
+  "github.com/johnjallday/dolphin-tool-calling-agent/internal/paths"
   "github.com/johnjallday/dolphin-tool-calling-agent/pkg/tools"
 )
 
 const (
-  DefaultConfigDir     = "configs"
   SettingsFileName     = "app_setting.toml"
   ToolpacksFileName    = "toolpacks.toml"
 )
 
+// ConfigDir is the resolved config directory (see the paths package).
+func ConfigDir() string {
+  return paths.ConfigDir()
+}
+
+// Approval policies for tools marked RequiresApproval.
+const (
+  ApprovalAsk  = "ask"  // prompt the user every time (default)
+  ApprovalAuto = "auto" // run without asking
+  ApprovalDeny = "deny" // never run them
+)
+
 // AppSettings mirrors configs/app_setting.toml
 type AppSettings struct {
-  DefaultUser string `toml:"default_user"`
+  DefaultUser    string `toml:"default_user"`
+  ApprovalPolicy string `toml:"approval_policy,omitempty"`
+  // StrictToolpacks makes a toolpack that fails to load fail its whole
+  // agent; by default it is skipped and flagged instead.
+  StrictToolpacks bool `toml:"strict_toolpacks,omitempty"`
+  // TrustedKeys are the minisign public keys of publishers whose signed
+  // toolpack releases are trusted; RequireSignature refuses any other.
+  TrustedKeys      []string `toml:"trusted_keys,omitempty"`
+  RequireSignature bool     `toml:"require_signature,omitempty"`
+  // APIKey is the model provider's key, normally a reference such as
+  // "secret:openai"; empty uses OPENAI_API_KEY.
+  APIKey string `toml:"api_key,omitempty"`
+}
+
+// Approval returns the effective approval policy; anything unrecognised
+// falls back to ApprovalAsk.
+func (s *AppSettings) Approval() string {
+  switch s.ApprovalPolicy {
+  case ApprovalAuto, ApprovalDeny:
+    return s.ApprovalPolicy
+  }
+  return ApprovalAsk
 }
 
-// toolpacksConfig mirrors the [[toolpacks]] table in configs/toolpacks.toml
+// Catalog source types for [[catalog]] in toolpacks.toml.
+const (
+  CatalogGitHub = "github" // releases of GitHub repos (the [[toolpack]] list)
+  CatalogHTTP   = "http"   // an index.json served over HTTP(S)
+  CatalogDir    = "dir"    // a directory (or file share) with an index.json
+  CatalogGit    = "git"    // a git repo with an index.json at its root
+)
+
+// CatalogSource is one [[catalog]] table: where to find more toolpacks.
+type CatalogSource struct {
+  Name string `toml:"name"`
+  Type string `toml:"type"`
+  URL  string `toml:"url,omitempty"`  // http: index URL; git: clone URL
+  Path string `toml:"path,omitempty"` // dir: the catalog directory
+  Ref  string `toml:"ref,omitempty"`  // git: branch or tag (default branch if empty)
+}
+
+// toolpacksConfig mirrors configs/toolpacks.toml: [[toolpack]] entries
+// (GitHub repos) and [[catalog]] sources.
 type toolpacksConfig struct {
   Toolpacks []tools.ToolPackage `toml:"toolpack"`
+  Catalogs  []CatalogSource     `toml:"catalog,omitempty"`
 }
 
-// EnsureConfigDir makes sure configs/ exists and that both
+// EnsureConfigDir makes sure the config dir exists and that both
 // app_setting.toml and toolpacks.toml exist, initializing them if missing.
 func EnsureConfigDir() error {
   // 1) make configs folder
-  if err := os.MkdirAll(DefaultConfigDir, 0755); err != nil {
-    return fmt.Errorf("mkdir %q: %w", DefaultConfigDir, err)
+  if err := os.MkdirAll(ConfigDir(), 0755); err != nil {
+    return fmt.Errorf("mkdir %q: %w", ConfigDir(), err)
   }
 
   // 2) ensure app_setting.toml
   if err := ensureFileWithDefault(
-    filepath.Join(DefaultConfigDir, SettingsFileName),
-    `default_user = ""`+"\n",
+    filepath.Join(ConfigDir(), SettingsFileName),
+    `default_user = ""`+"\n"+
+      `# approval_policy = "ask" # ask | auto | deny, for tools that change things`+"\n"+
+      `# trusted_keys = ["RWQ..."] # minisign public keys of toolpack publishers`+"\n"+
+      `# require_signature = false # only install toolpacks signed by trusted_keys`+"\n"+
+      `# api_key = "secret:openai" # a stored secret (see `+"`secret set`"+`); default OPENAI_API_KEY`+"\n",
   ); err != nil {
     return err
   }
 
   // 3) ensure toolpacks.toml
   if err := ensureFileWithDefault(
-    filepath.Join(DefaultConfigDir, ToolpacksFileName),
+    filepath.Join(ConfigDir(), ToolpacksFileName),
     `# List your remote tool-packages here (will unmarshal into []tools.ToolPackage)
-# [[toolpacks]]
+# link is the GitHub repo whose releases carry <name>.so; install one with
+# `+"`toolpack install <name>`"+` (or <name>@<tag>).
+# [[toolpack]]
 # name = "reaper_project_manager"
 # version = "0.1.0"
-# link = "https://example.com/rpm.so"
+# link = "https://github.com/johnjallday/reaper_project_manager"
 # description = "Manage Reaper projects in your DAW"
+#
+# More catalogs (searched after the list above, in order):
+# [[catalog]]
+# name = "team"
+# type = "http"   # http | dir | git
+# url = "https://tools.example.com/dolphin/index.json"
+# # path = "/mnt/share/toolpacks"   (type = "dir")
+# # ref = "main"                    (type = "git")
 ` ,
   ); err != nil {
     return err
@@ -80,7 +147,7 @@ func ensureFileWithDefault(path, defaultContents string) error {
 
 // LoadAppSettings reads and decodes configs/app_setting.toml
 func LoadAppSettings() (*AppSettings, error) {
-  path := filepath.Join(DefaultConfigDir, SettingsFileName)
+  path := filepath.Join(ConfigDir(), SettingsFileName)
   var s AppSettings
   if _, err := toml.DecodeFile(path, &s); err != nil {
     return nil, fmt.Errorf("decode %s: %w", path, err)
@@ -95,25 +162,73 @@ func SetDefaultUser(userName string) error {
     return err
   }
   s.DefaultUser = userName
-  return saveToml(filepath.Join(DefaultConfigDir, SettingsFileName), s)
+  return saveToml(filepath.Join(ConfigDir(), SettingsFileName), s)
 }
 
 // LoadRemoteToolpacks reads and decodes configs/toolpacks.toml
 // returning the slice of ToolPackage declared there.
 func LoadRemoteToolpacks() ([]tools.ToolPackage, error) {
-  path := filepath.Join(DefaultConfigDir, ToolpacksFileName)
+  cfg, err := loadToolpacksConfig()
+  if err != nil {
+    return nil, err
+  }
+  return cfg.Toolpacks, nil
+}
+
+// LoadCatalogSources returns the [[catalog]] tables of toolpacks.toml,
+// checked for a known type and the location it needs.
+func LoadCatalogSources() ([]CatalogSource, error) {
+  cfg, err := loadToolpacksConfig()
+  if err != nil {
+    return nil, err
+  }
+  seen := map[string]bool{CatalogGitHub: true}
+  for i, c := range cfg.Catalogs {
+    where := fmt.Sprintf("%s: catalog #%d", ToolpacksFileName, i+1)
+    if c.Name == "" {
+      return nil, fmt.Errorf("%s: name is required", where)
+    }
+    if seen[c.Name] {
+      return nil, fmt.Errorf("%s: duplicate (or reserved) name %q", where, c.Name)
+    }
+    seen[c.Name] = true
+    switch c.Type {
+    case CatalogHTTP, CatalogGit:
+      if c.URL == "" {
+        return nil, fmt.Errorf("%s (%s): url is required for type %q", where, c.Name, c.Type)
+      }
+      if strings.HasPrefix(c.URL, "-") || strings.HasPrefix(c.Ref, "-") {
+        return nil, fmt.Errorf("%s (%s): url and ref may not start with -", where, c.Name)
+      }
+    case CatalogDir:
+      if c.Path == "" {
+        return nil, fmt.Errorf("%s (%s): path is required for type %q", where, c.Name, c.Type)
+      }
+    default:
+      return nil, fmt.Errorf("%s (%s): unknown type %q (want http, dir or git)", where, c.Name, c.Type)
+    }
+  }
+  return cfg.Catalogs, nil
+}
+
+func loadToolpacksConfig() (*toolpacksConfig, error) {
+  path := filepath.Join(ConfigDir(), ToolpacksFileName)
   var cfg toolpacksConfig
   if _, err := toml.DecodeFile(path, &cfg); err != nil {
     return nil, fmt.Errorf("decode %s: %w", path, err)
   }
-  return cfg.Toolpacks, nil
+  return &cfg, nil
 }
 
-// SaveRemoteToolpacks overwrites configs/toolpacks.toml with the given list.
+// SaveRemoteToolpacks overwrites the [[toolpack]] list in
+// configs/toolpacks.toml, keeping its [[catalog]] sources.
 // Useful if you fetch from a central registry and want to snapshot locally.
 func SaveRemoteToolpacks(packs []tools.ToolPackage) error {
   wrapper := toolpacksConfig{Toolpacks: packs}
- 	return saveToml(filepath.Join(DefaultConfigDir, ToolpacksFileName), wrapper)
+  if cfg, err := loadToolpacksConfig(); err == nil {
+    wrapper.Catalogs = cfg.Catalogs
+  }
+ 	return saveToml(filepath.Join(ConfigDir(), ToolpacksFileName), wrapper)
 }
 
 // saveToml is a small helper to encode a struct to TOML in path.
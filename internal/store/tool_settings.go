@@ -0,0 +1,101 @@
+package store
+
+import (
+  "fmt"
+  "os"
+  "path/filepath"
+  "strings"
+
+  "github.com/BurntSushi/toml"
+  "github.com/johnjallday/dolphin-tool-calling-agent/pkg/tools"
+)
+
+// ToolSettingsPath returns <config dir>/users/<username>/tools/<pack>.toml,
+// where a user's settings for a toolpack's Config live.
+func ToolSettingsPath(username, pack string) string {
+  return filepath.Join(UsersDir(), username, "tools", pack+".toml")
+}
+
+// ToolSettings returns the values username saved for pack, as stored
+// (nothing saved is an empty map).
+func ToolSettings(username, pack string) (map[string]interface{}, error) {
+  if err := checkSettingsName(username, pack); err != nil {
+    return nil, err
+  }
+  path := ToolSettingsPath(username, pack)
+  values := map[string]interface{}{}
+  if _, err := toml.DecodeFile(path, &values); err != nil {
+    if os.IsNotExist(err) {
+      return values, nil
+    }
+    return nil, fmt.Errorf("decode %s: %w", path, err)
+  }
+  return values, nil
+}
+
+// LoadToolSettings resolves pack's settings for username: the saved
+// values checked against fields, with defaults for the rest.
+func LoadToolSettings(username, pack string, fields []tools.ConfigField) (tools.Config, error) {
+  values, err := ToolSettings(username, pack)
+  if err != nil {
+    return nil, err
+  }
+  cfg, err := tools.ResolveConfig(fields, values)
+  if err != nil {
+    return nil, fmt.Errorf("toolpack %s settings: %w (set them with: toolpack config %s key=value)", pack, err, pack)
+  }
+  return cfg, nil
+}
+
+// SaveToolSettings checks values against fields and writes them as
+// username's settings for pack. A nil value removes the key, so its
+// default applies again.
+func SaveToolSettings(username, pack string, fields []tools.ConfigField, values map[string]interface{}) error {
+  if err := checkSettingsName(username, pack); err != nil {
+    return err
+  }
+  byKey := map[string]tools.ConfigField{}
+  for _, f := range fields {
+    byKey[f.Key] = f
+  }
+  out := map[string]interface{}{}
+  for k, v := range values {
+    f, ok := byKey[k]
+    if !ok {
+      return fmt.Errorf("toolpack %s has no setting %q", pack, k)
+    }
+    if v == nil {
+      continue
+    }
+    cv, err := f.Check(v)
+    if err != nil {
+      return err
+    }
+    out[k] = cv
+  }
+
+  path := ToolSettingsPath(username, pack)
+  if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+    return fmt.Errorf("mkdir %s: %w", filepath.Dir(path), err)
+  }
+  tmp := path + ".tmp"
+  if err := saveToml(tmp, out); err != nil {
+    os.Remove(tmp)
+    return err
+  }
+  if err := os.Rename(tmp, path); err != nil {
+    os.Remove(tmp)
+    return fmt.Errorf("rename %s: %w", tmp, err)
+  }
+  return nil
+}
+
+// checkSettingsName keeps user and pack names from leaving the users dir.
+func checkSettingsName(username, pack string) error {
+  for _, n := range []string{username, pack} {
+    if n == "" || n == "." || n == ".." || strings.ContainsAny(n, `/\`) {
+      return fmt.Errorf("invalid name %q", n)
+    }
+  }
+  return nil
+}
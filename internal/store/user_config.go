@@ -2,76 +2,37 @@ package store
 
 import (
   "fmt"
-  "os"
   "path/filepath"
 
-  "github.com/BurntSushi/toml"
-  "github.com/johnjallday/dolphin-tool-calling-agent/internal/user"
+  "github.com/johnjallday/dolphin-tool-calling-agent/internal/config"
+  "github.com/johnjallday/dolphin-tool-calling-agent/internal/paths"
 )
 
-// UserConfig mirrors the on‐disk structure of a configs/users/<name>.toml
-type UserConfig struct {
-  Name         string            `toml:"name"`
-  DefaultAgent string            `toml:"default_agent"`
-  Agents       []user.AgentMeta  `toml:"agents"`
+// UserConfig mirrors the on‐disk structure of a configs/users/<name>.toml.
+// The schema itself lives in the config package.
+type UserConfig = config.User
+
+// UsersDir is where the per-user TOML files live.
+func UsersDir() string {
+  return paths.UsersDir()
 }
 
-// LoadUserConfig reads configs/users/<username>.toml into a UserConfig.
-// Returns an error if the file does not exist or is invalid TOML.
-func LoadUserConfig(username string) (*UserConfig, error) {
-  userDir := filepath.Join(DefaultConfigDir, "users")
-  userFile := filepath.Join(userDir, username+".toml")
+// UserConfigPath returns <config dir>/users/<username>.toml.
+func UserConfigPath(username string) string {
+  return filepath.Join(UsersDir(), username+".toml")
+}
 
-  var cfg UserConfig
-  if _, err := toml.DecodeFile(userFile, &cfg); err != nil {
-    return nil, fmt.Errorf("store: decode %s: %w", userFile, err)
-  }
-  return &cfg, nil
+// LoadUserConfig reads <config dir>/users/<username>.toml into a UserConfig,
+// migrating and validating it on the way.
+func LoadUserConfig(username string) (*UserConfig, error) {
+  return config.LoadUser(UserConfigPath(username))
 }
 
-// SaveUserConfig writes the given UserConfig back to
-// configs/users/<cfg.Name>.toml (creating the dir if needed).
-// It does an atomic‐style write via a “.tmp” file + rename.
+// SaveUserConfig validates cfg and writes it back to
+// <config dir>/users/<cfg.Name>.toml (creating the dir if needed).
 func SaveUserConfig(cfg *UserConfig) error {
   if cfg == nil {
     return fmt.Errorf("store: cannot save nil UserConfig")
   }
-
-  userDir := filepath.Join(DefaultConfigDir, "users")
-  if err := os.MkdirAll(userDir, 0o755); err != nil {
-    return fmt.Errorf("store: mkdir %s: %w", userDir, err)
-  }
-
-  target := filepath.Join(userDir, cfg.Name+".toml")
-  tmpFile := target + ".tmp"
-
-  f, err := os.OpenFile(tmpFile,
-    os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644,
-  )
-  if err != nil {
-    return fmt.Errorf("store: open temp file %s: %w", tmpFile, err)
-  }
-  // ensure we clean up the temp on error
-  defer func() {
-    f.Close()
-    os.Remove(tmpFile)
-  }()
-
-  enc := toml.NewEncoder(f)
-  if err := enc.Encode(cfg); err != nil {
-    return fmt.Errorf("store: encode to %s: %w", tmpFile, err)
-  }
-  // flush to disk
-  if err := f.Sync(); err != nil {
-    return fmt.Errorf("store: sync %s: %w", tmpFile, err)
-  }
-  if err := f.Close(); err != nil {
-    return fmt.Errorf("store: close %s: %w", tmpFile, err)
-  }
-
-  // atomically replace the old file
-  if err := os.Rename(tmpFile, target); err != nil {
-    return fmt.Errorf("store: rename %s → %s: %w", tmpFile, target, err)
-  }
-  return nil
+  return config.SaveUser(UserConfigPath(cfg.Name), cfg)
 }
@@ -0,0 +1,461 @@
+package toolmanager
+
+import (
+  "bytes"
+  "fmt"
+  "os"
+  "os/exec"
+  "path/filepath"
+  "regexp"
+  "runtime"
+  "runtime/debug"
+  "sort"
+  "strings"
+  "text/template"
+)
+
+// hostModule is dolphin's module path; plugins import pkg/tools from it.
+const hostModule = "github.com/johnjallday/dolphin-tool-calling-agent"
+
+// newPackName is what `toolpack new` accepts: the name becomes a folder, a
+// .so and a Go file name.
+var newPackName = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// Scaffold writes a new plugin toolpack called name into dir, which must
+// not exist or be empty:
+//
+//	<name>.go       PluginPackage, PluginManifest and a stub tool to edit
+//	<name>_test.go  validates the pack and calls the stub (see toolstest)
+//	toolpack.toml   metadata Build copies into the installed manifest
+//	go.mod          only when dir isn't inside a Go module already
+//
+// It returns the files it wrote.
+func Scaffold(name, dir string) ([]string, error) {
+  if !newPackName.MatchString(name) {
+    return nil, fmt.Errorf("invalid toolpack name %q: use lower case letters, digits and _", name)
+  }
+  dir, err := filepath.Abs(dir)
+  if err != nil {
+    return nil, err
+  }
+  if entries, err := os.ReadDir(dir); err == nil && len(entries) > 0 {
+    return nil, fmt.Errorf("%s already exists and is not empty", dir)
+  }
+  inModule := findGoMod(filepath.Dir(dir)) != ""
+  if err := os.MkdirAll(dir, 0o755); err != nil {
+    return nil, err
+  }
+
+  host := hostBuildInfo()
+  data := map[string]string{
+    "Name":    name,
+    "Module":  hostModule,
+    "Go":      goDirective(),
+    "Version": releasedVersion(host),
+  }
+
+  files := []struct {
+    name string
+    tmpl *template.Template
+  }{
+    {name + ".go", scaffoldCode},
+    {name + "_test.go", scaffoldTest},
+    {ManifestFile, scaffoldManifest},
+  }
+  if !inModule {
+    files = append(files, struct {
+      name string
+      tmpl *template.Template
+    }{"go.mod", scaffoldGoMod})
+  }
+
+  var wrote []string
+  for _, f := range files {
+    var buf bytes.Buffer
+    if err := f.tmpl.Execute(&buf, data); err != nil {
+      return wrote, err
+    }
+    path := filepath.Join(dir, f.name)
+    if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+      return wrote, err
+    }
+    wrote = append(wrote, path)
+  }
+  return wrote, nil
+}
+
+// Built is what Build did.
+type Built struct {
+  Manifest  *Manifest
+  Previous  *Manifest // the version it replaced, kept for rollback
+  Toolchain string    // the Go toolchain it was compiled with
+  Pinned    []string  // module@version requirements moved to dolphin's
+  Problems  []Problem // non-fatal findings from loading the result
+}
+
+// Build compiles the plugin toolpack in src and installs it into
+// PluginDir/<name>/, keeping the version it replaces for Rollback. A
+// plugin only loads if it was built exactly like the host, so:
+//
+//  1. the Go toolchain is pinned to dolphin's own (GOTOOLCHAIN)
+//  2. in a module other than dolphin's, go.mod is tidied and every module
+//     dolphin also uses is moved to dolphin's version
+//  3. go build -buildmode=plugin runs with dolphin's -trimpath and -tags
+//  4. the result is checked and loaded once, and toolpack.toml is written
+//     from its PluginPackage plus src's own toolpack.toml, if any
+//
+// The pack is named by src's toolpack.toml, else after the folder.
+func Build(src string) (*Built, error) {
+  src, err := filepath.Abs(src)
+  if err != nil {
+    return nil, err
+  }
+  name := filepath.Base(src)
+  var meta *Manifest
+  if _, err := os.Stat(filepath.Join(src, ManifestFile)); err == nil {
+    if meta, err = LoadManifest(filepath.Join(src, ManifestFile)); err != nil {
+      return nil, err
+    }
+    if meta.Declarative() || meta.Scripted() {
+      return nil, fmt.Errorf("%s is a declarative or scripted pack: copy the folder into %s instead of building it", src, PluginDir())
+    }
+    name = meta.Name
+  }
+  if err := checkPackName(name); err != nil {
+    return nil, err
+  }
+
+  host := hostBuildInfo()
+  b := &Built{Toolchain: strings.Fields(runtime.Version())[0]}
+  env := append(os.Environ(), "CGO_ENABLED=1")
+  if strings.HasPrefix(b.Toolchain, "go") {
+    env = append(env, "GOTOOLCHAIN="+b.Toolchain)
+  }
+  goCmd := func(args ...string) (string, error) {
+    cmd := exec.Command("go", args...)
+    cmd.Dir, cmd.Env = src, env
+    out, err := cmd.CombinedOutput()
+    if err != nil {
+      return "", fmt.Errorf("go %s: %v\n%s", args[0], err, strings.TrimSpace(string(out)))
+    }
+    return string(out), nil
+  }
+
+  // 1) + 2) the module
+  gomod, err := goCmd("env", "GOMOD")
+  if err != nil {
+    return nil, err
+  }
+  if gomod = strings.TrimSpace(gomod); gomod == "" || gomod == os.DevNull {
+    return nil, fmt.Errorf("%s is not in a Go module (`toolpack new` writes a go.mod)", src)
+  }
+  mod, err := goCmd("list", "-m")
+  if err != nil {
+    return nil, err
+  }
+  if strings.TrimSpace(mod) != hostModule {
+    if _, err := goCmd("mod", "tidy"); err != nil {
+      return nil, err
+    }
+    if b.Pinned, err = alignModules(goCmd, host); err != nil {
+      return nil, err
+    }
+  }
+
+  // 3) compile, into a dot folder the listing skips
+  root, err := EnsurePluginDir()
+  if err != nil {
+    return nil, err
+  }
+  dir := filepath.Join(root, name)
+  if err := os.MkdirAll(dir, 0o755); err != nil {
+    return nil, err
+  }
+  tmpDir, err := os.MkdirTemp(dir, ".build-")
+  if err != nil {
+    return nil, err
+  }
+  defer os.RemoveAll(tmpDir)
+  lib := filepath.Join(tmpDir, name+".so")
+  args := append([]string{"build", "-buildmode=plugin", "-o", lib}, hostBuildFlags(host)...)
+  if _, err := goCmd(append(args, ".")...); err != nil {
+    return nil, err
+  }
+
+  // 4) check, describe, install
+  pkg, probs, err := OpenPlugin(lib)
+  if err != nil {
+    return nil, err
+  }
+  b.Problems = probs
+  sum, err := fileSHA256(lib)
+  if err != nil {
+    return nil, err
+  }
+  m := ManifestFor(pkg)
+  if m.Name != name && m.Description == "" {
+    m.Description = m.Name
+  }
+  m.Name = name
+  if meta != nil {
+    if meta.Version != "" && meta.Version != m.Version {
+      return nil, fmt.Errorf("%s says version %s but PluginPackage says %s", filepath.Join(src, ManifestFile), meta.Version, m.Version)
+    }
+    if meta.Description != "" {
+      m.Description = meta.Description
+    }
+    if meta.Homepage != "" {
+      m.Homepage = meta.Homepage
+    }
+    m.License, m.Dolphin, m.Dependencies = meta.License, meta.Dolphin, meta.Dependencies
+  }
+  m.Library = name + ".so"
+  m.Checksums = map[string]string{m.Library: sum}
+  m.Dir = dir
+
+  installMu.Lock()
+  defer installMu.Unlock()
+  if b.Previous, err = replacePack(dir, lib, &m); err != nil {
+    return nil, fmt.Errorf("install %s: %w", name, err)
+  }
+  b.Manifest = &m
+  // a local build is no longer the catalog's release; `toolpack install`
+  // brings that back
+  if l, err := LoadLock(); err == nil {
+    if _, ok := l.Get(name); ok {
+      l.Remove(name)
+      if err := l.Save(); err != nil {
+        return b, err
+      }
+    }
+  }
+  return b, nil
+}
+
+// alignModules moves every module both the pack and dolphin use to
+// dolphin's version: a plugin built against any other version of a shared
+// package won't load. It returns the requirements it changed.
+func alignModules(goCmd func(...string) (string, error), host *debug.BuildInfo) ([]string, error) {
+  want := map[string]string{}
+  for _, d := range host.Deps {
+    if v := moduleVersion(d); v != "" {
+      want[d.Path] = v
+    }
+  }
+  if v := releasedVersion(host); v != "" {
+    want[host.Main.Path] = v
+  }
+  mismatched := func() (map[string]string, error) {
+    have, err := listModules(goCmd)
+    if err != nil {
+      return nil, err
+    }
+    off := map[string]string{}
+    for path, v := range have {
+      if hv, ok := want[path]; ok && hv != v {
+        off[path] = v
+      }
+    }
+    return off, nil
+  }
+
+  off, err := mismatched()
+  if err != nil || len(off) == 0 {
+    return nil, err
+  }
+  var pins []string
+  for path := range off {
+    pins = append(pins, path+"@"+want[path])
+  }
+  sort.Strings(pins)
+  if _, err := goCmd(append([]string{"get"}, pins...)...); err != nil {
+    return nil, err
+  }
+  // go get may have had to raise something else for the rest of the graph
+  if off, err = mismatched(); err != nil {
+    return pins, err
+  }
+  if len(off) > 0 {
+    var why []string
+    for path, v := range off {
+      why = append(why, fmt.Sprintf("%s %s (dolphin uses %s)", path, v, want[path]))
+    }
+    sort.Strings(why)
+    return pins, fmt.Errorf("the toolpack's dependencies need other versions than dolphin's: %s", strings.Join(why, ", "))
+  }
+  return pins, nil
+}
+
+// listModules maps every module in the build list to its version.
+func listModules(goCmd func(...string) (string, error)) (map[string]string, error) {
+  out, err := goCmd("list", "-m", "-f", "{{.Path}} {{if .Replace}}{{.Replace.Version}}{{else}}{{.Version}}{{end}}", "all")
+  if err != nil {
+    return nil, err
+  }
+  mods := map[string]string{}
+  for _, line := range strings.Split(out, "\n") {
+    // the main module and directory replacements have no version
+    if f := strings.Fields(line); len(f) == 2 {
+      mods[f[0]] = f[1]
+    }
+  }
+  return mods, nil
+}
+
+// hostBuildFlags are the go build flags dolphin was built with that change
+// what gets compiled.
+func hostBuildFlags(host *debug.BuildInfo) []string {
+  var flags []string
+  for _, s := range host.Settings {
+    switch {
+    case s.Key == "-trimpath" && s.Value == "true":
+      flags = append(flags, "-trimpath")
+    case s.Key == "-tags" && s.Value != "":
+      flags = append(flags, "-tags="+s.Value)
+    }
+  }
+  return flags
+}
+
+func hostBuildInfo() *debug.BuildInfo {
+  if bi, ok := debug.ReadBuildInfo(); ok {
+    return bi
+  }
+  return &debug.BuildInfo{}
+}
+
+// releasedVersion is dolphin's module version, or "" for a development
+// build (no version, or one stamped from a modified checkout).
+func releasedVersion(host *debug.BuildInfo) string {
+  v := host.Main.Version
+  if v == "(devel)" || strings.HasSuffix(v, "+dirty") {
+    return ""
+  }
+  return v
+}
+
+// goDirective is the go line for a new go.mod: dolphin's toolchain version.
+func goDirective() string {
+  v := strings.TrimPrefix(strings.Fields(runtime.Version())[0], "go")
+  if ok, _ := regexp.MatchString(`^\d+\.\d+(\.\d+)?$`, v); !ok {
+    return "1.24"
+  }
+  return v
+}
+
+// findGoMod returns the go.mod governing dir, or "".
+func findGoMod(dir string) string {
+  for {
+    if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+      return filepath.Join(dir, "go.mod")
+    }
+    parent := filepath.Dir(dir)
+    if parent == dir {
+      return ""
+    }
+    dir = parent
+  }
+}
+
+var scaffoldCode = template.Must(template.New("code").Parse(`// Package main is the {{.Name}} toolpack for dolphin. Build and install it
+// with ` + "`toolpack build`" + ` from this folder.
+package main
+
+import (
+	"fmt"
+
+	"github.com/johnjallday/dolphin-tool-calling-agent/pkg/tools"
+	"github.com/openai/openai-go"
+)
+
+const (
+	packName    = "{{.Name}}"
+	packVersion = "v0.1.0"
+	packLink    = ""
+)
+
+// HelloTool is a stub to start from: rename it, tell the model what it
+// does, and declare its arguments as a JSON schema.
+var HelloTool = tools.Tool{
+	Name:        "hello",
+	Description: "Greets someone by name",
+	Parameters: openai.FunctionParameters{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"name": map[string]interface{}{"type": "string", "description": "who to greet"},
+		},
+		"required": []string{"name"},
+	},
+	Exec: func(args map[string]interface{}) (string, error) {
+		name, _ := args["name"].(string)
+		if name == "" {
+			return "", fmt.Errorf("name is required")
+		}
+		return fmt.Sprintf("Hello, %s!", name), nil
+	},
+}
+
+// PluginManifest lets dolphin check this build before loading it.
+var PluginManifest = tools.NewManifest()
+
+// PluginPackage exposes the toolpack to dolphin.
+func PluginPackage() tools.ToolPackage {
+	return tools.ToolPackage{
+		Name:        packName,
+		Version:     packVersion,
+		Link:        packLink,
+		Description: "The {{.Name}} toolpack",
+		Tools:       []tools.Tool{HelloTool},
+	}
+}
+`))
+
+var scaffoldTest = template.Must(template.New("test").Parse(`package main
+
+import (
+	"testing"
+
+	"github.com/johnjallday/dolphin-tool-calling-agent/pkg/tools/toolstest"
+)
+
+func TestPluginPackage(t *testing.T) {
+	toolstest.Validate(t, PluginPackage())
+}
+
+func TestHello(t *testing.T) {
+	toolstest.Run(t, PluginPackage(), []toolstest.Case{
+		{Tool: "hello", Args: ` + "`" + `{"name": "Ada"}` + "`" + `, Want: "Hello, Ada!"},
+		{Tool: "hello", Args: ` + "`" + `{}` + "`" + `, WantErr: "name is required", OffSchema: true},
+	})
+}
+
+// TestModel calls the tools the way a model does, through dolphin's tool
+// registry.
+func TestModel(t *testing.T) {
+	m := toolstest.NewModel(t, PluginPackage())
+	if got := m.Call("hello", ` + "`" + `{"name": "Grace"}` + "`" + `); got != "Hello, Grace!" {
+		t.Errorf("hello = %q", got)
+	}
+}
+`))
+
+var scaffoldManifest = template.Must(template.New("manifest").Parse(`# The {{.Name}} toolpack. ` + "`toolpack build`" + ` installs this next to the .so,
+# adding the version and tools from PluginPackage().
+name = "{{.Name}}"
+description = "The {{.Name}} toolpack"
+# homepage = "https://github.com/you/{{.Name}}"
+# license = "MIT"
+# dolphin = ">=0.1"
+`))
+
+var scaffoldGoMod = template.Must(template.New("go.mod").Parse(`module {{.Name}}
+
+go {{.Go}}
+{{if .Version}}
+require {{.Module}} {{.Version}}
+{{else}}
+// This dolphin is a development build, so there is no version to require:
+// build the toolpack against the checkout dolphin was built from, e.g.
+// require {{.Module}} v0.0.0
+// replace {{.Module}} => ../dolphin-tool-calling-agent
+{{end}}`))
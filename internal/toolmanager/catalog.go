@@ -0,0 +1,199 @@
+package toolmanager
+
+import (
+  "fmt"
+  "sort"
+  "strings"
+
+  "github.com/johnjallday/dolphin-tool-calling-agent/internal/semver"
+  "github.com/johnjallday/dolphin-tool-calling-agent/pkg/tools"
+)
+
+// Catalog is somewhere toolpacks can be installed from.
+type Catalog interface {
+  // Name identifies the catalog in toolpacks.toml and the lock.
+  Name() string
+  // Source is where it lives (a URL or a path), for messages.
+  Source() string
+  // List returns what the catalog offers.
+  List() ([]CatalogEntry, error)
+  // Versions lists the published versions of pack, newest first.
+  Versions(pack string) ([]string, error)
+  // Release returns the given version of pack ("" or "latest" for the
+  // newest).
+  Release(pack, version string) (*Release, error)
+}
+
+// CatalogEntry is one toolpack a catalog offers.
+type CatalogEntry struct {
+  Name        string
+  Description string
+  Homepage    string
+  Versions    []string // newest first; empty if the catalog can't tell cheaply
+  Catalog     string
+}
+
+// Catalogs are searched in order; the first one offering a pack wins.
+type Catalogs []Catalog
+
+// Find returns the catalog called name.
+func (cs Catalogs) Find(name string) (Catalog, error) {
+  for _, c := range cs {
+    if c.Name() == name {
+      return c, nil
+    }
+  }
+  return nil, fmt.Errorf("no catalog named %q", name)
+}
+
+// Lookup resolves "pack" or "catalog/pack" to the catalog offering it.
+func (cs Catalogs) Lookup(spec string) (Catalog, string, error) {
+  if cat, pack, ok := strings.Cut(spec, "/"); ok {
+    c, err := cs.Find(cat)
+    return c, pack, err
+  }
+  var errs []string
+  for _, c := range cs {
+    entries, err := c.List()
+    if err != nil {
+      errs = append(errs, fmt.Sprintf("%s: %v", c.Name(), err))
+      continue
+    }
+    for _, e := range entries {
+      if e.Name == spec {
+        return c, spec, nil
+      }
+    }
+  }
+  if len(errs) > 0 {
+    return nil, "", fmt.Errorf("toolpack %q not found (unreachable catalogs: %s)", spec, strings.Join(errs, "; "))
+  }
+  return nil, "", fmt.Errorf("toolpack %q is in no catalog", spec)
+}
+
+// List returns every catalog's entries, in catalog order. A catalog that
+// can't be read is reported in errs and skipped.
+func (cs Catalogs) List() (entries []CatalogEntry, errs []error) {
+  for _, c := range cs {
+    es, err := c.List()
+    if err != nil {
+      errs = append(errs, fmt.Errorf("catalog %s (%s): %w", c.Name(), c.Source(), err))
+      continue
+    }
+    entries = append(entries, es...)
+  }
+  return entries, errs
+}
+
+// forLock returns the catalog an installed pack came from. Entries from
+// before catalogs were recorded came from a GitHub repo. GitHub entries
+// record the pack's repo; ones that recorded the API instead are looked
+// up by name in the [[toolpack]] list.
+func (cs Catalogs) forLock(e LockEntry) (Catalog, error) {
+  if e.Catalog != "" && e.Catalog != GitHubCatalogName {
+    return cs.Find(e.Catalog)
+  }
+  if _, _, err := parseGitHubRepo(e.Source); err == nil {
+    return NewGitHubCatalog([]tools.ToolPackage{{Name: e.Name, Link: e.Source}}), nil
+  }
+  c, err := cs.Find(GitHubCatalogName)
+  if err != nil {
+    return nil, fmt.Errorf("toolpack %s: no repo recorded and %w", e.Name, err)
+  }
+  return c, nil
+}
+
+// lockSource is what the lockfile records as where pack came from: its
+// repo for GitHub, whose Source is only the API, else the catalog's.
+func lockSource(cat Catalog, pack string) string {
+  if gh, ok := cat.(*githubCatalog); ok {
+    if p, err := gh.find(pack); err == nil {
+      return p.Link
+    }
+  }
+  return cat.Source()
+}
+
+// GitHubCatalogName is the catalog made of the [[toolpack]] list.
+const GitHubCatalogName = "github"
+
+// githubCatalog installs from the releases of each pack's GitHub repo.
+type githubCatalog struct {
+  packs []tools.ToolPackage
+}
+
+// NewGitHubCatalog is the catalog of toolpacks.toml's [[toolpack]]
+// entries, whose link is the GitHub repo publishing the pack's releases.
+func NewGitHubCatalog(packs []tools.ToolPackage) Catalog {
+  return &githubCatalog{packs: packs}
+}
+
+func (c *githubCatalog) Name() string   { return GitHubCatalogName }
+func (c *githubCatalog) Source() string { return GitHubAPI }
+
+func (c *githubCatalog) List() ([]CatalogEntry, error) {
+  var out []CatalogEntry
+  for _, p := range c.packs {
+    out = append(out, CatalogEntry{
+      Name:        p.Name,
+      Description: p.Description,
+      Homepage:    p.Link,
+      Catalog:     GitHubCatalogName,
+    })
+  }
+  return out, nil
+}
+
+func (c *githubCatalog) Versions(pack string) ([]string, error) {
+  p, err := c.find(pack)
+  if err != nil {
+    return nil, err
+  }
+  tags, err := FetchReleaseTags(p.Link)
+  if err != nil {
+    return nil, err
+  }
+  sortVersions(tags)
+  return tags, nil
+}
+
+func (c *githubCatalog) Release(pack, version string) (*Release, error) {
+  p, err := c.find(pack)
+  if err != nil {
+    return nil, err
+  }
+  rel, err := FetchRelease(p.Link, version)
+  if err != nil {
+    return nil, err
+  }
+  rel.Pack, rel.Description = pack, p.Description
+  // what it requires is only published in its toolpack.toml
+  if url, ok := rel.Assets[ManifestFile]; ok {
+    m, err := fetchManifest(url, rel.Local)
+    if err != nil {
+      return nil, fmt.Errorf("release %s of %s: %w", rel.Tag, pack, err)
+    }
+    rel.Dolphin, rel.Dependencies = m.Dolphin, m.Dependencies
+  }
+  return rel, nil
+}
+
+func (c *githubCatalog) find(pack string) (tools.ToolPackage, error) {
+  for _, p := range c.packs {
+    if p.Name == pack {
+      return p, nil
+    }
+  }
+  return tools.ToolPackage{}, fmt.Errorf("toolpack %q is not in the %s catalog", pack, GitHubCatalogName)
+}
+
+// sortVersions orders versions newest first.
+func sortVersions(vs []string) {
+  sort.SliceStable(vs, func(i, j int) bool { return CompareVersions(vs[i], vs[j]) > 0 })
+}
+
+// CompareVersions orders two version strings the semver way; see
+// semver.Compare.
+func CompareVersions(a, b string) int {
+  return semver.Compare(a, b)
+}
@@ -0,0 +1,383 @@
+package toolmanager
+
+import (
+  "crypto/sha256"
+  "encoding/hex"
+  "encoding/json"
+  "fmt"
+  "net/url"
+  "os"
+  "os/exec"
+  "path/filepath"
+  "strings"
+  "sync"
+  "time"
+)
+
+// IndexFile is the catalog index an HTTP, directory or git catalog serves.
+//
+//	{
+//	  "packages": [{
+//	    "name": "weather",
+//	    "description": "Current weather",
+//	    "homepage": "https://example.com/weather",
+//	    "versions": [{
+//	      "version": "v0.2.0",
+//	      "artifacts": [
+//	        {"os": "linux", "arch": "amd64", "go": "go1.24.3", "sdk": 2,
+//	         "url": "weather/v0.2.0/linux-amd64/weather.so", "sha256": "…"},
+//	        {"url": "weather/v0.2.0/weather_darwin_arm64_go1.24.3_sdk2.so", "sha256": "…"}
+//	      ],
+//	      "files": {"toolpack.toml": "weather/v0.2.0/toolpack.toml"},
+//	      "dolphin": ">=0.1",
+//	      "dependencies": {"geo": "^1.0"}
+//	    }]
+//	  }]
+//	}
+//
+// Relative URLs are resolved against the index itself. Each artifact must
+// carry its sha256 (or the version must list a checksum file); signatures
+// go in files as <artifact>.minisig.
+const IndexFile = "index.json"
+
+// Index is the decoded IndexFile.
+type Index struct {
+  Packages []IndexPackage `json:"packages"`
+}
+
+// IndexPackage is one toolpack in an Index.
+type IndexPackage struct {
+  Name        string         `json:"name"`
+  Description string         `json:"description,omitempty"`
+  Homepage    string         `json:"homepage,omitempty"`
+  Versions    []IndexVersion `json:"versions"`
+}
+
+// IndexVersion is one release of an IndexPackage. Dolphin and
+// Dependencies repeat what its toolpack.toml requires, so versions can be
+// resolved without downloading anything.
+type IndexVersion struct {
+  Version      string            `json:"version"`
+  Artifacts    []IndexArtifact   `json:"artifacts"`
+  Files        map[string]string `json:"files,omitempty"` // name → URL
+  Dolphin      string            `json:"dolphin,omitempty"`
+  Dependencies map[string]string `json:"dependencies,omitempty"`
+}
+
+// IndexArtifact is one platform build in an IndexVersion. Facts left out
+// are read from the file name when it follows the naming convention.
+type IndexArtifact struct {
+  OS     string `json:"os,omitempty"`
+  Arch   string `json:"arch,omitempty"`
+  Go     string `json:"go,omitempty"`  // toolchain, e.g. go1.24.3
+  SDK    int    `json:"sdk,omitempty"` // toolpack SDK version
+  URL    string `json:"url"`
+  SHA256 string `json:"sha256,omitempty"`
+}
+
+// indexCatalog reads an Index over http(s) or file://.
+type indexCatalog struct {
+  name, src string
+  base      *url.URL // the index's own URL
+
+  once  sync.Once
+  index *Index
+  err   error
+}
+
+// NewIndexCatalog is a catalog served as an index.json at indexURL
+// (http, https or file).
+func NewIndexCatalog(name, indexURL string) (Catalog, error) {
+  u, err := url.Parse(indexURL)
+  if err != nil || (u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "file") {
+    return nil, fmt.Errorf("catalog %s: %q is not an http(s) or file URL", name, indexURL)
+  }
+  return &indexCatalog{name: name, src: indexURL, base: u}, nil
+}
+
+// NewDirCatalog is a catalog in a local directory or file share: dir must
+// contain an index.json.
+func NewDirCatalog(name, dir string) (Catalog, error) {
+  abs, err := filepath.Abs(dir)
+  if err != nil {
+    return nil, err
+  }
+  c, err := NewIndexCatalog(name, (&url.URL{Scheme: "file", Path: filepath.ToSlash(filepath.Join(abs, IndexFile))}).String())
+  if err != nil {
+    return nil, err
+  }
+  c.(*indexCatalog).src = abs
+  return c, nil
+}
+
+func (c *indexCatalog) Name() string   { return c.name }
+func (c *indexCatalog) Source() string { return c.src }
+
+// load fetches the index once per catalog value.
+func (c *indexCatalog) load() (*Index, error) {
+  c.once.Do(func() {
+    data, err := fetch(c.base.String(), c.base.Scheme == "file")
+    if err != nil {
+      c.err = err
+      return
+    }
+    var idx Index
+    if err := json.Unmarshal(data, &idx); err != nil {
+      c.err = fmt.Errorf("decode %s: %w", c.base, err)
+      return
+    }
+    for i := range idx.Packages {
+      vs := idx.Packages[i].Versions
+      sortIndexVersions(vs)
+    }
+    c.index = &idx
+  })
+  return c.index, c.err
+}
+
+func (c *indexCatalog) List() ([]CatalogEntry, error) {
+  idx, err := c.load()
+  if err != nil {
+    return nil, err
+  }
+  var out []CatalogEntry
+  for _, p := range idx.Packages {
+    e := CatalogEntry{Name: p.Name, Description: p.Description, Homepage: p.Homepage, Catalog: c.name}
+    for _, v := range p.Versions {
+      e.Versions = append(e.Versions, v.Version)
+    }
+    out = append(out, e)
+  }
+  return out, nil
+}
+
+func (c *indexCatalog) Versions(pack string) ([]string, error) {
+  idx, err := c.load()
+  if err != nil {
+    return nil, err
+  }
+  for _, p := range idx.Packages {
+    if p.Name == pack {
+      var out []string
+      for _, v := range p.Versions {
+        out = append(out, v.Version)
+      }
+      return out, nil
+    }
+  }
+  return nil, fmt.Errorf("toolpack %q is not in catalog %s", pack, c.name)
+}
+
+func (c *indexCatalog) Release(pack, version string) (*Release, error) {
+  idx, err := c.load()
+  if err != nil {
+    return nil, err
+  }
+  for _, p := range idx.Packages {
+    if p.Name != pack {
+      continue
+    }
+    if len(p.Versions) == 0 {
+      return nil, fmt.Errorf("toolpack %q has no versions in catalog %s", pack, c.name)
+    }
+    v := &p.Versions[0] // newest
+    if version != "" && version != "latest" {
+      v = nil
+      for i := range p.Versions {
+        if p.Versions[i].Version == version {
+          v = &p.Versions[i]
+        }
+      }
+      if v == nil {
+        return nil, fmt.Errorf("toolpack %q has no version %s in catalog %s", pack, version, c.name)
+      }
+    }
+
+    rel := &Release{
+      Pack:         pack,
+      Tag:          v.Version,
+      Description:  p.Description,
+      Homepage:     p.Homepage,
+      Assets:       map[string]string{},
+      Dolphin:      v.Dolphin,
+      Dependencies: v.Dependencies,
+      Local:        c.base.Scheme == "file",
+    }
+    for _, a := range v.Artifacts {
+      u, err := c.resolve(a.URL)
+      if err != nil {
+        return nil, err
+      }
+      art := Artifact{Name: filepath.Base(a.URL), URL: u}
+      parseArtifactName(&art)
+      if a.OS != "" || a.Arch != "" {
+        art.OS, art.Arch = a.OS, a.Arch
+      }
+      if a.Go != "" {
+        art.GoVersion = a.Go
+      }
+      if a.SDK != 0 {
+        art.SDK = a.SDK
+      }
+      art.SHA256 = strings.ToLower(a.SHA256)
+      rel.Artifacts = append(rel.Artifacts, art)
+    }
+    for name, ref := range v.Files {
+      u, err := c.resolve(ref)
+      if err != nil {
+        return nil, err
+      }
+      rel.Assets[name] = u
+    }
+    return rel, nil
+  }
+  return nil, fmt.Errorf("toolpack %q is not in catalog %s", pack, c.name)
+}
+
+// resolve makes ref absolute against the index URL.
+func (c *indexCatalog) resolve(ref string) (string, error) {
+  u, err := url.Parse(ref)
+  if err != nil {
+    return "", fmt.Errorf("catalog %s: bad url %q: %w", c.name, ref, err)
+  }
+  return c.base.ResolveReference(u).String(), nil
+}
+
+func sortIndexVersions(vs []IndexVersion) {
+  names := make([]string, len(vs))
+  byName := make(map[string]IndexVersion, len(vs))
+  for i, v := range vs {
+    names[i] = v.Version
+    byName[v.Version] = v
+  }
+  sortVersions(names)
+  for i, n := range names {
+    vs[i] = byName[n]
+  }
+}
+
+// gitRefreshEvery is how long a git catalog's checkout is used before it
+// is fetched again.
+const gitRefreshEvery = 5 * time.Minute
+
+var (
+  gitMu      sync.Mutex
+  gitFetched = map[string]time.Time{}
+)
+
+// gitCatalog is a dir catalog over a cached checkout, synced on first use.
+type gitCatalog struct {
+  name, repo, ref string
+
+  once  sync.Once
+  inner Catalog
+  err   error
+}
+
+// NewGitCatalog is a catalog kept in a git repository with an index.json
+// at its root. The repo is cloned into the data dir's cache on first use
+// and fetched again when it is more than a few minutes old; artifact URLs
+// may be relative (files in the repo) or absolute.
+func NewGitCatalog(name, repoURL, ref string) Catalog {
+  return &gitCatalog{name: name, repo: repoURL, ref: ref}
+}
+
+func (c *gitCatalog) Name() string   { return c.name }
+func (c *gitCatalog) Source() string { return c.repo }
+
+func (c *gitCatalog) open() (Catalog, error) {
+  c.once.Do(func() {
+    dir, err := syncGitCatalog(c.repo, c.ref)
+    if err != nil {
+      c.err = err
+      return
+    }
+    c.inner, c.err = NewDirCatalog(c.name, dir)
+  })
+  return c.inner, c.err
+}
+
+func (c *gitCatalog) List() ([]CatalogEntry, error) {
+  inner, err := c.open()
+  if err != nil {
+    return nil, err
+  }
+  return inner.List()
+}
+
+func (c *gitCatalog) Versions(pack string) ([]string, error) {
+  inner, err := c.open()
+  if err != nil {
+    return nil, err
+  }
+  return inner.Versions(pack)
+}
+
+func (c *gitCatalog) Release(pack, version string) (*Release, error) {
+  inner, err := c.open()
+  if err != nil {
+    return nil, err
+  }
+  return inner.Release(pack, version)
+}
+
+// syncGitCatalog clones or updates the cached checkout of repoURL@ref.
+// Both come from toolpacks.toml, so neither may pass for a git option.
+func syncGitCatalog(repoURL, ref string) (string, error) {
+  if strings.HasPrefix(ref, "-") {
+    return "", fmt.Errorf("git catalog %s: invalid ref %q", repoURL, ref)
+  }
+  sum := sha256.Sum256([]byte(repoURL + "#" + ref))
+  dir := filepath.Join(PluginDir(), ".cache", "catalogs", hex.EncodeToString(sum[:8]))
+
+  gitMu.Lock()
+  defer gitMu.Unlock()
+  if time.Since(gitFetched[dir]) < gitRefreshEvery {
+    return dir, nil
+  }
+
+  if _, err := os.Stat(filepath.Join(dir, ".git")); err != nil {
+    if err := os.MkdirAll(filepath.Dir(dir), 0o755); err != nil {
+      return "", err
+    }
+    args := []string{"clone", "--quiet", "--depth", "1"}
+    if ref != "" {
+      args = append(args, "--branch", ref)
+    }
+    if err := runGit(append(args, "--", repoURL, dir)...); err != nil {
+      os.RemoveAll(dir)
+      return "", err
+    }
+  } else {
+    target := ref
+    if target == "" {
+      target = "HEAD"
+    }
+    if err := runGit("-C", dir, "fetch", "--quiet", "--depth", "1", "--", "origin", target); err != nil {
+      // offline: keep using the last checkout
+      if _, statErr := os.Stat(filepath.Join(dir, IndexFile)); statErr == nil {
+        return dir, nil
+      }
+      return "", err
+    }
+    if err := runGit("-C", dir, "reset", "--quiet", "--hard", "FETCH_HEAD"); err != nil {
+      return "", err
+    }
+  }
+  gitFetched[dir] = time.Now()
+  return dir, nil
+}
+
+func runGit(args ...string) error {
+  cmd := exec.Command("git", args...)
+  cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+  out, err := cmd.CombinedOutput()
+  if err != nil {
+    sub := args[0]
+    if sub == "-C" && len(args) > 2 {
+      sub = args[2]
+    }
+    return fmt.Errorf("git %s: %v: %s", sub, err, strings.TrimSpace(string(out)))
+  }
+  return nil
+}
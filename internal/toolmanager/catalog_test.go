@@ -0,0 +1,153 @@
+package toolmanager
+
+import (
+  "encoding/json"
+  "net/url"
+  "os"
+  "path/filepath"
+  "strings"
+  "testing"
+
+  "github.com/johnjallday/dolphin-tool-calling-agent/pkg/tools"
+)
+
+// serveGitHub stands in for the release API of github.com/o/weather with
+// one release per lib.
+func serveGitHub(t *testing.T, libs map[string][]byte) {
+  t.Helper()
+  files := map[string][]byte{} // read per request, so filled in below
+  base := serve(t, files)
+  old := GitHubAPI
+  GitHubAPI = base
+  t.Cleanup(func() { GitHubAPI = old })
+
+  var list []map[string]interface{}
+  latest := ""
+  for tag, lib := range libs {
+    files["dl/"+tag+"/weather.so"] = lib
+    files["dl/"+tag+"/weather.so.sha256"] = []byte(sha256Hex(lib) + "  weather.so\n")
+    rel := map[string]interface{}{
+      "tag_name": tag,
+      "assets": []map[string]string{
+        {"name": "weather.so", "browser_download_url": base + "/dl/" + tag + "/weather.so"},
+        {"name": "weather.so.sha256", "browser_download_url": base + "/dl/" + tag + "/weather.so.sha256"},
+      },
+    }
+    data, _ := json.Marshal(rel)
+    files["repos/o/weather/releases/tags/"+tag] = data
+    list = append(list, rel)
+    if latest == "" || CompareVersions(tag, latest) > 0 {
+      latest = tag
+    }
+  }
+  files["repos/o/weather/releases/latest"] = files["repos/o/weather/releases/tags/"+latest]
+  files["repos/o/weather/releases"], _ = json.Marshal(list)
+}
+
+func TestGitHubInstallRecordsRepo(t *testing.T) {
+  testHome(t)
+  serveGitHub(t, map[string][]byte{"v1.0.0": testPlugin(t, "v1.0.0"), "v1.1.0": testPlugin(t, "v1.1.0")})
+  const repo = "https://github.com/o/weather"
+  gh := NewGitHubCatalog([]tools.ToolPackage{{Name: "weather", Link: repo}})
+
+  if _, err := Install(gh, "weather", "v1.0.0", Trust{}); err != nil {
+    t.Fatal(err)
+  }
+  e, _ := lockEntry(t, "weather")
+  if e.Catalog != GitHubCatalogName || e.Source != repo {
+    t.Fatalf("lock entry: %+v", e)
+  }
+  // found again from the lock alone, with no catalogs configured
+  ups, err := Updates(nil)
+  if err != nil || len(ups) != 1 || ups[0].Err != nil || ups[0].Latest != "v1.1.0" {
+    t.Fatalf("Updates: %+v, %v", ups, err)
+  }
+
+  // entries that recorded the API are looked up in the [[toolpack]] list
+  lock, _ := LoadLock()
+  e.Source = GitHubAPI
+  lock.Put(e)
+  if err := lock.Save(); err != nil {
+    t.Fatal(err)
+  }
+  ups, err = Updates(Catalogs{gh})
+  if err != nil || len(ups) != 1 || ups[0].Err != nil || ups[0].Latest != "v1.1.0" {
+    t.Fatalf("Updates of an API source: %+v, %v", ups, err)
+  }
+  if ups, _ := Updates(nil); len(ups) != 1 || ups[0].Err == nil {
+    t.Errorf("an API source without a github catalog: %+v", ups)
+  }
+}
+
+func TestRemoteCatalogCantReadFiles(t *testing.T) {
+  testHome(t)
+  secret := filepath.Join(t.TempDir(), "secret.so")
+  if err := os.WriteFile(secret, []byte("local file"), 0o600); err != nil {
+    t.Fatal(err)
+  }
+  fileURL := (&url.URL{Scheme: "file", Path: filepath.ToSlash(secret)}).String()
+
+  index, _ := json.Marshal(Index{Packages: []IndexPackage{{Name: "weather", Versions: []IndexVersion{{
+    Version:   "v1.0.0",
+    Artifacts: []IndexArtifact{{URL: fileURL, SHA256: sha256Hex([]byte("local file"))}},
+    Files:     map[string]string{ManifestFile: fileURL},
+  }}}}})
+  cat, err := NewIndexCatalog("remote", serve(t, map[string][]byte{IndexFile: index})+"/"+IndexFile)
+  if err != nil {
+    t.Fatal(err)
+  }
+  _, err = Install(cat, "weather", "", Trust{})
+  if err == nil || !strings.Contains(err.Error(), "can't point at local files") {
+    t.Fatalf("got %v", err)
+  }
+  if _, err := fetch(fileURL, false); err == nil {
+    t.Error("fetch read a file for a remote catalog")
+  }
+  if data, err := fetch(fileURL, true); err != nil || string(data) != "local file" {
+    t.Errorf("local fetch: %q, %v", data, err)
+  }
+}
+
+func TestDirCatalogInstalls(t *testing.T) {
+  testHome(t)
+  lib := testPlugin(t, "v1.0.0")
+  dir := t.TempDir()
+  if err := os.MkdirAll(filepath.Join(dir, "weather"), 0o755); err != nil {
+    t.Fatal(err)
+  }
+  if err := os.WriteFile(filepath.Join(dir, "weather", "weather.so"), lib, 0o644); err != nil {
+    t.Fatal(err)
+  }
+  index, _ := json.Marshal(Index{Packages: []IndexPackage{{Name: "weather", Versions: []IndexVersion{{
+    Version:   "v1.0.0",
+    Artifacts: []IndexArtifact{{URL: "weather/weather.so", SHA256: sha256Hex(lib)}},
+  }}}}})
+  if err := os.WriteFile(filepath.Join(dir, IndexFile), index, 0o644); err != nil {
+    t.Fatal(err)
+  }
+  cat, err := NewDirCatalog("local", dir)
+  if err != nil {
+    t.Fatal(err)
+  }
+  if _, err := Install(cat, "weather", "", Trust{}); err != nil {
+    t.Fatal(err)
+  }
+  if e, _ := lockEntry(t, "weather"); e.Catalog != "local" || e.Source != dir {
+    t.Errorf("lock entry: %+v", e)
+  }
+}
+
+func TestGitCatalogArgsArentOptions(t *testing.T) {
+  testHome(t)
+  marker := filepath.Join(t.TempDir(), "ran")
+  if _, err := syncGitCatalog("https://example.invalid/c.git", "--upload-pack=touch "+marker); err == nil || !strings.Contains(err.Error(), "invalid ref") {
+    t.Errorf("ref: %v", err)
+  }
+  // a url that looks like an option is taken as a (missing) repository
+  if _, err := syncGitCatalog("--upload-pack=touch "+marker, ""); err == nil {
+    t.Error("cloned from an option")
+  }
+  if _, err := os.Stat(marker); err == nil {
+    t.Error("git ran the upload-pack option")
+  }
+}
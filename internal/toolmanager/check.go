@@ -0,0 +1,288 @@
+package toolmanager
+
+import (
+  "debug/buildinfo"
+  "fmt"
+  "path/filepath"
+  "plugin"
+  "regexp"
+  "runtime"
+  "runtime/debug"
+  "strings"
+
+  "github.com/johnjallday/dolphin-tool-calling-agent/pkg/tools"
+)
+
+// Problem is one thing wrong with (or suspicious about) a .so toolpack,
+// phrased for the user.
+type Problem struct {
+  Fatal bool
+  What  string // what is wrong
+  Fix   string // how to fix it, if we know
+}
+
+func (p Problem) String() string {
+  if p.Fix == "" {
+    return p.What
+  }
+  return p.What + " — " + p.Fix
+}
+
+// LoadError is returned when a .so toolpack cannot be loaded. Problems
+// explains why; Err is the underlying plugin error, if any.
+type LoadError struct {
+  Pack     string
+  Path     string
+  Problems []Problem
+  Err      error
+}
+
+func (e *LoadError) Error() string {
+  for _, p := range e.Problems {
+    if p.Fatal {
+      return fmt.Sprintf("toolpack %q: %s", e.Pack, p.What)
+    }
+  }
+  return fmt.Sprintf("toolpack %q: %v", e.Pack, e.Err)
+}
+
+func (e *LoadError) Unwrap() error { return e.Err }
+
+// OpenPlugin loads the toolpack in the .so at path, checking it step by
+// step so a failure comes with an explanation:
+//
+//  1. the build info embedded in the file (Go version, platform, shared
+//     dependency versions) is compared with the host's, before dlopen
+//  2. plugin.Open, with its opaque errors translated
+//  3. the exported PluginManifest (SDK version, required capabilities)
+//  4. finally the PluginPackage constructor
+//
+// Non-fatal problems (e.g. a missing manifest) are returned alongside a
+// successfully loaded package. On failure err is a *LoadError.
+func OpenPlugin(path string) (tools.ToolPackage, []Problem, error) {
+  name := strings.TrimSuffix(filepath.Base(path), ".so")
+  fail := func(probs []Problem, err error) (tools.ToolPackage, []Problem, error) {
+    return tools.ToolPackage{}, probs, &LoadError{Pack: name, Path: path, Problems: probs, Err: err}
+  }
+
+  // 1) compare build info before dlopen, which can't be undone
+  probs := precheck(path)
+  for _, p := range probs {
+    if p.Fatal {
+      return fail(probs, nil)
+    }
+  }
+
+  // 2) open
+  plug, err := plugin.Open(path)
+  if err != nil {
+    return fail(append(probs, explainOpen(err)), err)
+  }
+
+  // 3) manifest
+  if sym, err := plug.Lookup("PluginManifest"); err != nil {
+    probs = append(probs, Problem{
+      What: "no PluginManifest exported, compatibility can't be verified",
+      Fix:  "add `var PluginManifest = tools.NewManifest()` to the toolpack",
+    })
+  } else if m, ok := sym.(*tools.Manifest); !ok {
+    return fail(append(probs, Problem{Fatal: true,
+      What: fmt.Sprintf("PluginManifest has type %T, want tools.Manifest", sym),
+      Fix:  "declare it as `var PluginManifest = tools.NewManifest()`",
+    }), nil)
+  } else {
+    if m.SDKVersion != tools.SDKVersion {
+      return fail(append(probs, Problem{Fatal: true,
+        What: fmt.Sprintf("built against toolpack SDK v%d, this dolphin speaks v%d", m.SDKVersion, tools.SDKVersion),
+        Fix:  "rebuild the toolpack against this version of dolphin",
+      }), nil)
+    }
+    if missing := m.Missing(); len(missing) > 0 {
+      return fail(append(probs, Problem{Fatal: true,
+        What: fmt.Sprintf("requires host capabilities this dolphin lacks: %s", strings.Join(missing, ", ")),
+        Fix:  "upgrade dolphin",
+      }), nil)
+    }
+  }
+
+  // 4) constructor
+  sym, err := plug.Lookup("PluginPackage")
+  if err != nil {
+    return fail(append(probs, Problem{Fatal: true,
+      What: "does not export PluginPackage()",
+      Fix:  "add `func PluginPackage() tools.ToolPackage` to the toolpack's main package",
+    }), err)
+  }
+  ctor, ok := sym.(func() tools.ToolPackage)
+  if !ok {
+    return fail(append(probs, Problem{Fatal: true,
+      What: fmt.Sprintf("PluginPackage has signature %T", sym),
+      Fix:  "it must be `func PluginPackage() tools.ToolPackage`",
+    }), nil)
+  }
+  return ctor(), probs, nil
+}
+
+// precheck compares the .so's embedded build info with the host's. Every
+// mismatch here would make plugin.Open fail with a much vaguer message.
+func precheck(path string) []Problem {
+  bi, err := buildinfo.ReadFile(path)
+  if err != nil {
+    return []Problem{{Fatal: true,
+      What: fmt.Sprintf("not a Go plugin (%v)", err),
+      Fix:  "build it with `go build -buildmode=plugin`",
+    }}
+  }
+
+  var probs []Problem
+  if bi.GoVersion != runtime.Version() {
+    probs = append(probs, Problem{Fatal: true,
+      What: fmt.Sprintf("built with %s, dolphin was built with %s", bi.GoVersion, runtime.Version()),
+      Fix:  fmt.Sprintf("rebuild the toolpack with %s", runtime.Version()),
+    })
+  }
+
+  settings := map[string]string{}
+  for _, s := range bi.Settings {
+    settings[s.Key] = s.Value
+  }
+  if mode := settings["-buildmode"]; mode != "" && mode != "plugin" {
+    probs = append(probs, Problem{Fatal: true,
+      What: fmt.Sprintf("built with -buildmode=%s", mode),
+      Fix:  "build it with `go build -buildmode=plugin`",
+    })
+  }
+  if goos, arch := settings["GOOS"], settings["GOARCH"]; goos != "" && (goos != runtime.GOOS || arch != runtime.GOARCH) {
+    probs = append(probs, Problem{Fatal: true,
+      What: fmt.Sprintf("built for %s/%s, this is %s/%s", goos, arch, runtime.GOOS, runtime.GOARCH),
+      Fix:  "rebuild it on (or for) this platform",
+    })
+  }
+
+  // shared dependencies must be the exact same version on both sides
+  host, ok := debug.ReadBuildInfo()
+  if !ok {
+    return probs
+  }
+  hostDeps := map[string]string{}
+  for _, d := range host.Deps {
+    hostDeps[d.Path] = moduleVersion(d)
+  }
+  for _, d := range bi.Deps {
+    hv, shared := hostDeps[d.Path]
+    if pv := moduleVersion(d); shared && hv != pv {
+      probs = append(probs, Problem{Fatal: true,
+        What: fmt.Sprintf("built against %s %s, dolphin uses %s", d.Path, pv, hv),
+        Fix:  fmt.Sprintf("`go get %s@%s` in the toolpack and rebuild", d.Path, hv),
+      })
+    }
+  }
+  return probs
+}
+
+func moduleVersion(m *debug.Module) string {
+  if m.Replace != nil {
+    m = m.Replace
+  }
+  return m.Version
+}
+
+var differentPkg = regexp.MustCompile(`different version of package (\S+)`)
+
+// explainOpen turns plugin.Open's error into something actionable.
+func explainOpen(err error) Problem {
+  msg := err.Error()
+  switch {
+  case strings.Contains(msg, "not implemented"):
+    return Problem{Fatal: true,
+      What: "this dolphin binary was built without plugin support (static or CGO_ENABLED=0)",
+      Fix:  "use a compiled-in toolpack, or a dolphin build with cgo",
+    }
+  case differentPkg.MatchString(msg):
+    pkg := differentPkg.FindStringSubmatch(msg)[1]
+    return Problem{Fatal: true,
+      What: fmt.Sprintf("built against a different version of %s than dolphin", pkg),
+      Fix:  "rebuild the toolpack from the same dolphin version (and go.sum) as the app",
+    }
+  case strings.Contains(msg, "already loaded"):
+    return Problem{Fatal: true,
+      What: "a plugin with the same package path is already loaded",
+      Fix:  "give each toolpack its own main package path",
+    }
+  }
+  return Problem{Fatal: true, What: msg}
+}
+
+// Diagnosis is the doctor's verdict on one toolpack.
+type Diagnosis struct {
+  Name     string
+  Path     string // empty for compiled-in packs
+  Version  string
+  Problems []Problem
+  Err      error
+}
+
+// OK reports whether the toolpack loads.
+func (d Diagnosis) OK() bool { return d.Err == nil }
+
+// Doctor checks every compiled-in toolpack and every pack under PluginDir,
+// verifying each checksum and then loading the pack, and explains each
+// failure. Because the load is real, a .so that passes here loads in an
+// agent too.
+func Doctor() ([]Diagnosis, error) {
+  var out []Diagnosis
+  for _, name := range tools.Packages() {
+    pkg, _ := tools.LookupPackage(name)
+    out = append(out, Diagnosis{Name: name, Version: pkg.Version})
+  }
+
+  ms, skipped, err := Manifests()
+  if err != nil {
+    return out, err
+  }
+  for _, e := range skipped {
+    out = append(out, Diagnosis{
+      Name: filepath.Base(filepath.Dir(e.Path)), Path: e.Path, Err: e.Err,
+      Problems: []Problem{{Fatal: true, What: e.Err.Error(),
+        Fix: "fix the " + ManifestFile + " or reinstall the toolpack"}},
+    })
+  }
+  for _, m := range ms {
+    d := Diagnosis{Name: m.Name, Path: m.LibraryPath(), Version: m.Version}
+    if m.Legacy {
+      d.Problems = append(d.Problems, Problem{
+        What: "no " + ManifestFile + ", the pack has to be loaded to be listed",
+        Fix:  "reinstall it, or run `toolpack manifest " + d.Path + "`",
+      })
+    }
+    if err := m.Verify(); err != nil {
+      d.Problems = append(d.Problems, Problem{Fatal: true, What: err.Error(),
+        Fix: "reinstall the toolpack"})
+      d.Err = err
+      out = append(out, d)
+      continue
+    }
+    if m.Declarative() || m.Scripted() {
+      d.Path = filepath.Join(m.Dir, ManifestFile)
+      if m.Scripted() {
+        d.Path = m.ScriptPath()
+        _, d.Err = OpenScripted(m)
+      } else {
+        _, d.Err = OpenDeclarative(m)
+      }
+      if d.Err != nil {
+        d.Problems = append(d.Problems, Problem{Fatal: true, What: d.Err.Error(), Fix: "fix " + filepath.Base(d.Path)})
+      }
+      out = append(out, d)
+      continue
+    }
+    pkg, probs, err := OpenPlugin(d.Path)
+    if d.Version == "" {
+      d.Version = pkg.Version
+    }
+    d.Problems = append(d.Problems, probs...)
+    d.Err = err
+    out = append(out, d)
+  }
+  return out, nil
+}
@@ -0,0 +1,74 @@
+package toolmanager
+
+import (
+  "fmt"
+
+  "github.com/johnjallday/dolphin-tool-calling-agent/internal/decltool"
+  "github.com/johnjallday/dolphin-tool-calling-agent/internal/startool"
+  "github.com/johnjallday/dolphin-tool-calling-agent/pkg/tools"
+)
+
+// Toolpacks that need no .so: declarative ones (tools defined by their
+// actions in toolpack.toml) and scripted ones (a Starlark file).
+
+// OpenDeclarative builds the tools of a declarative pack from its
+// manifest; nothing is compiled or loaded. The pack may read the secrets
+// its actions refer to.
+func OpenDeclarative(m *Manifest) (tools.ToolPackage, error) {
+  if !m.Declarative() {
+    return tools.ToolPackage{}, fmt.Errorf("toolpack %q is not declarative", m.Name)
+  }
+  ts, err := m.declaredTools()
+  if err != nil {
+    return tools.ToolPackage{}, fmt.Errorf("toolpack %q: %w", m.Name, err)
+  }
+  pkg := m.ToolPackage()
+  pkg.Tools = ts
+  for _, t := range m.Tools {
+    pkg.Secrets = append(pkg.Secrets, t.Action.Secrets()...)
+  }
+  return pkg, nil
+}
+
+func (m *Manifest) declaredTools() ([]tools.Tool, error) {
+  var out []tools.Tool
+  for _, t := range m.Tools {
+    tool, err := decltool.Build(decltool.Def{
+      Name:             t.Name,
+      Description:      t.Description,
+      Parameters:       t.Parameters,
+      Action:           *t.Action,
+      Dir:              m.Dir,
+      RequiresApproval: t.RequiresApproval,
+    })
+    if err != nil {
+      return nil, err
+    }
+    out = append(out, tool)
+  }
+  return out, nil
+}
+
+// OpenScripted runs a scripted pack's Starlark file and binds its tools.
+func OpenScripted(m *Manifest) (tools.ToolPackage, error) {
+  if !m.Scripted() {
+    return tools.ToolPackage{}, fmt.Errorf("toolpack %q has no script", m.Name)
+  }
+  script, err := startool.Load(m.Name, m.ScriptPath())
+  if err != nil {
+    return tools.ToolPackage{}, err
+  }
+  pkg := m.ToolPackage()
+  pkg.Tools = nil
+  for _, t := range m.Tools {
+    tool, err := script.Tool(t.Name, t.Description, t.Parameters)
+    if err != nil {
+      return tools.ToolPackage{}, err
+    }
+    if t.RequiresApproval != nil {
+      tool.RequiresApproval = *t.RequiresApproval
+    }
+    pkg.Tools = append(pkg.Tools, tool)
+  }
+  return pkg, nil
+}
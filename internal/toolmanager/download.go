@@ -1,15 +1,51 @@
 package toolmanager
 
 import (
+  "crypto/sha256"
+  "encoding/hex"
   "encoding/json"
   "fmt"
   "io"
   "net/http"
+  "net/url"
   "os"
   "path/filepath"
   "strings"
+  "time"
 )
 
+// GitHubAPI is the base URL of the release API. Set DOLPHIN_GITHUB_API to
+// point installs at a local stand-in (or a GitHub Enterprise host).
+var GitHubAPI = envOr("DOLPHIN_GITHUB_API", "https://api.github.com")
+
+// httpClient is used for every catalog request and download over http(s).
+// It doesn't speak file://, so neither a URL nor a redirect from a remote
+// catalog can read local files.
+var httpClient = &http.Client{Timeout: 5 * time.Minute}
+
+// fileClient reads the file:// URLs of directory and git catalogs (and
+// file:// indexes), so they go through the same code.
+var fileClient = &http.Client{Transport: http.NewFileTransport(http.Dir("/"))}
+
+// clientFor picks the client for rawURL. local says whether it came from a
+// catalog on this machine; only those may point at files.
+func clientFor(rawURL string, local bool) (*http.Client, error) {
+  u, err := url.Parse(rawURL)
+  if err != nil {
+    return nil, err
+  }
+  switch u.Scheme {
+  case "http", "https":
+    return httpClient, nil
+  case "file":
+    if local {
+      return fileClient, nil
+    }
+    return nil, fmt.Errorf("%s: a remote catalog can't point at local files", rawURL)
+  }
+  return nil, fmt.Errorf("%s: unsupported URL scheme %q", rawURL, u.Scheme)
+}
+
 type ghRelease struct {
   TagName string `json:"tag_name"`
   Assets  []struct {
@@ -18,9 +54,39 @@ type ghRelease struct {
   } `json:"assets"`
 }
 
+// Release is one published version of a toolpack, as a catalog sees it.
+type Release struct {
+  Pack        string
+  Tag         string
+  Description string
+  Homepage    string
+  // Artifacts are the builds of the .so on offer.
+  Artifacts []Artifact
+  // Assets are the other files: checksums, signatures, toolpack.toml.
+  Assets map[string]string // file name → download URL
+  // Dolphin and Dependencies are what the release requires (see Manifest).
+  Dolphin      string
+  Dependencies map[string]string
+  // Local is set by catalogs on this machine (directory, git, file://
+  // index); only their URLs may be file://.
+  Local bool
+}
+
+// Artifact is one build of a toolpack's library. The platform facts come
+// from the catalog or the asset name (see platform.go); empty means unknown.
+type Artifact struct {
+  Name      string // file name, e.g. weather_linux_amd64.so
+  URL       string
+  OS        string // GOOS it was built for
+  Arch      string // GOARCH
+  GoVersion string // toolchain, e.g. go1.24.3
+  SDK       int    // tools.SDKVersion it was built against
+  SHA256    string // published inline by the catalog, if at all
+}
+
 // EnsurePluginDir makes sure PluginDir exists and returns its absolute path.
 func EnsurePluginDir() (string, error) {
-  dir := PluginDir
+  dir := PluginDir()
   if !filepath.IsAbs(dir) {
     wd, err := os.Getwd()
     if err != nil {
@@ -34,10 +100,9 @@ func EnsurePluginDir() (string, error) {
   return dir, nil
 }
 
-// DownloadReleaseSO downloads all .so assets for the given repo@tag (or "latest")
-// into your PluginDir.  If tag=="latest" we hit /releases/latest, otherwise
-// /releases/tags/{tag}.  Returns a slice of local filenames.
-func DownloadReleaseSO(repoURL, tag string) ([]string, error) {
+// FetchRelease looks up repoURL's GitHub release tag, or the latest one
+// when tag is "" or "latest".
+func FetchRelease(repoURL, tag string) (*Release, error) {
   owner, repo, err := parseGitHubRepo(repoURL)
   if err != nil {
     return nil, err
@@ -47,62 +112,131 @@ func DownloadReleaseSO(repoURL, tag string) ([]string, error) {
   if tag != "" && tag != "latest" {
     apiPath = "tags/" + tag
   }
-  apiURL := fmt.Sprintf(
-    "https://api.github.com/repos/%s/%s/releases/%s",
-    owner, repo, apiPath,
-  )
+  apiURL := fmt.Sprintf("%s/repos/%s/%s/releases/%s",
+    strings.TrimSuffix(GitHubAPI, "/"), owner, repo, apiPath)
 
-  resp, err := http.Get(apiURL)
+  resp, err := httpClient.Get(apiURL)
   if err != nil {
     return nil, err
   }
   defer resp.Body.Close()
+  if resp.StatusCode == http.StatusNotFound {
+    return nil, fmt.Errorf("no release %q for %s", apiPath, repoURL)
+  }
   if resp.StatusCode != http.StatusOK {
-    return nil, fmt.Errorf("GitHub API %q returned %d", apiURL, resp.StatusCode)
+    return nil, fmt.Errorf("release API %q returned %s", apiURL, resp.Status)
   }
 
   var rel ghRelease
   if err := json.NewDecoder(resp.Body).Decode(&rel); err != nil {
-    return nil, err
+    return nil, fmt.Errorf("decode release %s: %w", apiURL, err)
   }
+  out := &Release{Tag: rel.TagName, Homepage: repoURL, Assets: map[string]string{}}
+  for _, a := range rel.Assets {
+    if strings.HasSuffix(a.Name, ".so") {
+      art := Artifact{Name: a.Name, URL: a.BrowserDownloadURL}
+      parseArtifactName(&art)
+      out.Artifacts = append(out.Artifacts, art)
+      continue
+    }
+    out.Assets[a.Name] = a.BrowserDownloadURL
+  }
+  return out, nil
+}
 
-  pluginsDir, err := EnsurePluginDir()
+// FetchReleaseTags lists the tags of repoURL's published GitHub releases
+// (drafts left out), as the API returns them.
+func FetchReleaseTags(repoURL string) ([]string, error) {
+  owner, repo, err := parseGitHubRepo(repoURL)
   if err != nil {
     return nil, err
   }
-
-  var downloaded []string
-  for _, a := range rel.Assets {
-    if !strings.HasSuffix(a.Name, ".so") {
-      continue
-    }
-    dst := filepath.Join(pluginsDir, a.Name)
-    // skip if already present
-    if _, err := os.Stat(dst); err == nil {
-      downloaded = append(downloaded, dst)
-      continue
-    }
-    out, err := os.Create(dst)
-    if err != nil {
-      return nil, err
+  apiURL := fmt.Sprintf("%s/repos/%s/%s/releases?per_page=100",
+    strings.TrimSuffix(GitHubAPI, "/"), owner, repo)
+  resp, err := httpClient.Get(apiURL)
+  if err != nil {
+    return nil, err
+  }
+  defer resp.Body.Close()
+  if resp.StatusCode != http.StatusOK {
+    return nil, fmt.Errorf("release API %q returned %s", apiURL, resp.Status)
+  }
+  var rels []struct {
+    TagName string `json:"tag_name"`
+    Draft   bool   `json:"draft"`
+  }
+  if err := json.NewDecoder(resp.Body).Decode(&rels); err != nil {
+    return nil, fmt.Errorf("decode releases %s: %w", apiURL, err)
+  }
+  var tags []string
+  for _, r := range rels {
+    if !r.Draft {
+      tags = append(tags, r.TagName)
     }
-    defer out.Close()
+  }
+  return tags, nil
+}
 
-    dl, err := http.Get(a.BrowserDownloadURL)
+// download streams url into a new temp file in dir and returns its path
+// and SHA-256. The caller renames it into place or removes it.
+func download(url, dir, pattern string, local bool) (path, sum string, err error) {
+  client, err := clientFor(url, local)
+  if err != nil {
+    return "", "", err
+  }
+  resp, err := client.Get(url)
+  if err != nil {
+    return "", "", err
+  }
+  defer resp.Body.Close()
+  if resp.StatusCode != http.StatusOK {
+    return "", "", fmt.Errorf("download %s: %s", url, resp.Status)
+  }
+
+  f, err := os.CreateTemp(dir, pattern)
+  if err != nil {
+    return "", "", err
+  }
+  defer func() {
     if err != nil {
-      return nil, err
-    }
-    defer dl.Body.Close()
-    if dl.StatusCode != http.StatusOK {
-      return nil, fmt.Errorf("download %q failed: %d", a.Name, dl.StatusCode)
+      f.Close()
+      os.Remove(f.Name())
     }
-    if _, err := io.Copy(out, dl.Body); err != nil {
-      return nil, err
-    }
-    downloaded = append(downloaded, dst)
+  }()
+  h := sha256.New()
+  if _, err = io.Copy(io.MultiWriter(f, h), resp.Body); err != nil {
+    return "", "", fmt.Errorf("download %s: %w", url, err)
+  }
+  if err = f.Sync(); err != nil {
+    return "", "", err
+  }
+  if err = f.Close(); err != nil {
+    return "", "", err
+  }
+  return f.Name(), hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// fetch reads a small asset (e.g. a toolpack.toml) into memory; local is
+// as for clientFor.
+func fetch(url string, local bool) ([]byte, error) {
+  client, err := clientFor(url, local)
+  if err != nil {
+    return nil, err
   }
-  if len(downloaded) == 0 {
-    return nil, fmt.Errorf("no .so assets found in %s@%s", repoURL, rel.TagName)
+  resp, err := client.Get(url)
+  if err != nil {
+    return nil, err
+  }
+  defer resp.Body.Close()
+  if resp.StatusCode != http.StatusOK {
+    return nil, fmt.Errorf("download %s: %s", url, resp.Status)
+  }
+  return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
+
+func envOr(key, def string) string {
+  if v := os.Getenv(key); v != "" {
+    return v
   }
-  return downloaded, nil
+  return def
 }
@@ -0,0 +1,397 @@
+package toolmanager
+
+import (
+  "bytes"
+  "fmt"
+  "os"
+  "path/filepath"
+  "strings"
+  "sync"
+  "time"
+
+  "github.com/BurntSushi/toml"
+)
+
+// previousDir holds the version an install replaced, inside the pack's
+// folder; dot folders are skipped when listing packs.
+const previousDir = ".previous"
+
+// installMu serialises changes to PluginDir and the lock.
+var installMu sync.Mutex
+
+// UpdateStatus is the update status of one installed toolpack.
+type UpdateStatus struct {
+  Name      string
+  Installed string
+  Latest    string
+  Err       error // the catalog could not be asked
+}
+
+// Available reports whether a newer release exists.
+func (u UpdateStatus) Available() bool {
+  return u.Err == nil && u.Latest != "" && CompareVersions(u.Latest, u.Installed) > 0
+}
+
+// Install downloads version ("" for the latest) of pack from cat into
+// PluginDir/<pack>/ and records it in the lock:
+//
+//  1. the release must publish a SHA-256 for the .so (and a signature
+//     if trust requires one), or nothing is downloaded
+//  2. the .so is streamed to a temp file next to its final place
+//  3. checksum and signature are verified; a file that fails is moved to
+//     QuarantineDir instead of being installed
+//  4. its build info is checked against this binary (nothing is loaded)
+//  5. the installed version, if any, is moved to .previous/ for Rollback
+//  6. the temp file is renamed into place and toolpack.toml written;
+//     if either fails the previous version is put back
+//
+// Installing over a pack that is already loaded only takes effect after a
+// restart: Go cannot unload plugin code.
+func Install(cat Catalog, pack, version string, trust Trust) (*LockEntry, error) {
+  installMu.Lock()
+  defer installMu.Unlock()
+
+  if err := checkPackName(pack); err != nil {
+    return nil, err
+  }
+  rel, err := cat.Release(pack, version)
+  if err != nil {
+    return nil, fmt.Errorf("install %s: %w", pack, err)
+  }
+  art, err := rel.artifact(pack)
+  if err != nil {
+    return nil, fmt.Errorf("install %s: %w", pack, err)
+  }
+  asset := art.Name
+  if err := checkHost(pack, rel.Tag, rel.Dolphin); err != nil {
+    return nil, fmt.Errorf("install %s: %w", pack, err)
+  }
+
+  // 1) nothing unverifiable is downloaded
+  want, sums, sumsData, err := releaseChecksum(rel, art)
+  if err != nil {
+    return nil, fmt.Errorf("install %s: %w", pack, err)
+  }
+  _, signed := rel.Assets[sums+".minisig"]
+  signed = signed && sums != ""
+  if _, ok := rel.Assets[asset+".minisig"]; ok {
+    signed = true
+  }
+  if trust.RequireSignature && !signed {
+    return nil, fmt.Errorf("install %s: release %s is not signed and require_signature is set", pack, rel.Tag)
+  }
+
+  root, err := EnsurePluginDir()
+  if err != nil {
+    return nil, err
+  }
+  dir := filepath.Join(root, pack)
+  if err := os.MkdirAll(dir, 0o755); err != nil {
+    return nil, err
+  }
+
+  // 2) download
+  tmp, sum, err := download(art.URL, dir, "."+pack+".so.tmp-*", rel.Local)
+  if err != nil {
+    return nil, fmt.Errorf("install %s: %w", pack, err)
+  }
+  defer os.Remove(tmp) // no-op once renamed or quarantined
+
+  // 3) verify, quarantining anything that doesn't match
+  reject := func(why error) (*LockEntry, error) {
+    if qerr := quarantine(tmp, pack, rel.Tag, why); qerr != nil {
+      return nil, fmt.Errorf("install %s: %v (and quarantine failed: %v)", pack, why, qerr)
+    }
+    return nil, fmt.Errorf("install %s: %w; the download was quarantined in %s", pack, why, QuarantineDir())
+  }
+  if sum != want {
+    return reject(fmt.Errorf("%s does not match its published checksum in %s (got %s, want %s)", asset, sums, sum, want))
+  }
+  signer, err := verifySignature(rel, trust, asset, tmp, sums, sumsData)
+  if err != nil {
+    return reject(err)
+  }
+
+  // 4) refuse a build this binary can't load
+  for _, p := range precheck(tmp) {
+    if p.Fatal {
+      p.What = strings.ReplaceAll(p.What, tmp, asset+" "+rel.Tag)
+      return nil, &LoadError{Pack: pack, Path: art.URL, Problems: []Problem{p}}
+    }
+  }
+
+  // the release's own toolpack.toml, else what the catalog says
+  m := Manifest{
+    Name:         pack,
+    Description:  rel.Description,
+    Homepage:     rel.Homepage,
+    Dolphin:      rel.Dolphin,
+    Dependencies: rel.Dependencies,
+  }
+  if url, ok := rel.Assets[ManifestFile]; ok {
+    pm, err := fetchManifest(url, rel.Local)
+    if err != nil {
+      return nil, fmt.Errorf("install %s: release %s: %w", pack, rel.Tag, err)
+    }
+    if pm.Description == "" {
+      pm.Description = m.Description
+    }
+    if pm.Homepage == "" {
+      pm.Homepage = m.Homepage
+    }
+    m = *pm
+    m.Name = pack
+  }
+  m.Version = rel.Tag
+  m.Library = pack + ".so"
+  m.Checksums = map[string]string{m.Library: sum}
+  m.SignedBy = signer
+  m.Dir = dir
+
+  // 5+6) swap it in
+  prev, err := replacePack(dir, tmp, &m)
+  if err != nil {
+    return nil, fmt.Errorf("install %s: %w", pack, err)
+  }
+
+  lock, err := LoadLock()
+  if err != nil {
+    return nil, err
+  }
+  e := LockEntry{
+    Name:         pack,
+    Version:      rel.Tag,
+    Catalog:      cat.Name(),
+    Source:       lockSource(cat, pack),
+    SHA256:       sum,
+    SignedBy:     signer,
+    InstalledAt:  time.Now().UTC().Truncate(time.Second),
+    Dependencies: m.Dependencies,
+  }
+  if old, ok := lock.Get(pack); ok {
+    e.RequiredBy = old.RequiredBy
+  }
+  if prev != nil {
+    e.Previous = prev.Version
+  }
+  lock.Put(e)
+  if err := lock.Save(); err != nil {
+    return nil, err
+  }
+  return &e, nil
+}
+
+// replacePack moves the pack currently in dir (if any) to .previous/ and
+// puts lib and m in its place, restoring the old files on failure. It
+// returns the manifest of the version that was replaced.
+func replacePack(dir, lib string, m *Manifest) (*Manifest, error) {
+  cur, _ := LoadManifest(filepath.Join(dir, ManifestFile))
+  prevDir := filepath.Join(dir, previousDir)
+
+  var moved [][2]string // from → to, for undo
+  undo := func() {
+    for i := len(moved) - 1; i >= 0; i-- {
+      os.Rename(moved[i][1], moved[i][0])
+    }
+  }
+  move := func(from, to string) error {
+    if err := os.Rename(from, to); err != nil {
+      return err
+    }
+    moved = append(moved, [2]string{from, to})
+    return nil
+  }
+
+  if cur != nil {
+    if err := os.RemoveAll(prevDir); err != nil {
+      return nil, err
+    }
+    if err := os.MkdirAll(prevDir, 0o755); err != nil {
+      return nil, err
+    }
+    if err := move(cur.LibraryPath(), filepath.Join(prevDir, filepath.Base(cur.LibraryPath()))); err != nil && !os.IsNotExist(err) {
+      undo()
+      return nil, err
+    }
+    if err := move(filepath.Join(dir, ManifestFile), filepath.Join(prevDir, ManifestFile)); err != nil {
+      undo()
+      return nil, err
+    }
+  }
+
+  if err := move(lib, m.LibraryPath()); err != nil {
+    undo()
+    return nil, err
+  }
+  if err := writeManifestFile(m); err != nil {
+    os.Remove(filepath.Join(dir, ManifestFile))
+    undo()
+    return nil, err
+  }
+  return cur, nil
+}
+
+// Updates asks each locked pack's catalog for its latest release.
+func Updates(cats Catalogs) ([]UpdateStatus, error) {
+  lock, err := LoadLock()
+  if err != nil {
+    return nil, err
+  }
+  var out []UpdateStatus
+  for _, e := range lock.Packs {
+    u := UpdateStatus{Name: e.Name, Installed: e.Version}
+    cat, err := cats.forLock(e)
+    if err == nil {
+      var rel *Release
+      if rel, err = cat.Release(e.Name, "latest"); err == nil {
+        u.Latest = rel.Tag
+      }
+    }
+    u.Err = err
+    out = append(out, u)
+  }
+  return out, nil
+}
+
+// CheckVersion prints which installed toolpacks have updates available.
+func CheckVersion(cats Catalogs) error {
+  ups, err := Updates(cats)
+  if err != nil {
+    return err
+  }
+  if len(ups) == 0 {
+    fmt.Println("no toolpacks installed from a catalog")
+  }
+  for _, u := range ups {
+    switch {
+    case u.Err != nil:
+      fmt.Printf("%s: %v\n", u.Name, u.Err)
+    case u.Available():
+      fmt.Printf("%s: update available: %s → %s\n", u.Name, u.Installed, u.Latest)
+    default:
+      fmt.Printf("%s: up-to-date (%s)\n", u.Name, u.Installed)
+    }
+  }
+  return nil
+}
+
+// CanRollback reports whether a previous version of name is kept.
+func CanRollback(name string) bool {
+  _, err := os.Stat(filepath.Join(PluginDir(), name, previousDir, ManifestFile))
+  return err == nil
+}
+
+// Rollback swaps an installed pack with the version it replaced. Rolling
+// back twice returns to where you started.
+func Rollback(name string) (*LockEntry, error) {
+  installMu.Lock()
+  defer installMu.Unlock()
+
+  if err := checkPackName(name); err != nil {
+    return nil, err
+  }
+  dir := filepath.Join(PluginDir(), name)
+  prevDir := filepath.Join(dir, previousDir)
+  prev, err := LoadManifest(filepath.Join(prevDir, ManifestFile))
+  if err != nil {
+    return nil, fmt.Errorf("no previous version of %q to roll back to", name)
+  }
+  if err := prev.Verify(); err != nil {
+    return nil, err
+  }
+
+  // stage the previous version as a temp copy of the library, then let
+  // replacePack swap it in exactly like an install
+  staged, err := os.CreateTemp(dir, "."+name+".so.tmp-*")
+  if err != nil {
+    return nil, err
+  }
+  staged.Close()
+  defer os.Remove(staged.Name())
+  if err := os.Rename(prev.LibraryPath(), staged.Name()); err != nil {
+    return nil, err
+  }
+  os.Remove(filepath.Join(prevDir, ManifestFile))
+
+  m := *prev
+  m.Dir = dir
+  cur, err := replacePack(dir, staged.Name(), &m)
+  if err != nil {
+    // put the previous version back where it was
+    os.MkdirAll(prevDir, 0o755)
+    os.Rename(staged.Name(), prev.LibraryPath())
+    writeManifestFile(prev)
+    return nil, fmt.Errorf("rollback %s: %w", name, err)
+  }
+
+  lock, err := LoadLock()
+  if err != nil {
+    return nil, err
+  }
+  e, _ := lock.Get(name)
+  e.Name = name
+  e.Version = m.Version
+  e.SHA256 = m.Checksums[filepath.Base(m.LibraryPath())]
+  e.SignedBy = m.SignedBy
+  e.Dependencies = m.Dependencies
+  e.InstalledAt = time.Now().UTC().Truncate(time.Second)
+  e.Previous = ""
+  if cur != nil {
+    e.Previous = cur.Version
+  }
+  lock.Put(e)
+  if err := lock.Save(); err != nil {
+    return nil, err
+  }
+  return &e, nil
+}
+
+// Uninstall removes an installed toolpack and its lock entry.
+func Uninstall(name string) error {
+  installMu.Lock()
+  defer installMu.Unlock()
+
+  if err := checkPackName(name); err != nil {
+    return err
+  }
+  m, err := Locate(name)
+  if err != nil {
+    return err
+  }
+  root, _ := filepath.Abs(PluginDir())
+  dir, _ := filepath.Abs(m.Dir)
+  if dir == root {
+    // a bare .so dropped straight into the plugin dir
+    err = os.Remove(m.LibraryPath())
+  } else {
+    err = os.RemoveAll(dir)
+  }
+  if err != nil {
+    return fmt.Errorf("uninstall %s: %w", name, err)
+  }
+
+  lock, err := LoadLock()
+  if err != nil {
+    return err
+  }
+  lock.Remove(name)
+  return lock.Save()
+}
+
+// checkPackName rejects names that would escape PluginDir.
+func checkPackName(name string) error {
+  if name == "" || name == "." || name == ".." || strings.HasPrefix(name, ".") ||
+    strings.ContainsAny(name, `/\`) {
+    return fmt.Errorf("invalid toolpack name %q", name)
+  }
+  return nil
+}
+
+// writeManifestFile writes m to toolpack.toml in m.Dir, atomically.
+func writeManifestFile(m *Manifest) error {
+  var buf bytes.Buffer
+  if err := toml.NewEncoder(&buf).Encode(m); err != nil {
+    return fmt.Errorf("encode manifest: %w", err)
+  }
+  return writeFileAtomic(filepath.Join(m.Dir, ManifestFile), buf.Bytes())
+}
@@ -0,0 +1,261 @@
+package toolmanager
+
+import (
+  "bytes"
+  "crypto/sha256"
+  "encoding/hex"
+  "encoding/json"
+  "net/http"
+  "net/http/httptest"
+  "os"
+  "os/exec"
+  "path/filepath"
+  "runtime"
+  "strings"
+  "sync"
+  "testing"
+
+  "github.com/johnjallday/dolphin-tool-calling-agent/internal/paths"
+  "github.com/johnjallday/dolphin-tool-calling-agent/pkg/tools"
+)
+
+var (
+  pluginsMu sync.Mutex
+  plugins   = map[string][]byte{}
+)
+
+// testPlugin builds (once per test binary) a tiny plugin whose content
+// differs by version, so installs can be checked byte for byte. Install
+// refuses anything but a plugin this binary could load, so it has to be a
+// real one; tests are skipped where plugins can't be built.
+func testPlugin(t *testing.T, version string) []byte {
+  t.Helper()
+  pluginsMu.Lock()
+  defer pluginsMu.Unlock()
+  if lib, ok := plugins[version]; ok {
+    return lib
+  }
+  dir := t.TempDir()
+  src := "package main\n\nvar Version = \"" + version + "\"\n"
+  if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module testpack\n\ngo 1.24\n"), 0o644); err != nil {
+    t.Fatal(err)
+  }
+  if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte(src), 0o644); err != nil {
+    t.Fatal(err)
+  }
+  cmd := exec.Command("go", "build", "-buildmode=plugin", "-o", "pack.so", ".")
+  cmd.Dir = dir
+  cmd.Env = append(os.Environ(), "GOTOOLCHAIN=local", "GOFLAGS=", "CGO_ENABLED=1")
+  if out, err := cmd.CombinedOutput(); err != nil {
+    t.Skipf("can't build a plugin here: %v\n%s", err, out)
+  }
+  lib, err := os.ReadFile(filepath.Join(dir, "pack.so"))
+  if err != nil {
+    t.Fatal(err)
+  }
+  plugins[version] = lib
+  return lib
+}
+
+// fakeVersion is one release a test catalog serves.
+type fakeVersion struct {
+  tag   string
+  lib   []byte
+  files map[string][]byte // published next to the .so (checksums, signatures)
+  // sha256 is the checksum the index lists; "" lists the real one,
+  // "-" none at all.
+  sha256 string
+}
+
+// serveCatalog serves an index.json with pack's versions over HTTP, the
+// way a remote catalog would.
+func serveCatalog(t *testing.T, pack string, versions ...fakeVersion) Catalog {
+  t.Helper()
+  files := map[string][]byte{}
+  var iv []IndexVersion
+  for _, v := range versions {
+    prefix := pack + "/" + v.tag + "/"
+    files[prefix+pack+".so"] = v.lib
+    a := IndexArtifact{
+      OS: runtime.GOOS, Arch: runtime.GOARCH, Go: runtime.Version(), SDK: tools.SDKVersion,
+      URL: prefix + pack + ".so", SHA256: v.sha256,
+    }
+    switch v.sha256 {
+    case "":
+      a.SHA256 = sha256Hex(v.lib)
+    case "-":
+      a.SHA256 = ""
+    }
+    ver := IndexVersion{Version: v.tag, Artifacts: []IndexArtifact{a}, Files: map[string]string{}}
+    for name, data := range v.files {
+      files[prefix+name] = data
+      ver.Files[name] = prefix + name
+    }
+    iv = append(iv, ver)
+  }
+  index, err := json.Marshal(Index{Packages: []IndexPackage{{Name: pack, Versions: iv}}})
+  if err != nil {
+    t.Fatal(err)
+  }
+  files[IndexFile] = index
+  cat, err := NewIndexCatalog("test", serve(t, files)+"/"+IndexFile)
+  if err != nil {
+    t.Fatal(err)
+  }
+  return cat
+}
+
+func sha256Hex(data []byte) string {
+  sum := sha256.Sum256(data)
+  return hex.EncodeToString(sum[:])
+}
+
+// serve serves files by path ("/x" → files["x"]) and returns the server's URL.
+func serve(t *testing.T, files map[string][]byte) string {
+  t.Helper()
+  srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+    data, ok := files[strings.TrimPrefix(r.URL.Path, "/")]
+    if !ok {
+      http.NotFound(w, r)
+      return
+    }
+    w.Write(data)
+  }))
+  t.Cleanup(srv.Close)
+  return srv.URL
+}
+
+// testHome points the plugin dir and lock at a fresh directory.
+func testHome(t *testing.T) {
+  t.Helper()
+  t.Setenv(paths.EnvHome, t.TempDir())
+}
+
+func lockEntry(t *testing.T, name string) (LockEntry, bool) {
+  t.Helper()
+  lock, err := LoadLock()
+  if err != nil {
+    t.Fatal(err)
+  }
+  return lock.Get(name)
+}
+
+func installedLib(t *testing.T, pack string) []byte {
+  t.Helper()
+  data, err := os.ReadFile(filepath.Join(PluginDir(), pack, pack+".so"))
+  if err != nil {
+    t.Fatal(err)
+  }
+  return data
+}
+
+func TestInstallUpdateRollbackUninstall(t *testing.T) {
+  testHome(t)
+  v1, v2 := testPlugin(t, "v1.0.0"), testPlugin(t, "v1.1.0")
+  cat := serveCatalog(t, "weather",
+    fakeVersion{tag: "v1.0.0", lib: v1},
+    fakeVersion{tag: "v1.1.0", lib: v2})
+
+  // install
+  e, err := Install(cat, "weather", "v1.0.0", Trust{})
+  if err != nil {
+    t.Fatal(err)
+  }
+  got, ok := lockEntry(t, "weather")
+  if !ok {
+    t.Fatal("no lock entry after install")
+  }
+  if got.Version != "v1.0.0" || got.Catalog != "test" || got.Source != cat.Source() ||
+    got.SHA256 != sha256Hex(v1) || got.Previous != "" || got.InstalledAt.IsZero() {
+    t.Errorf("lock entry after install: %+v", got)
+  }
+  if e.Version != got.Version {
+    t.Errorf("Install returned %+v, lock has %+v", e, got)
+  }
+  if !bytes.Equal(installedLib(t, "weather"), v1) {
+    t.Error("installed library is not v1.0.0")
+  }
+  m, err := Locate("weather")
+  if err != nil {
+    t.Fatal(err)
+  }
+  if m.Version != "v1.0.0" || m.Verify() != nil {
+    t.Errorf("manifest after install: version %s, verify %v", m.Version, m.Verify())
+  }
+  if CanRollback("weather") {
+    t.Error("a first install has nothing to roll back to")
+  }
+
+  ups, err := Updates(Catalogs{cat})
+  if err != nil || len(ups) != 1 || ups[0].Latest != "v1.1.0" || !ups[0].Available() {
+    t.Fatalf("Updates: %+v, %v", ups, err)
+  }
+
+  // update
+  if _, err := Install(cat, "weather", "", Trust{}); err != nil {
+    t.Fatal(err)
+  }
+  got, _ = lockEntry(t, "weather")
+  if got.Version != "v1.1.0" || got.Previous != "v1.0.0" || got.SHA256 != sha256Hex(v2) {
+    t.Errorf("lock entry after update: %+v", got)
+  }
+  if !bytes.Equal(installedLib(t, "weather"), v2) {
+    t.Error("installed library is not v1.1.0")
+  }
+  if !CanRollback("weather") {
+    t.Fatal("update kept no previous version")
+  }
+
+  // rollback, twice
+  for _, want := range []struct {
+    version, previous string
+    lib               []byte
+  }{{"v1.0.0", "v1.1.0", v1}, {"v1.1.0", "v1.0.0", v2}} {
+    e, err := Rollback("weather")
+    if err != nil {
+      t.Fatal(err)
+    }
+    got, _ = lockEntry(t, "weather")
+    if e.Version != want.version || got.Version != want.version || got.Previous != want.previous ||
+      got.SHA256 != sha256Hex(want.lib) || got.Catalog != "test" {
+      t.Errorf("lock entry after rollback to %s: %+v", want.version, got)
+    }
+    if !bytes.Equal(installedLib(t, "weather"), want.lib) {
+      t.Errorf("installed library is not %s after rollback", want.version)
+    }
+  }
+
+  // uninstall
+  if err := Uninstall("weather"); err != nil {
+    t.Fatal(err)
+  }
+  if _, ok := lockEntry(t, "weather"); ok {
+    t.Error("lock entry left after uninstall")
+  }
+  if _, err := os.Stat(filepath.Join(PluginDir(), "weather")); !os.IsNotExist(err) {
+    t.Errorf("pack folder left after uninstall: %v", err)
+  }
+  if err := Uninstall("weather"); err == nil {
+    t.Error("uninstalling twice succeeded")
+  }
+  if _, err := Rollback("weather"); err == nil {
+    t.Error("rollback after uninstall succeeded")
+  }
+}
+
+func TestInstallRefusesBadNamesAndBuilds(t *testing.T) {
+  testHome(t)
+  cat := serveCatalog(t, "weather", fakeVersion{tag: "v1.0.0", lib: []byte("not a plugin")})
+  for _, name := range []string{"", "..", ".hidden", "a/b"} {
+    if _, err := Install(cat, name, "", Trust{}); err == nil || !strings.Contains(err.Error(), "invalid toolpack name") {
+      t.Errorf("Install(%q): %v", name, err)
+    }
+  }
+  // a library that checks out but isn't a plugin is never installed
+  if _, err := Install(cat, "weather", "", Trust{}); err == nil || !strings.Contains(err.Error(), "not a Go plugin") {
+    t.Errorf("non-plugin: %v", err)
+  }
+  if _, ok := lockEntry(t, "weather"); ok {
+    t.Error("a refused build was recorded in the lock")
+  }
+}
@@ -0,0 +1,122 @@
+package toolmanager
+
+import (
+  "bytes"
+  "fmt"
+  "os"
+  "path/filepath"
+  "sort"
+  "time"
+
+  "github.com/BurntSushi/toml"
+)
+
+// LockFile records what was installed from the catalog, in PluginDir.
+const LockFile = "toolpacks.lock"
+
+// LockEntry is one installed toolpack.
+type LockEntry struct {
+  Name        string    `toml:"name"`
+  Version     string    `toml:"version"`
+  Catalog     string    `toml:"catalog,omitempty"` // catalog it was installed from
+  Source      string    `toml:"source"`            // that catalog's location (repo for github)
+  SHA256      string    `toml:"sha256"` // of the installed .so
+  SignedBy    string    `toml:"signed_by,omitempty"` // minisign key id
+  InstalledAt time.Time `toml:"installed_at"`
+  // Previous is the version kept next to the pack for Rollback.
+  Previous string `toml:"previous,omitempty"`
+  // Dependencies are what the installed version requires; RequiredBy
+  // lists the agents and packs that asked for it at the last resolve.
+  Dependencies map[string]string `toml:"dependencies,omitempty"`
+  RequiredBy   []string          `toml:"required_by,omitempty"`
+}
+
+// Lock is the contents of toolpacks.lock.
+type Lock struct {
+  Packs []LockEntry `toml:"pack"`
+}
+
+// LockPath is where toolpacks.lock lives.
+func LockPath() string {
+  return filepath.Join(PluginDir(), LockFile)
+}
+
+// LoadLock reads toolpacks.lock; a missing file is an empty lock.
+func LoadLock() (*Lock, error) {
+  var l Lock
+  if _, err := toml.DecodeFile(LockPath(), &l); err != nil && !os.IsNotExist(err) {
+    return nil, fmt.Errorf("decode %s: %w", LockPath(), err)
+  }
+  return &l, nil
+}
+
+// Save writes the lock via a temp file, so a crash never leaves it torn.
+func (l *Lock) Save() error {
+  sort.Slice(l.Packs, func(i, j int) bool { return l.Packs[i].Name < l.Packs[j].Name })
+  var buf bytes.Buffer
+  if err := toml.NewEncoder(&buf).Encode(l); err != nil {
+    return fmt.Errorf("encode %s: %w", LockFile, err)
+  }
+  if _, err := EnsurePluginDir(); err != nil {
+    return err
+  }
+  return writeFileAtomic(LockPath(), buf.Bytes())
+}
+
+// Get returns the entry for name.
+func (l *Lock) Get(name string) (LockEntry, bool) {
+  for _, e := range l.Packs {
+    if e.Name == name {
+      return e, true
+    }
+  }
+  return LockEntry{}, false
+}
+
+// Put adds or replaces the entry for e.Name.
+func (l *Lock) Put(e LockEntry) {
+  for i := range l.Packs {
+    if l.Packs[i].Name == e.Name {
+      l.Packs[i] = e
+      return
+    }
+  }
+  l.Packs = append(l.Packs, e)
+}
+
+// Remove drops the entry for name, if any.
+func (l *Lock) Remove(name string) {
+  for i := range l.Packs {
+    if l.Packs[i].Name == name {
+      l.Packs = append(l.Packs[:i], l.Packs[i+1:]...)
+      return
+    }
+  }
+}
+
+// writeFileAtomic writes data to a temp file next to path and renames it over.
+func writeFileAtomic(path string, data []byte) error {
+  f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+  if err != nil {
+    return err
+  }
+  tmp := f.Name()
+  if _, err := f.Write(data); err != nil {
+    f.Close()
+    os.Remove(tmp)
+    return fmt.Errorf("write %s: %w", path, err)
+  }
+  if err := f.Close(); err != nil {
+    os.Remove(tmp)
+    return err
+  }
+  if err := os.Chmod(tmp, 0o644); err != nil {
+    os.Remove(tmp)
+    return err
+  }
+  if err := os.Rename(tmp, path); err != nil {
+    os.Remove(tmp)
+    return fmt.Errorf("write %s: %w", path, err)
+  }
+  return nil
+}
@@ -0,0 +1,414 @@
+package toolmanager
+
+import (
+  "crypto/sha256"
+  "encoding/hex"
+  "fmt"
+  "io"
+  "io/fs"
+  "os"
+  "path/filepath"
+  "sort"
+  "strings"
+
+  "github.com/BurntSushi/toml"
+  "github.com/johnjallday/dolphin-tool-calling-agent/internal/decltool"
+  "github.com/johnjallday/dolphin-tool-calling-agent/internal/semver"
+  "github.com/johnjallday/dolphin-tool-calling-agent/pkg/tools"
+)
+
+// ManifestFile is the name of the manifest that sits next to a toolpack's
+// library in its own folder under PluginDir:
+//
+//	plugins/weather/toolpack.toml
+//	plugins/weather/weather.so
+const ManifestFile = "toolpack.toml"
+
+// ToolInfo describes one tool of a toolpack without loading it. In a
+// declarative pack it is the tool itself: Action says what it does.
+type ToolInfo struct {
+  Name        string                 `toml:"name"`
+  Description string                 `toml:"description"`
+  Parameters  map[string]interface{} `toml:"parameters,omitempty"`
+  Action      *decltool.Action       `toml:"action,omitempty"`
+  // RequiresApproval overrides the action's default (see decltool.Def).
+  RequiresApproval *bool `toml:"requires_approval,omitempty"`
+}
+
+// Manifest is the contents of a toolpack.toml. Everything the app lists,
+// searches or shows in the agent editor comes from here, so no plugin code
+// runs until an agent actually uses the pack.
+type Manifest struct {
+  Name        string `toml:"name"`
+  Version     string `toml:"version"`
+  Description string `toml:"description,omitempty"`
+  Homepage    string `toml:"homepage,omitempty"`
+  License     string `toml:"license,omitempty"`
+  // Library is the .so file, relative to the manifest (default <name>.so).
+  Library string `toml:"library,omitempty"`
+  // Script is a Starlark file next to the manifest implementing the
+  // tools instead of a library (see the startool package).
+  Script string `toml:"script,omitempty"`
+  // Checksums maps file names (relative to the manifest) to their
+  // hex-encoded SHA-256; the library is verified before it is opened.
+  Checksums map[string]string `toml:"checksums,omitempty"`
+  // SignedBy is the key id of the publisher whose signature was verified
+  // when the pack was installed ("" if it was unsigned).
+  SignedBy  string            `toml:"signed_by,omitempty"`
+  // Dolphin is the range of dolphin versions the pack works with, e.g.
+  // ">=0.2" (see HostVersion).
+  Dolphin string `toml:"dolphin,omitempty"`
+  // Dependencies maps other toolpacks this one needs to a version
+  // constraint; they are installed with it and loaded alongside it.
+  Dependencies map[string]string `toml:"dependencies,omitempty"`
+  Tools        []ToolInfo        `toml:"tools"`
+  // Config is the settings the pack declares (ToolPackage.Config), so
+  // they can be edited without loading it.
+  Config []tools.ConfigField `toml:"config,omitempty"`
+  // Secrets is the stored secrets the pack may read (ToolPackage.Secrets).
+  Secrets []string `toml:"secrets,omitempty"`
+
+  // Dir is the folder the manifest was read from ("" for compiled-in packs).
+  Dir string `toml:"-"`
+  // Builtin is set for packs linked into the binary.
+  Builtin bool `toml:"-"`
+  // Legacy is set for a bare .so found without a manifest; only Name,
+  // Dir and Library are known.
+  Legacy bool `toml:"-"`
+}
+
+// Declarative reports whether the pack is defined by its toolpack.toml
+// alone (every tool has an action) and has no .so.
+func (m *Manifest) Declarative() bool {
+  if m.Builtin || m.Legacy || m.Script != "" || len(m.Tools) == 0 {
+    return false
+  }
+  for _, t := range m.Tools {
+    if t.Action == nil {
+      return false
+    }
+  }
+  return true
+}
+
+// Scripted reports whether the pack's tools are a Starlark script.
+func (m *Manifest) Scripted() bool {
+  return !m.Builtin && m.Script != ""
+}
+
+// ScriptPath is the absolute path of the pack's script ("" if none).
+func (m *Manifest) ScriptPath() string {
+  if !m.Scripted() {
+    return ""
+  }
+  return filepath.Join(m.Dir, m.Script)
+}
+
+// LibraryPath is the absolute path of the pack's .so ("" for compiled-in,
+// declarative and scripted packs).
+func (m *Manifest) LibraryPath() string {
+  if m.Builtin || m.Declarative() || m.Scripted() {
+    return ""
+  }
+  lib := m.Library
+  if lib == "" {
+    lib = m.Name + ".so"
+  }
+  return filepath.Join(m.Dir, lib)
+}
+
+// Verify checks the library (or script) against its recorded checksum,
+// if any.
+func (m *Manifest) Verify() error {
+  lib := m.LibraryPath()
+  if m.Scripted() {
+    lib = m.ScriptPath()
+  }
+  want, ok := m.Checksums[filepath.Base(lib)]
+  if lib == "" || !ok {
+    return nil
+  }
+  got, err := fileSHA256(lib)
+  if err != nil {
+    return err
+  }
+  if !strings.EqualFold(got, want) {
+    return fmt.Errorf("toolpack %q: %s does not match the checksum in its %s (file changed since it was installed?)",
+      m.Name, filepath.Base(lib), ManifestFile)
+  }
+  return nil
+}
+
+// ToolPackage returns the metadata as a tools.ToolPackage. The tools carry
+// no Exec; they are for display only.
+func (m *Manifest) ToolPackage() tools.ToolPackage {
+  pkg := tools.ToolPackage{
+    Name:        m.Name,
+    Version:     m.Version,
+    Link:        m.Homepage,
+    Description: m.Description,
+    Config:      m.Config,
+    Secrets:     m.Secrets,
+  }
+  for _, t := range m.Tools {
+    pkg.Tools = append(pkg.Tools, tools.Tool{Name: t.Name, Description: t.Description, Parameters: t.Parameters})
+  }
+  return pkg
+}
+
+// Matches reports whether query (case-insensitive) occurs in the pack's
+// name, description or any of its tools' names and descriptions.
+func (m *Manifest) Matches(query string) bool {
+  q := strings.ToLower(strings.TrimSpace(query))
+  if q == "" {
+    return true
+  }
+  hay := []string{m.Name, m.Description}
+  for _, t := range m.Tools {
+    hay = append(hay, t.Name, t.Description)
+  }
+  for _, h := range hay {
+    if strings.Contains(strings.ToLower(h), q) {
+      return true
+    }
+  }
+  return false
+}
+
+// ManifestFor describes a loaded package. Builtin packs get one on the fly;
+// WriteManifest uses it to generate toolpack.toml at build time.
+func ManifestFor(pkg tools.ToolPackage) Manifest {
+  m := Manifest{
+    Name:        pkg.Name,
+    Version:     pkg.Version,
+    Description: pkg.Description,
+    Homepage:    pkg.Link,
+    Config:      pkg.Config,
+    Secrets:     pkg.Secrets,
+  }
+  for _, t := range pkg.Tools {
+    m.Tools = append(m.Tools, ToolInfo{Name: t.Name, Description: t.Description, Parameters: t.Parameters})
+  }
+  return m
+}
+
+// LoadManifest reads the toolpack.toml at path.
+func LoadManifest(path string) (*Manifest, error) {
+  var m Manifest
+  if _, err := toml.DecodeFile(path, &m); err != nil {
+    return nil, fmt.Errorf("decode %s: %w", path, err)
+  }
+  if m.Name == "" {
+    return nil, fmt.Errorf("%s: name is required", path)
+  }
+  if strings.ContainsAny(m.Library, `/\`) {
+    return nil, fmt.Errorf("%s: library must be a file name next to the manifest", path)
+  }
+  if strings.ContainsAny(m.Script, `/\`) {
+    return nil, fmt.Errorf("%s: script must be a file name next to the manifest", path)
+  }
+  if err := m.checkRequirements(); err != nil {
+    return nil, fmt.Errorf("%s: %w", path, err)
+  }
+  m.Dir = filepath.Dir(path)
+  if err := m.checkActions(); err != nil {
+    return nil, fmt.Errorf("%s: %w", path, err)
+  }
+  return &m, nil
+}
+
+// checkActions makes sure a pack is either all declarative or a plugin,
+// that every action compiles and that its config declaration is sound.
+func (m *Manifest) checkActions() error {
+  n := 0
+  for _, t := range m.Tools {
+    if t.Action != nil {
+      n++
+    }
+  }
+  if err := tools.CheckFields(m.Config); err != nil {
+    return err
+  }
+  switch {
+  case len(m.Config) > 0 && (m.Script != "" || n > 0):
+    return fmt.Errorf("config is only supported for plugin toolpacks (declared in PluginPackage)")
+  case m.Script != "" && (n > 0 || m.Library != ""):
+    return fmt.Errorf("a scripted pack has neither a library nor tool actions")
+  case n == 0:
+    return nil
+  case n < len(m.Tools):
+    return fmt.Errorf("either every tool has an action (a declarative pack) or none does")
+  case m.Library != "":
+    return fmt.Errorf("a declarative pack has no library")
+  }
+  _, err := m.declaredTools()
+  return err
+}
+
+// checkRequirements validates the dolphin and dependencies constraints.
+func (m *Manifest) checkRequirements() error {
+  if _, err := semver.ParseConstraint(m.Dolphin); err != nil {
+    return fmt.Errorf("dolphin: %w", err)
+  }
+  for dep, c := range m.Dependencies {
+    if err := checkPackName(dep); err != nil {
+      return fmt.Errorf("dependencies: %w", err)
+    }
+    if dep == m.Name {
+      return fmt.Errorf("dependencies: %s depends on itself", dep)
+    }
+    if _, err := semver.ParseConstraint(c); err != nil {
+      return fmt.Errorf("dependencies.%s: %w", dep, err)
+    }
+  }
+  return nil
+}
+
+// CheckHost reports whether this dolphin is in the pack's dolphin range.
+func (m *Manifest) CheckHost() error {
+  return checkHost(m.Name, m.Version, m.Dolphin)
+}
+
+func checkHost(name, version, want string) error {
+  c, err := semver.ParseConstraint(want)
+  if err != nil {
+    return fmt.Errorf("toolpack %q: dolphin: %w", name, err)
+  }
+  if !c.Allows(HostVersion) {
+    return fmt.Errorf("toolpack %s %s needs dolphin %s, this is %s", name, version, c, HostVersion)
+  }
+  return nil
+}
+
+// fetchManifest downloads and decodes a release's toolpack.toml.
+func fetchManifest(url string, local bool) (*Manifest, error) {
+  data, err := fetch(url, local)
+  if err != nil {
+    return nil, err
+  }
+  var m Manifest
+  if err := toml.Unmarshal(data, &m); err != nil {
+    return nil, fmt.Errorf("%s: %w", ManifestFile, err)
+  }
+  if err := m.checkRequirements(); err != nil {
+    return nil, fmt.Errorf("%s: %w", ManifestFile, err)
+  }
+  return &m, nil
+}
+
+// ManifestError is a toolpack.toml Manifests had to skip.
+type ManifestError struct {
+  Path string
+  Err  error
+}
+
+func (e *ManifestError) Error() string { return e.Err.Error() }
+func (e *ManifestError) Unwrap() error { return e.Err }
+
+// Manifests returns every toolpack under PluginDir (searched recursively),
+// sorted by name, and the manifests it skipped because they don't load.
+// A .so that no manifest claims is returned as a Legacy entry so it still
+// shows up, but nothing is opened to describe it.
+func Manifests() ([]*Manifest, []*ManifestError, error) {
+  var (
+    out     []*Manifest
+    skipped []*ManifestError
+    libs    []string
+    claimed = map[string]bool{}
+  )
+  err := filepath.WalkDir(PluginDir(), func(path string, d fs.DirEntry, err error) error {
+    if err != nil {
+      return err
+    }
+    switch {
+    case d.IsDir() && strings.HasPrefix(d.Name(), ".") && path != PluginDir():
+      return fs.SkipDir // e.g. .previous/ kept for rollback
+    case d.IsDir():
+    case d.Name() == ManifestFile:
+      m, err := LoadManifest(path)
+      if err != nil {
+        skipped = append(skipped, &ManifestError{Path: path, Err: err})
+        return nil
+      }
+      claimed[m.LibraryPath()] = true
+      out = append(out, m)
+    case filepath.Ext(path) == ".so":
+      libs = append(libs, path)
+    }
+    return nil
+  })
+  if err != nil && !os.IsNotExist(err) {
+    return nil, nil, fmt.Errorf("walking plugin dir %q: %w", PluginDir(), err)
+  }
+  for _, lib := range libs {
+    if claimed[lib] {
+      continue
+    }
+    out = append(out, &Manifest{
+      Name:    strings.TrimSuffix(filepath.Base(lib), ".so"),
+      Dir:     filepath.Dir(lib),
+      Library: filepath.Base(lib),
+      Legacy:  true,
+    })
+  }
+  sort.SliceStable(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+  return out, skipped, nil
+}
+
+// Locate finds the installed toolpack called name under PluginDir.
+func Locate(name string) (*Manifest, error) {
+  ms, _, err := Manifests()
+  if err != nil {
+    return nil, err
+  }
+  for _, m := range ms {
+    if m.Name == name {
+      return m, nil
+    }
+  }
+  return nil, fmt.Errorf("toolpack %q not found under %s", name, PluginDir())
+}
+
+// WriteManifest loads the .so at soPath once and writes a toolpack.toml
+// next to it describing its tools, with the library's checksum. Run it
+// when a pack is built or installed, never on the listing path.
+func WriteManifest(soPath string) (*Manifest, error) {
+  pkg, _, err := OpenPlugin(soPath)
+  if err != nil {
+    return nil, err
+  }
+  sum, err := fileSHA256(soPath)
+  if err != nil {
+    return nil, err
+  }
+  m := ManifestFor(pkg)
+  // the file name is what agents refer to; the pack's display name
+  // (e.g. "Calculator") is kept in the description if it differs
+  base := strings.TrimSuffix(filepath.Base(soPath), ".so")
+  if m.Name != base {
+    if m.Description == "" {
+      m.Description = m.Name
+    }
+    m.Name = base
+  }
+  m.Library = filepath.Base(soPath)
+  m.Checksums = map[string]string{m.Library: sum}
+  m.Dir = filepath.Dir(soPath)
+  if err := writeManifestFile(&m); err != nil {
+    return nil, err
+  }
+  return &m, nil
+}
+
+func fileSHA256(path string) (string, error) {
+  f, err := os.Open(path)
+  if err != nil {
+    return "", err
+  }
+  defer f.Close()
+  h := sha256.New()
+  if _, err := io.Copy(h, f); err != nil {
+    return "", fmt.Errorf("hash %s: %w", path, err)
+  }
+  return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,51 @@
+package toolmanager
+
+import (
+  "os"
+  "path/filepath"
+  "strings"
+  "testing"
+)
+
+func TestManifestsReportsSkipped(t *testing.T) {
+  testHome(t)
+  good := filepath.Join(PluginDir(), "weather")
+  bad := filepath.Join(PluginDir(), "broken")
+  for _, dir := range []string{good, bad} {
+    if err := os.MkdirAll(dir, 0o755); err != nil {
+      t.Fatal(err)
+    }
+  }
+  if err := writeManifestFile(&Manifest{Name: "weather", Version: "v1.0.0", Library: "weather.so", Dir: good}); err != nil {
+    t.Fatal(err)
+  }
+  badPath := filepath.Join(bad, ManifestFile)
+  if err := os.WriteFile(badPath, []byte("version = \"v1.0.0\"\n"), 0o644); err != nil {
+    t.Fatal(err)
+  }
+
+  ms, skipped, err := Manifests()
+  if err != nil {
+    t.Fatal(err)
+  }
+  if len(ms) != 1 || ms[0].Name != "weather" {
+    t.Errorf("manifests %+v, want weather", ms)
+  }
+  if len(skipped) != 1 || skipped[0].Path != badPath || !strings.Contains(skipped[0].Error(), "name is required") {
+    t.Fatalf("skipped %v", skipped)
+  }
+
+  diags, err := Doctor()
+  if err != nil {
+    t.Fatal(err)
+  }
+  for _, d := range diags {
+    if d.Name == "broken" {
+      if d.OK() || d.Path != badPath {
+        t.Errorf("doctor: %+v", d)
+      }
+      return
+    }
+  }
+  t.Errorf("doctor didn't report the skipped manifest: %+v", diags)
+}
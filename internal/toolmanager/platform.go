@@ -0,0 +1,160 @@
+package toolmanager
+
+import (
+  "fmt"
+  "runtime"
+  "strconv"
+  "strings"
+
+  "github.com/johnjallday/dolphin-tool-calling-agent/pkg/tools"
+)
+
+// Artifact naming convention for releases with several builds. Each .so
+// asset is named
+//
+//	<pack>_<goos>_<goarch>[_go<version>][_sdk<n>].so
+//
+// e.g. weather_linux_amd64_go1.24.3_sdk2.so. The Go and SDK parts are
+// optional but let the installer skip builds that could never load here
+// instead of finding out after the download. Index catalogs carry the same
+// facts as the os, arch, go and sdk fields of an artifact. A bare
+// <pack>.so says nothing and is only used if nothing more specific fits.
+
+// ArtifactName is what a build of pack made by this toolchain should be
+// published as.
+func ArtifactName(pack string) string {
+  return fmt.Sprintf("%s_%s_%s_%s_sdk%d.so", pack, runtime.GOOS, runtime.GOARCH, runtime.Version(), tools.SDKVersion)
+}
+
+var knownOS = map[string]bool{
+  "linux": true, "darwin": true, "windows": true, "freebsd": true, "openbsd": true,
+  "netbsd": true, "dragonfly": true, "solaris": true, "illumos": true, "android": true, "ios": true,
+}
+
+var knownArch = map[string]bool{
+  "amd64": true, "arm64": true, "386": true, "arm": true, "riscv64": true, "ppc64le": true,
+  "ppc64": true, "s390x": true, "mips64le": true, "mips64": true, "mipsle": true, "mips": true, "loong64": true,
+}
+
+// parseArtifactName reads the platform facts off an asset name following
+// the convention; whatever isn't there is left empty (or 0).
+func parseArtifactName(a *Artifact) {
+  parts := strings.Split(strings.TrimSuffix(a.Name, ".so"), "_")
+  // peel known suffixes off the end: sdk, go, arch, os
+  if n := len(parts); n > 1 && strings.HasPrefix(parts[n-1], "sdk") {
+    if v, err := strconv.Atoi(strings.TrimPrefix(parts[n-1], "sdk")); err == nil {
+      a.SDK = v
+      parts = parts[:n-1]
+    }
+  }
+  if n := len(parts); n > 1 && strings.HasPrefix(parts[n-1], "go1") {
+    a.GoVersion = parts[n-1]
+    parts = parts[:n-1]
+  }
+  if n := len(parts); n > 2 && knownArch[parts[n-1]] && knownOS[parts[n-2]] {
+    a.OS, a.Arch = parts[n-2], parts[n-1]
+  }
+}
+
+// String describes the build for messages, e.g. "linux/amd64 go1.24.3 sdk2".
+func (a *Artifact) String() string {
+  plat := "any platform"
+  if a.OS != "" || a.Arch != "" {
+    plat = orAny(a.OS) + "/" + orAny(a.Arch)
+  }
+  if a.GoVersion != "" {
+    plat += " " + a.GoVersion
+  }
+  if a.SDK != 0 {
+    plat += fmt.Sprintf(" sdk%d", a.SDK)
+  }
+  return plat
+}
+
+func orAny(s string) string {
+  if s == "" {
+    return "*"
+  }
+  return s
+}
+
+// mismatch says why a can't be loaded by this binary ("" if it can, as
+// far as its metadata tells).
+func (a *Artifact) mismatch() string {
+  switch {
+  case a.OS != "" && a.OS != runtime.GOOS:
+    return "built for " + a.OS
+  case a.Arch != "" && a.Arch != runtime.GOARCH:
+    return "built for " + a.Arch
+  case a.GoVersion != "" && a.GoVersion != runtime.Version():
+    return "built with " + a.GoVersion
+  case a.SDK != 0 && a.SDK != tools.SDKVersion:
+    return fmt.Sprintf("toolpack SDK v%d", a.SDK)
+  }
+  return ""
+}
+
+// specificity counts the facts a declares, so a build labelled for this
+// exact platform beats an unlabelled one.
+func (a *Artifact) specificity() int {
+  n := 0
+  for _, known := range []bool{a.OS != "", a.Arch != "", a.GoVersion != "", a.SDK != 0} {
+    if known {
+      n++
+    }
+  }
+  return n
+}
+
+// hostPlatform describes this binary the way Artifact.String does.
+func hostPlatform() string {
+  return fmt.Sprintf("%s/%s %s sdk%d", runtime.GOOS, runtime.GOARCH, runtime.Version(), tools.SDKVersion)
+}
+
+// artifact picks the one build of pack name this binary can load: among
+// the builds whose metadata fits, the most specific; a tie between
+// builds that say equally much is broken by the name <name>.so, else it
+// is an error. When nothing fits, the error lists every build and why it
+// was rejected.
+func (r *Release) artifact(name string) (*Artifact, error) {
+  if len(r.Artifacts) == 0 {
+    return nil, fmt.Errorf("release %s has no .so artifact", r.Tag)
+  }
+
+  var (
+    best     []*Artifact
+    rejected []string
+  )
+  for i := range r.Artifacts {
+    a := &r.Artifacts[i]
+    if why := a.mismatch(); why != "" {
+      rejected = append(rejected, fmt.Sprintf("%s (%s: %s)", a.Name, a.String(), why))
+      continue
+    }
+    switch {
+    case len(best) == 0 || a.specificity() > best[0].specificity():
+      best = []*Artifact{a}
+    case a.specificity() == best[0].specificity():
+      best = append(best, a)
+    }
+  }
+
+  switch len(best) {
+  case 0:
+    return nil, fmt.Errorf("release %s has no build for this dolphin (%s); it offers: %s",
+      r.Tag, hostPlatform(), strings.Join(rejected, ", "))
+  case 1:
+    return best[0], nil
+  }
+  for _, a := range best {
+    if a.Name == name+".so" {
+      return a, nil
+    }
+  }
+  var names []string
+  for _, a := range best {
+    names = append(names, a.Name)
+  }
+  return nil, fmt.Errorf("release %s has several builds that fit %s and can't tell them apart: %s (name them per the <pack>_<os>_<arch>_go<version>_sdk<n>.so convention)",
+    r.Tag, hostPlatform(), strings.Join(names, ", "))
+}
@@ -0,0 +1,406 @@
+package toolmanager
+
+import (
+  "fmt"
+  "sort"
+  "strings"
+
+  "github.com/johnjallday/dolphin-tool-calling-agent/internal/semver"
+)
+
+// Requirement asks for a toolpack within a range of versions.
+type Requirement struct {
+  Name       string
+  Constraint semver.Constraint
+  // Catalog to install it from ("" for the first one offering it).
+  Catalog string
+  // From says who asks, for messages: "agent bob/writer", "news v1.2.0".
+  From string
+}
+
+// ParseSpec splits an agent's plugins entry or an install argument,
+// "name" or "name@constraint" (e.g. "weather@^0.2").
+func ParseSpec(spec string) (string, semver.Constraint, error) {
+  name, want, _ := strings.Cut(strings.TrimSpace(spec), "@")
+  if name == "" {
+    return "", semver.Any, fmt.Errorf("toolpack %q: missing name", spec)
+  }
+  c, err := semver.ParseConstraint(want)
+  if err != nil {
+    return "", semver.Any, fmt.Errorf("toolpack %s: %w", name, err)
+  }
+  return name, c, nil
+}
+
+// Pick is the version of one toolpack a Plan settles on.
+type Pick struct {
+  Name      string
+  Version   string
+  Installed string // version installed now, "" if none
+  // Catalog is where to install it from; nil for packs that can't be
+  // (compiled in, or installed by hand and offered by no catalog).
+  Catalog      Catalog
+  Dependencies map[string]string
+  RequiredBy   []string
+}
+
+// Changes reports whether applying the plan installs this pick.
+func (p Pick) Changes() bool { return p.Version != p.Installed }
+
+// Plan is a consistent set of toolpack versions: every constraint of
+// every agent and every dependency holds.
+type Plan struct {
+  Picks []Pick // by name
+}
+
+// ConflictError says why no version of a toolpack fits.
+type ConflictError struct {
+  Name      string
+  Wants     []Requirement
+  Available []string // newest first
+  Rejected  []string // versions skipped for other reasons, with the reason
+}
+
+func (e *ConflictError) Error() string {
+  var b strings.Builder
+  fmt.Fprintf(&b, "no version of toolpack %q satisfies everything asked of it:", e.Name)
+  for _, r := range e.Wants {
+    fmt.Fprintf(&b, "\n  %s: %s", r.From, r.Constraint)
+  }
+  if len(e.Available) == 0 {
+    b.WriteString("\n  and no version of it is published")
+  } else {
+    fmt.Fprintf(&b, "\n  available: %s", strings.Join(e.Available, ", "))
+  }
+  for _, r := range e.Rejected {
+    fmt.Fprintf(&b, "\n  skipped %s", r)
+  }
+  return b.String()
+}
+
+// maxRounds bounds Resolve; each round re-picks only what a changed
+// dependency ruled out, so real setups settle in two or three.
+const maxRounds = 50
+
+// Resolver settles which version of each toolpack to install. It keeps
+// what is installed when that still fits and otherwise takes the newest
+// version every constraint allows.
+type Resolver struct {
+  Catalogs Catalogs
+  // Fixed are packs compiled into the binary (name → version); they
+  // can't be installed, only checked.
+  Fixed map[string]string
+  // Constraints apply to a pack only when something else pulls it in,
+  // e.g. what the agents want while a single pack is installed.
+  Constraints []Requirement
+  // Upgrade names packs to move to the newest allowed version instead of
+  // keeping the installed one.
+  Upgrade map[string]bool
+
+  installed map[string]*Manifest
+  lock      *Lock
+  releases  map[string]*Release // "name@version"
+}
+
+// Resolve finds versions for roots and, transitively, their dependencies.
+func (r *Resolver) Resolve(roots []Requirement) (*Plan, error) {
+  if err := r.init(); err != nil {
+    return nil, err
+  }
+  picks := map[string]*Pick{}
+  for round := 0; round < maxRounds; round++ {
+    wants, err := r.wants(roots, picks)
+    if err != nil {
+      return nil, err
+    }
+    changed := false
+    for name := range picks {
+      if _, ok := wants[name]; !ok {
+        delete(picks, name)
+        changed = true
+      }
+    }
+    for _, name := range sortedNames(wants) {
+      rs := wants[name]
+      if p := picks[name]; p != nil && allows(rs, p.Version) {
+        p.RequiredBy = froms(rs)
+        continue
+      }
+      p, err := r.choose(name, rs)
+      if err != nil {
+        return nil, err
+      }
+      picks[name] = p
+      changed = true
+    }
+    if !changed {
+      plan := &Plan{}
+      for _, name := range sortedNames(picks) {
+        plan.Picks = append(plan.Picks, *picks[name])
+      }
+      return plan, nil
+    }
+  }
+  return nil, fmt.Errorf("toolpack versions did not settle after %d rounds; check the dependency ranges for a cycle", maxRounds)
+}
+
+func (r *Resolver) init() error {
+  if r.installed != nil {
+    return nil
+  }
+  ms, _, err := Manifests()
+  if err != nil {
+    return err
+  }
+  r.installed = map[string]*Manifest{}
+  for _, m := range ms {
+    r.installed[m.Name] = m
+  }
+  if r.lock, err = LoadLock(); err != nil {
+    return err
+  }
+  r.releases = map[string]*Release{}
+  return nil
+}
+
+// wants gathers, per pack, the roots, the dependencies of the current
+// picks, and the Constraints on any of those.
+func (r *Resolver) wants(roots []Requirement, picks map[string]*Pick) (map[string][]Requirement, error) {
+  wants := map[string][]Requirement{}
+  for _, q := range roots {
+    wants[q.Name] = append(wants[q.Name], q)
+  }
+  for _, name := range sortedNames(picks) {
+    p := picks[name]
+    for _, dep := range sortedNames(p.Dependencies) {
+      c, err := semver.ParseConstraint(p.Dependencies[dep])
+      if err != nil {
+        return nil, fmt.Errorf("toolpack %s %s: dependencies.%s: %w", p.Name, p.Version, dep, err)
+      }
+      wants[dep] = append(wants[dep], Requirement{Name: dep, Constraint: c, From: p.Name + " " + p.Version})
+    }
+  }
+  for _, q := range r.Constraints {
+    if _, ok := wants[q.Name]; ok {
+      wants[q.Name] = append(wants[q.Name], q)
+    }
+  }
+  return wants, nil
+}
+
+// choose picks a version of name that all of rs allow.
+func (r *Resolver) choose(name string, rs []Requirement) (*Pick, error) {
+  if v, ok := r.Fixed[name]; ok {
+    if !allows(rs, v) {
+      if v == "" {
+        v = "unversioned"
+      }
+      return nil, &ConflictError{Name: name, Wants: rs, Available: []string{v + " (compiled in)"}}
+    }
+    return &Pick{Name: name, Version: v, Installed: v, RequiredBy: froms(rs)}, nil
+  }
+
+  cat, versions, err := r.candidates(name, rs)
+  if err != nil {
+    return nil, err
+  }
+  installed := ""
+  if m := r.installed[name]; m != nil {
+    installed = m.Version
+  }
+  order := versions
+  if installed != "" && !r.Upgrade[name] {
+    order = append([]string{installed}, versions...)
+  }
+
+  var rejected []string
+  for _, v := range order {
+    if !allows(rs, v) {
+      continue
+    }
+    dolphin, deps, err := r.requires(cat, name, v, v == installed)
+    if err != nil {
+      return nil, err
+    }
+    if err := checkHost(name, v, dolphin); err != nil {
+      rejected = append(rejected, fmt.Sprintf("%s (needs dolphin %s, this is %s)", v, dolphin, HostVersion))
+      continue
+    }
+    return &Pick{
+      Name:         name,
+      Version:      v,
+      Installed:    installed,
+      Catalog:      cat,
+      Dependencies: deps,
+      RequiredBy:   froms(rs),
+    }, nil
+  }
+  return nil, &ConflictError{Name: name, Wants: rs, Available: versions, Rejected: rejected}
+}
+
+// candidates returns the catalog name comes from and the versions it
+// offers, newest first, including the installed one.
+func (r *Resolver) candidates(name string, rs []Requirement) (Catalog, []string, error) {
+  var (
+    cat Catalog
+    err error
+  )
+  explicit := ""
+  for _, q := range rs {
+    if q.Catalog != "" {
+      explicit = q.Catalog
+    }
+  }
+  e, locked := r.lock.Get(name)
+  m := r.installed[name]
+  switch {
+  case explicit != "":
+    cat, err = r.Catalogs.Find(explicit)
+  case locked:
+    cat, err = r.Catalogs.forLock(e)
+  case m != nil:
+    // installed by hand; a catalog may still offer newer versions
+    cat, _, err = r.Catalogs.Lookup(name)
+    if err != nil {
+      return nil, []string{m.Version}, nil
+    }
+  default:
+    cat, _, err = r.Catalogs.Lookup(name)
+  }
+  if err != nil {
+    return nil, nil, err
+  }
+
+  versions, err := cat.Versions(name)
+  if err != nil {
+    return nil, nil, fmt.Errorf("toolpack %s: catalog %s: %w", name, cat.Name(), err)
+  }
+  if m != nil && m.Version != "" && !contains(versions, m.Version) {
+    versions = append(versions, m.Version)
+    sortVersions(versions)
+  }
+  return cat, versions, nil
+}
+
+// requires returns what version v of name needs: from its installed
+// manifest, else from the catalog's release.
+func (r *Resolver) requires(cat Catalog, name, v string, installed bool) (string, map[string]string, error) {
+  if m := r.installed[name]; installed && m != nil {
+    return m.Dolphin, m.Dependencies, nil
+  }
+  if cat == nil {
+    return "", nil, fmt.Errorf("toolpack %s %s is in no catalog", name, v)
+  }
+  key := name + "@" + v
+  rel, ok := r.releases[key]
+  if !ok {
+    var err error
+    if rel, err = cat.Release(name, v); err != nil {
+      return "", nil, fmt.Errorf("toolpack %s %s: %w", name, v, err)
+    }
+    r.releases[key] = rel
+  }
+  return rel.Dolphin, rel.Dependencies, nil
+}
+
+// Apply installs every pick that changes, dependencies first, and records
+// in the lock who required each pack. It returns what it installed; on
+// error, the packs before the failing one stay installed.
+func Apply(plan *Plan, trust Trust) ([]LockEntry, error) {
+  var out []LockEntry
+  for _, p := range plan.ordered() {
+    if !p.Changes() {
+      continue
+    }
+    if p.Catalog == nil {
+      return out, fmt.Errorf("toolpack %s %s can't be installed: it is in no catalog", p.Name, p.Version)
+    }
+    e, err := Install(p.Catalog, p.Name, p.Version, trust)
+    if err != nil {
+      return out, err
+    }
+    out = append(out, *e)
+  }
+
+  installMu.Lock()
+  defer installMu.Unlock()
+  lock, err := LoadLock()
+  if err != nil {
+    return out, err
+  }
+  for _, p := range plan.Picks {
+    if e, ok := lock.Get(p.Name); ok {
+      e.RequiredBy = p.RequiredBy
+      lock.Put(e)
+    }
+  }
+  for i := range out {
+    out[i], _ = lock.Get(out[i].Name)
+  }
+  return out, lock.Save()
+}
+
+// ordered returns the picks with every pack after its dependencies.
+func (p *Plan) ordered() []Pick {
+  byName := map[string]Pick{}
+  for _, pk := range p.Picks {
+    byName[pk.Name] = pk
+  }
+  var (
+    out  []Pick
+    seen = map[string]bool{}
+    visit func(string)
+  )
+  visit = func(name string) {
+    pk, ok := byName[name]
+    if !ok || seen[name] {
+      return
+    }
+    seen[name] = true
+    for _, dep := range sortedNames(pk.Dependencies) {
+      visit(dep)
+    }
+    out = append(out, pk)
+  }
+  for _, pk := range p.Picks {
+    visit(pk.Name)
+  }
+  return out
+}
+
+func allows(rs []Requirement, v string) bool {
+  for _, q := range rs {
+    if !q.Constraint.Allows(v) {
+      return false
+    }
+  }
+  return true
+}
+
+func froms(rs []Requirement) []string {
+  var out []string
+  for _, q := range rs {
+    if q.From != "" && !contains(out, q.From) {
+      out = append(out, q.From)
+    }
+  }
+  return out
+}
+
+func contains(list []string, s string) bool {
+  for _, x := range list {
+    if x == s {
+      return true
+    }
+  }
+  return false
+}
+
+func sortedNames[V any](m map[string]V) []string {
+  out := make([]string, 0, len(m))
+  for k := range m {
+    out = append(out, k)
+  }
+  sort.Strings(out)
+  return out
+}
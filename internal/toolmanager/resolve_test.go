@@ -0,0 +1,232 @@
+package toolmanager
+
+import (
+  "errors"
+  "os"
+  "path/filepath"
+  "strings"
+  "testing"
+)
+
+// memRelease is a version a memCatalog offers and what it requires.
+type memRelease struct {
+  dolphin string
+  deps    map[string]string
+}
+
+// memCatalog offers releases from memory; nothing can be downloaded.
+type memCatalog struct {
+  name  string
+  packs map[string]map[string]memRelease // pack → version → release
+}
+
+func (c *memCatalog) Name() string   { return c.name }
+func (c *memCatalog) Source() string { return "mem:" + c.name }
+
+func (c *memCatalog) List() ([]CatalogEntry, error) {
+  var out []CatalogEntry
+  for _, name := range sortedNames(c.packs) {
+    vs, _ := c.Versions(name)
+    out = append(out, CatalogEntry{Name: name, Catalog: c.name, Versions: vs})
+  }
+  return out, nil
+}
+
+func (c *memCatalog) Versions(pack string) ([]string, error) {
+  rels, ok := c.packs[pack]
+  if !ok {
+    return nil, errors.New("no such pack")
+  }
+  vs := sortedNames(rels)
+  sortVersions(vs)
+  return vs, nil
+}
+
+func (c *memCatalog) Release(pack, version string) (*Release, error) {
+  rel, ok := c.packs[pack][version]
+  if !ok {
+    return nil, errors.New("no such release")
+  }
+  return &Release{Pack: pack, Tag: version, Dolphin: rel.dolphin, Dependencies: rel.deps}, nil
+}
+
+func want(t *testing.T, spec, from string) Requirement {
+  t.Helper()
+  name, c, err := ParseSpec(spec)
+  if err != nil {
+    t.Fatal(err)
+  }
+  return Requirement{Name: name, Constraint: c, From: from}
+}
+
+func picked(plan *Plan) string {
+  var out []string
+  for _, p := range plan.Picks {
+    out = append(out, p.Name+"@"+p.Version)
+  }
+  return strings.Join(out, " ")
+}
+
+func TestResolve(t *testing.T) {
+  cat := &memCatalog{name: "mem", packs: map[string]map[string]memRelease{
+    "weather": {"v1.0.0": {}, "v1.2.0": {}, "v1.3.0-rc1": {}, "v2.0.0": {}},
+    "news": {
+      "v1.0.0": {deps: map[string]string{"geo": "^1.0"}},
+      "v1.1.0": {deps: map[string]string{"geo": "^1.2", "feeds": "~0.3"}},
+    },
+    "geo":    {"v1.0.0": {}, "v1.2.0": {}, "v1.5.0": {}, "v2.0.0": {}},
+    "feeds":  {"v0.3.0": {}, "v0.3.4": {}, "v0.4.0": {}},
+    "future": {"v1.0.0": {}, "v2.0.0": {dolphin: ">=99"}},
+    "maps":   {"v1.0.0": {deps: map[string]string{"geo": "^2"}}},
+  }}
+
+  tests := []struct {
+    name        string
+    roots       []string
+    constraints []string
+    fixed       map[string]string
+    want        string
+    conflict    string // the pack that can't be settled
+  }{
+    {name: "newest allowed", roots: []string{"weather@^1"}, want: "weather@v1.2.0"},
+    {name: "no constraint", roots: []string{"weather"}, want: "weather@v2.0.0"},
+    {name: "exact", roots: []string{"weather@v1.0.0"}, want: "weather@v1.0.0"},
+    {name: "prerelease asked for", roots: []string{"weather@^1.3.0-rc1"}, want: "weather@v1.3.0-rc1"},
+    {name: "dependencies", roots: []string{"news@v1.0.0"}, want: "geo@v1.5.0 news@v1.0.0"},
+    {name: "transitive ranges", roots: []string{"news"}, want: "feeds@v0.3.4 geo@v1.5.0 news@v1.1.0"},
+    {name: "two roots narrow a dependency", roots: []string{"news", "geo@<1.3"}, want: "feeds@v0.3.4 geo@v1.2.0 news@v1.1.0"},
+    {name: "constraints only apply when pulled in", roots: []string{"weather@^1"}, constraints: []string{"geo@^2"}, want: "weather@v1.2.0"},
+    {name: "constraint on a dependency", roots: []string{"news@v1.0.0"}, constraints: []string{"geo@<1.5"}, want: "geo@v1.2.0 news@v1.0.0"},
+    {name: "dolphin too old for the newest", roots: []string{"future"}, want: "future@v1.0.0"},
+    {name: "compiled in", roots: []string{"dolphin_tools@^0.1"}, fixed: map[string]string{"dolphin_tools": "v0.1.2"}, want: "dolphin_tools@v0.1.2"},
+
+    {name: "root out of range", roots: []string{"weather@^3"}, conflict: "weather"},
+    {name: "roots disagree", roots: []string{"weather@^1", "weather@^2"}, conflict: "weather"},
+    {name: "constraint against a root", roots: []string{"weather@^1"}, constraints: []string{"weather@>=2"}, conflict: "weather"},
+    {name: "dependencies disagree", roots: []string{"news@v1.0.0", "maps"}, conflict: "geo"},
+    {name: "dolphin too old for any", roots: []string{"future@^2"}, conflict: "future"},
+    {name: "compiled in too old", roots: []string{"dolphin_tools@^0.2"}, fixed: map[string]string{"dolphin_tools": "v0.1.2"}, conflict: "dolphin_tools"},
+  }
+  for _, tt := range tests {
+    t.Run(tt.name, func(t *testing.T) {
+      testHome(t)
+      r := &Resolver{Catalogs: Catalogs{cat}, Fixed: tt.fixed}
+      for _, c := range tt.constraints {
+        r.Constraints = append(r.Constraints, want(t, c, "agent "+c))
+      }
+      var roots []Requirement
+      for _, s := range tt.roots {
+        roots = append(roots, want(t, s, "root "+s))
+      }
+      plan, err := r.Resolve(roots)
+      if tt.conflict != "" {
+        var ce *ConflictError
+        if !errors.As(err, &ce) {
+          t.Fatalf("got %v, %v; want a conflict on %s", plan, err, tt.conflict)
+        }
+        if ce.Name != tt.conflict {
+          t.Errorf("conflict on %s, want %s:\n%v", ce.Name, tt.conflict, ce)
+        }
+        return
+      }
+      if err != nil {
+        t.Fatal(err)
+      }
+      if got := picked(plan); got != tt.want {
+        t.Errorf("picked %s, want %s", got, tt.want)
+      }
+    })
+  }
+}
+
+func TestResolveConflictMessage(t *testing.T) {
+  testHome(t)
+  cat := &memCatalog{name: "mem", packs: map[string]map[string]memRelease{
+    "a":   {"v1.0.0": {deps: map[string]string{"geo": "^1"}}},
+    "b":   {"v1.0.0": {deps: map[string]string{"geo": "^2"}}},
+    "geo": {"v1.0.0": {}, "v2.0.0": {dolphin: ">=99"}},
+  }}
+  r := &Resolver{Catalogs: Catalogs{cat}}
+  _, err := r.Resolve([]Requirement{want(t, "a", "agent x"), want(t, "b", "agent y")})
+  var ce *ConflictError
+  if !errors.As(err, &ce) {
+    t.Fatalf("got %v", err)
+  }
+  msg := ce.Error()
+  for _, s := range []string{`toolpack "geo"`, "a v1.0.0: ^1", "b v1.0.0: ^2", "available: v2.0.0, v1.0.0"} {
+    if !strings.Contains(msg, s) {
+      t.Errorf("message lacks %q:\n%s", s, msg)
+    }
+  }
+}
+
+func TestResolveKeepsInstalled(t *testing.T) {
+  testHome(t)
+  dir := filepath.Join(PluginDir(), "weather")
+  if err := os.MkdirAll(dir, 0o755); err != nil {
+    t.Fatal(err)
+  }
+  m := &Manifest{Name: "weather", Version: "v1.0.0", Library: "weather.so", Dir: dir}
+  if err := writeManifestFile(m); err != nil {
+    t.Fatal(err)
+  }
+  cat := &memCatalog{name: "mem", packs: map[string]map[string]memRelease{
+    "weather": {"v1.0.0": {}, "v1.2.0": {}, "v2.0.0": {}},
+  }}
+
+  r := &Resolver{Catalogs: Catalogs{cat}}
+  plan, err := r.Resolve([]Requirement{want(t, "weather@^1", "agent")})
+  if err != nil {
+    t.Fatal(err)
+  }
+  if p := plan.Picks[0]; p.Version != "v1.0.0" || p.Installed != "v1.0.0" || p.Changes() {
+    t.Errorf("installed version not kept: %+v", p)
+  }
+
+  r = &Resolver{Catalogs: Catalogs{cat}, Upgrade: map[string]bool{"weather": true}}
+  plan, err = r.Resolve([]Requirement{want(t, "weather@^1", "agent")})
+  if err != nil {
+    t.Fatal(err)
+  }
+  if p := plan.Picks[0]; p.Version != "v1.2.0" || !p.Changes() {
+    t.Errorf("upgrade: %+v", p)
+  }
+
+  // an installed version out of range is replaced
+  r = &Resolver{Catalogs: Catalogs{cat}}
+  plan, err = r.Resolve([]Requirement{want(t, "weather@^2", "agent")})
+  if err != nil || plan.Picks[0].Version != "v2.0.0" {
+    t.Errorf("out of range: %v, %v", plan, err)
+  }
+}
+
+func TestPlanOrdered(t *testing.T) {
+  plan := &Plan{Picks: []Pick{
+    {Name: "a", Dependencies: map[string]string{"b": "*", "c": "*"}},
+    {Name: "b", Dependencies: map[string]string{"c": "*"}},
+    {Name: "c"},
+    {Name: "d"},
+  }}
+  var got []string
+  for _, p := range plan.ordered() {
+    got = append(got, p.Name)
+  }
+  if strings.Join(got, " ") != "c b a d" {
+    t.Errorf("order %v, want dependencies first", got)
+  }
+}
+
+func TestParseSpec(t *testing.T) {
+  name, c, err := ParseSpec(" weather@^0.2 ")
+  if err != nil || name != "weather" || c.String() != "^0.2" {
+    t.Errorf("got %q %v %v", name, c, err)
+  }
+  if name, c, err := ParseSpec("weather"); err != nil || name != "weather" || !c.IsAny() {
+    t.Errorf("got %q %v %v", name, c, err)
+  }
+  for _, bad := range []string{"", "@^1", "weather@^x"} {
+    if _, _, err := ParseSpec(bad); err == nil {
+      t.Errorf("ParseSpec(%q) accepted", bad)
+    }
+  }
+}
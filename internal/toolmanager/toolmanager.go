@@ -1,124 +1,47 @@
 package toolmanager
 
 import (
-  "encoding/json"
   "errors"
-	"io/fs"
   "fmt"
-  "net/http"
-  "path/filepath"
-  "plugin"
   "strings"
 
+  "github.com/johnjallday/dolphin-tool-calling-agent/internal/paths"
   "github.com/johnjallday/dolphin-tool-calling-agent/pkg/tools"
 )
 
-var (
-  // PluginDir is where your .so plugin files live. Change as needed.
-  PluginDir = "./plugins"
-)
+// HostVersion is this dolphin's version, which a toolpack.toml can
+// require (dolphin = ">=0.2"). Release builds set it with
+// -ldflags "-X github.com/johnjallday/dolphin-tool-calling-agent/internal/toolmanager.HostVersion=v0.2.0".
+var HostVersion = "0.1.0"
+
+// PluginDir is where your .so plugin files live (see the paths package for
+// how it is resolved).
+func PluginDir() string {
+  return paths.PluginDir()
+}
 
-// ToolPacks scans PluginDir (recursively), opens every “.so” it finds,
-// looks up the PluginPackage() symbol, and returns the slice of ToolPackage.
+// ToolPacks returns the compiled-in toolpacks, then every toolpack found
+// under PluginDir as described by its toolpack.toml. No plugin code is
+// loaded; the returned tools are metadata only (no Exec).
 func ToolPacks() ([]tools.ToolPackage, error) {
   var packs []tools.ToolPackage
-
-  walkErr := filepath.WalkDir(PluginDir, func(path string, d fs.DirEntry, err error) error {
-    if err != nil {
-      // e.g. permission error
-      return err
-    }
-    if d.IsDir() {
-      return nil
-    }
-    if filepath.Ext(path) != ".so" {
-      return nil
-    }
-
-    p, err := plugin.Open(path)
-    if err != nil {
-      fmt.Printf("⚠️  failed to open plugin %q: %v\n", path, err)
-      return nil // keep walking
-    }
-
-    sym, err := p.Lookup("PluginPackage")
-    if err != nil {
-      fmt.Printf("⚠️  %q does not export PluginPackage(): %v\n", path, err)
-      return nil
-    }
-
-    constructor, ok := sym.(func() tools.ToolPackage)
-    if !ok {
-      fmt.Printf("⚠️  %q: PluginPackage has wrong signature\n", path)
-      return nil
+  for _, name := range tools.Packages() {
+    if pkg, ok := tools.LookupPackage(name); ok {
+      packs = append(packs, pkg)
     }
-
-    pkg := constructor()
-    packs = append(packs, pkg)
-    return nil
-  })
-
-  if walkErr != nil {
-    return nil, fmt.Errorf("walking plugin dir %q: %w", PluginDir, walkErr)
   }
-  if len(packs) == 0 {
-    return nil, errors.New("no plugins loaded")
-  }
-  return packs, nil
-}
 
-// CheckVersion fetches the latest GitHub release for each discovered
-// ToolPackage and compares it to the local Version field.
-// Prints out which are up-to-date and which have updates available.
-func CheckVersion() error {
-  packs, err := ToolPacks()
+  ms, _, err := Manifests()
   if err != nil {
-    return err
+    return nil, err
   }
-
-  type ghRelease struct {
-    TagName string `json:"tag_name"`
+  for _, m := range ms {
+    packs = append(packs, m.ToolPackage())
   }
-
-  for _, pkg := range packs {
-    owner, repo, err := parseGitHubRepo(pkg.Link)
-    if err != nil {
-      fmt.Printf("%s: invalid GitHub link %q: %v\n", pkg.Name, pkg.Link, err)
-      continue
-    }
-
-    url := fmt.Sprintf(
-      "https://api.github.com/repos/%s/%s/releases/latest",
-      owner, repo,
-    )
-
-    resp, err := http.Get(url)
-    if err != nil {
-      fmt.Printf("%s: HTTP error: %v\n", pkg.Name, err)
-      continue
-    }
-    defer resp.Body.Close()
-
-    if resp.StatusCode != http.StatusOK {
-      fmt.Printf("%s: unexpected HTTP status %s\n", pkg.Name, resp.Status)
-      continue
-    }
-
-    var rel ghRelease
-    if err := json.NewDecoder(resp.Body).Decode(&rel); err != nil {
-      fmt.Printf("%s: JSON decode error: %v\n", pkg.Name, err)
-      continue
-    }
-
-    if rel.TagName == pkg.Version {
-      fmt.Printf("%s: up-to-date (%s)\n", pkg.Name, pkg.Version)
-    } else {
-      fmt.Printf("%s: update available: local v%s → remote %s\n",
-        pkg.Name, pkg.Version, rel.TagName)
-    }
+  if len(packs) == 0 {
+    return nil, errors.New("no plugins loaded")
   }
-
-  return nil
+  return packs, nil
 }
 
 // parseGitHubRepo extracts owner and repo from a GitHub HTTPS URL.
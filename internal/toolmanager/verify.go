@@ -0,0 +1,301 @@
+package toolmanager
+
+import (
+  "bufio"
+  "bytes"
+  "crypto/ed25519"
+  "encoding/base64"
+  "encoding/hex"
+  "fmt"
+  "io"
+  "os"
+  "path/filepath"
+  "strings"
+  "time"
+
+  "golang.org/x/crypto/blake2b"
+)
+
+// Trust is what an install must prove before a downloaded .so goes
+// anywhere near the plugin dir. A SHA-256 checksum published with the
+// release is always required; signatures are checked against Keys.
+type Trust struct {
+  // Keys are the trusted publishers' minisign public keys.
+  Keys []PublicKey
+  // RequireSignature refuses releases not signed by one of Keys.
+  RequireSignature bool
+}
+
+// PublicKey is a minisign (ed25519) public key.
+type PublicKey struct {
+  ID  [8]byte
+  Key ed25519.PublicKey
+}
+
+// IDString is the key id as minisign prints it.
+func (k PublicKey) IDString() string {
+  id := k.ID
+  // minisign shows the little-endian id as a big-endian hex number
+  for i, j := 0, len(id)-1; i < j; i, j = i+1, j-1 {
+    id[i], id[j] = id[j], id[i]
+  }
+  return strings.ToUpper(hex.EncodeToString(id[:]))
+}
+
+// ParsePublicKey reads a minisign public key: the base64 line alone, or
+// the whole .pub file with its "untrusted comment:" line.
+func ParsePublicKey(s string) (PublicKey, error) {
+  var k PublicKey
+  line := ""
+  for _, l := range strings.Split(strings.TrimSpace(s), "\n") {
+    if l = strings.TrimSpace(l); l != "" && !strings.HasPrefix(l, "untrusted comment:") {
+      line = l
+      break
+    }
+  }
+  raw, err := base64.StdEncoding.DecodeString(line)
+  if err != nil || len(raw) != 2+8+ed25519.PublicKeySize || string(raw[:2]) != "Ed" {
+    return k, fmt.Errorf("not a minisign public key: %q", s)
+  }
+  copy(k.ID[:], raw[2:10])
+  k.Key = ed25519.PublicKey(raw[10:])
+  return k, nil
+}
+
+// checksumNames are the release assets a checksum may come from, besides
+// <asset>.sha256.
+var checksumNames = []string{"SHA256SUMS", "sha256sums.txt", "checksums.txt"}
+
+// releaseChecksum finds the published SHA-256 of art: inline from the
+// catalog, or from a checksum asset of rel. It returns the checksum, the
+// asset it was read from ("" if inline) and that asset's contents (which a
+// signature may cover).
+func releaseChecksum(rel *Release, art *Artifact) (sum, from string, data []byte, err error) {
+  if isSHA256(art.SHA256) {
+    return strings.ToLower(art.SHA256), "", nil, nil
+  }
+  asset := art.Name
+  for _, name := range append([]string{asset + ".sha256"}, checksumNames...) {
+    url, ok := rel.Assets[name]
+    if !ok {
+      continue
+    }
+    data, err := fetch(url, rel.Local)
+    if err != nil {
+      return "", "", nil, err
+    }
+    if sum := parseChecksums(data, name, asset); sum != "" {
+      return sum, name, data, nil
+    }
+  }
+  return "", "", nil, fmt.Errorf("release %s publishes no SHA-256 checksum for %s (expected %s.sha256 or %s)",
+    rel.Tag, asset, asset, strings.Join(checksumNames, ", "))
+}
+
+// parseChecksums reads sha256sum output ("<hex>  <file>" per line, the
+// file optionally prefixed with '*') from the release asset from, and
+// returns the digest for asset. A lone hex digest only counts in
+// <asset>.sha256; a shared file has to name the asset.
+func parseChecksums(data []byte, from, asset string) string {
+  sc := bufio.NewScanner(bytes.NewReader(data))
+  for sc.Scan() {
+    fields := strings.Fields(sc.Text())
+    switch {
+    case len(fields) == 1 && isSHA256(fields[0]) && from == asset+".sha256":
+      return strings.ToLower(fields[0])
+    case len(fields) == 2 && isSHA256(fields[0]) && strings.TrimPrefix(fields[1], "*") == asset:
+      return strings.ToLower(fields[0])
+    }
+  }
+  return ""
+}
+
+func isSHA256(s string) bool {
+  b, err := hex.DecodeString(s)
+  return err == nil && len(b) == 32
+}
+
+// minisig is a parsed minisign signature file.
+type minisig struct {
+  prehashed      bool // "ED": the signature covers BLAKE2b-512(data)
+  keyID          [8]byte
+  sig            []byte
+  trustedComment string
+  globalSig      []byte
+}
+
+func parseMinisig(data []byte) (*minisig, error) {
+  lines := strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+  if len(lines) < 4 || !strings.HasPrefix(lines[2], "trusted comment: ") {
+    return nil, fmt.Errorf("malformed minisign signature")
+  }
+  raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[1]))
+  if err != nil || len(raw) != 2+8+ed25519.SignatureSize {
+    return nil, fmt.Errorf("malformed minisign signature")
+  }
+  global, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[3]))
+  if err != nil || len(global) != ed25519.SignatureSize {
+    return nil, fmt.Errorf("malformed minisign global signature")
+  }
+  s := &minisig{
+    sig:            raw[10:],
+    trustedComment: strings.TrimPrefix(lines[2], "trusted comment: "),
+    globalSig:      global,
+  }
+  switch string(raw[:2]) {
+  case "Ed":
+  case "ED":
+    s.prehashed = true
+  default:
+    return nil, fmt.Errorf("unsupported minisign algorithm %q", raw[:2])
+  }
+  copy(s.keyID[:], raw[2:10])
+  return s, nil
+}
+
+// errUntrustedKey means a signature is well-formed but made by a key
+// that is not in Trust.Keys.
+type errUntrustedKey struct{ id string }
+
+func (e errUntrustedKey) Error() string {
+  return fmt.Sprintf("signed by key %s, which is not a trusted publisher", e.id)
+}
+
+// verify checks the signature over the content read from r and returns
+// the key that made it.
+func (s *minisig) verify(keys []PublicKey, r io.Reader) (PublicKey, error) {
+  var key *PublicKey
+  for i := range keys {
+    if keys[i].ID == s.keyID {
+      key = &keys[i]
+      break
+    }
+  }
+  if key == nil {
+    return PublicKey{}, errUntrustedKey{PublicKey{ID: s.keyID}.IDString()}
+  }
+
+  var msg []byte
+  if s.prehashed {
+    h, _ := blake2b.New512(nil)
+    if _, err := io.Copy(h, r); err != nil {
+      return PublicKey{}, err
+    }
+    msg = h.Sum(nil)
+  } else {
+    b, err := io.ReadAll(r)
+    if err != nil {
+      return PublicKey{}, err
+    }
+    msg = b
+  }
+  if !ed25519.Verify(key.Key, msg, s.sig) {
+    return PublicKey{}, fmt.Errorf("signature by key %s does not match", key.IDString())
+  }
+  // the trusted comment is signed too, so it can't be swapped
+  if !ed25519.Verify(key.Key, append(append([]byte(nil), s.sig...), s.trustedComment...), s.globalSig) {
+    return PublicKey{}, fmt.Errorf("trusted comment signature by key %s does not match", key.IDString())
+  }
+  return *key, nil
+}
+
+// verifySignature looks for a minisign signature of the checksum file
+// (<sums>.minisig) or of the library itself (<asset>.minisig) and checks
+// it. It returns the signing key's id, or "" when the release is unsigned
+// (or signed by an unknown key) and trust doesn't require a signature.
+func verifySignature(rel *Release, trust Trust, asset, lib, sums string, sumsData []byte) (string, error) {
+  var (
+    sigURL string
+    open   func() (io.ReadCloser, error)
+  )
+  if url, ok := rel.Assets[sums+".minisig"]; ok && sums != "" {
+    sigURL = url
+    open = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(sumsData)), nil }
+  } else if url, ok := rel.Assets[asset+".minisig"]; ok {
+    sigURL = url
+    open = func() (io.ReadCloser, error) { return os.Open(lib) }
+  }
+
+  if sigURL == "" {
+    if trust.RequireSignature {
+      return "", fmt.Errorf("release %s is not signed (expected %s.minisig)", rel.Tag, asset)
+    }
+    return "", nil
+  }
+  data, err := fetch(sigURL, rel.Local)
+  if err != nil {
+    return "", err
+  }
+  sig, err := parseMinisig(data)
+  if err != nil {
+    return "", err
+  }
+  r, err := open()
+  if err != nil {
+    return "", err
+  }
+  defer r.Close()
+  key, err := sig.verify(trust.Keys, r)
+  if _, unknown := err.(errUntrustedKey); unknown && !trust.RequireSignature {
+    return "", nil
+  }
+  if err != nil {
+    return "", err
+  }
+  return key.IDString(), nil
+}
+
+// QuarantineDir holds downloads that failed verification, for inspection.
+func QuarantineDir() string {
+  return filepath.Join(PluginDir(), ".quarantine")
+}
+
+// quarantine moves a downloaded file that failed verification out of the
+// way (never into a pack folder) and records why next to it.
+func quarantine(tmp, pack, tag string, why error) error {
+  dir := QuarantineDir()
+  if err := os.MkdirAll(dir, 0o700); err != nil {
+    return err
+  }
+  base := fmt.Sprintf("%s-%s-%s", pack, tag, time.Now().UTC().Format("20060102T150405.000000Z"))
+  // not .so, so nothing ever mistakes it for a toolpack
+  dst := filepath.Join(dir, base+".so.quarantined")
+  if err := os.Rename(tmp, dst); err != nil {
+    return err
+  }
+  os.Chmod(dst, 0o600)
+  return os.WriteFile(filepath.Join(dir, base+".reason"), []byte(why.Error()+"\n"), 0o600)
+}
+
+// Quarantined is one download held back by verification.
+type Quarantined struct {
+  Path   string
+  Reason string
+}
+
+// QuarantinedFiles lists what is in QuarantineDir.
+func QuarantinedFiles() ([]Quarantined, error) {
+  entries, err := os.ReadDir(QuarantineDir())
+  if os.IsNotExist(err) {
+    return nil, nil
+  }
+  if err != nil {
+    return nil, err
+  }
+  var out []Quarantined
+  for _, e := range entries {
+    if !strings.HasSuffix(e.Name(), ".so.quarantined") {
+      continue
+    }
+    q := Quarantined{Path: filepath.Join(QuarantineDir(), e.Name())}
+    reason, _ := os.ReadFile(strings.TrimSuffix(q.Path, ".so.quarantined") + ".reason")
+    q.Reason = strings.TrimSpace(string(reason))
+    out = append(out, q)
+  }
+  return out, nil
+}
+
+// ClearQuarantine deletes every quarantined download.
+func ClearQuarantine() error {
+  return os.RemoveAll(QuarantineDir())
+}
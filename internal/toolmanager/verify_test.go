@@ -0,0 +1,297 @@
+package toolmanager
+
+import (
+  "bytes"
+  "crypto/ed25519"
+  "crypto/rand"
+  "encoding/base64"
+  "os"
+  "path/filepath"
+  "strings"
+  "testing"
+
+  "golang.org/x/crypto/blake2b"
+)
+
+// testKey is a minisign key pair made up for a test.
+type testKey struct {
+  pub  PublicKey
+  priv ed25519.PrivateKey
+}
+
+func newTestKey(t *testing.T) testKey {
+  t.Helper()
+  pub, priv, err := ed25519.GenerateKey(rand.Reader)
+  if err != nil {
+    t.Fatal(err)
+  }
+  k := testKey{pub: PublicKey{Key: pub}, priv: priv}
+  rand.Read(k.pub.ID[:])
+  return k
+}
+
+// String is the key as minisign writes it to a .pub file.
+func (k testKey) String() string {
+  raw := append(append([]byte("Ed"), k.pub.ID[:]...), k.pub.Key...)
+  return "untrusted comment: minisign public key\n" + base64.StdEncoding.EncodeToString(raw) + "\n"
+}
+
+// sign makes a minisign signature of data, prehashed ("ED") like current
+// minisign unless legacy is set.
+func (k testKey) sign(data []byte, legacy bool) []byte {
+  alg, msg := "ED", data
+  if legacy {
+    alg = "Ed"
+  } else {
+    h := blake2b.Sum512(data)
+    msg = h[:]
+  }
+  sig := ed25519.Sign(k.priv, msg)
+  comment := "timestamp:1700000000"
+  global := ed25519.Sign(k.priv, append(append([]byte(nil), sig...), comment...))
+  raw := append(append([]byte(alg), k.pub.ID[:]...), sig...)
+  return []byte("untrusted comment: signature from minisign secret key\n" +
+    base64.StdEncoding.EncodeToString(raw) + "\n" +
+    "trusted comment: " + comment + "\n" +
+    base64.StdEncoding.EncodeToString(global) + "\n")
+}
+
+func TestParsePublicKey(t *testing.T) {
+  k := newTestKey(t)
+  got, err := ParsePublicKey(k.String())
+  if err != nil {
+    t.Fatal(err)
+  }
+  if got.ID != k.pub.ID || !bytes.Equal(got.Key, k.pub.Key) {
+    t.Errorf("got %v, want %v", got, k.pub)
+  }
+  line := strings.Split(k.String(), "\n")[1]
+  if _, err := ParsePublicKey(line); err != nil {
+    t.Errorf("bare key line: %v", err)
+  }
+  for _, bad := range []string{"", "not base64!", base64.StdEncoding.EncodeToString([]byte("Ed short"))} {
+    if _, err := ParsePublicKey(bad); err == nil {
+      t.Errorf("ParsePublicKey(%q) accepted", bad)
+    }
+  }
+}
+
+func TestParseChecksums(t *testing.T) {
+  a, b := strings.Repeat("a", 64), strings.Repeat("B", 64)
+  tests := []struct {
+    name, data, from, asset, want string
+  }{
+    {"sha256sum", a + "  weather.so\n" + b + "  other.so\n", "SHA256SUMS", "other.so", strings.ToLower(b)},
+    {"binary mode", a + " *weather.so\n", "checksums.txt", "weather.so", a},
+    {"lone digest", a + "\n", "weather.so.sha256", "weather.so", a},
+    {"lone digest in a shared file", a + "\n", "SHA256SUMS", "weather.so", ""},
+    {"lone digest for another asset", a + "\n", "other.so.sha256", "weather.so", ""},
+    {"missing line", a + "  other.so\n", "SHA256SUMS", "weather.so", ""},
+    {"short digest", "abc  weather.so\n", "weather.so.sha256", "weather.so", ""},
+    {"empty", "", "weather.so.sha256", "weather.so", ""},
+  }
+  for _, tt := range tests {
+    t.Run(tt.name, func(t *testing.T) {
+      if got := parseChecksums([]byte(tt.data), tt.from, tt.asset); got != tt.want {
+        t.Errorf("got %q, want %q", got, tt.want)
+      }
+    })
+  }
+}
+
+func TestReleaseChecksum(t *testing.T) {
+  lib := []byte("library")
+  sums := []byte(sha256Hex(lib) + "  weather.so\n")
+  base := serve(t, map[string][]byte{"SHA256SUMS": sums, "other": []byte(sha256Hex(lib) + "  other.so\n")})
+
+  art := &Artifact{Name: "weather.so"}
+  rel := &Release{Tag: "v1", Assets: map[string]string{"SHA256SUMS": base + "/SHA256SUMS"}}
+  sum, from, data, err := releaseChecksum(rel, art)
+  if err != nil || sum != sha256Hex(lib) || from != "SHA256SUMS" || !bytes.Equal(data, sums) {
+    t.Fatalf("got %q from %q (%v)", sum, from, err)
+  }
+
+  inline := &Artifact{Name: "weather.so", SHA256: strings.ToUpper(sha256Hex(lib))}
+  if sum, from, _, err := releaseChecksum(&Release{Tag: "v1"}, inline); err != nil || sum != sha256Hex(lib) || from != "" {
+    t.Errorf("inline: got %q from %q (%v)", sum, from, err)
+  }
+
+  // a checksum file without a line for the asset doesn't count
+  rel.Assets = map[string]string{"checksums.txt": base + "/other"}
+  if _, _, _, err := releaseChecksum(rel, art); err == nil || !strings.Contains(err.Error(), "no SHA-256") {
+    t.Errorf("missing line: got %v", err)
+  }
+  if _, _, _, err := releaseChecksum(&Release{Tag: "v1"}, art); err == nil {
+    t.Error("no checksum at all was accepted")
+  }
+}
+
+func TestMinisigVerify(t *testing.T) {
+  k, other := newTestKey(t), newTestKey(t)
+  data := []byte("the checksums")
+
+  for _, legacy := range []bool{false, true} {
+    sig, err := parseMinisig(k.sign(data, legacy))
+    if err != nil {
+      t.Fatal(err)
+    }
+    if got, err := sig.verify([]PublicKey{other.pub, k.pub}, bytes.NewReader(data)); err != nil || got.ID != k.pub.ID {
+      t.Errorf("legacy=%v: good signature: %v", legacy, err)
+    }
+    if _, err := sig.verify([]PublicKey{k.pub}, bytes.NewReader([]byte("the checksumz"))); err == nil {
+      t.Errorf("legacy=%v: tampered data verified", legacy)
+    }
+    if _, err := sig.verify([]PublicKey{other.pub}, bytes.NewReader(data)); err == nil {
+      t.Errorf("legacy=%v: verified without its key", legacy)
+    } else if _, ok := err.(errUntrustedKey); !ok {
+      t.Errorf("legacy=%v: unknown key: got %T %v", legacy, err, err)
+    }
+  }
+
+  // another key claiming k's id
+  forged := other
+  forged.pub.ID = k.pub.ID
+  sig, _ := parseMinisig(forged.sign(data, false))
+  if _, err := sig.verify([]PublicKey{k.pub}, bytes.NewReader(data)); err == nil {
+    t.Error("signature by the wrong key verified")
+  }
+
+  // the trusted comment is covered by the global signature
+  swapped := strings.Replace(string(k.sign(data, false)), "timestamp:1700000000", "timestamp:1", 1)
+  sig, _ = parseMinisig([]byte(swapped))
+  if _, err := sig.verify([]PublicKey{k.pub}, bytes.NewReader(data)); err == nil {
+    t.Error("swapped trusted comment verified")
+  }
+
+  for _, bad := range []string{"", "one\ntwo\nthree\nfour", "untrusted comment: x\nAAAA\ntrusted comment: y\nAAAA\n"} {
+    if _, err := parseMinisig([]byte(bad)); err == nil {
+      t.Errorf("parseMinisig(%q) accepted", bad)
+    }
+  }
+}
+
+func TestVerifySignature(t *testing.T) {
+  k, other := newTestKey(t), newTestKey(t)
+  lib := []byte("library")
+  sums := []byte(sha256Hex(lib) + "  weather.so\n")
+  base := serve(t, map[string][]byte{
+    "SHA256SUMS.minisig":  k.sign(sums, false),
+    "weather.so.minisig":  k.sign(lib, false),
+    "tampered.so.minisig": k.sign([]byte("something else"), false),
+  })
+  libPath := filepath.Join(t.TempDir(), "weather.so")
+  if err := os.WriteFile(libPath, lib, 0o644); err != nil {
+    t.Fatal(err)
+  }
+  trusted := Trust{Keys: []PublicKey{k.pub}}
+  strict := Trust{Keys: []PublicKey{other.pub}, RequireSignature: true}
+
+  release := func(assets ...string) *Release {
+    rel := &Release{Tag: "v1", Assets: map[string]string{}}
+    for _, a := range assets {
+      rel.Assets[a] = base + "/" + a
+    }
+    return rel
+  }
+
+  tests := []struct {
+    name    string
+    rel     *Release
+    trust   Trust
+    sums    string
+    want    string // signer id
+    wantErr string
+  }{
+    {name: "checksum file signed", rel: release("SHA256SUMS.minisig"), trust: trusted, sums: "SHA256SUMS", want: k.pub.IDString()},
+    {name: "library signed", rel: release("weather.so.minisig"), trust: trusted, want: k.pub.IDString()},
+    {name: "unsigned", rel: release(), trust: trusted},
+    {name: "unsigned but required", rel: release(), trust: Trust{Keys: trusted.Keys, RequireSignature: true}, wantErr: "not signed"},
+    {name: "unknown key", rel: release("weather.so.minisig"), trust: Trust{Keys: []PublicKey{other.pub}}},
+    {name: "unknown key but required", rel: release("weather.so.minisig"), trust: strict, wantErr: "not a trusted publisher"},
+    {name: "signature of other data", rel: &Release{Tag: "v1", Assets: map[string]string{"weather.so.minisig": base + "/tampered.so.minisig"}}, trust: trusted, wantErr: "does not match"},
+  }
+  for _, tt := range tests {
+    t.Run(tt.name, func(t *testing.T) {
+      got, err := verifySignature(tt.rel, tt.trust, "weather.so", libPath, tt.sums, sums)
+      switch {
+      case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+        t.Fatalf("got %q, %v; want an error containing %q", got, err, tt.wantErr)
+      case tt.wantErr == "" && err != nil:
+        t.Fatalf("unexpected error: %v", err)
+      case got != tt.want:
+        t.Errorf("signer %q, want %q", got, tt.want)
+      }
+    })
+  }
+}
+
+func TestInstallVerifies(t *testing.T) {
+  k := newTestKey(t)
+  lib := testPlugin(t, "v1.0.0")
+  sums := []byte(sha256Hex(lib) + "  weather.so\n")
+  signed := map[string][]byte{"SHA256SUMS": sums, "SHA256SUMS.minisig": k.sign(sums, false)}
+
+  t.Run("signed", func(t *testing.T) {
+    testHome(t)
+    cat := serveCatalog(t, "weather", fakeVersion{tag: "v1.0.0", lib: lib, files: signed, sha256: "-"})
+    e, err := Install(cat, "weather", "", Trust{Keys: []PublicKey{k.pub}, RequireSignature: true})
+    if err != nil {
+      t.Fatal(err)
+    }
+    if got, _ := lockEntry(t, "weather"); e.SignedBy != k.pub.IDString() || got.SignedBy != e.SignedBy {
+      t.Errorf("signed by %q, lock has %q, want %q", e.SignedBy, got.SignedBy, k.pub.IDString())
+    }
+  })
+
+  t.Run("tampered artifact", func(t *testing.T) {
+    testHome(t)
+    tampered := append([]byte(nil), lib...)
+    tampered[len(tampered)/2] ^= 0xff
+    cat := serveCatalog(t, "weather", fakeVersion{tag: "v1.0.0", lib: tampered, sha256: sha256Hex(lib)})
+    _, err := Install(cat, "weather", "", Trust{})
+    if err == nil || !strings.Contains(err.Error(), "does not match its published checksum") {
+      t.Fatalf("got %v", err)
+    }
+    q, err := QuarantinedFiles()
+    if err != nil || len(q) != 1 {
+      t.Fatalf("quarantined %+v, %v", q, err)
+    }
+    if _, err := os.Stat(filepath.Join(PluginDir(), "weather", "weather.so")); !os.IsNotExist(err) {
+      t.Error("a tampered artifact was installed")
+    }
+  })
+
+  t.Run("bad signature", func(t *testing.T) {
+    testHome(t)
+    files := map[string][]byte{"SHA256SUMS": sums, "SHA256SUMS.minisig": k.sign([]byte("other sums"), false)}
+    cat := serveCatalog(t, "weather", fakeVersion{tag: "v1.0.0", lib: lib, files: files, sha256: "-"})
+    if _, err := Install(cat, "weather", "", Trust{Keys: []PublicKey{k.pub}}); err == nil || !strings.Contains(err.Error(), "quarantined") {
+      t.Fatalf("got %v", err)
+    }
+    if _, ok := lockEntry(t, "weather"); ok {
+      t.Error("a badly signed release was recorded in the lock")
+    }
+  })
+
+  t.Run("no checksum", func(t *testing.T) {
+    testHome(t)
+    cat := serveCatalog(t, "weather", fakeVersion{tag: "v1.0.0", lib: lib, sha256: "-",
+      files: map[string][]byte{"SHA256SUMS": []byte(sha256Hex(lib) + "  other.so\n")}})
+    if _, err := Install(cat, "weather", "", Trust{}); err == nil || !strings.Contains(err.Error(), "no SHA-256 checksum") {
+      t.Fatalf("got %v", err)
+    }
+  })
+
+  t.Run("signature required", func(t *testing.T) {
+    testHome(t)
+    cat := serveCatalog(t, "weather", fakeVersion{tag: "v1.0.0", lib: lib})
+    _, err := Install(cat, "weather", "", Trust{Keys: []PublicKey{k.pub}, RequireSignature: true})
+    if err == nil || !strings.Contains(err.Error(), "require_signature") {
+      t.Fatalf("got %v", err)
+    }
+    // refused before anything was downloaded
+    if _, err := os.Stat(QuarantineDir()); !os.IsNotExist(err) {
+      t.Errorf("quarantine dir exists: %v", err)
+    }
+  })
+}
@@ -0,0 +1,30 @@
+package tui
+
+import (
+  "context"
+  "fmt"
+  "strings"
+
+  "github.com/fatih/color"
+  "github.com/johnjallday/dolphin-tool-calling-agent/internal/app"
+)
+
+// Approve asks on the terminal whether a tool that changes things may run.
+// Turns run on the REPL goroutine, so the prompt simply takes over the line.
+// Install it with App.SetApprover.
+func (t *TUIApp) Approve(ctx context.Context, req app.ApprovalRequest) bool {
+  if ctx.Err() != nil {
+    return false
+  }
+  color.New(color.FgYellow, color.Bold).Fprintf(t.Out, "%s wants to run %s", req.Agent, req.Tool)
+  fmt.Fprintf(t.Out, " %s\n", req.Args)
+  answer, err := t.Rl.Prompt("allow? [y/N] ")
+  if err != nil {
+    return false
+  }
+  switch strings.ToLower(strings.TrimSpace(answer)) {
+  case "y", "yes":
+    return true
+  }
+  return false
+}
@@ -6,6 +6,7 @@ import (
     "github.com/fatih/color"
 		"strings"
     "github.com/johnjallday/dolphin-tool-calling-agent/internal/app"
+    "github.com/johnjallday/dolphin-tool-calling-agent/internal/store"
     "github.com/johnjallday/dolphin-tool-calling-agent/internal/user"
 		"os"
   	"path/filepath"
@@ -203,22 +204,92 @@ func AgentCmd(t *TUIApp, _ []string) error {
     return ToolsCmd(t, nil)
 }
 
-// AgentsCmd lists all agents for the current user.
+// AgentsCmd lists all agents for the current user, marking the live ones
+// (“*” for the current agent, “+” for others loaded alongside it).
 func AgentsCmd(t *TUIApp, _ []string) error {
+    live := map[string]bool{}
+    for _, a := range t.App.LiveAgents() {
+        live[a.Name] = true
+    }
+    current := ""
+    if a := t.App.Agent(); a != nil {
+        current = a.Name
+    }
+
     fmt.Fprintln(t.Out, "Agents:")
     for _, m := range t.App.Agents() {
-        fmt.Fprintf(t.Out, "  %s\t%s\n", m.Name, m.Model)
+        mark := " "
+        switch {
+        case m.Name == current:
+            mark = "*"
+        case live[m.Name]:
+            mark = "+"
+        }
+        fmt.Fprintf(t.Out, "  %s %s\t%s\n", mark, m.Name, m.Model)
+    }
+    return nil
+}
+
+// UsageCmd prints the tokens spent by the current agent and a breakdown of
+// every call it delegated to a sub-agent.
+func UsageCmd(t *TUIApp, _ []string) error {
+    a := t.App.Agent()
+    if a == nil {
+        fmt.Fprintln(t.Out, "No agent loaded")
+        return nil
+    }
+    cLabel := color.New(color.FgYellow, color.Bold)
+    cLabel.Fprint(t.Out, "Usage: ")
+    fmt.Fprintln(t.Out, a.Usage())
+
+    calls := a.SubCalls()
+    if len(calls) == 0 {
+        return nil
+    }
+    cLabel.Fprintln(t.Out, "Sub-agent calls:")
+    for _, c := range calls {
+        status := "ok"
+        if c.Err != nil {
+            status = c.Err.Error()
+        }
+        fmt.Fprintf(t.Out, "  %s\t%-40s\t%s\t%s\n", c.Agent, shorten(c.Task, 40), c.Usage, status)
     }
     return nil
 }
 
-// ToolsCmd lists all tools on the current agent.
-func ToolsCmd(t *TUIApp, _ []string) error {
+// shorten cuts s to at most n characters, ending in "..." if it was cut.
+func shorten(s string, n int) string {
+    r := []rune(s)
+    if len(r) <= n {
+        return s
+    }
+    return string(r[:n-3]) + "..."
+}
+
+// CloseAgentCmd unloads one live agent without touching the others.
+func CloseAgentCmd(t *TUIApp, args []string) error {
+    if len(args) != 1 {
+        fmt.Fprintln(t.Out, "usage: close-agent <agent-name>")
+        return nil
+    }
+    if err := t.App.CloseAgent(args[0]); err != nil {
+        return fmt.Errorf("close agent: %w", err)
+    }
+    color.New(color.FgGreen).Fprintln(t.Out, "✓ agent closed:", args[0])
+    return t.Refresh()
+}
+
+// ToolsCmd lists all tools on the current agent; `tools offered` shows
+// which of them went out with each recent turn instead.
+func ToolsCmd(t *TUIApp, args []string) error {
     // guard against no‐agent
     if t.App.Agent() == nil {
         fmt.Fprintln(t.Out, "No agent loaded")
         return nil
     }
+    if len(args) > 0 && args[0] == "offered" {
+        return printToolOffers(t)
+    }
 
     cLabel := color.New(color.FgYellow, color.Bold)
     cVal   := color.New(color.FgGreen)
@@ -229,13 +300,88 @@ func ToolsCmd(t *TUIApp, _ []string) error {
 			fmt.Println("No Agent Loaded: Load an Agent")
 		}
 
+    reg := t.App.Agent().Registry
     for _, tool := range t.App.Tools() {
         cVal.Fprintf(t.Out, "  %s\t", tool.Name)
-        cDesc.Fprintf(t.Out, "%s\n", tool.Description)
+        cDesc.Fprintf(t.Out, "%s", tool.Description)
+        if q, ok := reg.Renamed(tool.Name); ok {
+            color.New(color.Faint).Fprintf(t.Out, " (%s)", q)
+        }
+        fmt.Fprintln(t.Out)
+    }
+    if hidden := t.App.Agent().HiddenTools(); len(hidden) > 0 {
+        color.New(color.Faint).Fprintf(t.Out, "  hidden by tools_include/tools_exclude: %s\n", strings.Join(hidden, ", "))
+    }
+    printBrokenToolpacks(t)
+    printToolpackHealth(t)
+    return nil
+}
+
+// printToolpackHealth runs the Health hooks of the agent's toolpacks.
+func printToolpackHealth(t *TUIApp) {
+    health := t.App.Agent().ToolpackHealth()
+    if len(health) == 0 {
+        return
+    }
+    color.New(color.FgYellow, color.Bold).Fprintln(t.Out, "Health:")
+    for _, h := range health {
+        if h.Err != nil {
+            color.New(color.FgRed).Fprintf(t.Out, "  ✗ %s: %v\n", h.Name, h.Err)
+        } else {
+            color.New(color.FgGreen).Fprintf(t.Out, "  ✓ %s\n", h.Name)
+        }
+    }
+}
+
+// printToolOffers prints the per-turn log of an agent with max_tools.
+func printToolOffers(t *TUIApp) error {
+    a := t.App.Agent()
+    sel := a.ToolSelection()
+    if !sel.Enabled() {
+        fmt.Fprintln(t.Out, "Every tool is offered each turn; set max_tools on the agent to narrow them.")
+        return nil
+    }
+    offers := a.ToolOffers()
+    if len(offers) == 0 {
+        fmt.Fprintf(t.Out, "Up to %d tools (plus pinned) are offered per turn; no turns yet.\n", sel.Max)
+        return nil
+    }
+    cLabel := color.New(color.FgYellow, color.Bold)
+    for _, o := range offers {
+        cLabel.Fprintf(t.Out, "%q", shorten(o.Message, 50))
+        fmt.Fprintf(t.Out, "  %d of %d tools\n", len(o.Tools), o.Total)
+        if o.Pinned > 0 {
+            color.New(color.Faint).Fprintf(t.Out, "  pinned: %s\n", strings.Join(o.Tools[:o.Pinned], ", "))
+        }
+        fmt.Fprintf(t.Out, "  %s\n", strings.Join(o.Tools[o.Pinned:], ", "))
     }
     return nil
 }
 
+// printBrokenToolpacks flags toolpacks and sub-agents the current agent
+// had to skip and tool names two of its packs define.
+func printBrokenToolpacks(t *TUIApp) {
+    a := t.App.Agent()
+    if a == nil {
+        return
+    }
+    for _, b := range a.BrokenToolpacks() {
+        color.New(color.FgRed).Fprintf(t.Out, "  ⚠ toolpack %s skipped: %v\n", b.Name, b.Err)
+    }
+    if len(a.BrokenToolpacks()) > 0 {
+        fmt.Fprintln(t.Out, "  run `toolpack doctor` for details")
+    }
+    for _, b := range a.BrokenSubAgents() {
+        color.New(color.FgRed).Fprintf(t.Out, "  ⚠ sub-agent %s left out: %v\n", b.Name, b.Err)
+    }
+    for _, c := range a.ToolCollisions() {
+        color.New(color.FgYellow).Fprintf(t.Out, "  ⚠ %s\n", c)
+    }
+    if len(a.ToolCollisions()) > 0 {
+        fmt.Fprintln(t.Out, "  set tool_aliases for the agent to pick your own names")
+    }
+}
+
 // EditAgentCmd prompts the user to update an existing agent’s name, model,
 // and tool paths. Usage: edit-agent <old-name>
 func EditAgentCmd(t *TUIApp, args []string) error {
@@ -333,10 +479,10 @@ func EditAgentCmd(t *TUIApp, args []string) error {
     return t.Refresh()
 }
 
-// InitCmd ensures configs/users exists, and if there are no .toml users,
+// InitCmd ensures the users dir exists, and if there are no .toml users,
 // immediately calls CreateUserCmd to bootstrap the first user.
 func InitCmd(t *TUIApp, args []string) error {
-  usersDir := filepath.Join("configs", "users")
+  usersDir := store.UsersDir()
   if err := os.MkdirAll(usersDir, 0755); err != nil {
     return fmt.Errorf("mkdir %q: %w", usersDir, err)
   }
@@ -0,0 +1,89 @@
+package tui
+
+import (
+  "errors"
+  "fmt"
+
+  "github.com/fatih/color"
+  "github.com/peterh/liner"
+)
+
+// Passphrase asks for the secrets file's passphrase on the terminal,
+// twice when it is a new one. Install it with App.SetPassphrasePrompt.
+func (t *TUIApp) Passphrase(create bool) (string, error) {
+  if create {
+    fmt.Fprintln(t.Out, "Choose a passphrase for the secrets file; it can't be recovered.")
+  }
+  p, err := t.hidden("secrets passphrase: ")
+  if err != nil || !create {
+    return p, err
+  }
+  again, err := t.hidden("again: ")
+  if err != nil {
+    return "", err
+  }
+  if again != p {
+    return "", errors.New("the passphrases don't match")
+  }
+  return p, nil
+}
+
+// hidden reads a line without echoing it. When input isn't a terminal
+// (piped from a script) it is read as a plain line.
+func (t *TUIApp) hidden(prompt string) (string, error) {
+  s, err := t.Rl.PasswordPrompt(prompt)
+  if err != nil && !errors.Is(err, liner.ErrPromptAborted) {
+    return t.Rl.Prompt(prompt)
+  }
+  return s, err
+}
+
+// SecretCmd manages the encrypted secrets that TOML refers to as
+// secret:<name>. Values are typed at a hidden prompt, never passed as
+// arguments, so they stay out of the shell history.
+func SecretCmd(t *TUIApp, args []string) error {
+  if len(args) == 0 {
+    fmt.Fprintln(t.Out, "usage: secret list | set <name> | rm <name>")
+    return nil
+  }
+  switch args[0] {
+  case "list", "ls":
+    names, err := t.App.SecretNames()
+    if err != nil {
+      return fmt.Errorf("secret list: %w", err)
+    }
+    if len(names) == 0 {
+      fmt.Fprintln(t.Out, "No secrets stored")
+    }
+    for _, n := range names {
+      color.New(color.FgGreen).Fprintf(t.Out, "  %s\n", n)
+    }
+    return nil
+  case "set":
+    if len(args) != 2 {
+      fmt.Fprintln(t.Out, "usage: secret set <name>")
+      return nil
+    }
+    value, err := t.hidden(args[1] + ": ")
+    if err != nil {
+      return err
+    }
+    if err := t.App.SetSecret(args[1], value); err != nil {
+      return fmt.Errorf("secret set: %w", err)
+    }
+    color.New(color.FgGreen).Fprintf(t.Out, "✓ stored %s; refer to it as secret:%s\n", args[1], args[1])
+    return nil
+  case "rm", "delete":
+    if len(args) != 2 {
+      fmt.Fprintln(t.Out, "usage: secret rm <name>")
+      return nil
+    }
+    if err := t.App.DeleteSecret(args[1]); err != nil {
+      return fmt.Errorf("secret rm: %w", err)
+    }
+    color.New(color.FgGreen).Fprintf(t.Out, "✓ removed %s\n", args[1])
+    return nil
+  default:
+    return fmt.Errorf("unknown secret command %q (try: list, set, rm)", args[0])
+  }
+}
@@ -3,6 +3,7 @@ package tui
 import (
   	"github.com/fatih/color"
 		"fmt"
+		"strings"
 )
 
 
@@ -24,7 +25,7 @@ func (t *TUIApp) StatusCmd() error {
     case userLoaded && !agentLoaded:
         cmdList = "unload-user | load-agent | switch-user | users | agents |help"
     default: // agentLoaded (with or without user)
-        cmdList = "tools | unload-user | unload-agent | switch-user | switch-agent | agents | edit-agent | help"
+        cmdList = "tools | toolpack list|install|update|doctor | unload-user | unload-agent | close-agent | switch-user | switch-agent | agents | edit-agent | @agent <msg> | help"
     }
 
     cLabel := color.New(color.FgCyan, color.Bold)
@@ -43,11 +44,27 @@ func (t *TUIApp) StatusCmd() error {
 
     cLabel.Fprint(t.Out, "Current Agent: ")
     if agentLoaded {
-        cValue.Fprintln(t.Out, a.Name)
+        cValue.Fprint(t.Out, a.Name)
+        if n := len(a.BrokenToolpacks()); n > 0 {
+            color.New(color.FgRed).Fprintf(t.Out, "  (⚠ %d toolpack(s) skipped, see `tools`)", n)
+        }
+        if n := len(a.BrokenSubAgents()); n > 0 {
+            color.New(color.FgRed).Fprintf(t.Out, "  (⚠ %d sub-agent(s) left out, see `tools`)", n)
+        }
+        fmt.Fprintln(t.Out)
     } else {
         cValue.Fprintln(t.Out, "<none>")
     }
 
+    if live := t.App.LiveAgents(); len(live) > 1 {
+        names := make([]string, len(live))
+        for i, l := range live {
+            names[i] = "@" + l.Name
+        }
+        cLabel.Fprint(t.Out, "Live Agents:   ")
+        cValue.Fprintln(t.Out, strings.Join(names, " "))
+    }
+
     return nil
 }
 
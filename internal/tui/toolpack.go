@@ -0,0 +1,578 @@
+package tui
+
+import (
+  "fmt"
+  "path/filepath"
+  "strings"
+
+  "github.com/fatih/color"
+  "github.com/johnjallday/dolphin-tool-calling-agent/internal/toolmanager"
+  "github.com/johnjallday/dolphin-tool-calling-agent/pkg/tools"
+)
+
+// ToolpackCmd dispatches the `toolpack <subcommand>` family.
+func ToolpackCmd(t *TUIApp, args []string) error {
+  if len(args) == 0 {
+    fmt.Fprintln(t.Out, "usage: toolpack list [query] | info <name> | remote [query] | install <[catalog/]name[@version|@constraint]> | update [name] | outdated | resolve | sync | rollback <name> | uninstall <name> | quarantine [clear] | manifest <file.so> | new <name> [dir] | build [dir] | config <name> [key=value...] | doctor")
+    return nil
+  }
+  switch args[0] {
+  case "list", "search":
+    return ToolpackListCmd(t, args[1:])
+  case "info":
+    return ToolpackInfoCmd(t, args[1:])
+  case "remote":
+    return ToolpackRemoteCmd(t, args[1:])
+  case "install":
+    return ToolpackInstallCmd(t, args[1:])
+  case "update", "upgrade":
+    return ToolpackUpdateCmd(t, args[1:])
+  case "outdated":
+    return ToolpackOutdatedCmd(t, args[1:])
+  case "resolve":
+    return ToolpackResolveCmd(t, args[1:])
+  case "sync":
+    return ToolpackSyncCmd(t, args[1:])
+  case "rollback":
+    return ToolpackRollbackCmd(t, args[1:])
+  case "uninstall", "remove":
+    return ToolpackUninstallCmd(t, args[1:])
+  case "quarantine":
+    return ToolpackQuarantineCmd(t, args[1:])
+  case "manifest":
+    return ToolpackManifestCmd(t, args[1:])
+  case "new":
+    return ToolpackNewCmd(t, args[1:])
+  case "build":
+    return ToolpackBuildCmd(t, args[1:])
+  case "config", "settings":
+    return ToolpackConfigCmd(t, args[1:])
+  case "doctor":
+    return ToolpackDoctorCmd(t, args[1:])
+  default:
+    return fmt.Errorf("unknown toolpack command %q (try: list, info, remote, install, update, outdated, resolve, sync, rollback, uninstall, quarantine, manifest, new, build, config, doctor)", args[0])
+  }
+}
+
+// ToolpackListCmd lists local toolpacks from their manifests, optionally
+// only those matching a query (name, description or tool).
+func ToolpackListCmd(t *TUIApp, args []string) error {
+  query := strings.Join(args, " ")
+  cName := color.New(color.FgGreen, color.Bold)
+  faint := color.New(color.Faint)
+
+  n := 0
+  for _, m := range t.App.LocalToolpacks() {
+    if !m.Matches(query) {
+      continue
+    }
+    n++
+    cName.Fprintf(t.Out, "  %s", m.Name)
+    if m.Version != "" {
+      fmt.Fprintf(t.Out, " %s", m.Version)
+    }
+    switch {
+    case m.Builtin:
+      faint.Fprint(t.Out, " (built in)")
+    case m.Legacy:
+      faint.Fprint(t.Out, " (no manifest)")
+    case m.Declarative():
+      faint.Fprint(t.Out, " (declarative)")
+    case m.Scripted():
+      faint.Fprint(t.Out, " (starlark)")
+    }
+    fmt.Fprintf(t.Out, "\t%s", m.Description)
+    if len(m.Tools) > 0 {
+      faint.Fprintf(t.Out, " [%d tools]", len(m.Tools))
+    }
+    fmt.Fprintln(t.Out)
+  }
+  if n == 0 {
+    fmt.Fprintln(t.Out, "No matching toolpacks")
+  }
+  return nil
+}
+
+// ToolpackInfoCmd prints one toolpack's manifest: metadata and tools.
+func ToolpackInfoCmd(t *TUIApp, args []string) error {
+  if len(args) != 1 {
+    fmt.Fprintln(t.Out, "usage: toolpack info <name>")
+    return nil
+  }
+  for _, m := range t.App.LocalToolpacks() {
+    if m.Name != args[0] {
+      continue
+    }
+    cLabel := color.New(color.FgYellow, color.Bold)
+    row := func(k, v string) {
+      if v != "" {
+        cLabel.Fprintf(t.Out, "%-13s", k+":")
+        fmt.Fprintln(t.Out, v)
+      }
+    }
+    row("Name", m.Name)
+    row("Version", m.Version)
+    row("Description", m.Description)
+    row("Homepage", m.Homepage)
+    row("License", m.License)
+    row("Library", m.LibraryPath())
+    if m.Declarative() {
+      row("Kind", "declarative (tools defined in "+filepath.Join(m.Dir, toolmanager.ManifestFile)+")")
+    }
+    row("Script", m.ScriptPath())
+    row("Signed by", m.SignedBy)
+    if len(m.Config) > 0 {
+      keys := make([]string, len(m.Config))
+      for i, f := range m.Config {
+        keys[i] = f.Key
+      }
+      row("Settings", strings.Join(keys, ", ")+" (see `toolpack config "+m.Name+"`)")
+    }
+    if m.Legacy {
+      fmt.Fprintln(t.Out, "(no toolpack.toml; tools are unknown until an agent loads it)")
+      return nil
+    }
+    cLabel.Fprintln(t.Out, "Tools:")
+    if len(m.Tools) == 0 {
+      fmt.Fprintln(t.Out, "  (none listed; run `toolpack manifest "+m.LibraryPath()+"` to describe them)")
+    }
+    for _, tool := range m.Tools {
+      color.New(color.FgGreen).Fprintf(t.Out, "  %s\t", tool.Name)
+      fmt.Fprint(t.Out, tool.Description)
+      if a := tool.Action; a != nil {
+        kind := "template"
+        switch {
+        case a.Shell != nil:
+          kind = "shell: " + strings.Join(a.Shell.Command, " ")
+        case a.HTTP != nil:
+          kind = "http: " + a.HTTP.URL
+        }
+        color.New(color.Faint).Fprintf(t.Out, " [%s]", kind)
+      }
+      fmt.Fprintln(t.Out)
+    }
+    return nil
+  }
+  return fmt.Errorf("toolpack %q not found", args[0])
+}
+
+// ToolpackRemoteCmd lists what the configured catalogs offer, optionally
+// only entries whose name or description contain query.
+func ToolpackRemoteCmd(t *TUIApp, args []string) error {
+  query := strings.ToLower(strings.Join(args, " "))
+  entries, err := t.App.RemoteToolpacks()
+  if err != nil {
+    color.New(color.FgYellow).Fprintf(t.Out, "⚠ %v\n", err)
+  }
+  faint := color.New(color.Faint)
+  n := 0
+  for _, e := range entries {
+    if query != "" && !strings.Contains(strings.ToLower(e.Name+" "+e.Description), query) {
+      continue
+    }
+    n++
+    color.New(color.FgGreen, color.Bold).Fprintf(t.Out, "  %s", e.Name)
+    faint.Fprintf(t.Out, " [%s]", e.Catalog)
+    if len(e.Versions) > 0 {
+      fmt.Fprintf(t.Out, " %s", e.Versions[0])
+    }
+    fmt.Fprintf(t.Out, "\t%s\n", e.Description)
+  }
+  if n == 0 {
+    fmt.Fprintln(t.Out, "No matching remote toolpacks")
+  }
+  return nil
+}
+
+// ToolpackInstallCmd installs toolpacks, and what they depend on, from
+// the configured catalogs.
+func ToolpackInstallCmd(t *TUIApp, args []string) error {
+  if len(args) == 0 {
+    fmt.Fprintln(t.Out, "usage: toolpack install <[catalog/]name[@version|@constraint]>...")
+    return nil
+  }
+  for _, spec := range args {
+    es, err := t.App.InstallToolpack(spec)
+    printInstalledAll(t, "installed", es)
+    if err != nil {
+      return err
+    }
+  }
+  return nil
+}
+
+// ToolpackUpdateCmd updates the named packs, or every pack with a newer
+// release when no name is given.
+func ToolpackUpdateCmd(t *TUIApp, args []string) error {
+  names := args
+  if len(names) == 0 {
+    ups, err := t.App.ToolpackUpdates()
+    if err != nil {
+      return err
+    }
+    for _, u := range ups {
+      if u.Available() {
+        names = append(names, u.Name)
+      }
+    }
+    if len(names) == 0 {
+      fmt.Fprintln(t.Out, "All toolpacks are up to date")
+      return nil
+    }
+  }
+  for _, name := range names {
+    es, err := t.App.UpdateToolpack(name)
+    printInstalledAll(t, "updated", es)
+    if err != nil {
+      return err
+    }
+  }
+  return nil
+}
+
+// ToolpackOutdatedCmd lists installed packs with a newer release.
+func ToolpackOutdatedCmd(t *TUIApp, _ []string) error {
+  ups, err := t.App.ToolpackUpdates()
+  if err != nil {
+    return err
+  }
+  if len(ups) == 0 {
+    fmt.Fprintln(t.Out, "No toolpacks installed from the catalog")
+    return nil
+  }
+  faint := color.New(color.Faint)
+  n := 0
+  for _, u := range ups {
+    switch {
+    case u.Err != nil:
+      color.New(color.FgRed).Fprintf(t.Out, "  %s %s\t%v\n", u.Name, u.Installed, u.Err)
+    case u.Available():
+      n++
+      color.New(color.FgYellow, color.Bold).Fprintf(t.Out, "  %s", u.Name)
+      fmt.Fprintf(t.Out, " %s → %s\n", u.Installed, u.Latest)
+    default:
+      faint.Fprintf(t.Out, "  %s %s (up to date)\n", u.Name, u.Installed)
+    }
+  }
+  if n > 0 {
+    fmt.Fprintf(t.Out, "%d update(s) available, run `toolpack update`\n", n)
+  }
+  return nil
+}
+
+// ToolpackResolveCmd shows which version of each toolpack the agents of
+// all users need and what `toolpack sync` would change. Conflicts are
+// returned as the error.
+func ToolpackResolveCmd(t *TUIApp, _ []string) error {
+  plan, err := t.App.ResolveToolpacks()
+  if err != nil {
+    return err
+  }
+  if len(plan.Picks) == 0 {
+    fmt.Fprintln(t.Out, "No agent uses a toolpack")
+    return nil
+  }
+  faint := color.New(color.Faint)
+  n := 0
+  for _, p := range plan.Picks {
+    switch {
+    case p.Changes() && p.Installed == "":
+      n++
+      color.New(color.FgYellow, color.Bold).Fprintf(t.Out, "  + %s %s", p.Name, p.Version)
+    case p.Changes():
+      n++
+      color.New(color.FgYellow, color.Bold).Fprintf(t.Out, "  ~ %s %s → %s", p.Name, p.Installed, p.Version)
+    default:
+      fmt.Fprintf(t.Out, "    %s %s", p.Name, p.Version)
+    }
+    faint.Fprintf(t.Out, "\t(%s)\n", strings.Join(p.RequiredBy, ", "))
+  }
+  if n > 0 {
+    fmt.Fprintf(t.Out, "%d change(s), run `toolpack sync` to install them\n", n)
+  } else {
+    fmt.Fprintln(t.Out, "Everything the agents need is installed")
+  }
+  return nil
+}
+
+// ToolpackSyncCmd installs what ToolpackResolveCmd lists as changes.
+func ToolpackSyncCmd(t *TUIApp, _ []string) error {
+  es, err := t.App.SyncToolpacks()
+  for i := range es {
+    printInstalled(t, "installed", &es[i])
+  }
+  if err != nil {
+    return err
+  }
+  if len(es) == 0 {
+    fmt.Fprintln(t.Out, "Everything the agents need is installed")
+  }
+  return nil
+}
+
+// ToolpackRollbackCmd swaps a pack with the version it replaced.
+func ToolpackRollbackCmd(t *TUIApp, args []string) error {
+  if len(args) != 1 {
+    fmt.Fprintln(t.Out, "usage: toolpack rollback <name>")
+    return nil
+  }
+  e, err := t.App.RollbackToolpack(args[0])
+  if err != nil {
+    return err
+  }
+  printInstalled(t, "rolled back", e)
+  return nil
+}
+
+// ToolpackUninstallCmd removes installed packs.
+func ToolpackUninstallCmd(t *TUIApp, args []string) error {
+  if len(args) == 0 {
+    fmt.Fprintln(t.Out, "usage: toolpack uninstall <name>...")
+    return nil
+  }
+  for _, name := range args {
+    if err := t.App.UninstallToolpack(name); err != nil {
+      return err
+    }
+    color.New(color.FgGreen).Fprintf(t.Out, "✓ uninstalled %s\n", name)
+  }
+  return nil
+}
+
+// printInstalledAll reports an install: the requested pack first, then
+// whatever came along as a dependency.
+func printInstalledAll(t *TUIApp, what string, es []toolmanager.LockEntry) {
+  for i := range es {
+    if i > 0 {
+      what = "installed"
+      if len(es[i].RequiredBy) > 0 {
+        what = "installed (for " + strings.Join(es[i].RequiredBy, ", ") + ")"
+      }
+    }
+    printInstalled(t, what, &es[i])
+  }
+}
+
+func printInstalled(t *TUIApp, what string, e *toolmanager.LockEntry) {
+  color.New(color.FgGreen).Fprintf(t.Out, "✓ %s %s %s", what, e.Name, e.Version)
+  if e.Previous != "" {
+    color.New(color.Faint).Fprintf(t.Out, " (was %s, `toolpack rollback %s` to undo)", e.Previous, e.Name)
+  }
+  fmt.Fprintln(t.Out)
+  // plugin code can't be unloaded, so a replaced version stays live
+  if e.Previous != "" && len(t.App.LiveAgents()) > 0 {
+    fmt.Fprintln(t.Out, "  restart dolphin to load it in agents that are already running")
+  }
+}
+
+// ToolpackQuarantineCmd lists downloads that failed verification, or
+// deletes them with `clear`.
+func ToolpackQuarantineCmd(t *TUIApp, args []string) error {
+  if len(args) == 1 && args[0] == "clear" {
+    if err := toolmanager.ClearQuarantine(); err != nil {
+      return err
+    }
+    fmt.Fprintln(t.Out, "Quarantine cleared")
+    return nil
+  }
+  qs, err := toolmanager.QuarantinedFiles()
+  if err != nil {
+    return err
+  }
+  if len(qs) == 0 {
+    fmt.Fprintln(t.Out, "Nothing in quarantine")
+    return nil
+  }
+  for _, q := range qs {
+    color.New(color.FgRed).Fprintf(t.Out, "  %s\n", filepath.Base(q.Path))
+    fmt.Fprintf(t.Out, "    %s\n", q.Reason)
+  }
+  fmt.Fprintf(t.Out, "%d file(s) in %s; `toolpack quarantine clear` deletes them\n", len(qs), toolmanager.QuarantineDir())
+  return nil
+}
+
+// ToolpackManifestCmd loads a .so once and writes its toolpack.toml.
+func ToolpackManifestCmd(t *TUIApp, args []string) error {
+  if len(args) != 1 {
+    fmt.Fprintln(t.Out, "usage: toolpack manifest <file.so>")
+    return nil
+  }
+  m, err := toolmanager.WriteManifest(args[0])
+  if err != nil {
+    return fmt.Errorf("toolpack manifest: %w", err)
+  }
+  color.New(color.FgGreen).Fprintf(t.Out, "✓ wrote %s for %s (%d tools)\n",
+    filepath.Join(m.Dir, toolmanager.ManifestFile), m.Name, len(m.Tools))
+  color.New(color.Faint).Fprintf(t.Out, "  publish the library as %s\n", toolmanager.ArtifactName(m.Name))
+  return nil
+}
+
+// ToolpackNewCmd scaffolds a plugin toolpack to start from.
+func ToolpackNewCmd(t *TUIApp, args []string) error {
+  if len(args) < 1 || len(args) > 2 {
+    fmt.Fprintln(t.Out, "usage: toolpack new <name> [dir]")
+    return nil
+  }
+  dir := args[0]
+  if len(args) == 2 {
+    dir = args[1]
+  }
+  files, err := toolmanager.Scaffold(args[0], dir)
+  if err != nil {
+    return fmt.Errorf("toolpack new: %w", err)
+  }
+  color.New(color.FgGreen).Fprintf(t.Out, "✓ created toolpack %s\n", args[0])
+  for _, f := range files {
+    fmt.Fprintf(t.Out, "  %s\n", f)
+  }
+  color.New(color.Faint).Fprintf(t.Out, "  edit %s.go, then `go test` and `toolpack build` in %s\n", args[0], filepath.Dir(files[0]))
+  return nil
+}
+
+// ToolpackBuildCmd compiles a plugin toolpack from source the way this
+// binary needs it and installs it.
+func ToolpackBuildCmd(t *TUIApp, args []string) error {
+  if len(args) > 1 {
+    fmt.Fprintln(t.Out, "usage: toolpack build [dir]")
+    return nil
+  }
+  src := "."
+  if len(args) == 1 {
+    src = args[0]
+  }
+  b, err := toolmanager.Build(src)
+  if err != nil {
+    return fmt.Errorf("toolpack build: %w", err)
+  }
+  faint := color.New(color.Faint)
+  for _, p := range b.Pinned {
+    faint.Fprintf(t.Out, "  pinned %s to match dolphin\n", p)
+  }
+  m := b.Manifest
+  color.New(color.FgGreen).Fprintf(t.Out, "✓ built %s %s with %s (%d tools) → %s\n",
+    m.Name, m.Version, b.Toolchain, len(m.Tools), m.LibraryPath())
+  if b.Previous != nil {
+    faint.Fprintf(t.Out, "  replaced %s; `toolpack rollback %s` undoes it\n", b.Previous.Version, m.Name)
+  }
+  for _, p := range b.Problems {
+    color.New(color.FgYellow).Fprintf(t.Out, "  %s\n", p)
+  }
+  return nil
+}
+
+// ToolpackConfigCmd shows a toolpack's settings for the current user or,
+// given key=value pairs, changes them ("key=" resets one to its default).
+func ToolpackConfigCmd(t *TUIApp, args []string) error {
+  if len(args) < 1 {
+    fmt.Fprintln(t.Out, "usage: toolpack config <name> [key=value...]")
+    return nil
+  }
+  pack := args[0]
+  if len(args) > 1 {
+    values := map[string]string{}
+    for _, kv := range args[1:] {
+      k, v, ok := strings.Cut(kv, "=")
+      if !ok {
+        return fmt.Errorf("toolpack config: %q is not key=value", kv)
+      }
+      values[k] = v
+    }
+    res, err := t.App.SetToolpackConfig(pack, values)
+    if err != nil {
+      return fmt.Errorf("toolpack config: %w", err)
+    }
+    color.New(color.FgGreen).Fprintf(t.Out, "✓ saved settings for %s\n", pack)
+    faint := color.New(color.Faint)
+    if res.Missing != nil {
+      color.New(color.FgYellow).Fprintf(t.Out, "  still needed: %v\n", res.Missing)
+    }
+    if len(res.Reconfigured) > 0 {
+      faint.Fprintf(t.Out, "  applied to %s\n", strings.Join(res.Reconfigured, ", "))
+    }
+    if len(res.Restarted) > 0 {
+      faint.Fprintf(t.Out, "  restarted %s, which had skipped it\n", strings.Join(res.Restarted, ", "))
+    }
+  }
+
+  fields, values, err := t.App.ToolpackConfig(pack)
+  if err != nil {
+    return fmt.Errorf("toolpack config: %w", err)
+  }
+  cKey := color.New(color.FgGreen)
+  faint := color.New(color.Faint)
+  for _, f := range fields {
+    cKey.Fprintf(t.Out, "  %s", f.Key)
+    faint.Fprintf(t.Out, " (%s", f.Type)
+    if f.Type == tools.ConfigChoice {
+      faint.Fprintf(t.Out, ": %s", strings.Join(f.Choices, "|"))
+    }
+    if f.Required {
+      faint.Fprint(t.Out, ", required")
+    }
+    faint.Fprint(t.Out, ")")
+    switch v, ok := values[f.Key]; {
+    case ok:
+      fmt.Fprintf(t.Out, " = %v", v)
+    case f.Default != nil:
+      fmt.Fprintf(t.Out, " = %v", f.Default)
+      faint.Fprint(t.Out, " (default)")
+    case f.Required:
+      color.New(color.FgYellow).Fprint(t.Out, " not set")
+    }
+    fmt.Fprintln(t.Out)
+    if f.Description != "" {
+      faint.Fprintf(t.Out, "      %s\n", f.Description)
+    }
+  }
+  return nil
+}
+
+// ToolpackDoctorCmd tries to load every toolpack and explains each
+// failure, plus any warnings about the ones that do load.
+func ToolpackDoctorCmd(t *TUIApp, _ []string) error {
+  diags, err := toolmanager.Doctor()
+  if err != nil {
+    return fmt.Errorf("toolpack doctor: %w", err)
+  }
+  if len(diags) == 0 {
+    fmt.Fprintln(t.Out, "No toolpacks found in", toolmanager.PluginDir())
+    return nil
+  }
+
+  ok := color.New(color.FgGreen, color.Bold)
+  bad := color.New(color.FgRed, color.Bold)
+  warn := color.New(color.FgYellow)
+  faint := color.New(color.Faint)
+
+  broken := 0
+  for _, d := range diags {
+    where := "compiled in"
+    if d.Path != "" {
+      where = d.Path
+    }
+    if d.OK() {
+      ok.Fprint(t.Out, "✓ ")
+    } else {
+      broken++
+      bad.Fprint(t.Out, "✗ ")
+    }
+    fmt.Fprintf(t.Out, "%s %s ", d.Name, d.Version)
+    faint.Fprintln(t.Out, "("+where+")")
+
+    for _, p := range d.Problems {
+      c := warn
+      if p.Fatal {
+        c = bad
+      }
+      c.Fprintf(t.Out, "    %s\n", p.What)
+      if p.Fix != "" {
+        fmt.Fprintf(t.Out, "      fix: %s\n", p.Fix)
+      }
+    }
+    if !d.OK() && len(d.Problems) == 0 {
+      bad.Fprintf(t.Out, "    %v\n", d.Err)
+    }
+  }
+  fmt.Fprintf(t.Out, "\n%d toolpack(s), %d broken\n", len(diags), broken)
+  return nil
+}
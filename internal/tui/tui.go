@@ -64,10 +64,24 @@ func (t *TUIApp) RunInteractiveShell(
       if err := fn(t, args); err != nil {
         fmt.Fprintln(t.Err, "ERROR:", err)
       }
+    } else if strings.HasPrefix(rawCmd, "@") && len(rawCmd) > 1 {
+      // “@agent message” → address a specific (possibly not yet live) agent
+      name := rawCmd[1:]
+      msg := strings.TrimSpace(strings.TrimPrefix(line, rawCmd))
+      reply, err := t.App.SendMessageTo(t.Ctx, name, msg)
+      if err != nil {
+        fmt.Fprintln(t.Err, "ERROR:", err)
+      } else {
+        color.New(color.FgGreen, color.Bold).Fprint(t.Out, name+": ")
+        fmt.Fprintln(t.Out, reply)
+      }
     } else {
       // fallback → send to LLM/chat
-      if err := t.App.SendMessage(t.Ctx, line); err != nil {
+      reply, err := t.App.SendMessage(t.Ctx, line)
+      if err != nil {
         fmt.Fprintln(t.Err, "ERROR:", err)
+      } else {
+        fmt.Fprintln(t.Out, reply)
       }
     		}
 
@@ -98,7 +112,7 @@ func (t *TUIApp) PrintLogo(){
         🐬
 `
     fmt.Print("\033[36m" + logo + "\033[0m\n")
-    fmt.Println("Dolphin Tool Calling Agent Client\n")
+    fmt.Print("Dolphin Tool Calling Agent Client\n\n")
 }
 
 // PrintToolPacks walks “./plugins” looking for .so files.
@@ -0,0 +1,40 @@
+package tui
+
+import (
+  "fmt"
+  "strings"
+
+  "github.com/fatih/color"
+  "github.com/johnjallday/dolphin-tool-calling-agent/internal/app"
+)
+
+// WatchConfig hot-reloads hand edits to the user TOML and toolpacks.toml in
+// the background and reports each reload inline. Call it once from main.
+func (t *TUIApp) WatchConfig() {
+  go func() {
+    err := t.App.Watch(t.Ctx, func(ev app.ReloadEvent) {
+      switch {
+      case ev.Err != nil:
+        color.New(color.FgRed).Fprintln(t.Err, "\nconfig reload failed, keeping current state:")
+        fmt.Fprintln(t.Err, ev.Err)
+      default:
+        color.New(color.FgGreen).Fprintln(t.Out, "\n↻ reloaded", ev.Path)
+      }
+      for _, name := range ev.Removed {
+        color.New(color.FgYellow).Fprintf(t.Out, "  agent %s was removed and unloaded\n", name)
+      }
+      for _, name := range ev.Restarted {
+        color.New(color.FgYellow).Fprintf(t.Out, "  agent %s changed, new session started\n", name)
+      }
+      if len(ev.Catalogs) > 0 {
+        fmt.Fprintf(t.Out, "  catalogs: %s (see `toolpack remote`)\n", strings.Join(ev.Catalogs, ", "))
+      }
+      if ev.Shutdown != nil {
+        color.New(color.FgYellow).Fprintf(t.Err, "  %v\n", ev.Shutdown)
+      }
+    })
+    if err != nil {
+      fmt.Fprintln(t.Err, "config watch:", err)
+    }
+  }()
+}
@@ -2,17 +2,14 @@ package user
 
 import (
   "fmt"
-  "path/filepath"
 
-  "github.com/BurntSushi/toml"
   "github.com/johnjallday/dolphin-tool-calling-agent/internal/agent"
+  "github.com/johnjallday/dolphin-tool-calling-agent/internal/config"
+  "github.com/johnjallday/dolphin-tool-calling-agent/internal/store"
 )
 
-type AgentMeta struct {
-  Name    string   `toml:"name"`
-  Model   string   `toml:"model"`
-  Plugins []string `toml:"plugins"`
-}
+// AgentMeta is one [[agents]] entry of the user's TOML.
+type AgentMeta = config.Agent
 
 type User struct {
   Name         string
@@ -20,24 +17,28 @@ type User struct {
   DefaultAgent *agent.Agent
 }
 
-func NewUser(userID string) (*User, error) {
-  path := filepath.Join("configs", "users", userID+".toml")
-  fmt.Println("Loading user config:", path)
+// Builder constructs a live agent from its definition. The app passes its
+// own so built-in toolpacks and sub-agents get wired up; nil means a plain
+// agent.NewAgent.
+type Builder func(AgentMeta) (*agent.Agent, error)
 
-  var raw struct {
-    Name         string      `toml:"name"`
-    DefaultAgent string      `toml:"default_agent"`
-    Agents       []AgentMeta `toml:"agents"`
-  }
+func NewUser(userID string, build Builder) (*User, error) {
+  fmt.Println("Loading user config:", store.UserConfigPath(userID))
 
-  if _, err := toml.DecodeFile(path, &raw); err != nil {
-    return nil, fmt.Errorf("decode %s: %w", path, err)
+  raw, err := store.LoadUserConfig(userID)
+  if err != nil {
+    return nil, err
   }
 
   u := &User{Name: raw.Name, Agents: raw.Agents}
   for _, meta := range raw.Agents {
     if meta.Name == raw.DefaultAgent {
-			ag, err := agent.NewAgent(meta.Name, meta.Model, meta.Plugins)
+      if build == nil {
+        build = func(m AgentMeta) (*agent.Agent, error) {
+          return agent.NewAgent(m.Name, m.Model, m.Plugins)
+        }
+      }
+      ag, err := build(meta)
       if err != nil {
         return nil, fmt.Errorf("init default agent %q: %w", meta.Name, err)
       }
@@ -0,0 +1,245 @@
+package tools
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ConfigType is the kind of value a toolpack setting holds.
+type ConfigType string
+
+const (
+	ConfigString ConfigType = "string"
+	ConfigInt    ConfigType = "int"
+	ConfigFloat  ConfigType = "float"
+	ConfigBool   ConfigType = "bool"
+	// ConfigPath is a file or folder; a leading ~ is expanded.
+	ConfigPath ConfigType = "path"
+	// ConfigChoice is one of the field's Choices.
+	ConfigChoice ConfigType = "choice"
+	// ConfigSecret is stored as a "secret:name" reference to the user's
+	// secrets; Configure gets the secret itself.
+	ConfigSecret ConfigType = "secret"
+)
+
+// ConfigField declares one setting of a toolpack. The host stores the
+// values per user, checks them against their field and hands them to the
+// pack's Configure when it is loaded and whenever they change:
+//
+//	Config: []tools.ConfigField{
+//		{Key: "default_template", Type: tools.ConfigPath, Required: true,
+//			Description: "Project copied for every new project"},
+//	},
+//	Configure: func(c tools.Config) error {
+//		template = c.String("default_template")
+//		return nil
+//	},
+type ConfigField struct {
+	Key         string      `toml:"key"`
+	Type        ConfigType  `toml:"type"`
+	Description string      `toml:"description,omitempty"`
+	Default     interface{} `toml:"default,omitempty"`
+	Required    bool        `toml:"required,omitempty"`
+	Choices     []string    `toml:"choices,omitempty"`
+}
+
+var knownTypes = map[ConfigType]bool{
+	ConfigString: true, ConfigInt: true, ConfigFloat: true,
+	ConfigBool: true, ConfigPath: true, ConfigChoice: true, ConfigSecret: true,
+}
+
+// Config is a toolpack's settings by key. Each value has its field's Go
+// type: string (string, path, choice, secret), int64, float64 or bool.
+type Config map[string]interface{}
+
+// String returns a string, path, choice or secret setting ("" if unset).
+func (c Config) String(key string) string {
+	s, _ := c[key].(string)
+	return s
+}
+
+// Int returns an int setting (0 if unset).
+func (c Config) Int(key string) int64 {
+	n, _ := c[key].(int64)
+	return n
+}
+
+// Float returns a float setting (0 if unset).
+func (c Config) Float(key string) float64 {
+	f, _ := c[key].(float64)
+	return f
+}
+
+// Bool returns a bool setting (false if unset).
+func (c Config) Bool(key string) bool {
+	b, _ := c[key].(bool)
+	return b
+}
+
+// Has reports whether key has a value, set or defaulted.
+func (c Config) Has(key string) bool {
+	_, ok := c[key]
+	return ok
+}
+
+// Parse reads a value for f as a user types it: "42", "true", "~/music",
+// or a secret's name.
+func (f ConfigField) Parse(s string) (interface{}, error) {
+	s = strings.TrimSpace(s)
+	switch f.Type {
+	case ConfigInt:
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %q is not a whole number", f.Key, s)
+		}
+		return n, nil
+	case ConfigFloat:
+		x, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %q is not a number", f.Key, s)
+		}
+		return x, nil
+	case ConfigBool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %q is not true or false", f.Key, s)
+		}
+		return b, nil
+	case ConfigSecret:
+		if _, ok := SecretRef(s); !ok && s != "" {
+			s = SecretPrefix + s
+		}
+	}
+	return f.Check(s)
+}
+
+// Check converts v, as decoded from TOML or JSON, to f's Go type and
+// validates it.
+func (f ConfigField) Check(v interface{}) (interface{}, error) {
+	bad := func() (interface{}, error) {
+		return nil, fmt.Errorf("%s: %v is not a %s", f.Key, v, f.Type)
+	}
+	switch f.Type {
+	case ConfigString, ConfigPath, ConfigChoice, ConfigSecret:
+		s, ok := v.(string)
+		if !ok {
+			return bad()
+		}
+		if name, ok := SecretRef(s); f.Type == ConfigSecret && (!ok || name == "") {
+			return nil, fmt.Errorf("%s: must name a stored secret (%sname), not hold one", f.Key, SecretPrefix)
+		}
+		if f.Type == ConfigPath {
+			return expandHome(s), nil
+		}
+		if f.Type == ConfigChoice && !contains(f.Choices, s) {
+			return nil, fmt.Errorf("%s: %q is not one of %s", f.Key, s, strings.Join(f.Choices, ", "))
+		}
+		return s, nil
+	case ConfigInt:
+		switch n := v.(type) {
+		case int64:
+			return n, nil
+		case int:
+			return int64(n), nil
+		case float64:
+			if n == math.Trunc(n) && math.Abs(n) < 1<<53 {
+				return int64(n), nil
+			}
+		}
+		return bad()
+	case ConfigFloat:
+		switch x := v.(type) {
+		case float64:
+			return x, nil
+		case int64:
+			return float64(x), nil
+		case int:
+			return float64(x), nil
+		}
+		return bad()
+	case ConfigBool:
+		if b, ok := v.(bool); ok {
+			return b, nil
+		}
+		return bad()
+	}
+	return nil, fmt.Errorf("%s: unknown type %q", f.Key, f.Type)
+}
+
+// CheckFields validates a pack's declaration: keys set and unique, known
+// types, choices for a choice, and defaults that fit their field.
+func CheckFields(fields []ConfigField) error {
+	seen := map[string]bool{}
+	for _, f := range fields {
+		switch {
+		case f.Key == "":
+			return fmt.Errorf("a config field has no key")
+		case !knownTypes[f.Type]:
+			return fmt.Errorf("config field %s has unknown type %q", f.Key, f.Type)
+		case seen[f.Key]:
+			return fmt.Errorf("config field %s is declared twice", f.Key)
+		case f.Type == ConfigChoice && len(f.Choices) == 0:
+			return fmt.Errorf("config field %s is a choice without choices", f.Key)
+		}
+		seen[f.Key] = true
+		if f.Default != nil {
+			if _, err := f.Check(f.Default); err != nil {
+				return fmt.Errorf("config field %s: default: %w", f.Key, err)
+			}
+		}
+	}
+	return nil
+}
+
+// ResolveConfig checks values against fields and fills in defaults. It
+// fails if a required field ends up without a value; keys no field
+// declares (say, left over from an older version) are dropped.
+func ResolveConfig(fields []ConfigField, values map[string]interface{}) (Config, error) {
+	cfg := Config{}
+	var missing []string
+	for _, f := range fields {
+		v, ok := values[f.Key]
+		if !ok || v == nil {
+			v = f.Default
+		}
+		if v == nil || v == "" && f.Required {
+			if f.Required {
+				missing = append(missing, f.Key)
+			}
+			continue
+		}
+		cv, err := f.Check(v)
+		if err != nil {
+			return nil, err
+		}
+		cfg[f.Key] = cv
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return nil, fmt.Errorf("%s not set", strings.Join(missing, ", "))
+	}
+	return cfg, nil
+}
+
+func expandHome(p string) string {
+	if p == "~" || strings.HasPrefix(p, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, p[1:])
+		}
+	}
+	return p
+}
+
+func contains(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
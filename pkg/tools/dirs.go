@@ -0,0 +1,52 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// envHome pins Dolphin's home directory. The host exports it when started
+// with -home, so a plugin resolves the same directories as the host that
+// loaded it.
+const envHome = "DOLPHIN_HOME"
+
+// ConfigDir returns the directory a toolpack should keep its settings in
+// (<dolphin config dir>/tools/<pack>), so plugins don't depend on the CWD.
+func ConfigDir(pack string) string {
+	return filepath.Join(configRoot(), "tools", pack)
+}
+
+// DataDir returns the directory a toolpack should keep its data in
+// (<dolphin data dir>/tooldata/<pack>); scripted packs can only touch files
+// there.
+func DataDir(pack string) string {
+	return filepath.Join(dataRoot(), "tooldata", pack)
+}
+
+// configRoot and dataRoot follow the host's layout: <home>/configs and
+// <home> with DOLPHIN_HOME set, the XDG directories otherwise.
+func configRoot() string {
+	if h := os.Getenv(envHome); h != "" {
+		return filepath.Join(h, "configs")
+	}
+	return filepath.Join(xdg("XDG_CONFIG_HOME", ".config"), "dolphin")
+}
+
+func dataRoot() string {
+	if h := os.Getenv(envHome); h != "" {
+		return h
+	}
+	return filepath.Join(xdg("XDG_DATA_HOME", filepath.Join(".local", "share")), "dolphin")
+}
+
+// xdg returns $env, or ~/fallback when it is unset or not absolute.
+func xdg(env, fallback string) string {
+	if v := os.Getenv(env); filepath.IsAbs(v) {
+		return v
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return fallback
+	}
+	return filepath.Join(home, fallback)
+}
@@ -0,0 +1,51 @@
+package tools
+
+// SDKVersion is the plugin ABI version of this package. Bump it whenever
+// Tool or ToolPackage change shape: a .so built against another shape
+// cannot be loaded by this host.
+const SDKVersion = 2
+
+// Host capabilities a toolpack may require (Manifest.Requires).
+const (
+	CapApproval  = "approval"   // honours Tool.RequiresApproval
+	CapConfigDir = "config_dir" // provides ConfigDir(pack)
+	CapConfig    = "config"     // stores ToolPackage.Config and calls Configure
+	CapLifecycle = "lifecycle"  // calls ToolPackage.Init, Health and Shutdown
+)
+
+// HostCapabilities lists what this build of the host provides.
+var HostCapabilities = []string{CapApproval, CapConfigDir, CapConfig, CapLifecycle}
+
+// Manifest describes what a .so toolpack was built against. Plugins export
+// it next to PluginPackage so the host can refuse a mismatched build with
+// a clear message instead of failing somewhere inside the pack:
+//
+//	var PluginManifest = tools.NewManifest(tools.CapConfigDir)
+type Manifest struct {
+	SDKVersion int
+	Requires   []string
+}
+
+// NewManifest returns the manifest of a plugin built against this SDK,
+// requiring the given host capabilities.
+func NewManifest(requires ...string) Manifest {
+	return Manifest{
+		SDKVersion: SDKVersion,
+		Requires:   requires,
+	}
+}
+
+// Missing returns the required capabilities the host doesn't provide.
+func (m Manifest) Missing() []string {
+	have := make(map[string]bool, len(HostCapabilities))
+	for _, c := range HostCapabilities {
+		have[c] = true
+	}
+	var missing []string
+	for _, c := range m.Requires {
+		if !have[c] {
+			missing = append(missing, c)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,39 @@
+package tools
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"strings"
+)
+
+// MaxNameLen is the longest function name the API accepts.
+const MaxNameLen = 64
+
+// WireName makes name safe to send as a function name: dots become "__",
+// anything else outside [a-zA-Z0-9_-] becomes "_", and names over
+// MaxNameLen are cut short and suffixed with a hash of the whole.
+func WireName(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ReplaceAll(name, ".", "__") {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '-':
+			b.WriteRune(r)
+		default:
+			b.WriteByte('_')
+		}
+	}
+	out := b.String()
+	if out == "" {
+		out = "_"
+	}
+	if len(out) > MaxNameLen {
+		sum := sha1.Sum([]byte(name))
+		out = out[:MaxNameLen-9] + "_" + hex.EncodeToString(sum[:4])
+	}
+	return out
+}
+
+// ValidWireName reports whether name can be sent as-is.
+func ValidWireName(name string) bool {
+	return name != "" && WireName(name) == name
+}
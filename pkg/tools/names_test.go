@@ -0,0 +1,37 @@
+package tools
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWireName(t *testing.T) {
+	for in, want := range map[string]string{
+		"add":            "add",
+		"calculator.add": "calculator__add",
+		"my pack.do-it!": "my_pack__do-it_",
+		"天気":             "__",
+		"":               "_",
+	} {
+		if got := WireName(in); got != want {
+			t.Errorf("WireName(%q) = %q, want %q", in, got, want)
+		}
+	}
+
+	a := strings.Repeat("x", MaxNameLen) + ".one"
+	b := strings.Repeat("x", MaxNameLen) + ".two"
+	wa, wb := WireName(a), WireName(b)
+	if len(wa) != MaxNameLen || !ValidWireName(wa) {
+		t.Errorf("long name became %q", wa)
+	}
+	if wa == wb {
+		t.Errorf("two long names share %q", wa)
+	}
+	if WireName(a) != wa {
+		t.Error("WireName isn't stable")
+	}
+
+	if ValidWireName("a.b") || ValidWireName("") || !ValidWireName("a_b-1") {
+		t.Error("ValidWireName")
+	}
+}
@@ -0,0 +1,57 @@
+package tools
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Compiled-in toolpacks. A pack linked into the binary registers itself
+// from init:
+//
+//	func init() { tools.RegisterPackage("calculator", Package) }
+//
+// and is then found by name before any .so in the plugin dir, so it works
+// in static builds and never hits plugin toolchain mismatches.
+var (
+	packagesMu sync.RWMutex
+	packages   = make(map[string]func() ToolPackage)
+)
+
+// RegisterPackage makes a toolpack available under name. fn is called once
+// per agent that uses the pack, so it may return fresh (stateful) tools.
+// Registering the same name twice panics, like database/sql.Register.
+func RegisterPackage(name string, fn func() ToolPackage) {
+	packagesMu.Lock()
+	defer packagesMu.Unlock()
+	if fn == nil {
+		panic("tools: RegisterPackage " + name + " with nil constructor")
+	}
+	if _, dup := packages[name]; dup {
+		panic(fmt.Sprintf("tools: RegisterPackage called twice for %q", name))
+	}
+	packages[name] = fn
+}
+
+// LookupPackage builds the compiled-in toolpack called name, if any.
+func LookupPackage(name string) (ToolPackage, bool) {
+	packagesMu.RLock()
+	fn, ok := packages[name]
+	packagesMu.RUnlock()
+	if !ok {
+		return ToolPackage{}, false
+	}
+	return fn(), true
+}
+
+// Packages returns the names of all compiled-in toolpacks, sorted.
+func Packages() []string {
+	packagesMu.RLock()
+	defer packagesMu.RUnlock()
+	names := make([]string, 0, len(packages))
+	for name := range packages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
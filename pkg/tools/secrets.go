@@ -0,0 +1,58 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// SecretPrefix marks a value in a TOML file as a reference to a stored
+// secret rather than the value itself: api_key = "secret:openai".
+const SecretPrefix = "secret:"
+
+// ErrNoSecrets is returned by Secret when the host passed no secrets store,
+// as in a plain Exec call from a test.
+var ErrNoSecrets = errors.New("no secrets available")
+
+// Secrets looks up credentials the user stored with dolphin. The host
+// puts one in the context of every ExecContext call:
+//
+//	ExecContext: func(ctx context.Context, args map[string]interface{}) (string, error) {
+//		key, err := tools.Secret(ctx, "weather_api_key")
+//		if err != nil {
+//			return "", err
+//		}
+//		...
+//	},
+//
+// The pack must list the names it reads in ToolPackage.Secrets; others
+// are refused. Values read this way are masked in anything the tool
+// returns.
+type Secrets interface {
+	Secret(name string) (string, error)
+}
+
+type secretsKey struct{}
+
+// WithSecrets returns ctx carrying s.
+func WithSecrets(ctx context.Context, s Secrets) context.Context {
+	return context.WithValue(ctx, secretsKey{}, s)
+}
+
+// Secret returns the named secret from the store in ctx.
+func Secret(ctx context.Context, name string) (string, error) {
+	s, _ := ctx.Value(secretsKey{}).(Secrets)
+	if s == nil {
+		return "", ErrNoSecrets
+	}
+	return s.Secret(name)
+}
+
+// SecretRef returns the name v refers to if it is a "secret:name"
+// reference.
+func SecretRef(v string) (string, bool) {
+	if !strings.HasPrefix(v, SecretPrefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(v, SecretPrefix), true
+}
@@ -1,6 +1,8 @@
 package tools
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"github.com/openai/openai-go"
 )
@@ -14,6 +16,38 @@ type Tool struct {
 	Description string
 	Parameters  openai.FunctionParameters
 	Exec        func(map[string]interface{}) (string, error)
+	// ExecContext is used instead of Exec when set. Its ctx is cancelled
+	// with the chat turn and carries the user's Secrets.
+	ExecContext func(ctx context.Context, args map[string]interface{}) (string, error)
+	// RequiresApproval marks tools that change state outside the chat
+	// (config, files, the DAW…); the host asks the user, or applies its
+	// approval_policy, before running them.
+	RequiresApproval bool
+}
+
+// Call runs the tool: ExecContext if it has one, else Exec.
+func (t Tool) Call(ctx context.Context, args map[string]interface{}) (string, error) {
+	switch {
+	case t.ExecContext != nil:
+		return t.ExecContext(ctx, args)
+	case t.Exec != nil:
+		return t.Exec(args)
+	}
+	return "", fmt.Errorf("tool %s has no Exec", t.Name)
+}
+
+// Reply runs the tool on args, the JSON object a model sent, and returns
+// what the model reads back: the result, or what went wrong.
+func (t Tool) Reply(ctx context.Context, args string) string {
+	var m map[string]interface{}
+	if err := json.Unmarshal([]byte(args), &m); err != nil {
+		return fmt.Sprintf("Error parsing arguments: %v", err)
+	}
+	res, err := t.Call(ctx, m)
+	if err != nil {
+		return fmt.Sprintf("Error running %s: %v", t.Name, err)
+	}
+	return res
 }
 
 type ToolPackage struct {
@@ -22,6 +56,24 @@ type ToolPackage struct {
   Link        string `toml:"link"`
   Description string `toml:"description,omitempty"`
   Tools       []Tool `toml:"-"`      // ← never read/write this from TOML
+  // Config declares the pack's settings (see ConfigField); the host
+  // calls Configure with their values when the pack is loaded and again
+  // whenever the user changes them.
+  Config    []ConfigField      `toml:"-"`
+  Configure func(Config) error `toml:"-"`
+  // Secrets names the stored secrets the pack's tools and Init read with
+  // Secret; the host refuses them any other.
+  Secrets []string `toml:"-"`
+
+  // Lifecycle hooks, all optional. A pack is loaded once per process and
+  // shared by the agents using it: Init runs when the first of them loads
+  // it (after Configure; its ctx carries the user's Secrets and gives up
+  // after a while), Shutdown when the last one is unloaded. Health says
+  // whether the pack can do its job right now (its service is reachable,
+  // its files exist); nil means healthy. It should answer quickly.
+  Init     func(ctx context.Context, cfg Config) error `toml:"-"`
+  Health   func() error                                `toml:"-"`
+  Shutdown func() error                                `toml:"-"`
 }
 
 func (tp ToolPackage) String() string {
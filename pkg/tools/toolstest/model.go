@@ -0,0 +1,149 @@
+package toolstest
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/openai/openai-go"
+
+	"github.com/johnjallday/dolphin-tool-calling-agent/pkg/tools"
+)
+
+// Model stands in for a live model. It offers the packs' tools under the
+// names an agent would give them (pack__tool where two packs define the
+// same tool) and answers tool calls the way the agent does (Tool.Reply):
+// JSON arguments decoded, errors turned into the text the model reads.
+// Tools needing approval are run as if the user approved.
+type Model struct {
+	t       testing.TB
+	names   []string                     // wire names, in pack order
+	tools   map[string]tools.Tool        // by wire name
+	packs   map[string]tools.ToolPackage // by wire name
+	secrets Secrets
+}
+
+// NewModel registers pkgs for a simulated model.
+func NewModel(t testing.TB, pkgs ...tools.ToolPackage) *Model {
+	t.Helper()
+	defs := map[string][]string{} // tool name → packs defining it
+	for _, p := range pkgs {
+		for _, tool := range p.Tools {
+			defs[tool.Name] = append(defs[tool.Name], p.Name)
+		}
+	}
+	m := &Model{t: t, tools: map[string]tools.Tool{}, packs: map[string]tools.ToolPackage{}}
+	exposed := map[string][]string{} // tool name → its wire names
+	for _, p := range pkgs {
+		for _, tool := range p.Tools {
+			name := tools.WireName(tool.Name)
+			if len(defs[tool.Name]) > 1 {
+				name = tools.WireName(p.Name + "." + tool.Name)
+			}
+			if _, dup := m.tools[name]; dup {
+				t.Fatalf("two tools would both be called %q", name)
+			}
+			exposed[tool.Name] = append(exposed[tool.Name], name)
+			tool.Name = name
+			m.tools[name] = tool
+			m.packs[name] = p
+			m.names = append(m.names, name)
+		}
+	}
+	for _, name := range sortedNames(defs) {
+		if packs := defs[name]; len(packs) > 1 {
+			t.Logf("tool %q is defined by %s; exposed as %s", name, strings.Join(packs, " and "), strings.Join(exposed[name], ", "))
+		}
+	}
+	return m
+}
+
+// SetSecrets gives the tools these secrets for the calls that follow.
+func (m *Model) SetSecrets(s Secrets) {
+	m.secrets = s
+}
+
+// withSecrets is tools.WithSecrets with only the secrets pkg declares
+// readable, as in dolphin, except that no secrets leave the ctx without
+// any, so tools.Secret says ErrNoSecrets like it does in a host that has
+// none.
+func withSecrets(ctx context.Context, s Secrets, pkg tools.ToolPackage) context.Context {
+	if s == nil {
+		return ctx
+	}
+	return tools.WithSecrets(ctx, declared{s: s, pkg: pkg})
+}
+
+// declared refuses the secrets its pack doesn't list in Secrets.
+type declared struct {
+	s   Secrets
+	pkg tools.ToolPackage
+}
+
+func (d declared) Secret(name string) (string, error) {
+	if !slices.Contains(d.pkg.Secrets, name) {
+		return "", fmt.Errorf("secret %s: toolpack %s doesn't declare it (add it to Secrets)", name, d.pkg.Name)
+	}
+	return d.s.Secret(name)
+}
+
+// Tools returns the tool definitions a request to the model would carry.
+func (m *Model) Tools() []openai.ChatCompletionToolParam {
+	out := make([]openai.ChatCompletionToolParam, 0, len(m.names))
+	for _, name := range m.names {
+		t := m.tools[name]
+		out = append(out, openai.ChatCompletionToolParam{
+			Function: openai.FunctionDefinitionParam{
+				Name:        t.Name,
+				Description: openai.String(t.Description),
+				Parameters:  t.Parameters,
+			},
+		})
+	}
+	return out
+}
+
+// ToolCall is one call the model makes: a tool's name as offered and its
+// arguments as JSON.
+type ToolCall struct {
+	Name string
+	Args string
+}
+
+// Call makes one tool call and returns the reply the model reads back,
+// e.g. "Error running hello: name is required" when the tool fails.
+func (m *Model) Call(name, args string) string {
+	m.t.Helper()
+	return m.Turn(ToolCall{Name: name, Args: args})[0]
+}
+
+// Turn makes several tool calls in one assistant turn, as models do, and
+// returns the replies in order. Calling a tool that isn't offered fails
+// the test.
+func (m *Model) Turn(calls ...ToolCall) []string {
+	m.t.Helper()
+	out := make([]string, len(calls))
+	for i, c := range calls {
+		tool, ok := m.tools[c.Name]
+		if !ok {
+			m.t.Fatalf("the model can't call %q: it is offered %s", c.Name, strings.Join(m.names, ", "))
+		}
+		if strings.TrimSpace(c.Args) == "" {
+			c.Args = "{}"
+		}
+		out[i] = tool.Reply(withSecrets(context.Background(), m.secrets, m.packs[c.Name]), c.Args)
+	}
+	return out
+}
+
+func sortedNames(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,443 @@
+// Package toolstest tests a toolpack without the app or a model. In the
+// pack's _test.go:
+//
+//	func TestPack(t *testing.T) {
+//		pkg := PluginPackage() // or toolstest.Load(t, "weather.so")
+//		toolstest.Validate(t, pkg)
+//		toolstest.Start(t, pkg, map[string]interface{}{"units": "metric"}, nil)
+//		toolstest.Run(t, pkg, []toolstest.Case{
+//			{Tool: "get_weather", Args: `{"location":"Paris"}`, Contains: "°C"},
+//			{Tool: "get_weather", Args: `{}`, WantErr: "location", OffSchema: true},
+//		})
+//
+//		m := toolstest.NewModel(t, pkg)
+//		if out := m.Call("get_weather", `{"location":"Oslo"}`); out == "" {
+//			t.Fatal("no answer")
+//		}
+//	}
+package toolstest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"plugin"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/johnjallday/dolphin-tool-calling-agent/pkg/tools"
+)
+
+// Load opens the toolpack in the .so at path the way dolphin does: it
+// must export PluginPackage and, if it exports a PluginManifest, speak
+// this SDK and need no capability the host lacks.
+func Load(t testing.TB, path string) tools.ToolPackage {
+	t.Helper()
+	plug, err := plugin.Open(path)
+	if err != nil {
+		t.Fatalf("load %s: %v", path, err)
+	}
+	if sym, err := plug.Lookup("PluginManifest"); err != nil {
+		t.Logf("load %s: no PluginManifest exported, compatibility can't be verified", path)
+	} else if m, ok := sym.(*tools.Manifest); !ok {
+		t.Fatalf("load %s: PluginManifest has type %T, want tools.Manifest", path, sym)
+	} else if m.SDKVersion != tools.SDKVersion {
+		t.Fatalf("load %s: built against toolpack SDK v%d, this dolphin speaks v%d", path, m.SDKVersion, tools.SDKVersion)
+	} else if missing := m.Missing(); len(missing) > 0 {
+		t.Fatalf("load %s: requires host capabilities this dolphin lacks: %s", path, strings.Join(missing, ", "))
+	}
+	sym, err := plug.Lookup("PluginPackage")
+	if err != nil {
+		t.Fatalf("load %s: does not export PluginPackage()", path)
+	}
+	ctor, ok := sym.(func() tools.ToolPackage)
+	if !ok {
+		t.Fatalf("load %s: PluginPackage has signature %T, want func() tools.ToolPackage", path, sym)
+	}
+	return ctor()
+}
+
+// Problems lists what dolphin or the API would reject in pkg: tool names
+// that aren't valid function names, duplicates, tools without a
+// description or Exec, parameter schemas that aren't a JSON Schema
+// object the API accepts, and a Config the host would refuse.
+func Problems(pkg tools.ToolPackage) []string {
+	var probs []string
+	seen := map[string]bool{}
+	for _, tool := range pkg.Tools {
+		name := tool.Name
+		switch {
+		case name == "":
+			probs = append(probs, "a tool has no name")
+			name = "(unnamed)"
+		case !tools.ValidWireName(name):
+			probs = append(probs, fmt.Sprintf("%s: names may only use letters, digits, _ and - (max %d)", name, tools.MaxNameLen))
+		case seen[name]:
+			probs = append(probs, fmt.Sprintf("%s: defined twice", name))
+		}
+		seen[name] = true
+		if strings.TrimSpace(tool.Description) == "" {
+			probs = append(probs, fmt.Sprintf("%s: no description, the model won't know when to call it", name))
+		}
+		if tool.Exec == nil && tool.ExecContext == nil {
+			probs = append(probs, fmt.Sprintf("%s: no Exec", name))
+		}
+		for _, p := range schemaProblems(tool.Parameters) {
+			probs = append(probs, fmt.Sprintf("%s: parameters: %s", name, p))
+		}
+	}
+	if err := tools.CheckFields(pkg.Config); err != nil {
+		probs = append(probs, err.Error())
+	}
+	if len(pkg.Config) > 0 && pkg.Configure == nil {
+		probs = append(probs, "Config is declared but there is no Configure to receive it")
+	}
+	return probs
+}
+
+// Validate fails t with every problem Problems finds.
+func Validate(t testing.TB, pkg tools.ToolPackage) {
+	t.Helper()
+	if len(pkg.Tools) == 0 {
+		t.Errorf("toolpack %q has no tools", pkg.Name)
+	}
+	for _, p := range Problems(pkg) {
+		t.Error(p)
+	}
+}
+
+// Start brings pkg up the way dolphin does when an agent loads it:
+// Configure with values over the Config defaults, then Init, then Health.
+// Shutdown runs when t finishes. secrets may be nil.
+func Start(t testing.TB, pkg tools.ToolPackage, values map[string]interface{}, secrets Secrets) {
+	t.Helper()
+	cfg, err := tools.ResolveConfig(pkg.Config, values)
+	if err != nil {
+		t.Fatalf("config: %v", err)
+	}
+	if pkg.Configure != nil {
+		if err := pkg.Configure(cfg); err != nil {
+			t.Fatalf("configure: %v", err)
+		}
+	}
+	if pkg.Init != nil {
+		if err := pkg.Init(withSecrets(context.Background(), secrets, pkg), cfg); err != nil {
+			t.Fatalf("init: %v", err)
+		}
+	}
+	if pkg.Shutdown != nil {
+		t.Cleanup(func() {
+			if err := pkg.Shutdown(); err != nil {
+				t.Errorf("shutdown: %v", err)
+			}
+		})
+	}
+	if pkg.Health != nil {
+		if err := pkg.Health(); err != nil {
+			t.Errorf("health: %v", err)
+		}
+	}
+}
+
+// Case is one call of a tool, with its arguments as the model would send
+// them.
+type Case struct {
+	Name string // subtest name; default tool(args)
+	Tool string
+	Args string // a JSON object; "" is {}
+
+	Want     string // the exact result, if set
+	Contains string // a substring of the result, if set
+	// WantErr expects Exec to fail with an error containing it.
+	WantErr string
+	// OffSchema sends Args even though they don't match the tool's
+	// schema, to test how the tool copes; otherwise that's a failure.
+	OffSchema bool
+	// Secrets are what tools.Secret returns during the call, for the
+	// names the pack declares in its Secrets.
+	Secrets Secrets
+}
+
+// Secrets stands in for the user's secrets store.
+type Secrets map[string]string
+
+func (s Secrets) Secret(name string) (string, error) {
+	v, ok := s[name]
+	if !ok {
+		return "", fmt.Errorf("secret %s is not set", name)
+	}
+	return v, nil
+}
+
+// Run runs every case as a subtest, calling the tool (ExecContext or
+// Exec) with the decoded Args after checking them against its schema.
+func Run(t *testing.T, pkg tools.ToolPackage, cases []Case) {
+	t.Helper()
+	byName := map[string]tools.Tool{}
+	for _, tool := range pkg.Tools {
+		byName[tool.Name] = tool
+	}
+	for _, c := range cases {
+		name := c.Name
+		if name == "" {
+			name = c.Tool + "(" + c.Args + ")"
+		}
+		t.Run(name, func(t *testing.T) {
+			tool, ok := byName[c.Tool]
+			if !ok {
+				t.Fatalf("toolpack %q has no tool %q", pkg.Name, c.Tool)
+			}
+			args, err := decodeArgs(c.Args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := CheckArgs(tool, args); err != nil && !c.OffSchema {
+				t.Fatalf("arguments don't match the schema (set OffSchema to send them anyway): %v", err)
+			} else if err == nil && c.OffSchema {
+				t.Logf("OffSchema is set but the arguments match the schema")
+			}
+
+			out, err := tool.Call(withSecrets(context.Background(), c.Secrets, pkg), args)
+			switch {
+			case c.WantErr != "" && err == nil:
+				t.Fatalf("got %q, want an error containing %q", out, c.WantErr)
+			case c.WantErr != "" && !strings.Contains(err.Error(), c.WantErr):
+				t.Fatalf("got error %q, want one containing %q", err, c.WantErr)
+			case c.WantErr != "":
+				return
+			case err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if c.Want != "" && out != c.Want {
+				t.Errorf("got %q, want %q", out, c.Want)
+			}
+			if c.Contains != "" && !strings.Contains(out, c.Contains) {
+				t.Errorf("got %q, want it to contain %q", out, c.Contains)
+			}
+		})
+	}
+}
+
+// CheckArgs checks args against tool's parameter schema the way a
+// well-behaved model would follow it: required properties present, the
+// declared types and enums, and no extras when additionalProperties is
+// false.
+func CheckArgs(tool tools.Tool, args map[string]interface{}) error {
+	schema, err := normalize(tool.Parameters)
+	if err != nil {
+		return err
+	}
+	props, _ := schema["properties"].(map[string]interface{})
+	var errs []string
+	for _, r := range stringList(schema["required"]) {
+		if _, ok := args[r]; !ok {
+			errs = append(errs, fmt.Sprintf("%s is required", r))
+		}
+	}
+	for _, k := range sortedKeys(args) {
+		p, ok := props[k].(map[string]interface{})
+		if !ok {
+			if schema["additionalProperties"] == false {
+				errs = append(errs, fmt.Sprintf("%s is not a parameter", k))
+			}
+			continue
+		}
+		if err := checkValue(p, args[k]); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", k, err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// checkValue checks v against a property schema: type, enum and the
+// items of an array.
+func checkValue(p map[string]interface{}, v interface{}) error {
+	if types := stringList(p["type"]); len(types) > 0 {
+		ok := false
+		for _, typ := range types {
+			ok = ok || hasType(typ, v)
+		}
+		if !ok {
+			return fmt.Errorf("got %s, want %s", jsonType(v), strings.Join(types, " or "))
+		}
+	}
+	if enum, ok := p["enum"].([]interface{}); ok {
+		found := false
+		for _, e := range enum {
+			found = found || reflect.DeepEqual(e, v)
+		}
+		if !found {
+			return fmt.Errorf("%v is not one of %v", v, enum)
+		}
+	}
+	if items, ok := p["items"].(map[string]interface{}); ok {
+		arr, _ := v.([]interface{})
+		for i, x := range arr {
+			if err := checkValue(items, x); err != nil {
+				return fmt.Errorf("[%d]: %v", i, err)
+			}
+		}
+	}
+	return nil
+}
+
+func hasType(typ string, v interface{}) bool {
+	switch typ {
+	case "integer":
+		f, ok := v.(float64)
+		return ok && f == float64(int64(f))
+	case "number":
+		_, ok := v.(float64)
+		return ok
+	}
+	return jsonType(v) == typ
+}
+
+func jsonType(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+var jsonTypes = map[string]bool{
+	"string": true, "number": true, "integer": true, "boolean": true,
+	"array": true, "object": true, "null": true,
+}
+
+// schemaProblems checks a tool's parameter schema; none at all is fine
+// for a tool without arguments.
+func schemaProblems(params map[string]interface{}) []string {
+	if params == nil {
+		return nil
+	}
+	schema, err := normalize(params)
+	if err != nil {
+		return []string{err.Error()}
+	}
+	var probs []string
+	if schema["type"] != "object" {
+		probs = append(probs, fmt.Sprintf(`type is %v, must be "object"`, schema["type"]))
+	}
+	props := map[string]interface{}{}
+	if raw, ok := schema["properties"]; ok {
+		if props, ok = raw.(map[string]interface{}); !ok {
+			probs = append(probs, "properties must be an object")
+		}
+	}
+	for _, k := range sortedKeys(props) {
+		probs = append(probs, propertyProblems(k, props[k])...)
+	}
+	if raw, ok := schema["required"]; ok {
+		req, isList := raw.([]interface{})
+		if !isList {
+			probs = append(probs, "required must be a list of property names")
+		}
+		for _, r := range req {
+			if s, ok := r.(string); !ok || props[s] == nil {
+				probs = append(probs, fmt.Sprintf("required names %v, which is not a property", r))
+			}
+		}
+	}
+	return probs
+}
+
+func propertyProblems(path string, raw interface{}) []string {
+	p, ok := raw.(map[string]interface{})
+	if !ok {
+		return []string{fmt.Sprintf("%s must be a schema object", path)}
+	}
+	if p["type"] == nil && p["enum"] == nil && p["anyOf"] == nil && p["oneOf"] == nil && p["$ref"] == nil && p["const"] == nil {
+		return []string{fmt.Sprintf("%s has no type", path)}
+	}
+	var probs []string
+	types := stringList(p["type"])
+	if p["type"] != nil && len(types) == 0 {
+		probs = append(probs, fmt.Sprintf("%s: type must be a string or a list of them", path))
+	}
+	for _, typ := range types {
+		if !jsonTypes[typ] {
+			probs = append(probs, fmt.Sprintf("%s: unknown type %q", path, typ))
+		}
+		if typ == "array" {
+			if p["items"] == nil {
+				probs = append(probs, fmt.Sprintf("%s: an array needs items", path))
+			} else {
+				probs = append(probs, propertyProblems(path+"[]", p["items"])...)
+			}
+		}
+	}
+	if nested, ok := p["properties"].(map[string]interface{}); ok {
+		for _, k := range sortedKeys(nested) {
+			probs = append(probs, propertyProblems(path+"."+k, nested[k])...)
+		}
+	}
+	return probs
+}
+
+// normalize round-trips a schema through JSON, as it goes to the API, so
+// map[string]string and []string read like what the API receives.
+func normalize(params map[string]interface{}) (map[string]interface{}, error) {
+	b, err := json.Marshal(params)
+	if err != nil {
+		return nil, fmt.Errorf("can't be sent as JSON: %v", err)
+	}
+	var out map[string]interface{}
+	if err := json.Unmarshal(b, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func decodeArgs(s string) (map[string]interface{}, error) {
+	if strings.TrimSpace(s) == "" {
+		return map[string]interface{}{}, nil
+	}
+	var args map[string]interface{}
+	if err := json.Unmarshal([]byte(s), &args); err != nil {
+		return nil, fmt.Errorf("Args must be a JSON object: %v", err)
+	}
+	return args, nil
+}
+
+// stringList reads a string or a list of strings.
+func stringList(v interface{}) []string {
+	switch v := v.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		var out []string
+		for _, x := range v {
+			if s, ok := x.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
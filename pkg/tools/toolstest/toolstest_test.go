@@ -0,0 +1,300 @@
+package toolstest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/johnjallday/dolphin-tool-calling-agent/pkg/tools"
+)
+
+// recorder is a testing.TB that keeps what it's told instead of failing
+// the real test, to check the harness reports what it should.
+type recorder struct {
+	testing.TB
+	errs  []string
+	logs  []string
+	fatal bool
+}
+
+func (r *recorder) Helper()                           {}
+func (r *recorder) Error(args ...interface{})         { r.errs = append(r.errs, fmt.Sprint(args...)) }
+func (r *recorder) Errorf(f string, a ...interface{}) { r.errs = append(r.errs, fmt.Sprintf(f, a...)) }
+func (r *recorder) Logf(f string, a ...interface{})   { r.logs = append(r.logs, fmt.Sprintf(f, a...)) }
+func (r *recorder) Cleanup(func())                    {}
+
+func (r *recorder) Fatalf(f string, a ...interface{}) {
+	r.Errorf(f, a...)
+	r.fatal = true
+	panic(r)
+}
+
+// record runs f against a recorder, stopping where f fails it fatally.
+func record(t *testing.T, f func(tb testing.TB)) *recorder {
+	r := &recorder{TB: t}
+	func() {
+		defer func() {
+			if p := recover(); p != nil && p != r {
+				panic(p)
+			}
+		}()
+		f(r)
+	}()
+	return r
+}
+
+func (r *recorder) has(t *testing.T, want string) {
+	t.Helper()
+	for _, e := range r.errs {
+		if strings.Contains(e, want) {
+			return
+		}
+	}
+	t.Errorf("no error mentions %q; got %q", want, r.errs)
+}
+
+func greetPack() tools.ToolPackage {
+	return tools.ToolPackage{Name: "greet", Version: "v1.0.0", Tools: []tools.Tool{
+		{
+			Name:        "hello",
+			Description: "Greet someone",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"name":  map[string]string{"type": "string"},
+					"times": map[string]string{"type": "integer"},
+					"tone":  map[string]interface{}{"type": "string", "enum": []string{"warm", "dry"}},
+					"tags":  map[string]interface{}{"type": "array", "items": map[string]string{"type": "string"}},
+				},
+				"required":             []string{"name"},
+				"additionalProperties": false,
+			},
+			Exec: func(args map[string]interface{}) (string, error) {
+				name, _ := args["name"].(string)
+				if name == "" {
+					return "", errors.New("name is required")
+				}
+				return "hello " + name, nil
+			},
+		},
+		{
+			Name:        "whoami",
+			Description: "Who the API key belongs to",
+			ExecContext: func(ctx context.Context, _ map[string]interface{}) (string, error) {
+				key, err := tools.Secret(ctx, "api_key")
+				if err != nil {
+					return "", err
+				}
+				return "key " + key, nil
+			},
+		},
+	}, Secrets: []string{"api_key"}}
+}
+
+func TestProblems(t *testing.T) {
+	if probs := Problems(greetPack()); len(probs) != 0 {
+		t.Errorf("a good pack has problems: %q", probs)
+	}
+
+	bad := tools.ToolPackage{Name: "bad", Tools: []tools.Tool{
+		{Name: "", Description: "x", Exec: func(map[string]interface{}) (string, error) { return "", nil }},
+		{Name: "has space", Description: "x", Exec: func(map[string]interface{}) (string, error) { return "", nil }},
+		{Name: "twice", Description: "x", Exec: func(map[string]interface{}) (string, error) { return "", nil }},
+		{Name: "twice", Description: "x", Exec: func(map[string]interface{}) (string, error) { return "", nil }},
+		{Name: "quiet", Exec: func(map[string]interface{}) (string, error) { return "", nil }},
+		{Name: "idle", Description: "x"},
+		{Name: "schema", Description: "x", Exec: func(map[string]interface{}) (string, error) { return "", nil },
+			Parameters: map[string]interface{}{
+				"type": "array",
+				"properties": map[string]interface{}{
+					"untyped": map[string]string{"description": "no type"},
+					"list":    map[string]string{"type": "array"},
+					"odd":     map[string]string{"type": "text"},
+				},
+				"required": []string{"missing"},
+			}},
+	}, Config: []tools.ConfigField{{Key: "units", Type: tools.ConfigString}}}
+
+	probs := strings.Join(Problems(bad), "\n")
+	for _, want := range []string{
+		"a tool has no name",
+		"has space: names may only use letters",
+		"twice: defined twice",
+		"quiet: no description",
+		"idle: no Exec",
+		`schema: parameters: type is array, must be "object"`,
+		"untyped has no type",
+		"list: an array needs items",
+		`odd: unknown type "text"`,
+		"required names missing",
+		"no Configure",
+	} {
+		if !strings.Contains(probs, want) {
+			t.Errorf("problems lack %q:\n%s", want, probs)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	Validate(t, greetPack())
+
+	r := record(t, func(tb testing.TB) { Validate(tb, tools.ToolPackage{Name: "empty"}) })
+	r.has(t, `toolpack "empty" has no tools`)
+}
+
+func TestCheckArgs(t *testing.T) {
+	hello := greetPack().Tools[0]
+	tests := []struct {
+		args string
+		want string // "" for no error
+	}{
+		{`{"name":"Ann"}`, ""},
+		{`{"name":"Ann","times":2,"tone":"dry","tags":["a","b"]}`, ""},
+		{`{}`, "name is required"},
+		{`{"name":3}`, "name: got number, want string"},
+		{`{"name":"Ann","times":1.5}`, "times: got number, want integer"},
+		{`{"name":"Ann","tone":"loud"}`, "tone: loud is not one of"},
+		{`{"name":"Ann","tags":["a",1]}`, "tags: [1]: got number, want string"},
+		{`{"name":"Ann","extra":true}`, "extra is not a parameter"},
+	}
+	for _, tt := range tests {
+		args, err := decodeArgs(tt.args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = CheckArgs(hello, args)
+		switch {
+		case tt.want == "" && err != nil:
+			t.Errorf("%s: %v", tt.args, err)
+		case tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)):
+			t.Errorf("%s: got %v, want %q", tt.args, err, tt.want)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	Run(t, greetPack(), []Case{
+		{Tool: "hello", Args: `{"name":"Ann"}`, Want: "hello Ann"},
+		{Tool: "hello", Args: `{"name":"Bo","tone":"warm"}`, Contains: "Bo"},
+		{Name: "missing name", Tool: "hello", Args: `{}`, WantErr: "name is required", OffSchema: true},
+		{Tool: "whoami", Secrets: Secrets{"api_key": "k1"}, Want: "key k1"},
+		{Name: "no secrets", Tool: "whoami", WantErr: tools.ErrNoSecrets.Error()},
+		{Name: "secret not set", Tool: "whoami", Secrets: Secrets{}, WantErr: "secret api_key is not set"},
+	})
+
+	undeclared := greetPack()
+	undeclared.Secrets = nil
+	Run(t, undeclared, []Case{
+		{Name: "undeclared", Tool: "whoami", Secrets: Secrets{"api_key": "k1"}, WantErr: "greet doesn't declare it"},
+	})
+}
+
+func TestStart(t *testing.T) {
+	var steps []string
+	var gotKey error
+	pkg := greetPack()
+	pkg.Config = []tools.ConfigField{
+		{Key: "units", Type: tools.ConfigChoice, Choices: []string{"metric", "imperial"}, Default: "metric"},
+	}
+	pkg.Configure = func(c tools.Config) error {
+		steps = append(steps, "configure "+c.String("units"))
+		return nil
+	}
+	pkg.Init = func(ctx context.Context, c tools.Config) error {
+		_, gotKey = tools.Secret(ctx, "api_key")
+		steps = append(steps, "init")
+		return nil
+	}
+	pkg.Health = func() error {
+		steps = append(steps, "health")
+		return nil
+	}
+	pkg.Shutdown = func() error {
+		steps = append(steps, "shutdown")
+		return nil
+	}
+
+	t.Run("up", func(t *testing.T) {
+		Start(t, pkg, map[string]interface{}{"units": "imperial"}, nil)
+		if got := strings.Join(steps, ", "); got != "configure imperial, init, health" {
+			t.Errorf("steps: %s", got)
+		}
+		if !errors.Is(gotKey, tools.ErrNoSecrets) {
+			t.Errorf("without secrets Init's ctx says %v, want ErrNoSecrets", gotKey)
+		}
+	})
+	if steps[len(steps)-1] != "shutdown" {
+		t.Errorf("Shutdown didn't run when the test finished: %v", steps)
+	}
+
+	steps = nil
+	t.Run("secrets", func(t *testing.T) {
+		Start(t, pkg, nil, Secrets{"api_key": "k"})
+		if gotKey != nil || steps[0] != "configure metric" {
+			t.Errorf("steps %v, secret %v", steps, gotKey)
+		}
+	})
+
+	r := record(t, func(tb testing.TB) { Start(tb, pkg, map[string]interface{}{"units": "kelvin"}, nil) })
+	r.has(t, "config:")
+
+	pkg.Init = func(context.Context, tools.Config) error { return errors.New("no service") }
+	r = record(t, func(tb testing.TB) { Start(tb, pkg, nil, nil) })
+	if !r.fatal {
+		t.Error("a failing Init didn't stop the test")
+	}
+	r.has(t, "init: no service")
+
+	pkg.Init = nil
+	pkg.Health = func() error { return errors.New("unreachable") }
+	r = record(t, func(tb testing.TB) { Start(tb, pkg, nil, nil) })
+	r.has(t, "health: unreachable")
+}
+
+func TestModel(t *testing.T) {
+	other := tools.ToolPackage{Name: "other", Tools: []tools.Tool{{
+		Name: "hello", Description: "Another hello",
+		Exec: func(map[string]interface{}) (string, error) { return "hi from other", nil },
+	}}}
+	m := NewModel(t, greetPack(), other)
+
+	var names []string
+	for _, tp := range m.Tools() {
+		names = append(names, tp.Function.Name)
+	}
+	if got := strings.Join(names, " "); got != "greet__hello whoami other__hello" {
+		t.Errorf("offered %s", got)
+	}
+
+	if got := m.Call("greet__hello", `{"name":"Ann"}`); got != "hello Ann" {
+		t.Errorf("reply %q", got)
+	}
+	replies := m.Turn(
+		ToolCall{Name: "greet__hello", Args: `{}`},
+		ToolCall{Name: "other__hello"},
+		ToolCall{Name: "whoami", Args: `not json`},
+	)
+	want := []string{
+		"Error running greet__hello: name is required",
+		"hi from other",
+		"Error parsing arguments: invalid character 'o' in literal null (expecting 'u')",
+	}
+	for i := range want {
+		if replies[i] != want[i] {
+			t.Errorf("reply %d: %q, want %q", i, replies[i], want[i])
+		}
+	}
+
+	if got := m.Call("whoami", ""); got != "Error running whoami: "+tools.ErrNoSecrets.Error() {
+		t.Errorf("without secrets: %q", got)
+	}
+	m.SetSecrets(Secrets{"api_key": "k2"})
+	if got := m.Call("whoami", ""); got != "key k2" {
+		t.Errorf("with secrets: %q", got)
+	}
+
+	r := record(t, func(tb testing.TB) { NewModel(tb, greetPack()).Call("hello_there", "") })
+	r.has(t, `the model can't call "hello_there": it is offered hello, whoami`)
+}
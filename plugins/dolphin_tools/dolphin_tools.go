@@ -1,32 +1,234 @@
-package main
+// Package dolphintools is the built-in "dolphin_tools" toolpack: it lets the
+// model manage Dolphin itself (agents and toolpacks) so users can configure
+// it conversationally. Unlike the other packs under plugins/ it is linked
+// into the app rather than built as a .so, since it needs the live App,
+// which reaches its tools through the call's ctx (see WithOps).
+package dolphintools
 
 import (
-		"github.com/johnjallday/dolphin-tool-calling-agent/pkg/tools"
-		"github.com/openai/openai-go"
-	)
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/openai/openai-go"
+	"github.com/johnjallday/dolphin-tool-calling-agent/pkg/tools"
+)
 
 const (
-	packName		= "Dolphin Tools"
-	packVersion = "v0.0.1"
-	packlink		= "github.com/johnjallday/dolphin-tool-calling-agent/plugins/dolphin_tools"
+	PackName    = "dolphin_tools"
+	packVersion = "v0.1.0"
+	packLink    = "https://github.com/johnjallday/dolphin-tool-calling-agent"
 )
 
-var CreateAgent = tools.Tool{
-	Name:				""
+// AgentInfo is what the pack needs to know about one configured agent.
+type AgentInfo struct {
+	Name    string
+	Model   string
+	Plugins []string
+	Current bool
+	Live    bool
+}
+
+// Ops is the slice of the app the pack may drive. The app hands in an
+// adapter, which keeps this package free of an import cycle.
+type Ops interface {
+	Agents() []AgentInfo
+	SwitchAgent(name string) error
+	CreateAgent(name, model string, plugins []string) error
+	InstalledToolpacks() []string
+	RemoteToolpacks() ([]string, error)
 }
 
-"cli agent"
-"call functions related to loading and unloading agents and tools"
+type opsKey struct{}
 
+// WithOps returns ctx carrying ops for the pack's tools.
+func WithOps(ctx context.Context, ops Ops) context.Context {
+	return context.WithValue(ctx, opsKey{}, ops)
+}
 
-"Initialize"
-"load App Config"
-"Load UserConfig"
+func opsFrom(ctx context.Context) (Ops, error) {
+	ops, _ := ctx.Value(opsKey{}).(Ops)
+	if ops == nil {
+		return nil, errors.New(PackName + " only runs inside the dolphin app")
+	}
+	return ops, nil
+}
 
-"If DefaultAgent is in UserConfig"
-"Load DefaultAgent"
-"Load Tools"
+func init() { tools.RegisterPackage(PackName, Package) }
 
-"Load"
+// Package returns the toolpack. Anything that changes the user's setup is
+// marked RequiresApproval.
+func Package() tools.ToolPackage {
+	return tools.ToolPackage{
+		Name:        PackName,
+		Version:     packVersion,
+		Link:        packLink,
+		Description: "Manage Dolphin agents and toolpacks",
+		Tools: []tools.Tool{
+			listAgents(),
+			switchAgent(),
+			listToolpacks(),
+			createAgent(),
+		},
+	}
+}
 
+func listAgents() tools.Tool {
+	return tools.Tool{
+		Name:        "list_agents",
+		Description: "List the user's configured agents with their model and toolpacks, marking the current one.",
+		Parameters: openai.FunctionParameters{
+			"type":       "object",
+			"properties": map[string]interface{}{},
+		},
+		ExecContext: func(ctx context.Context, _ map[string]interface{}) (string, error) {
+			ops, err := opsFrom(ctx)
+			if err != nil {
+				return "", err
+			}
+			agents := ops.Agents()
+			if len(agents) == 0 {
+				return "No agents configured.", nil
+			}
+			var b strings.Builder
+			for _, a := range agents {
+				mark := ""
+				switch {
+				case a.Current:
+					mark = " (current)"
+				case a.Live:
+					mark = " (loaded)"
+				}
+				plugins := "no toolpacks"
+				if len(a.Plugins) > 0 {
+					plugins = strings.Join(a.Plugins, ", ")
+				}
+				fmt.Fprintf(&b, "- %s%s: model %s, %s\n", a.Name, mark, a.Model, plugins)
+			}
+			return b.String(), nil
+		},
+	}
+}
 
+func switchAgent() tools.Tool {
+	return tools.Tool{
+		Name:        "switch_agent",
+		Description: "Make another configured agent the current one. The user's next messages go to it.",
+		Parameters: openai.FunctionParameters{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"name": map[string]interface{}{
+					"type":        "string",
+					"description": "Name of the agent to switch to",
+				},
+			},
+			"required": []string{"name"},
+		},
+		RequiresApproval: true,
+		ExecContext: func(ctx context.Context, args map[string]interface{}) (string, error) {
+			ops, err := opsFrom(ctx)
+			if err != nil {
+				return "", err
+			}
+			name, _ := args["name"].(string)
+			if name == "" {
+				return "", fmt.Errorf("name is required")
+			}
+			if err := ops.SwitchAgent(name); err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("Switched to agent %s.", name), nil
+		},
+	}
+}
+
+func listToolpacks() tools.Tool {
+	return tools.Tool{
+		Name:        "list_toolpacks",
+		Description: "List the toolpacks installed locally and the ones available from the configured remote list.",
+		Parameters: openai.FunctionParameters{
+			"type":       "object",
+			"properties": map[string]interface{}{},
+		},
+		ExecContext: func(ctx context.Context, _ map[string]interface{}) (string, error) {
+			ops, err := opsFrom(ctx)
+			if err != nil {
+				return "", err
+			}
+			installed := ops.InstalledToolpacks()
+			sort.Strings(installed)
+
+			var b strings.Builder
+			b.WriteString("Installed: ")
+			if len(installed) == 0 {
+				b.WriteString("(none)")
+			}
+			b.WriteString(strings.Join(installed, ", ") + "\n")
+
+			remote, err := ops.RemoteToolpacks()
+			if err != nil {
+				fmt.Fprintf(&b, "Remote: unavailable (%v)\n", err)
+				return b.String(), nil
+			}
+			sort.Strings(remote)
+			b.WriteString("Remote: ")
+			if len(remote) == 0 {
+				b.WriteString("(none)")
+			}
+			b.WriteString(strings.Join(remote, ", ") + "\n")
+			return b.String(), nil
+		},
+	}
+}
+
+func createAgent() tools.Tool {
+	return tools.Tool{
+		Name:        "create_agent",
+		Description: "Create a new agent for the user with the given model and installed toolpacks.",
+		Parameters: openai.FunctionParameters{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"name": map[string]interface{}{
+					"type":        "string",
+					"description": "Name of the new agent",
+				},
+				"model": map[string]interface{}{
+					"type":        "string",
+					"description": "Model to use, e.g. gpt-4.1-nano",
+				},
+				"plugins": map[string]interface{}{
+					"type":        "array",
+					"items":       map[string]interface{}{"type": "string"},
+					"description": "Toolpack names to give the agent (see list_toolpacks)",
+				},
+			},
+			"required": []string{"name", "model"},
+		},
+		RequiresApproval: true,
+		ExecContext: func(ctx context.Context, args map[string]interface{}) (string, error) {
+			ops, err := opsFrom(ctx)
+			if err != nil {
+				return "", err
+			}
+			name, _ := args["name"].(string)
+			model, _ := args["model"].(string)
+			if name == "" || model == "" {
+				return "", fmt.Errorf("name and model are required")
+			}
+			var plugins []string
+			if raw, ok := args["plugins"].([]interface{}); ok {
+				for _, p := range raw {
+					if s, ok := p.(string); ok && s != "" {
+						plugins = append(plugins, s)
+					}
+				}
+			}
+			if err := ops.CreateAgent(name, model, plugins); err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("Created agent %s (model %s).", name, model), nil
+		},
+	}
+}
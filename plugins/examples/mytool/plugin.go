@@ -23,6 +23,9 @@ var HelloTool = tools.Tool{
 	},
 }
 
+// PluginManifest lets the host check this build before loading it.
+var PluginManifest = tools.NewManifest()
+
 // Exposes tools
 func PluginPackage() tools.ToolPackage {
     return tools.ToolPackage{
@@ -2,16 +2,17 @@ package main
 
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 
 
 	"github.com/openai/openai-go"
-	"github.com/BurntSushi/toml"
 
 
 	"github.com/johnjallday/dolphin-tool-calling-agent/pkg/tools"
@@ -24,11 +25,22 @@ const (
 )
 
 type ReaperConfig struct {
-	DefaultTemplate string `toml:"default_template"`
-	ScriptPath      string `toml:"script_path"`
+	DefaultTemplate string
+	ScriptPath      string
 }
 
-var reaperConfig ReaperConfig
+// reaperConfig is set by the host from the user's settings (see Config),
+// again whenever they change, while tools and Health may be reading it.
+var (
+	configMu     sync.RWMutex
+	reaperConfig ReaperConfig
+)
+
+func currentConfig() ReaperConfig {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	return reaperConfig
+}
 
 // Tool defines schema and executor for CreateNewProject.
 var CreateNewProjectTool = tools.Tool{
@@ -53,9 +65,9 @@ var CreateNewProjectTool = tools.Tool{
 }
 
 func CreateNewProject(name string, bpm int) (string, error) {
-	registerConfig()
-	if reaperConfig.DefaultTemplate == "" {
-		return "", fmt.Errorf("default template not configured")
+	cfg := currentConfig()
+	if cfg.DefaultTemplate == "" {
+		return "", fmt.Errorf("default template not configured (toolpack config reaper_project_manager default_template=...)")
 	}
 	projectDir := name
 	fmt.Println(projectDir)
@@ -63,7 +75,7 @@ func CreateNewProject(name string, bpm int) (string, error) {
 		return "", err
 	}
 	dest := filepath.Join(projectDir, name+".RPP")
-	data, err := os.ReadFile(reaperConfig.DefaultTemplate)
+	data, err := os.ReadFile(cfg.DefaultTemplate)
 	if err != nil {
 		return "", err
 	}
@@ -107,47 +119,66 @@ func CreateNewProject(name string, bpm int) (string, error) {
 	return msg, nil
 }
 
-func registerConfig() error {
-	configPath := "./plugins/reaper_project_manager/settings.toml"
-	absPath, err := filepath.Abs(configPath)
-	if err != nil {
-		return fmt.Errorf("could not resolve settings.toml path: %w", err)
-	}
+func PluginSpecs() []tools.Tool {
 
-	// Check if settings.toml exists, if not, create it with default values
-	if _, err := os.Stat(absPath); os.IsNotExist(err) {
-		fmt.Println("settings.toml not found, creating default config...")
+	return []tools.Tool{ CreateNewProjectTool }
+}
 
-		defaultConfig := ReaperConfig{
-			DefaultTemplate: "./tools/reaper/Default.RPP", // You can change this placeholder
-			ScriptPath:      "./tools/reaper/scripts",              // You can change this placeholder
-		}
-		// Serialize and write default config
-		f, ferr := os.Create(absPath)
-		if ferr != nil {
-			return fmt.Errorf("could not create settings.toml: %w", ferr)
-		}
-		defer f.Close()
-		enc := toml.NewEncoder(f)
-		if err := enc.Encode(defaultConfig); err != nil {
-			return fmt.Errorf("could not encode default config: %w", err)
-		}
-		fmt.Printf("Created %s with default values. Please edit as needed.\n", absPath)
-		reaperConfig = defaultConfig
-		return nil
+// PluginManifest lets the host check this build before loading it.
+var PluginManifest = tools.NewManifest(tools.CapConfigDir, tools.CapConfig, tools.CapLifecycle)
+
+// Config is what the user can set for this pack; the host stores it and
+// calls Configure with the values.
+func Config() []tools.ConfigField {
+	dir := tools.ConfigDir("reaper_project_manager")
+	return []tools.ConfigField{
+		{Key: "default_template", Type: tools.ConfigPath, Default: filepath.Join(dir, "Default.RPP"),
+			Description: "Reaper project copied for every new project"},
+		{Key: "script_path", Type: tools.ConfigPath, Default: filepath.Join(dir, "scripts"),
+			Description: "Folder with custom ReaScripts"},
 	}
+}
 
-	// Load settings.toml
-	if _, err := toml.DecodeFile(absPath, &reaperConfig); err != nil {
-		return fmt.Errorf("failed to decode settings.toml: %w", err)
+func Configure(c tools.Config) error {
+	configMu.Lock()
+	defer configMu.Unlock()
+	reaperConfig = ReaperConfig{
+		DefaultTemplate: c.String("default_template"),
+		ScriptPath:      c.String("script_path"),
 	}
 	return nil
 }
 
+// Init does the setup the first project used to do on its way (creating
+// the folders the template and scripts live in), once, when the pack is
+// loaded.
+func Init(_ context.Context, c tools.Config) error {
+	dirs := []string{c.String("script_path")}
+	if t := c.String("default_template"); t != "" {
+		dirs = append(dirs, filepath.Dir(t))
+	}
+	for _, dir := range dirs {
+		if dir == "" {
+			continue
+		}
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-func PluginSpecs() []tools.Tool {
-
-	return []tools.Tool{ CreateNewProjectTool }
+// Health reports a template that has gone missing before a project is
+// created from it.
+func Health() error {
+	cfg := currentConfig()
+	if cfg.DefaultTemplate == "" {
+		return fmt.Errorf("default template not configured")
+	}
+	if _, err := os.Stat(cfg.DefaultTemplate); err != nil {
+		return fmt.Errorf("default template: %w", err)
+	}
+	return nil
 }
 
 func PluginPackage() tools.ToolPackage {
@@ -157,5 +188,9 @@ func PluginPackage() tools.ToolPackage {
         Link:    packLink,
 				Description: "sample reaper manager plugin",
         Tools:   []tools.Tool{ CreateNewProjectTool },
+        Config:    Config(),
+        Configure: Configure,
+        Init:      Init,
+        Health:    Health,
     }
 }
@@ -32,6 +32,9 @@ func getWeather(location string) string {
 	return "Sunny, 25°C"
 }
 
+// PluginManifest lets the host check this build before loading it.
+var PluginManifest = tools.NewManifest()
+
 //exposes the plugin to dolphin-tool-calling-agent
 func PluginPackage() tools.ToolPackage {
     return tools.ToolPackage{
@@ -1,13 +1,30 @@
 #!/bin/bash
 
-go build -buildmode=plugin -o ./plugins/calculator.so ./plugins/examples/calculator_plugin/
-echo "calculator build complete"
-go build -buildmode=plugin -o ./plugins/mytool.so ./plugins/examples/mytool
-echo "mytool build complete"
-go build -buildmode=plugin -o ./plugins/weather.so ./plugins/examples/weather
-echo "weather build complete"
-go build -buildmode=plugin -o ./plugins/reaper_project_manager/reaper_project_manager.so ./plugins/examples/reaper_project_manager
+# Install into the same plugin dir the app resolves:
+# $DOLPHIN_HOME/plugins, else $XDG_DATA_HOME/dolphin/plugins.
+if [ -n "$DOLPHIN_HOME" ]; then
+  PLUGIN_DIR="$DOLPHIN_HOME/plugins"
+else
+  PLUGIN_DIR="${XDG_DATA_HOME:-$HOME/.local/share}/dolphin/plugins"
+fi
 
-echo "build complete"
+# each pack gets its own folder with the .so and a toolpack.toml manifest,
+# so the app can list and search packs without loading their code
+TUI="$(mktemp -d)/dolphin_tui"
+go build -o "$TUI" ./cmd/tui || exit 1
 
-#go build -buildmode=plugin -o calculator.so calculator_plugin/plugin.go
+# `toolpack build` compiles with the app's own toolchain and flags and
+# installs into $PLUGIN_DIR/<folder name>/
+build_pack() {
+  "$TUI" toolpack build "$1" || exit 1
+}
+
+# calculator is linked into the app (internal/app/linked.go); build the
+# .so only for hosts that load it as a plugin:
+#build_pack ./plugins/examples/calculator_plugin/
+build_pack ./plugins/examples/mytool
+build_pack ./plugins/examples/weather
+build_pack ./plugins/examples/reaper_project_manager
+
+rm -f "$TUI"
+echo "build complete → $PLUGIN_DIR"